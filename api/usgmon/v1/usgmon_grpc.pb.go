@@ -0,0 +1,307 @@
+// The usgmon gRPC API, served by the daemon on api.grpc_listen.
+//
+// Regenerate the Go code with "make proto".
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v5.28.3
+// source: usgmon/v1/usgmon.proto
+
+package usgmonv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	UsageService_QueryUsage_FullMethodName         = "/usgmon.v1.UsageService/QueryUsage"
+	UsageService_GetTopChangers_FullMethodName     = "/usgmon.v1.UsageService/GetTopChangers"
+	UsageService_ListScans_FullMethodName          = "/usgmon.v1.UsageService/ListScans"
+	UsageService_TriggerScan_FullMethodName        = "/usgmon.v1.UsageService/TriggerScan"
+	UsageService_StreamScanProgress_FullMethodName = "/usgmon.v1.UsageService/StreamScanProgress"
+)
+
+// UsageServiceClient is the client API for UsageService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// UsageService reads stored usage and scans and starts scans of the daemon's
+// configured paths.
+type UsageServiceClient interface {
+	// QueryUsage returns usage records, newest first.
+	QueryUsage(ctx context.Context, in *QueryUsageRequest, opts ...grpc.CallOption) (*QueryUsageResponse, error)
+	// GetTopChangers returns the directories under a base path whose size
+	// changed most over a time range.
+	GetTopChangers(ctx context.Context, in *GetTopChangersRequest, opts ...grpc.CallOption) (*GetTopChangersResponse, error)
+	// ListScans returns recorded scans, newest first.
+	ListScans(ctx context.Context, in *ListScansRequest, opts ...grpc.CallOption) (*ListScansResponse, error)
+	// TriggerScan starts a scan of a configured path in the background and
+	// returns once its scan record exists. It fails with ALREADY_EXISTS if the
+	// path is already being scanned.
+	TriggerScan(ctx context.Context, in *TriggerScanRequest, opts ...grpc.CallOption) (*TriggerScanResponse, error)
+	// StreamScanProgress reports a scan's progress periodically while it
+	// runs, then its outcome, and ends. It fails with NOT_FOUND unless the
+	// scan is running or is the last scan the daemon finished of its path.
+	StreamScanProgress(ctx context.Context, in *StreamScanProgressRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ScanProgress], error)
+}
+
+type usageServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewUsageServiceClient(cc grpc.ClientConnInterface) UsageServiceClient {
+	return &usageServiceClient{cc}
+}
+
+func (c *usageServiceClient) QueryUsage(ctx context.Context, in *QueryUsageRequest, opts ...grpc.CallOption) (*QueryUsageResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(QueryUsageResponse)
+	err := c.cc.Invoke(ctx, UsageService_QueryUsage_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *usageServiceClient) GetTopChangers(ctx context.Context, in *GetTopChangersRequest, opts ...grpc.CallOption) (*GetTopChangersResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetTopChangersResponse)
+	err := c.cc.Invoke(ctx, UsageService_GetTopChangers_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *usageServiceClient) ListScans(ctx context.Context, in *ListScansRequest, opts ...grpc.CallOption) (*ListScansResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListScansResponse)
+	err := c.cc.Invoke(ctx, UsageService_ListScans_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *usageServiceClient) TriggerScan(ctx context.Context, in *TriggerScanRequest, opts ...grpc.CallOption) (*TriggerScanResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(TriggerScanResponse)
+	err := c.cc.Invoke(ctx, UsageService_TriggerScan_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *usageServiceClient) StreamScanProgress(ctx context.Context, in *StreamScanProgressRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ScanProgress], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &UsageService_ServiceDesc.Streams[0], UsageService_StreamScanProgress_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamScanProgressRequest, ScanProgress]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type UsageService_StreamScanProgressClient = grpc.ServerStreamingClient[ScanProgress]
+
+// UsageServiceServer is the server API for UsageService service.
+// All implementations must embed UnimplementedUsageServiceServer
+// for forward compatibility.
+//
+// UsageService reads stored usage and scans and starts scans of the daemon's
+// configured paths.
+type UsageServiceServer interface {
+	// QueryUsage returns usage records, newest first.
+	QueryUsage(context.Context, *QueryUsageRequest) (*QueryUsageResponse, error)
+	// GetTopChangers returns the directories under a base path whose size
+	// changed most over a time range.
+	GetTopChangers(context.Context, *GetTopChangersRequest) (*GetTopChangersResponse, error)
+	// ListScans returns recorded scans, newest first.
+	ListScans(context.Context, *ListScansRequest) (*ListScansResponse, error)
+	// TriggerScan starts a scan of a configured path in the background and
+	// returns once its scan record exists. It fails with ALREADY_EXISTS if the
+	// path is already being scanned.
+	TriggerScan(context.Context, *TriggerScanRequest) (*TriggerScanResponse, error)
+	// StreamScanProgress reports a scan's progress periodically while it
+	// runs, then its outcome, and ends. It fails with NOT_FOUND unless the
+	// scan is running or is the last scan the daemon finished of its path.
+	StreamScanProgress(*StreamScanProgressRequest, grpc.ServerStreamingServer[ScanProgress]) error
+	mustEmbedUnimplementedUsageServiceServer()
+}
+
+// UnimplementedUsageServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedUsageServiceServer struct{}
+
+func (UnimplementedUsageServiceServer) QueryUsage(context.Context, *QueryUsageRequest) (*QueryUsageResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method QueryUsage not implemented")
+}
+func (UnimplementedUsageServiceServer) GetTopChangers(context.Context, *GetTopChangersRequest) (*GetTopChangersResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTopChangers not implemented")
+}
+func (UnimplementedUsageServiceServer) ListScans(context.Context, *ListScansRequest) (*ListScansResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListScans not implemented")
+}
+func (UnimplementedUsageServiceServer) TriggerScan(context.Context, *TriggerScanRequest) (*TriggerScanResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method TriggerScan not implemented")
+}
+func (UnimplementedUsageServiceServer) StreamScanProgress(*StreamScanProgressRequest, grpc.ServerStreamingServer[ScanProgress]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamScanProgress not implemented")
+}
+func (UnimplementedUsageServiceServer) mustEmbedUnimplementedUsageServiceServer() {}
+func (UnimplementedUsageServiceServer) testEmbeddedByValue()                      {}
+
+// UnsafeUsageServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to UsageServiceServer will
+// result in compilation errors.
+type UnsafeUsageServiceServer interface {
+	mustEmbedUnimplementedUsageServiceServer()
+}
+
+func RegisterUsageServiceServer(s grpc.ServiceRegistrar, srv UsageServiceServer) {
+	// If the following call pancis, it indicates UnimplementedUsageServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&UsageService_ServiceDesc, srv)
+}
+
+func _UsageService_QueryUsage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(QueryUsageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UsageServiceServer).QueryUsage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UsageService_QueryUsage_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UsageServiceServer).QueryUsage(ctx, req.(*QueryUsageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UsageService_GetTopChangers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetTopChangersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UsageServiceServer).GetTopChangers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UsageService_GetTopChangers_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UsageServiceServer).GetTopChangers(ctx, req.(*GetTopChangersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UsageService_ListScans_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListScansRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UsageServiceServer).ListScans(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UsageService_ListScans_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UsageServiceServer).ListScans(ctx, req.(*ListScansRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UsageService_TriggerScan_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TriggerScanRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UsageServiceServer).TriggerScan(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UsageService_TriggerScan_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UsageServiceServer).TriggerScan(ctx, req.(*TriggerScanRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UsageService_StreamScanProgress_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamScanProgressRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(UsageServiceServer).StreamScanProgress(m, &grpc.GenericServerStream[StreamScanProgressRequest, ScanProgress]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type UsageService_StreamScanProgressServer = grpc.ServerStreamingServer[ScanProgress]
+
+// UsageService_ServiceDesc is the grpc.ServiceDesc for UsageService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var UsageService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "usgmon.v1.UsageService",
+	HandlerType: (*UsageServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "QueryUsage",
+			Handler:    _UsageService_QueryUsage_Handler,
+		},
+		{
+			MethodName: "GetTopChangers",
+			Handler:    _UsageService_GetTopChangers_Handler,
+		},
+		{
+			MethodName: "ListScans",
+			Handler:    _UsageService_ListScans_Handler,
+		},
+		{
+			MethodName: "TriggerScan",
+			Handler:    _UsageService_TriggerScan_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamScanProgress",
+			Handler:       _UsageService_StreamScanProgress_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "usgmon/v1/usgmon.proto",
+}
@@ -0,0 +1,360 @@
+// Package agent pushes scan results to a central usgmon server, which stores
+// them in its own database tagged with the agent's host name. Pushes are
+// queued and sent in order by a single goroutine, so an unreachable server
+// never holds up a scan.
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jgalley/usgmon/pkg/storage"
+)
+
+// queueSize bounds the pushes waiting to be sent. When the server falls this
+// far behind, the rest of the affected scans is dropped.
+const queueSize = 1024
+
+// Scan starts a pushed scan; it is the body of POST /api/v1/ingest/scans.
+type Scan struct {
+	ScanID    string                `json:"scan_id"`
+	Hostname  string                `json:"hostname"`
+	BasePath  string                `json:"base_path"`
+	StartedAt time.Time             `json:"started_at"`
+	Trigger   string                `json:"trigger,omitempty"`
+	Note      string                `json:"note,omitempty"`
+	Metadata  *storage.ScanMetadata `json:"metadata,omitempty"`
+}
+
+// Usage is a batch of a scan's measurements; it is the body of
+// POST /api/v1/ingest/scans/{id}/usage. Batches are numbered from 1 within
+// their scan, and the server stores each number once, so a push retried
+// after the server stored it is not stored twice.
+type Usage struct {
+	Hostname string   `json:"hostname"`
+	Batch    int      `json:"batch"`
+	Records  []Record `json:"records"`
+}
+
+// Record is a usage measurement as pushed to the server.
+type Record struct {
+	BasePath       string    `json:"base_path"`
+	Directory      string    `json:"directory"`
+	SizeBytes      int64     `json:"size_bytes"`
+	RecordedAt     time.Time `json:"recorded_at"`
+	FileFilter     string    `json:"file_filter,omitempty"`
+	SymlinkCount   *int64    `json:"symlink_count,omitempty"`
+	Fingerprint    string    `json:"fingerprint,omitempty"`
+	AllocatedBytes *int64    `json:"allocated_bytes,omitempty"`
+	Owner          string    `json:"owner,omitempty"`
+	Group          string    `json:"group,omitempty"`
+	QuotaLimit     *int64    `json:"quota_limit_bytes,omitempty"`
+	Deleted        bool      `json:"deleted,omitempty"`
+	FileCount      *int64    `json:"file_count,omitempty"`
+	DirCount       *int64    `json:"dir_count,omitempty"`
+
+	OwnerUsage []storage.OwnerUsage `json:"owner_usage,omitempty"`
+	LargeFiles []storage.LargeFile  `json:"large_files,omitempty"`
+	AgeUsage   []storage.AgeUsage   `json:"age_usage,omitempty"`
+}
+
+// NewRecord converts a stored measurement for pushing.
+func NewRecord(r storage.UsageRecord) Record {
+	return Record{
+		BasePath:       r.BasePath,
+		Directory:      r.Directory,
+		SizeBytes:      r.SizeBytes,
+		RecordedAt:     r.RecordedAt,
+		FileFilter:     r.FileFilter,
+		SymlinkCount:   r.SymlinkCount,
+		Fingerprint:    r.Fingerprint,
+		AllocatedBytes: r.AllocatedBytes,
+		Owner:          r.Owner,
+		Group:          r.Group,
+		QuotaLimit:     r.QuotaLimit,
+		Deleted:        r.Deleted,
+		FileCount:      r.FileCount,
+		DirCount:       r.DirCount,
+		OwnerUsage:     r.OwnerUsage,
+		LargeFiles:     r.LargeFiles,
+		AgeUsage:       r.AgeUsage,
+	}
+}
+
+// UsageRecord converts a pushed measurement of the given scan and host for
+// storing, in UTC whatever zone the agent sent it in.
+func (r Record) UsageRecord(scanID, hostname string) storage.UsageRecord {
+	return storage.UsageRecord{
+		BasePath:       r.BasePath,
+		Directory:      r.Directory,
+		SizeBytes:      r.SizeBytes,
+		RecordedAt:     r.RecordedAt.UTC(),
+		ScanID:         scanID,
+		FileFilter:     r.FileFilter,
+		SymlinkCount:   r.SymlinkCount,
+		Fingerprint:    r.Fingerprint,
+		AllocatedBytes: r.AllocatedBytes,
+		Owner:          r.Owner,
+		Group:          r.Group,
+		QuotaLimit:     r.QuotaLimit,
+		Deleted:        r.Deleted,
+		FileCount:      r.FileCount,
+		DirCount:       r.DirCount,
+		Hostname:       hostname,
+		OwnerUsage:     r.OwnerUsage,
+		LargeFiles:     r.LargeFiles,
+		AgeUsage:       r.AgeUsage,
+	}
+}
+
+// CPU is the CPU time a scan consumed; it is the body of
+// POST /api/v1/ingest/scans/{id}/cpu.
+type CPU struct {
+	Hostname string `json:"hostname"`
+	UserMS   int64  `json:"user_ms"`
+	SystemMS int64  `json:"system_ms"`
+}
+
+// Finish ends a pushed scan; it is the body of
+// POST /api/v1/ingest/scans/{id}/finish.
+type Finish struct {
+	Hostname    string `json:"hostname"`
+	Status      string `json:"status"` // completed, failed or skipped
+	Directories int    `json:"directories,omitempty"`
+	Reason      string `json:"reason,omitempty"` // why a scan failed or was skipped
+}
+
+// Options configures a Pusher.
+type Options struct {
+	Server     string // base URL of the central server
+	Token      string
+	Hostname   string
+	Timeout    time.Duration // per attempt
+	Retries    int           // attempts after the first
+	RetryDelay time.Duration // before the first retry, doubling after each
+}
+
+// push is a queued request.
+type push struct {
+	scanID string
+	path   string // relative to the server URL
+	body   []byte
+}
+
+// Pusher sends scan results to the central server in the background.
+type Pusher struct {
+	opts   Options
+	client *http.Client
+	logger *slog.Logger
+
+	queue  chan push
+	done   chan struct{}
+	ctx    context.Context // cancelled to abandon pushes on shutdown
+	cancel context.CancelFunc
+
+	// Scans whose remaining pushes are skipped. Entries are never removed, as
+	// a late push of a dropped scan can arrive at any time; they are small.
+	mu      sync.Mutex
+	dropped map[string]bool
+	batches map[string]int // usage batches queued per unfinished scan
+}
+
+// New creates a Pusher and starts sending.
+func New(opts Options, logger *slog.Logger) *Pusher {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &Pusher{
+		opts:    opts,
+		client:  &http.Client{Timeout: opts.Timeout},
+		logger:  logger,
+		queue:   make(chan push, queueSize),
+		done:    make(chan struct{}),
+		ctx:     ctx,
+		cancel:  cancel,
+		dropped: make(map[string]bool),
+		batches: make(map[string]int),
+	}
+	go p.run()
+	return p
+}
+
+// Hostname returns the name the agent's scans are stored under.
+func (p *Pusher) Hostname() string {
+	return p.opts.Hostname
+}
+
+// StartScan queues the start of a scan.
+func (p *Pusher) StartScan(s Scan) {
+	s.Hostname = p.opts.Hostname
+	p.enqueue(s.ScanID, "/api/v1/ingest/scans", s)
+}
+
+// Usage queues a batch of a scan's measurements.
+func (p *Pusher) Usage(scanID string, records []storage.UsageRecord) {
+	u := Usage{Hostname: p.opts.Hostname, Batch: p.nextBatch(scanID), Records: make([]Record, len(records))}
+	for i, r := range records {
+		u.Records[i] = NewRecord(r)
+	}
+	p.enqueue(scanID, scanPath(scanID, "usage"), u)
+}
+
+// ScanCPU queues the CPU time a scan consumed.
+func (p *Pusher) ScanCPU(scanID string, user, system time.Duration) {
+	p.enqueue(scanID, scanPath(scanID, "cpu"), CPU{Hostname: p.opts.Hostname, UserMS: user.Milliseconds(), SystemMS: system.Milliseconds()})
+}
+
+// Finish queues the end of a scan.
+func (p *Pusher) Finish(scanID string, f Finish) {
+	p.mu.Lock()
+	delete(p.batches, scanID)
+	p.mu.Unlock()
+	f.Hostname = p.opts.Hostname
+	p.enqueue(scanID, scanPath(scanID, "finish"), f)
+}
+
+// nextBatch returns the number of a scan's next usage batch.
+func (p *Pusher) nextBatch(scanID string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.batches[scanID]++
+	return p.batches[scanID]
+}
+
+// Close sends the pushes still queued, abandoning them when ctx is done.
+func (p *Pusher) Close(ctx context.Context) {
+	close(p.queue)
+	select {
+	case <-p.done:
+	case <-ctx.Done():
+		p.logger.Warn("abandoning pending pushes to the central server", "queued", len(p.queue))
+		p.cancel()
+		<-p.done
+	}
+	p.cancel()
+}
+
+// scanPath returns the ingest endpoint for an action on a scan.
+func scanPath(scanID, action string) string {
+	return "/api/v1/ingest/scans/" + url.PathEscape(scanID) + "/" + action
+}
+
+// enqueue encodes v and queues it for sending, dropping it when the queue is
+// full.
+func (p *Pusher) enqueue(scanID, path string, v any) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		p.logger.Error("failed to encode push to the central server", "scan_id", scanID, "error", err)
+		return
+	}
+
+	select {
+	case p.queue <- push{scanID: scanID, path: path, body: body}:
+	default:
+		if !p.drop(scanID) {
+			p.logger.Warn("push queue full, dropping the rest of the scan", "scan_id", scanID)
+		}
+	}
+}
+
+// drop marks a scan's remaining pushes to be skipped and reports whether it
+// already was.
+func (p *Pusher) drop(scanID string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	was := p.dropped[scanID]
+	p.dropped[scanID] = true
+	return was
+}
+
+// skipped reports whether a scan's remaining pushes are skipped.
+func (p *Pusher) skipped(scanID string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.dropped[scanID]
+}
+
+// run sends queued pushes in order until the queue is closed. Once a push of
+// a scan fails, the scan's remaining pushes are skipped so the server never
+// records a partial scan as completed.
+func (p *Pusher) run() {
+	defer close(p.done)
+	for item := range p.queue {
+		if p.ctx.Err() != nil || p.skipped(item.scanID) {
+			continue
+		}
+
+		if err := p.deliver(item); err != nil {
+			p.logger.Warn("push to central server failed, dropping the rest of the scan",
+				"server", p.opts.Server, "scan_id", item.scanID, "endpoint", item.path, "error", err)
+			p.drop(item.scanID)
+			continue
+		}
+		p.logger.Debug("pushed to central server", "scan_id", item.scanID, "endpoint", item.path)
+	}
+}
+
+// deliver sends a push, retrying transport errors, 429 and 5xx responses.
+func (p *Pusher) deliver(item push) error {
+	delay := p.opts.RetryDelay
+	var err error
+	for attempt := 0; ; attempt++ {
+		var retry bool
+		retry, err = p.attempt(item)
+		if err == nil || !retry || attempt >= p.opts.Retries {
+			return err
+		}
+
+		p.logger.Debug("retrying push", "scan_id", item.scanID, "attempt", attempt+1, "delay", delay, "error", err)
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-p.ctx.Done():
+			timer.Stop()
+			return errors.Join(err, p.ctx.Err())
+		}
+		delay *= 2
+	}
+}
+
+// attempt makes a single request and reports whether a failure is worth
+// retrying.
+func (p *Pusher) attempt(item push) (bool, error) {
+	u := strings.TrimSuffix(p.opts.Server, "/") + item.path
+	req, err := http.NewRequestWithContext(p.ctx, http.MethodPost, u, bytes.NewReader(item.body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+p.opts.Token)
+	req.Header.Set("User-Agent", "usgmon-agent")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	var apiErr struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(msg, &apiErr) == nil && apiErr.Error != "" {
+		err = fmt.Errorf("server returned %s: %s", resp.Status, apiErr.Error)
+	} else {
+		err = fmt.Errorf("server returned %s", resp.Status)
+	}
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, err
+}
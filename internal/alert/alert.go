@@ -0,0 +1,204 @@
+// Package alert decides when directory size alerts fire. A directory alerts
+// once when it goes over its threshold, optionally reminds after a cooldown
+// while it stays over, and reports recovery when it drops back under.
+// State is persisted so a daemon restart does not re-fire existing alerts.
+// Growth anomalies are one-off events with no state: each anomalous scan
+// notifies once.
+package alert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jgalley/usgmon/internal/anomaly"
+	"github.com/jgalley/usgmon/internal/humanize"
+	"github.com/jgalley/usgmon/pkg/storage"
+)
+
+// Kind identifies the type of alert event.
+type Kind string
+
+const (
+	Firing    Kind = "firing"    // directory went over its threshold
+	Reminder  Kind = "reminder"  // directory is still over after the reminder interval
+	Recovered Kind = "recovered" // directory dropped back under its threshold
+	Anomaly   Kind = "anomaly"   // directory grew far more than it usually does
+)
+
+// Event is a single alert notification.
+type Event struct {
+	Kind           Kind
+	BasePath       string
+	Directory      string
+	SizeBytes      int64
+	ThresholdBytes int64
+	Since          time.Time // when the directory went over the threshold, or grew for anomalies
+
+	// Set for anomalies only
+	GrowthBytes int64            // growth since the previous record
+	Baseline    anomaly.Baseline // the directory's usual growth
+}
+
+// Notifier delivers alert events.
+type Notifier interface {
+	Notify(ctx context.Context, e Event) error
+}
+
+// MultiNotifier delivers each event to every notifier in turn.
+type MultiNotifier []Notifier
+
+// Notify sends the event to all notifiers, returning their errors joined.
+func (m MultiNotifier) Notify(ctx context.Context, e Event) error {
+	var errs []error
+	for _, n := range m {
+		if err := n.Notify(ctx, e); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// LogNotifier writes alert events to a logger.
+type LogNotifier struct {
+	Logger *slog.Logger
+}
+
+// Notify logs the event; firing, reminder and anomaly events are warnings.
+func (n *LogNotifier) Notify(ctx context.Context, e Event) error {
+	if e.Kind == Anomaly {
+		n.Logger.Warn("growth anomaly",
+			"path", e.BasePath,
+			"directory", e.Directory,
+			"size_human", humanize.FormatSize(e.SizeBytes),
+			"growth_bytes", e.GrowthBytes,
+			"growth_human", humanize.FormatSize(e.GrowthBytes),
+			"mean_growth_human", humanize.FormatSize(int64(e.Baseline.Mean)),
+			"stddev_human", humanize.FormatSize(int64(e.Baseline.Stddev)),
+			"samples", e.Baseline.Samples,
+		)
+		return nil
+	}
+	level := slog.LevelWarn
+	if e.Kind == Recovered {
+		level = slog.LevelInfo
+	}
+	n.Logger.Log(ctx, level, "size alert "+string(e.Kind),
+		"path", e.BasePath,
+		"directory", e.Directory,
+		"size_bytes", e.SizeBytes,
+		"size_human", humanize.FormatSize(e.SizeBytes),
+		"threshold_human", humanize.FormatSize(e.ThresholdBytes),
+		"since", e.Since.Local().Format(time.RFC3339),
+	)
+	return nil
+}
+
+// Tracker evaluates measurements against thresholds and notifies only on
+// state transitions and reminders.
+type Tracker struct {
+	store    storage.Storage
+	notifier Notifier
+	reminder time.Duration // 0 disables reminders
+}
+
+// NewTracker creates a Tracker. A zero reminder interval sends no reminders.
+func NewTracker(store storage.Storage, notifier Notifier, reminder time.Duration) *Tracker {
+	return &Tracker{store: store, notifier: notifier, reminder: reminder}
+}
+
+// Load returns the persisted alert states for a base path, keyed by directory,
+// for use as the prev argument to Evaluate.
+func (t *Tracker) Load(ctx context.Context, basePath string) (map[string]storage.AlertState, error) {
+	states, err := t.store.ListAlertStates(ctx, basePath)
+	if err != nil {
+		return nil, err
+	}
+	byDir := make(map[string]storage.AlertState, len(states))
+	for _, s := range states {
+		byDir[s.Directory] = s
+	}
+	return byDir, nil
+}
+
+// Evaluate checks a directory's size against threshold, given its previous
+// alert state (nil if it was not alerting), notifying and persisting the new
+// state as needed.
+func (t *Tracker) Evaluate(ctx context.Context, basePath, directory string, size, threshold int64, prev *storage.AlertState, now time.Time) error {
+	over := size > threshold
+
+	switch {
+	case over && prev == nil:
+		state := storage.AlertState{
+			Directory:      directory,
+			BasePath:       basePath,
+			ThresholdBytes: threshold,
+			SizeBytes:      size,
+			Since:          now,
+			LastNotified:   now,
+		}
+		if err := t.notify(ctx, Firing, state); err != nil {
+			return err
+		}
+		return t.store.SaveAlertState(ctx, state)
+
+	case over:
+		if t.reminder <= 0 || now.Sub(prev.LastNotified) < t.reminder {
+			return nil
+		}
+		state := *prev
+		state.ThresholdBytes = threshold
+		state.SizeBytes = size
+		state.LastNotified = now
+		if err := t.notify(ctx, Reminder, state); err != nil {
+			return err
+		}
+		return t.store.SaveAlertState(ctx, state)
+
+	case prev != nil:
+		state := *prev
+		state.ThresholdBytes = threshold
+		state.SizeBytes = size
+		if err := t.notify(ctx, Recovered, state); err != nil {
+			return err
+		}
+		return t.store.DeleteAlertState(ctx, directory)
+	}
+
+	return nil
+}
+
+// Anomaly notifies that a directory grew by growth to size, far beyond its
+// baseline. Anomalies keep no state, so every call notifies.
+func (t *Tracker) Anomaly(ctx context.Context, basePath, directory string, size, growth int64, baseline anomaly.Baseline, now time.Time) error {
+	err := t.notifier.Notify(ctx, Event{
+		Kind:        Anomaly,
+		BasePath:    basePath,
+		Directory:   directory,
+		SizeBytes:   size,
+		Since:       now,
+		GrowthBytes: growth,
+		Baseline:    baseline,
+	})
+	if err != nil {
+		return fmt.Errorf("sending anomaly alert for %s: %w", directory, err)
+	}
+	return nil
+}
+
+func (t *Tracker) notify(ctx context.Context, kind Kind, s storage.AlertState) error {
+	err := t.notifier.Notify(ctx, Event{
+		Kind:           kind,
+		BasePath:       s.BasePath,
+		Directory:      s.Directory,
+		SizeBytes:      s.SizeBytes,
+		ThresholdBytes: s.ThresholdBytes,
+		Since:          s.Since,
+	})
+	if err != nil {
+		return fmt.Errorf("sending %s alert for %s: %w", kind, s.Directory, err)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+// Package anomaly flags directory growth that is far outside a directory's
+// own history. A directory's baseline is the mean and standard deviation of
+// the changes between its consecutive records; a change is anomalous when it
+// lies more than a number of standard deviations above the mean and is
+// large enough to matter.
+package anomaly
+
+import "math"
+
+// MinSamples is the fewest changes a baseline needs before anything can be
+// judged against it.
+const MinSamples = 5
+
+// Baseline is a directory's typical change between consecutive records.
+type Baseline struct {
+	Samples int     // number of changes the baseline was computed from
+	Mean    float64 // mean change in bytes
+	Stddev  float64 // standard deviation of the changes in bytes
+}
+
+// NewBaseline computes the baseline of a series of sizes, oldest first.
+func NewBaseline(sizes []int64) Baseline {
+	if len(sizes) < 2 {
+		return Baseline{}
+	}
+	n := float64(len(sizes) - 1)
+	var sum float64
+	for i := 1; i < len(sizes); i++ {
+		sum += float64(sizes[i] - sizes[i-1])
+	}
+	mean := sum / n
+
+	var squares float64
+	for i := 1; i < len(sizes); i++ {
+		d := float64(sizes[i]-sizes[i-1]) - mean
+		squares += d * d
+	}
+	return Baseline{Samples: len(sizes) - 1, Mean: mean, Stddev: math.Sqrt(squares / n)}
+}
+
+// Sigma returns how many standard deviations growth lies above the mean,
+// or false for a baseline that never varied, where it is undefined.
+func (b Baseline) Sigma(growth int64) (float64, bool) {
+	if b.Stddev == 0 {
+		return 0, false
+	}
+	return (float64(growth) - b.Mean) / b.Stddev, true
+}
+
+// Detector decides which changes are anomalous.
+type Detector struct {
+	Sigma     float64 // standard deviations above the mean change
+	MinGrowth int64   // smallest growth that can be anomalous
+}
+
+// Anomalous reports whether growth is anomalous against baseline: the
+// baseline has at least MinSamples changes, growth is at least MinGrowth,
+// and it lies at least Sigma standard deviations above the mean. Against a
+// baseline that never varied, any growth above the mean is anomalous once
+// it reaches MinGrowth.
+func (d Detector) Anomalous(b Baseline, growth int64) bool {
+	if b.Samples < MinSamples || growth < d.MinGrowth || growth <= 0 {
+		return false
+	}
+	sigma, ok := b.Sigma(growth)
+	if !ok {
+		return float64(growth) > b.Mean
+	}
+	return sigma >= d.Sigma
+}
@@ -0,0 +1,183 @@
+// Package cgroup reads the process's cgroup v2 I/O accounting and the
+// system's I/O pressure (PSI), and provides a gate that pauses work while
+// I/O pressure is high.
+package cgroup
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// IOStat holds cumulative I/O counters for a cgroup, summed over all devices.
+type IOStat struct {
+	ReadBytes  uint64
+	WriteBytes uint64
+	ReadIOs    uint64
+	WriteIOs   uint64
+}
+
+// Sub returns the difference between two snapshots (s - prev).
+func (s IOStat) Sub(prev IOStat) IOStat {
+	return IOStat{
+		ReadBytes:  s.ReadBytes - prev.ReadBytes,
+		WriteBytes: s.WriteBytes - prev.WriteBytes,
+		ReadIOs:    s.ReadIOs - prev.ReadIOs,
+		WriteIOs:   s.WriteIOs - prev.WriteIOs,
+	}
+}
+
+// cgroupRoot is where the cgroup v2 hierarchy is mounted.
+const cgroupRoot = "/sys/fs/cgroup"
+
+// pressurePath is the system-wide I/O pressure file.
+const pressurePath = "/proc/pressure/io"
+
+// ReadIOStat reads io.stat for the cgroup this process belongs to.
+// Only the unified (v2) hierarchy is supported.
+func ReadIOStat() (IOStat, error) {
+	path, err := selfCgroupPath()
+	if err != nil {
+		return IOStat{}, err
+	}
+
+	f, err := os.Open(filepath.Join(cgroupRoot, path, "io.stat"))
+	if err != nil {
+		return IOStat{}, fmt.Errorf("opening io.stat: %w", err)
+	}
+	defer f.Close()
+
+	// Format: "8:0 rbytes=123 wbytes=456 rios=7 wios=8 dbytes=0 dios=0"
+	var st IOStat
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		for _, field := range fields[1:] {
+			key, value, ok := strings.Cut(field, "=")
+			if !ok {
+				continue
+			}
+			n, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				continue
+			}
+			switch key {
+			case "rbytes":
+				st.ReadBytes += n
+			case "wbytes":
+				st.WriteBytes += n
+			case "rios":
+				st.ReadIOs += n
+			case "wios":
+				st.WriteIOs += n
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return IOStat{}, fmt.Errorf("reading io.stat: %w", err)
+	}
+
+	return st, nil
+}
+
+// selfCgroupPath returns this process's cgroup v2 path from /proc/self/cgroup.
+func selfCgroupPath() (string, error) {
+	data, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return "", fmt.Errorf("reading /proc/self/cgroup: %w", err)
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if path, ok := strings.CutPrefix(line, "0::"); ok {
+			return path, nil
+		}
+	}
+
+	return "", fmt.Errorf("no cgroup v2 entry in /proc/self/cgroup")
+}
+
+// ReadIOPressure returns the "some" avg10 value from /proc/pressure/io: the
+// percentage of the last 10 seconds in which at least one task was stalled on I/O.
+func ReadIOPressure() (float64, error) {
+	data, err := os.ReadFile(pressurePath)
+	if err != nil {
+		return 0, fmt.Errorf("reading %s: %w", pressurePath, err)
+	}
+
+	// Format: "some avg10=0.00 avg60=0.00 avg300=0.00 total=0"
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[0] != "some" {
+			continue
+		}
+		value, ok := strings.CutPrefix(fields[1], "avg10=")
+		if !ok {
+			break
+		}
+		return strconv.ParseFloat(value, 64)
+	}
+
+	return 0, fmt.Errorf("unexpected %s format", pressurePath)
+}
+
+// PressureGate blocks callers while system I/O pressure exceeds a threshold.
+// The pressure reading is cached for the poll interval so many workers can
+// share one gate cheaply.
+type PressureGate struct {
+	threshold float64
+	interval  time.Duration
+
+	mu        sync.Mutex
+	checkedAt time.Time
+	pressure  float64
+}
+
+// NewPressureGate creates a gate that pauses while avg10 I/O pressure is above
+// threshold (a percentage), re-checking every interval.
+func NewPressureGate(threshold float64, interval time.Duration) *PressureGate {
+	if interval <= 0 {
+		interval = time.Second
+	}
+	return &PressureGate{threshold: threshold, interval: interval}
+}
+
+// Wait returns once I/O pressure is at or below the threshold, or when ctx is
+// cancelled. If pressure cannot be read (e.g. PSI unsupported) it never blocks.
+func (g *PressureGate) Wait(ctx context.Context) error {
+	for {
+		pressure, err := g.current()
+		if err != nil || pressure <= g.threshold {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(g.interval):
+		}
+	}
+}
+
+// current returns the cached pressure, refreshing it once per interval.
+func (g *PressureGate) current() (float64, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if time.Since(g.checkedAt) < g.interval {
+		return g.pressure, nil
+	}
+
+	pressure, err := ReadIOPressure()
+	if err != nil {
+		return 0, err
+	}
+	g.pressure = pressure
+	g.checkedAt = time.Now()
+	return pressure, nil
+}
@@ -0,0 +1,234 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/jgalley/usgmon/internal/anomaly"
+	"github.com/jgalley/usgmon/internal/humanize"
+	"github.com/jgalley/usgmon/internal/timespec"
+	"github.com/jgalley/usgmon/pkg/storage"
+	"github.com/spf13/cobra"
+)
+
+var (
+	anomaliesSince     string
+	anomaliesBaseline  string
+	anomaliesSigma     float64
+	anomaliesMinGrowth string
+	anomaliesFormat    string
+)
+
+var anomaliesCmd = &cobra.Command{
+	Use:   "anomalies <base-path>",
+	Short: "Find directories that grew far more than they usually do",
+	Long: `Find the directories under a base path that recently grew far beyond their
+own history. Each change between consecutive records since --since is
+compared with the directory's baseline: the mean and standard deviation of
+its changes over up to alerts.anomaly_history records before it. A change
+is anomalous when it lies at least --sigma standard deviations above the
+mean and is at least --min-growth. A directory that never changed before
+("flat") is anomalous on any growth of at least --min-growth.
+
+This is the check the daemon alerts on when alerts.anomaly_sigma is set.
+
+Examples:
+  usgmon anomalies /www/users
+  usgmon anomalies /www/users --since 30d --sigma 3
+  usgmon anomalies /home --min-growth 10G --format json`,
+	Args: cobra.ExactArgs(1),
+	RunE: runAnomalies,
+}
+
+func init() {
+	anomaliesCmd.Flags().StringVar(&anomaliesSince, "since", "7d", "look for anomalous changes since (YYYY-MM-DD or relative like 48h, 3d, 2w)")
+	anomaliesCmd.Flags().StringVar(&anomaliesBaseline, "baseline", "90d", "how far before --since history is read for baselines")
+	anomaliesCmd.Flags().Float64Var(&anomaliesSigma, "sigma", 0, "standard deviations above the mean change (default alerts.anomaly_sigma, or 4)")
+	anomaliesCmd.Flags().StringVar(&anomaliesMinGrowth, "min-growth", "", "smallest growth to report (default alerts.anomaly_min_growth)")
+	anomaliesCmd.Flags().StringVar(&anomaliesFormat, "format", "text", "output format (text, json, csv)")
+}
+
+// defaultAnomalySigma is used when neither --sigma nor alerts.anomaly_sigma
+// is set.
+const defaultAnomalySigma = 4
+
+// growthAnomaly is an anomalous change of a directory.
+type growthAnomaly struct {
+	Directory  string
+	RecordedAt time.Time
+	SizeBytes  int64
+	Growth     int64
+	Baseline   anomaly.Baseline
+}
+
+func runAnomalies(cmd *cobra.Command, args []string) error {
+	basePath := filepath.Clean(args[0])
+
+	now := time.Now()
+	since, err := timespec.Parse(anomaliesSince, now, false)
+	if err != nil {
+		return fmt.Errorf("invalid --since value: %w", err)
+	}
+	lookback, err := timespec.ParseDuration(anomaliesBaseline)
+	if err != nil || lookback <= 0 {
+		return fmt.Errorf("invalid --baseline value %q", anomaliesBaseline)
+	}
+	if anomaliesSigma < 0 {
+		return fmt.Errorf("--sigma must be non-negative")
+	}
+	switch anomaliesFormat {
+	case "text", "json", "csv":
+	default:
+		return fmt.Errorf("invalid --format value %q (valid: text, json, csv)", anomaliesFormat)
+	}
+
+	ctx := context.Background()
+	cfg, store, err := openStorage(ctx)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	detector := anomaly.Detector{Sigma: anomaliesSigma, MinGrowth: int64(cfg.Alerts.AnomalyMinGrowth)}
+	if detector.Sigma == 0 {
+		detector.Sigma = cfg.Alerts.AnomalySigma
+	}
+	if detector.Sigma == 0 {
+		detector.Sigma = defaultAnomalySigma
+	}
+	if anomaliesMinGrowth != "" {
+		if detector.MinGrowth, err = humanize.ParseSize(anomaliesMinGrowth); err != nil {
+			return fmt.Errorf("invalid --min-growth value: %w", err)
+		}
+	}
+
+	from := since.Add(-lookback)
+	records, err := store.QueryUsage(ctx, storage.QueryOptions{BasePath: basePath, Since: &from})
+	if err != nil {
+		return fmt.Errorf("querying usage: %w", err)
+	}
+	found := findAnomalies(records, since, detector, cfg.Alerts.AnomalyHistory)
+
+	switch anomaliesFormat {
+	case "json":
+		return writeColumnsJSON(anomalyColumns, found)
+	case "csv":
+		return writeColumnsCSV(os.Stdout, anomalyColumns, found)
+	}
+	if len(found) == 0 {
+		fmt.Printf("No anomalous growth since %s\n", since.Local().Format("2006-01-02 15:04"))
+		return nil
+	}
+	return writeColumnsText(os.Stdout, anomalyColumns, found)
+}
+
+// findAnomalies returns the anomalous changes recorded since, newest first.
+// records are newest first, as QueryUsage returns them; each change is
+// judged against the history changes before it, up to history records.
+func findAnomalies(records []storage.UsageRecord, since time.Time, detector anomaly.Detector, history int) []growthAnomaly {
+	// Each directory's comparable sizes, oldest first, back to its last
+	// deletion
+	byDir := make(map[string][]storage.UsageRecord)
+	for i := len(records) - 1; i >= 0; i-- {
+		r := records[i]
+		switch {
+		case r.Deleted:
+			delete(byDir, r.Directory)
+		case r.FileFilter == "":
+			byDir[r.Directory] = append(byDir[r.Directory], r)
+		}
+	}
+
+	var found []growthAnomaly
+	for dir, recs := range byDir {
+		for i := 1; i < len(recs); i++ {
+			if recs[i].RecordedAt.Before(since) {
+				continue
+			}
+			sizes := make([]int64, 0, history)
+			for _, r := range recs[max(i-history, 0):i] {
+				sizes = append(sizes, r.SizeBytes)
+			}
+			growth := recs[i].SizeBytes - recs[i-1].SizeBytes
+			baseline := anomaly.NewBaseline(sizes)
+			if detector.Anomalous(baseline, growth) {
+				found = append(found, growthAnomaly{
+					Directory:  dir,
+					RecordedAt: recs[i].RecordedAt,
+					SizeBytes:  recs[i].SizeBytes,
+					Growth:     growth,
+					Baseline:   baseline,
+				})
+			}
+		}
+	}
+	sort.Slice(found, func(i, j int) bool {
+		if !found[i].RecordedAt.Equal(found[j].RecordedAt) {
+			return found[i].RecordedAt.After(found[j].RecordedAt)
+		}
+		return found[i].Directory < found[j].Directory
+	})
+	return found
+}
+
+// sigmaText describes how unusual an anomaly is.
+func sigmaText(a growthAnomaly) string {
+	sigma, ok := a.Baseline.Sigma(a.Growth)
+	if !ok {
+		return "flat"
+	}
+	return fmt.Sprintf("%.1f", sigma)
+}
+
+// anomalyColumns are the columns of anomalies output.
+var anomalyColumns = []column[growthAnomaly]{
+	{
+		Name: "directory", Header: "DIRECTORY",
+		Text: func(a growthAnomaly) string { return a.Directory },
+		JSON: func(a growthAnomaly) interface{} { return a.Directory },
+	},
+	{
+		Name: "recorded_at", Header: "RECORDED",
+		Text: func(a growthAnomaly) string { return a.RecordedAt.Local().Format("2006-01-02 15:04") },
+		JSON: func(a growthAnomaly) interface{} { return a.RecordedAt.Format(time.RFC3339) },
+	},
+	{
+		Name: "growth", Header: "GROWTH",
+		Text: func(a growthAnomaly) string { return signedSize(a.Growth) },
+		JSON: func(a growthAnomaly) interface{} { return a.Growth },
+	},
+	{
+		Name: "size", Header: "SIZE",
+		Text: func(a growthAnomaly) string { return humanize.FormatSize(a.SizeBytes) },
+		JSON: func(a growthAnomaly) interface{} { return a.SizeBytes },
+	},
+	{
+		Name: "mean_growth", Header: "MEAN",
+		Text: func(a growthAnomaly) string { return signedSize(int64(a.Baseline.Mean)) },
+		JSON: func(a growthAnomaly) interface{} { return a.Baseline.Mean },
+	},
+	{
+		Name: "stddev", Header: "STDDEV",
+		Text: func(a growthAnomaly) string { return humanize.FormatSize(int64(a.Baseline.Stddev)) },
+		JSON: func(a growthAnomaly) interface{} { return a.Baseline.Stddev },
+	},
+	{
+		Name: "sigma", Header: "SIGMA",
+		Text: sigmaText,
+		JSON: func(a growthAnomaly) interface{} {
+			if sigma, ok := a.Baseline.Sigma(a.Growth); ok {
+				return sigma
+			}
+			return nil
+		},
+	},
+	{
+		Name: "samples", Header: "SAMPLES",
+		Text: func(a growthAnomaly) string { return fmt.Sprint(a.Baseline.Samples) },
+		JSON: func(a growthAnomaly) interface{} { return a.Baseline.Samples },
+	},
+}
@@ -0,0 +1,160 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jgalley/usgmon/internal/humanize"
+	"github.com/jgalley/usgmon/internal/timespec"
+	"github.com/jgalley/usgmon/pkg/storage"
+	"github.com/spf13/cobra"
+)
+
+var (
+	atTime   string
+	atFormat string
+	atHost   string
+)
+
+var atCmd = &cobra.Command{
+	Use:   "at <base-path>",
+	Short: "Show directory sizes as of a point in time",
+	Long: `Reconstruct each directory's size under a base path as of a given time,
+using the most recent record at or before that time for each directory.
+
+Examples:
+  usgmon at /www/users --time "2026-01-15 12:00"
+  usgmon at /www/users --time 2026-01-15
+  usgmon at /www/users --time 3d --format json
+  usgmon at /www/users --time 3d --host fs01`,
+	Args: cobra.ExactArgs(1),
+	RunE: runAt,
+}
+
+func init() {
+	atCmd.Flags().StringVar(&atTime, "time", "", "point in time (\"YYYY-MM-DD HH:MM\", YYYY-MM-DD, or relative like 48h, 3d)")
+	atCmd.Flags().StringVar(&atFormat, "format", "text", "output format (text, json, csv)")
+	atCmd.Flags().StringVar(&atHost, "host", "", "only directories measured by this host")
+	atCmd.MarkFlagRequired("time")
+}
+
+func runAt(cmd *cobra.Command, args []string) error {
+	basePath := filepath.Clean(args[0])
+
+	// A bare date means "as of the end of that day"
+	at, err := timespec.Parse(atTime, time.Now(), true)
+	if err != nil {
+		return fmt.Errorf("invalid --time value: %w", err)
+	}
+
+	ctx := context.Background()
+	_, store, err := openStorage(ctx)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	records, err := store.GetSnapshotAt(ctx, basePath, atHost, at)
+	if err != nil {
+		return fmt.Errorf("querying snapshot: %w", err)
+	}
+
+	if len(records) == 0 && atFormat != "csv" {
+		fmt.Println("No records found")
+		return nil
+	}
+
+	switch atFormat {
+	case "json":
+		return outputAtJSON(records)
+	case "csv":
+		return writeColumnsCSV(os.Stdout, atCSVColumns, records)
+	default:
+		return outputAtText(records)
+	}
+}
+
+func outputAtText(records []storage.UsageRecord) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	// Hosts that measured the same paths are told apart
+	hosts := multipleHosts(records)
+	if hosts {
+		fmt.Fprintln(w, "DIRECTORY\tHOST\tSIZE\tRECORDED")
+		fmt.Fprintln(w, "---------\t----\t----\t--------")
+	} else {
+		fmt.Fprintln(w, "DIRECTORY\tSIZE\tRECORDED")
+		fmt.Fprintln(w, "---------\t----\t--------")
+	}
+
+	var total int64
+	for _, r := range records {
+		total += r.SizeBytes
+		if hosts {
+			fmt.Fprintf(w, "%s\t%s\t", r.Directory, orDash(r.Hostname))
+		} else {
+			fmt.Fprintf(w, "%s\t", r.Directory)
+		}
+		fmt.Fprintf(w, "%s\t%s\n",
+			humanize.FormatSize(r.SizeBytes),
+			r.RecordedAt.Local().Format("2006-01-02 15:04"),
+		)
+	}
+	if hosts {
+		fmt.Fprintf(w, "TOTAL\t\t%s\t\n", humanize.FormatSize(total))
+	} else {
+		fmt.Fprintf(w, "TOTAL\t%s\t\n", humanize.FormatSize(total))
+	}
+	return w.Flush()
+}
+
+type atJSONRecord struct {
+	Directory  string `json:"directory"`
+	SizeBytes  int64  `json:"size_bytes"`
+	SizeHuman  string `json:"size_human"`
+	RecordedAt string `json:"recorded_at"`
+	ScanID     string `json:"scan_id"`
+	Hostname   string `json:"hostname,omitempty"`
+}
+
+// atCSVColumns are the columns of at --format csv, those of its JSON output
+// without the human-readable size.
+var atCSVColumns = []column[storage.UsageRecord]{
+	{Name: "directory", JSON: func(r storage.UsageRecord) interface{} { return r.Directory }},
+	{Name: "size_bytes", JSON: func(r storage.UsageRecord) interface{} { return r.SizeBytes }},
+	{Name: "recorded_at", JSON: func(r storage.UsageRecord) interface{} { return r.RecordedAt.Format(time.RFC3339) }},
+	{Name: "scan_id", JSON: func(r storage.UsageRecord) interface{} { return r.ScanID }},
+	{Name: "hostname", JSON: func(r storage.UsageRecord) interface{} { return r.Hostname }},
+}
+
+// multipleHosts reports whether records were made by more than one host.
+func multipleHosts(records []storage.UsageRecord) bool {
+	for _, r := range records {
+		if r.Hostname != records[0].Hostname {
+			return true
+		}
+	}
+	return false
+}
+
+func outputAtJSON(records []storage.UsageRecord) error {
+	out := make([]atJSONRecord, len(records))
+	for i, r := range records {
+		out[i] = atJSONRecord{
+			Directory:  r.Directory,
+			SizeBytes:  r.SizeBytes,
+			SizeHuman:  humanize.FormatSize(r.SizeBytes),
+			RecordedAt: r.RecordedAt.Format(time.RFC3339),
+			ScanID:     r.ScanID,
+			Hostname:   r.Hostname,
+		}
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
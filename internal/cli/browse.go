@@ -0,0 +1,385 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/jgalley/usgmon/internal/humanize"
+	"github.com/jgalley/usgmon/internal/timespec"
+	"github.com/jgalley/usgmon/pkg/storage"
+	"github.com/spf13/cobra"
+)
+
+var browseSince string
+
+var browseCmd = &cobra.Command{
+	Use:   "browse <base-path>",
+	Short: "Browse stored directory sizes interactively",
+	Long: `Browse the latest recorded sizes of the directories under a base path as
+an interactive tree, like ncdu but from the database: nothing on the
+filesystem is read. Each entry shows its size, its change since --since, and
+when it was last recorded. Directories above the monitored depth are sized
+as the sum of the directories below them, and show the oldest of their
+record times.
+
+Keys:
+  up/down, j/k      move
+  enter, right, l   open the selected directory
+  esc, left, h      up to the parent directory
+  s, n, c           sort by size, name or change
+  q, ctrl+c         quit
+
+Examples:
+  usgmon browse /www/users
+  usgmon browse /home --since 30d`,
+	Args: cobra.ExactArgs(1),
+	RunE: runBrowse,
+}
+
+func init() {
+	browseCmd.Flags().StringVar(&browseSince, "since", "7d", "compare sizes with those at this time (YYYY-MM-DD or relative like 48h, 3d, 2w)")
+}
+
+func runBrowse(cmd *cobra.Command, args []string) error {
+	basePath := filepath.Clean(args[0])
+	now := time.Now()
+	since, err := timespec.Parse(browseSince, now, false)
+	if err != nil {
+		return fmt.Errorf("invalid --since value: %w", err)
+	}
+	if !since.Before(now) {
+		return fmt.Errorf("--since must be in the past")
+	}
+
+	ctx := context.Background()
+	_, store, err := openStorage(ctx)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	current, err := store.GetSnapshotAt(ctx, basePath, "", now)
+	if err != nil {
+		return fmt.Errorf("querying snapshot: %w", err)
+	}
+	if len(current) == 0 {
+		fmt.Println("No records found")
+		return nil
+	}
+	previous, err := store.GetSnapshotAt(ctx, basePath, "", since)
+	if err != nil {
+		return fmt.Errorf("querying snapshot: %w", err)
+	}
+
+	m := newBrowseModel(buildBrowseTree(basePath, current, previous), now.Sub(since))
+	_, err = tea.NewProgram(m, tea.WithAltScreen(), tea.WithContext(ctx)).Run()
+	return err
+}
+
+// browseNode is a directory in the browsed tree.
+type browseNode struct {
+	name     string
+	path     string
+	parent   *browseNode
+	children []*browseNode
+	measured bool // has a record of its own; otherwise sized from its children
+
+	size    int64
+	prev    int64     // size at --since
+	known   bool      // prev is known: it or a directory below it was recorded then
+	gone    int64     // size at --since of directories below it that are gone now
+	scanned time.Time // when last recorded; the oldest below it if not measured
+}
+
+// change describes how the node's size changed since --since.
+func (n *browseNode) change() string {
+	if !n.known {
+		return "new"
+	}
+	return signedSize(n.size - n.prev)
+}
+
+// buildBrowseTree assembles the tree rooted at basePath from the latest
+// records, current, and those at --since, previous. Directories recorded
+// then but gone now count towards the change of the nearest directory above
+// them that is sized from its children.
+func buildBrowseTree(basePath string, current, previous []storage.UsageRecord) *browseNode {
+	root := &browseNode{name: basePath, path: basePath}
+	nodes := map[string]*browseNode{basePath: root}
+
+	var getNode func(path string) *browseNode
+	getNode = func(path string) *browseNode {
+		if n, ok := nodes[path]; ok {
+			return n
+		}
+		parent := getNode(filepath.Dir(path))
+		n := &browseNode{name: filepath.Base(path), path: path, parent: parent}
+		nodes[path] = n
+		parent.children = append(parent.children, n)
+		return n
+	}
+
+	under := func(path string) bool {
+		rel, err := filepath.Rel(basePath, path)
+		return err == nil && rel != ".." && !strings.HasPrefix(rel, "../")
+	}
+	for _, r := range current {
+		p := filepath.Clean(r.Directory)
+		if !under(p) {
+			continue
+		}
+		n := getNode(p)
+		n.measured = true
+		n.size = r.SizeBytes
+		n.scanned = r.RecordedAt
+	}
+	for _, r := range previous {
+		p := filepath.Clean(r.Directory)
+		if !under(p) {
+			continue
+		}
+		if n, ok := nodes[p]; ok {
+			if n.measured {
+				n.prev, n.known = r.SizeBytes, true
+			}
+			continue
+		}
+		// Gone since: charge it to the nearest directory still shown
+		for p != basePath {
+			p = filepath.Dir(p)
+			if n, ok := nodes[p]; ok {
+				if !n.measured {
+					n.gone += r.SizeBytes
+					n.known = true
+				}
+				break
+			}
+		}
+	}
+
+	rollupBrowseTree(root)
+	return root
+}
+
+// rollupBrowseTree sizes the nodes that were not measured from their
+// children.
+func rollupBrowseTree(n *browseNode) {
+	for _, c := range n.children {
+		rollupBrowseTree(c)
+	}
+	if n.measured {
+		return
+	}
+	n.prev = n.gone
+	for _, c := range n.children {
+		n.size += c.size
+		n.prev += c.prev
+		n.known = n.known || c.known
+		if n.scanned.IsZero() || c.scanned.Before(n.scanned) {
+			n.scanned = c.scanned
+		}
+	}
+}
+
+// Widths of the browser's fixed columns.
+const (
+	browseBarWidth     = 10
+	browseScannedWidth = 9
+)
+
+// browseModel is the state of the browser.
+type browseModel struct {
+	root *browseNode
+	span time.Duration // how long ago --since is
+
+	dir            *browseNode   // directory being shown
+	entries        []*browseNode // its children, in display order
+	sortBy         string        // "size", "name" or "change"
+	cursor, offset int
+	width, height  int
+}
+
+// newBrowseModel returns a browser of the tree root, comparing sizes with
+// those span ago.
+func newBrowseModel(root *browseNode, span time.Duration) *browseModel {
+	m := &browseModel{root: root, span: span, sortBy: "size"}
+	m.openDir(root, nil)
+	return m
+}
+
+func (m *browseModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m *browseModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		m.scroll()
+	case tea.KeyMsg:
+		return m, m.handleKey(msg.String())
+	}
+	return m, nil
+}
+
+// handleKey acts on a key press.
+func (m *browseModel) handleKey(key string) tea.Cmd {
+	page := m.rows()
+	switch key {
+	case "q", "ctrl+c":
+		return tea.Quit
+	case "up", "k":
+		m.cursor--
+	case "down", "j":
+		m.cursor++
+	case "pgup":
+		m.cursor -= page
+	case "pgdown", " ":
+		m.cursor += page
+	case "home", "g":
+		m.cursor = 0
+	case "end", "G":
+		m.cursor = len(m.entries) - 1
+	case "enter", "right", "l":
+		if m.cursor < len(m.entries) && len(m.entries[m.cursor].children) > 0 {
+			m.openDir(m.entries[m.cursor], nil)
+		}
+	case "esc", "left", "h", "backspace":
+		if m.dir.parent != nil {
+			m.openDir(m.dir.parent, m.dir)
+		}
+	case "s":
+		m.sortBy = "size"
+		m.sortEntries(m.selected())
+	case "n":
+		m.sortBy = "name"
+		m.sortEntries(m.selected())
+	case "c":
+		m.sortBy = "change"
+		m.sortEntries(m.selected())
+	}
+	m.scroll()
+	return nil
+}
+
+// openDir shows dir's children with the cursor on selected, or on the
+// first if nil.
+func (m *browseModel) openDir(dir, selected *browseNode) {
+	m.dir = dir
+	m.cursor, m.offset = 0, 0
+	m.sortEntries(selected)
+}
+
+// selected returns the entry under the cursor, or nil.
+func (m *browseModel) selected() *browseNode {
+	if m.cursor < len(m.entries) {
+		return m.entries[m.cursor]
+	}
+	return nil
+}
+
+// sortEntries orders the shown directory's children, keeping the cursor on
+// selected.
+func (m *browseModel) sortEntries(selected *browseNode) {
+	m.entries = append(m.entries[:0], m.dir.children...)
+	less := func(a, b *browseNode) bool { return a.size > b.size }
+	switch m.sortBy {
+	case "name":
+		less = func(a, b *browseNode) bool { return a.name < b.name }
+	case "change":
+		less = func(a, b *browseNode) bool { return a.size-a.prev > b.size-b.prev }
+	}
+	sort.SliceStable(m.entries, func(i, j int) bool { return less(m.entries[i], m.entries[j]) })
+	for i, e := range m.entries {
+		if e == selected {
+			m.cursor = i
+		}
+	}
+	m.scroll()
+}
+
+// rows returns how many entries fit on screen.
+func (m *browseModel) rows() int {
+	// Title, blank line, column header, blank line and help
+	return max(m.height-5, 1)
+}
+
+// scroll keeps the cursor in range and on screen.
+func (m *browseModel) scroll() {
+	m.cursor = max(min(m.cursor, len(m.entries)-1), 0)
+	rows := m.rows()
+	if m.cursor < m.offset {
+		m.offset = m.cursor
+	}
+	if m.cursor >= m.offset+rows {
+		m.offset = m.cursor - rows + 1
+	}
+}
+
+func (m *browseModel) View() string {
+	if m.width == 0 {
+		return ""
+	}
+	var b strings.Builder
+	d := m.dir
+	b.WriteString(tuiTitleStyle.Render(truncateLeft(d.path, max(m.width/2, 16))))
+	b.WriteString(fmt.Sprintf("  %s in %d entries, %s over the last %s · sorted by %s\n\n",
+		humanize.FormatSize(d.size), len(d.children), d.change(), durationText(m.span), m.sortBy))
+
+	nameWidth := max(m.width-tuiSizeWidth-tuiChangeWidth-browseBarWidth-browseScannedWidth-6, 16)
+	b.WriteString(tuiHeaderStyle.Render(m.row(nameWidth, "SIZE", "CHANGE", "", "SCANNED", "NAME")) + "\n")
+
+	var largest int64
+	for _, e := range m.entries {
+		largest = max(largest, e.size)
+	}
+	rows := m.rows()
+	for i := m.offset; i < len(m.entries) && i < m.offset+rows; i++ {
+		e := m.entries[i]
+		name := e.name
+		if len(e.children) > 0 {
+			name += "/"
+		}
+		line := m.row(nameWidth, humanize.FormatSize(e.size), e.change(), sizeBar(e.size, largest),
+			sinceText(e.scanned)+" ago", name)
+		if i == m.cursor {
+			line = tuiSelectedStyle.Render(line)
+		}
+		b.WriteString(line + "\n")
+	}
+	for i := len(m.entries) - m.offset; i < rows; i++ {
+		b.WriteString("\n")
+	}
+
+	position := ""
+	if len(m.entries) > 0 {
+		position = fmt.Sprintf("%d/%d · ", m.cursor+1, len(m.entries))
+	}
+	b.WriteString("\n" + tuiHelpStyle.Render(position+"enter: open · esc: up · s/n/c: sort by size/name/change · q: quit"))
+	return b.String()
+}
+
+// row lays out one line of the browser's columns.
+func (m *browseModel) row(nameWidth int, size, change, bar, scanned, name string) string {
+	return fmt.Sprintf("%s %s %s %s %s",
+		padLeft(size, tuiSizeWidth),
+		padLeft(change, tuiChangeWidth),
+		padRight(bar, browseBarWidth+2),
+		padLeft(scanned, browseScannedWidth),
+		truncateRight(name, nameWidth),
+	)
+}
+
+// sizeBar draws size as a bar relative to largest.
+func sizeBar(size, largest int64) string {
+	filled := 0
+	if largest > 0 {
+		filled = int(size * browseBarWidth / largest)
+	}
+	return "[" + strings.Repeat("#", filled) + strings.Repeat(" ", browseBarWidth-filled) + "]"
+}
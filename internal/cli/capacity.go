@@ -0,0 +1,196 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/jgalley/usgmon/internal/humanize"
+	"github.com/jgalley/usgmon/internal/timespec"
+	"github.com/jgalley/usgmon/pkg/storage"
+	"github.com/spf13/cobra"
+)
+
+var (
+	capacitySince  string
+	capacityFormat string
+)
+
+var capacityCmd = &cobra.Command{
+	Use:   "capacity <base-path>",
+	Short: "Show filesystem capacity and project when it fills up",
+	Long: `Show the capacity of the filesystem holding a base path as recorded by its
+latest scan: size, used and available space, and how much of the used space
+is under the base path. The growth of used space over --since is fitted
+with least squares and projected forward to estimate when the filesystem
+is full.
+
+Capacity is recorded each time a scan of the path completes, in the daemon
+or with scan --store.
+
+Examples:
+  usgmon capacity /www/users
+  usgmon capacity /home --since 90d
+  usgmon capacity /home --format json`,
+	Args: cobra.ExactArgs(1),
+	RunE: runCapacity,
+}
+
+func init() {
+	capacityCmd.Flags().StringVar(&capacitySince, "since", "30d", "start of the history to fit growth to (YYYY-MM-DD or relative like 48h, 3d, 2w)")
+	capacityCmd.Flags().StringVar(&capacityFormat, "format", "text", "output format (text, json)")
+}
+
+// capacityReport is the capacity of a base path's filesystem and its
+// projection.
+type capacityReport struct {
+	latest    storage.FilesystemStats
+	samples   int
+	since     time.Time // oldest sample fitted
+	perDay    float64   // growth of used space in bytes per day
+	monitored *int64    // total of the base path's latest records, nil if none
+	fullAt    *time.Time
+}
+
+func runCapacity(cmd *cobra.Command, args []string) error {
+	basePath := filepath.Clean(args[0])
+
+	now := time.Now()
+	since, err := timespec.Parse(capacitySince, now, false)
+	if err != nil {
+		return fmt.Errorf("invalid --since value: %w", err)
+	}
+
+	ctx := context.Background()
+	_, store, err := openStorage(ctx)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	stats, err := store.QueryFilesystemStats(ctx, basePath, since)
+	if err != nil {
+		return fmt.Errorf("querying filesystem stats: %w", err)
+	}
+	if len(stats) == 0 {
+		fmt.Printf("No filesystem capacity recorded since %s\n", since.Local().Format("2006-01-02 15:04"))
+		return nil
+	}
+
+	// Fit only the host that made the latest measurement, oldest first
+	rep := capacityReport{latest: stats[0]}
+	var points []sizePoint
+	for i := len(stats) - 1; i >= 0; i-- {
+		if stats[i].Hostname == rep.latest.Hostname {
+			points = append(points, sizePoint{at: stats[i].RecordedAt, size: stats[i].UsedBytes})
+		}
+	}
+	rep.samples, rep.since = len(points), points[0].at
+	if len(points) >= 2 {
+		rep.perDay = linearGrowth(points)
+		if rep.perDay > 0 {
+			at := rep.latest.RecordedAt.Add(time.Duration(float64(rep.latest.AvailableBytes) / rep.perDay * float64(24*time.Hour)))
+			rep.fullAt = &at
+		}
+	}
+
+	snapshot, err := store.GetSnapshotAt(ctx, basePath, "", now)
+	if err != nil {
+		return fmt.Errorf("querying snapshot: %w", err)
+	}
+	if len(snapshot) > 0 {
+		var total int64
+		for _, r := range snapshot {
+			total += r.SizeBytes
+		}
+		rep.monitored = &total
+	}
+
+	if capacityFormat == "json" {
+		return outputCapacityJSON(rep)
+	}
+	outputCapacityText(rep, now)
+	return nil
+}
+
+// usedPercent returns the share of the space usable by unprivileged users
+// that is used, as df reports it.
+func usedPercent(st storage.FilesystemStats) float64 {
+	usable := st.UsedBytes + st.AvailableBytes
+	if usable <= 0 {
+		return 0
+	}
+	return 100 * float64(st.UsedBytes) / float64(usable)
+}
+
+func outputCapacityText(rep capacityReport, now time.Time) {
+	st := rep.latest
+	fmt.Printf("Path:        %s\n", st.BasePath)
+	fmt.Printf("Recorded:    %s (scan %s)\n", st.RecordedAt.Local().Format("2006-01-02 15:04"), st.ScanID)
+	fmt.Printf("Size:        %s\n", humanize.FormatSize(st.TotalBytes))
+	fmt.Printf("Used:        %s (%.0f%%)\n", humanize.FormatSize(st.UsedBytes), usedPercent(st))
+	fmt.Printf("Available:   %s\n", humanize.FormatSize(st.AvailableBytes))
+	if rep.monitored != nil && st.UsedBytes > 0 {
+		fmt.Printf("Base path:   %s (%.0f%% of used)\n", humanize.FormatSize(*rep.monitored), 100*float64(*rep.monitored)/float64(st.UsedBytes))
+	}
+
+	if rep.samples < 2 {
+		fmt.Println("Growth:      unknown (one sample)")
+		return
+	}
+	fmt.Printf("Growth:      %s/day over %d samples since %s\n", signedSize(int64(rep.perDay)), rep.samples, rep.since.Local().Format("2006-01-02"))
+	if rep.fullAt == nil {
+		fmt.Println("Full:        not filling up")
+		return
+	}
+	days, unit := math.Ceil(max(rep.fullAt.Sub(now), 0).Hours()/24), "days"
+	if days == 1 {
+		unit = "day"
+	}
+	fmt.Printf("Full:        in %.0f %s (%s)\n", days, unit, rep.fullAt.Local().Format("2006-01-02"))
+}
+
+type capacityJSON struct {
+	BasePath       string   `json:"base_path"`
+	RecordedAt     string   `json:"recorded_at"`
+	ScanID         string   `json:"scan_id"`
+	Hostname       string   `json:"hostname,omitempty"`
+	TotalBytes     int64    `json:"total_bytes"`
+	UsedBytes      int64    `json:"used_bytes"`
+	AvailableBytes int64    `json:"available_bytes"`
+	UsedPercent    float64  `json:"used_percent"`
+	MonitoredBytes *int64   `json:"monitored_bytes,omitempty"`
+	Samples        int      `json:"samples"`
+	BytesPerDay    *float64 `json:"bytes_per_day,omitempty"`
+	FullAt         string   `json:"full_at,omitempty"`
+}
+
+func outputCapacityJSON(rep capacityReport) error {
+	st := rep.latest
+	out := capacityJSON{
+		BasePath:       st.BasePath,
+		RecordedAt:     st.RecordedAt.Format(time.RFC3339),
+		ScanID:         st.ScanID,
+		Hostname:       st.Hostname,
+		TotalBytes:     st.TotalBytes,
+		UsedBytes:      st.UsedBytes,
+		AvailableBytes: st.AvailableBytes,
+		UsedPercent:    usedPercent(st),
+		MonitoredBytes: rep.monitored,
+		Samples:        rep.samples,
+	}
+	if rep.samples >= 2 {
+		out.BytesPerDay = &rep.perDay
+	}
+	if rep.fullAt != nil {
+		out.FullAt = rep.fullAt.Format(time.RFC3339)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
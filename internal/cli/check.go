@@ -0,0 +1,313 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jgalley/usgmon/internal/humanize"
+	"github.com/jgalley/usgmon/internal/timespec"
+	"github.com/jgalley/usgmon/pkg/storage"
+	"github.com/spf13/cobra"
+)
+
+// Monitoring plugin exit codes, as used by Nagios, Icinga and NRPE.
+const (
+	checkOK       = 0
+	checkWarning  = 1
+	checkCritical = 2
+	checkUnknown  = 3
+)
+
+var checkStateNames = [...]string{"OK", "WARNING", "CRITICAL", "UNKNOWN"}
+
+var (
+	checkWarn         string
+	checkCrit         string
+	checkGrowthWarn   string
+	checkGrowthCrit   string
+	checkGrowthWindow string
+	checkMaxAge       time.Duration
+)
+
+var checkCmd = &cobra.Command{
+	Use:   "check <directory>",
+	Short: "Check a directory's stored usage, as a Nagios/Icinga plugin",
+	Long: `Check the latest stored size of a directory against thresholds and print a
+one-line status with performance data, exiting 0 (OK), 1 (WARNING),
+2 (CRITICAL) or 3 (UNKNOWN) so it can run as a Nagios, Icinga or NRPE check.
+
+The check only reads the database; the daemon keeps it current. Growth is
+the size change over --growth-window (default 1d), given as SIZE/PERIOD
+where PERIOD is h, d, w or a duration such as 12h; a bare SIZE is per day.
+Growth is not checked until the directory has a window's worth of history.
+
+With --max-age, the check is UNKNOWN when the directory's base path has not
+completed a scan for that long, so a stopped daemon does not leave stale
+results looking healthy.
+
+Examples:
+  usgmon check /www/users/bob.com --warn 500G --crit 1T
+  usgmon check /www/users/bob.com --warn 500G --crit 1T --growth-warn 50G/d
+  usgmon check /www/users/bob.com --growth-crit 10G/h --growth-window 6h
+  usgmon check /www/users/bob.com --crit 1T --max-age 3h`,
+	RunE:          runCheck,
+	SilenceErrors: true, // every outcome is reported on stdout as a plugin status line
+}
+
+func init() {
+	checkCmd.Flags().StringVar(&checkWarn, "warn", "", "warning size threshold (e.g., \"500G\")")
+	checkCmd.Flags().StringVar(&checkCrit, "crit", "", "critical size threshold (e.g., \"1T\")")
+	checkCmd.Flags().StringVar(&checkGrowthWarn, "growth-warn", "", "warning growth threshold (e.g., \"50G/d\", \"2G/h\")")
+	checkCmd.Flags().StringVar(&checkGrowthCrit, "growth-crit", "", "critical growth threshold (e.g., \"100G/d\")")
+	checkCmd.Flags().StringVar(&checkGrowthWindow, "growth-window", "1d", "time span growth is measured over (e.g., 6h, 1d, 1w)")
+	checkCmd.Flags().DurationVar(&checkMaxAge, "max-age", 0, "UNKNOWN if the base path has not completed a scan within this long (0 = no limit)")
+}
+
+// checkResult is the outcome of a check.
+type checkResult struct {
+	state   int
+	message string
+	perf    []string
+}
+
+func runCheck(cmd *cobra.Command, args []string) error {
+	// Argument errors are reported as UNKNOWN like any other failure
+	var res checkResult
+	var err error
+	if len(args) != 1 {
+		err = fmt.Errorf("expected one directory, got %d arguments", len(args))
+	} else {
+		res, err = evaluateCheck(args[0])
+	}
+	if err != nil {
+		res = checkResult{state: checkUnknown, message: err.Error()}
+	}
+
+	line := fmt.Sprintf("USGMON %s - %s", checkStateNames[res.state], res.message)
+	if len(res.perf) > 0 {
+		line += " | " + strings.Join(res.perf, " ")
+	}
+	fmt.Println(line)
+
+	if res.state != checkOK {
+		return &ExitError{Code: res.state}
+	}
+	return nil
+}
+
+// evaluateCheck compares the stored usage of directory with the thresholds
+// given on the command line.
+func evaluateCheck(directory string) (checkResult, error) {
+	directory, err := filepath.Abs(directory)
+	if err != nil {
+		return checkResult{}, fmt.Errorf("resolving path: %w", err)
+	}
+	warn, err := parseCheckSize("--warn", checkWarn)
+	if err != nil {
+		return checkResult{}, err
+	}
+	crit, err := parseCheckSize("--crit", checkCrit)
+	if err != nil {
+		return checkResult{}, err
+	}
+	growthWarn, err := parseGrowthRate("--growth-warn", checkGrowthWarn)
+	if err != nil {
+		return checkResult{}, err
+	}
+	growthCrit, err := parseGrowthRate("--growth-crit", checkGrowthCrit)
+	if err != nil {
+		return checkResult{}, err
+	}
+	window, err := timespec.ParseDuration(checkGrowthWindow)
+	if err != nil || window <= 0 {
+		return checkResult{}, fmt.Errorf("invalid --growth-window value %q", checkGrowthWindow)
+	}
+	if warn > 0 && crit > 0 && warn > crit {
+		return checkResult{}, fmt.Errorf("--warn must not be above --crit")
+	}
+	if growthWarn > 0 && growthCrit > 0 && growthWarn > growthCrit {
+		return checkResult{}, fmt.Errorf("--growth-warn must not be above --growth-crit")
+	}
+	if warn == 0 && crit == 0 && growthWarn == 0 && growthCrit == 0 {
+		return checkResult{}, fmt.Errorf("no thresholds given; set --warn, --crit, --growth-warn or --growth-crit")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, store, err := openStorage(ctx)
+	if err != nil {
+		return checkResult{}, err
+	}
+	defer store.Close()
+
+	latest, err := store.GetLatestUsage(ctx, directory)
+	if err != nil {
+		return checkResult{}, err
+	}
+	if latest == nil {
+		return checkResult{}, fmt.Errorf("no usage recorded for %s", directory)
+	}
+	if latest.Deleted {
+		return checkResult{}, fmt.Errorf("%s no longer exists (gone since %s)", directory, latest.RecordedAt.Local().Format("2006-01-02 15:04"))
+	}
+
+	now := time.Now()
+	if checkMaxAge > 0 {
+		last, err := lastCompletedScan(ctx, store, latest.BasePath)
+		if err != nil {
+			return checkResult{}, err
+		}
+		if last.IsZero() {
+			return checkResult{}, fmt.Errorf("no completed scan of %s", latest.BasePath)
+		}
+		if age := now.Sub(last); age > checkMaxAge {
+			return checkResult{}, fmt.Errorf("last completed scan of %s was %s ago, more than --max-age %s",
+				latest.BasePath, age.Round(time.Minute), checkMaxAge)
+		}
+	}
+
+	res := checkResult{state: checkOK}
+	size := latest.SizeBytes
+	res.message = fmt.Sprintf("%s is %s", directory, humanize.FormatSize(size))
+	switch {
+	case crit > 0 && size >= crit:
+		res.state = checkCritical
+		res.message += " (over " + humanize.FormatSize(crit) + ")"
+	case warn > 0 && size >= warn:
+		res.state = checkWarning
+		res.message += " (over " + humanize.FormatSize(warn) + ")"
+	}
+	res.perf = append(res.perf, fmt.Sprintf("size=%dB;%s;%s;0", size, perfThreshold(warn), perfThreshold(crit)))
+
+	if growthWarn > 0 || growthCrit > 0 {
+		perDay, ok, err := growthPerDay(ctx, store, latest, now, window)
+		if err != nil {
+			return checkResult{}, err
+		}
+		if ok {
+			res.message += fmt.Sprintf(", %s/day over the last %s", formatGrowth(perDay), formatWindow(window))
+			switch {
+			case growthCrit > 0 && perDay >= growthCrit:
+				res.state = checkCritical
+				res.message += " (faster than " + humanize.FormatSize(growthCrit) + "/day)"
+			case growthWarn > 0 && perDay >= growthWarn:
+				res.state = max(res.state, checkWarning)
+				res.message += " (faster than " + humanize.FormatSize(growthWarn) + "/day)"
+			}
+			res.perf = append(res.perf, fmt.Sprintf("growth_per_day=%dB;%s;%s", perDay, perfThreshold(growthWarn), perfThreshold(growthCrit)))
+		} else {
+			res.message += fmt.Sprintf(", growth not checked (less than %s of history)", formatWindow(window))
+		}
+	}
+
+	res.message += ", as of " + latest.RecordedAt.Local().Format("2006-01-02 15:04")
+	return res, nil
+}
+
+// growthPerDay returns how much the directory grew over the window ending
+// at now, scaled to bytes per day. Records are only written when a size
+// changes enough, so the size at the start of the window is that of the
+// newest record at or before it. ok is false when there is no such record.
+func growthPerDay(ctx context.Context, store storage.Storage, latest *storage.UsageRecord, now time.Time, window time.Duration) (int64, bool, error) {
+	start := now.Add(-window)
+	records, err := store.QueryUsage(ctx, storage.QueryOptions{Directory: latest.Directory, Until: &start, Limit: 10})
+	if err != nil {
+		return 0, false, err
+	}
+	for _, r := range records {
+		// Skip measurements taken with a file filter; they are not comparable
+		if r.FileFilter != "" {
+			continue
+		}
+		growth := latest.SizeBytes - r.SizeBytes
+		return int64(float64(growth) * float64(24*time.Hour) / float64(window)), true, nil
+	}
+	return 0, false, nil
+}
+
+// lastCompletedScan returns the start time of the most recent completed scan
+// of basePath, or the zero time if there is none.
+func lastCompletedScan(ctx context.Context, store storage.Storage, basePath string) (time.Time, error) {
+	scans, err := store.ListScans(ctx, storage.ScanListOptions{BasePath: basePath, Limit: 20})
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, sc := range scans {
+		if sc.Status == "completed" {
+			return sc.StartedAt, nil
+		}
+	}
+	return time.Time{}, nil
+}
+
+// parseCheckSize parses a size threshold flag; empty means unset (0).
+func parseCheckSize(flag, s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	n, err := humanize.ParseSize(s)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %s value %q", flag, s)
+	}
+	return n, nil
+}
+
+// parseGrowthRate parses a growth threshold such as "50G/d", "2G/h" or
+// "10G/12h" into bytes per day. A bare size is per day; empty means unset.
+func parseGrowthRate(flag, s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	sizeStr, period, found := strings.Cut(s, "/")
+	size, err := parseCheckSize(flag, sizeStr)
+	if err != nil {
+		return 0, err
+	}
+	if !found {
+		return size, nil
+	}
+
+	// Allow a bare unit: "h" means "1h"
+	if period != "" && (period[0] < '0' || period[0] > '9') {
+		period = "1" + period
+	}
+	d, err := timespec.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid %s value %q: period must be h, d, w or a duration", flag, s)
+	}
+	return int64(float64(size) * float64(24*time.Hour) / float64(d)), nil
+}
+
+// perfThreshold formats a threshold for performance data; unset is empty.
+func perfThreshold(n int64) string {
+	if n == 0 {
+		return ""
+	}
+	return fmt.Sprint(n)
+}
+
+// formatGrowth formats a size change with an explicit sign.
+func formatGrowth(bytes int64) string {
+	sign := "+"
+	if bytes < 0 {
+		sign = ""
+	}
+	return sign + humanize.FormatSize(bytes)
+}
+
+// formatWindow formats a growth window compactly in its largest whole unit.
+func formatWindow(d time.Duration) string {
+	switch {
+	case d%(24*time.Hour) == 0:
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	case d%time.Minute == 0:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	}
+	return d.String()
+}
@@ -0,0 +1,123 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jgalley/usgmon/internal/humanize"
+	"github.com/jgalley/usgmon/internal/timespec"
+	"github.com/jgalley/usgmon/pkg/storage"
+	"github.com/spf13/cobra"
+)
+
+var (
+	coldAt     string
+	coldFormat string
+)
+
+var coldCmd = &cobra.Command{
+	Use:   "cold <dir>",
+	Short: "Show how much of a directory is cold data",
+	Long: `Show a directory's size split by file age, from its most recent scan with an
+age breakdown (scan.age_breakdown, or scan --age-breakdown --store). The
+oldest bucket is the data that has gone unmodified (or unread, for atime)
+the longest, and the first candidate for a cheaper storage tier.
+
+Examples:
+  usgmon cold /projects/genomics
+  usgmon cold /projects/genomics --at 30d
+  usgmon cold /projects/genomics --format json`,
+	Args: cobra.ExactArgs(1),
+	RunE: runCold,
+}
+
+func init() {
+	coldCmd.Flags().StringVar(&coldAt, "at", "", "use the newest breakdown at or before this time (\"YYYY-MM-DD HH:MM\", YYYY-MM-DD, or relative like 48h, 3d)")
+	coldCmd.Flags().StringVar(&coldFormat, "format", "text", "output format (text, json)")
+}
+
+func runCold(cmd *cobra.Command, args []string) error {
+	dir := filepath.Clean(args[0])
+
+	opts := storage.QueryOptions{
+		Directory: dir,
+		ByAge:     true,
+		Limit:     1,
+	}
+	if coldAt != "" {
+		at, err := timespec.Parse(coldAt, time.Now(), true)
+		if err != nil {
+			return fmt.Errorf("invalid --at value: %w", err)
+		}
+		opts.Until = &at
+	}
+
+	ctx := context.Background()
+	_, store, err := openStorage(ctx)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	records, err := store.QueryUsage(ctx, opts)
+	if err != nil {
+		return fmt.Errorf("querying age usage: %w", err)
+	}
+	if len(records) == 0 {
+		fmt.Println("No age breakdown recorded")
+		return nil
+	}
+
+	switch coldFormat {
+	case "json":
+		return outputColdJSON(records[0])
+	default:
+		return outputColdText(records[0])
+	}
+}
+
+func outputColdText(r storage.UsageRecord) error {
+	var total int64
+	for _, a := range r.AgeUsage {
+		total += a.SizeBytes
+	}
+	fmt.Printf("%s: %s as of %s, by %s\n\n", r.Directory, humanize.FormatSize(r.SizeBytes),
+		r.RecordedAt.Local().Format("2006-01-02 15:04"), r.AgeUsage[0].Basis)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "AGE\tSIZE\tSHARE")
+	fmt.Fprintln(w, "---\t----\t-----")
+	for _, a := range r.AgeUsage {
+		share := 0.0
+		if total > 0 {
+			share = float64(a.SizeBytes) / float64(total) * 100
+		}
+		fmt.Fprintf(w, "%s\t%s\t%.1f%%\n", a.Label(), humanize.FormatSize(a.SizeBytes), share)
+	}
+	return w.Flush()
+}
+
+type coldJSON struct {
+	Directory  string             `json:"directory"`
+	SizeBytes  int64              `json:"size_bytes"`
+	RecordedAt string             `json:"recorded_at"`
+	ScanID     string             `json:"scan_id"`
+	Ages       []storage.AgeUsage `json:"ages"`
+}
+
+func outputColdJSON(r storage.UsageRecord) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(coldJSON{
+		Directory:  r.Directory,
+		SizeBytes:  r.SizeBytes,
+		RecordedAt: r.RecordedAt.Format(time.RFC3339),
+		ScanID:     r.ScanID,
+		Ages:       r.AgeUsage,
+	})
+}
@@ -0,0 +1,153 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// column describes one selectable output column for rows of type T.
+type column[T any] struct {
+	Name   string              // name used with --columns and as the JSON key
+	Header string              // text output header
+	Text   func(T) string      // text/CSV rendering
+	JSON   func(T) interface{} // JSON rendering
+}
+
+// selectColumns resolves a comma-separated --columns spec against the known
+// columns, preserving the requested order. An empty spec selects defaults.
+func selectColumns[T any](all []column[T], spec string, defaults []string) ([]column[T], error) {
+	names := defaults
+	if strings.TrimSpace(spec) != "" {
+		names = strings.Split(spec, ",")
+	}
+
+	byName := make(map[string]column[T], len(all))
+	known := make([]string, 0, len(all))
+	for _, c := range all {
+		byName[c.Name] = c
+		known = append(known, c.Name)
+	}
+
+	selected := make([]column[T], 0, len(names))
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		c, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown column %q (valid: %s)", name, strings.Join(known, ", "))
+		}
+		selected = append(selected, c)
+	}
+
+	return selected, nil
+}
+
+// writeColumnsText renders rows as an aligned table with a header and underline.
+func writeColumnsText[T any](out io.Writer, cols []column[T], rows []T) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+
+	headers := make([]string, len(cols))
+	rules := make([]string, len(cols))
+	for i, c := range cols {
+		headers[i] = c.Header
+		rules[i] = strings.Repeat("-", len(c.Header))
+	}
+	fmt.Fprintln(w, strings.Join(headers, "\t"))
+	fmt.Fprintln(w, strings.Join(rules, "\t"))
+
+	fields := make([]string, len(cols))
+	for _, row := range rows {
+		for i, c := range cols {
+			fields[i] = c.Text(row)
+		}
+		fmt.Fprintln(w, strings.Join(fields, "\t"))
+	}
+
+	return w.Flush()
+}
+
+// writeColumnsJSON renders rows as a JSON array of objects holding only the
+// selected columns, with keys in the selected order.
+func writeColumnsJSON[T any](cols []column[T], rows []T) error {
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	for i, row := range rows {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteByte('{')
+		for j, c := range cols {
+			if j > 0 {
+				buf.WriteByte(',')
+			}
+			key, _ := json.Marshal(c.Name)
+			val, err := json.Marshal(c.JSON(row))
+			if err != nil {
+				return fmt.Errorf("encoding column %s: %w", c.Name, err)
+			}
+			buf.Write(key)
+			buf.WriteByte(':')
+			buf.Write(val)
+		}
+		buf.WriteByte('}')
+	}
+	buf.WriteByte(']')
+
+	var out bytes.Buffer
+	if err := json.Indent(&out, buf.Bytes(), "", "  "); err != nil {
+		return err
+	}
+	out.WriteByte('\n')
+	_, err := out.WriteTo(os.Stdout)
+	return err
+}
+
+// writeColumnsCSV renders rows as CSV with the column names as header. Values
+// are the raw JSON values (sizes in bytes, RFC 3339 times), with missing
+// values left empty.
+func writeColumnsCSV[T any](out io.Writer, cols []column[T], rows []T) error {
+	w := csv.NewWriter(out)
+
+	fields := make([]string, len(cols))
+	for i, c := range cols {
+		fields[i] = c.Name
+	}
+	w.Write(fields)
+
+	for _, row := range rows {
+		for i, c := range cols {
+			fields[i] = csvValue(c.JSON(row))
+		}
+		w.Write(fields)
+	}
+
+	w.Flush()
+	return w.Error()
+}
+
+// csvValue formats a JSON column value for CSV: strings unquoted, null
+// empty, anything else as its JSON encoding.
+func csvValue(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil || string(b) == "null" {
+		return ""
+	}
+	var str string
+	if json.Unmarshal(b, &str) == nil {
+		return str
+	}
+	return string(b)
+}
+
+// orDash returns s, or "-" when s is empty, for text columns.
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,36 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// confirm asks the user to approve a destructive action. It returns true
+// immediately when assumeYes is set. Without a terminal on stdin there is
+// nobody to ask, so the action is refused unless --yes was passed.
+func confirm(prompt string, assumeYes bool) (bool, error) {
+	if assumeYes {
+		return true, nil
+	}
+
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return false, fmt.Errorf("refusing to proceed without --yes in non-interactive mode")
+	}
+
+	fmt.Fprintf(os.Stderr, "%s [y/N]: ", prompt)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false, fmt.Errorf("reading confirmation: %w", err)
+	}
+
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	default:
+		return false, nil
+	}
+}
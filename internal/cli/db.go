@@ -0,0 +1,238 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jgalley/usgmon/internal/humanize"
+	"github.com/jgalley/usgmon/pkg/storage"
+	"github.com/spf13/cobra"
+)
+
+var (
+	dbInfoFormat  string
+	dbCheckFormat string
+)
+
+var dbCmd = &cobra.Command{
+	Use:   "db",
+	Short: "Inspect the usage database",
+	Long: `Inspect the usage database.
+
+Examples:
+  usgmon db info
+  usgmon db info --format json
+  usgmon db check`,
+}
+
+var dbInfoCmd = &cobra.Command{
+	Use:   "info",
+	Short: "Show database file, schema and content summary",
+	Args:  cobra.NoArgs,
+	RunE:  runDBInfo,
+}
+
+var dbCheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Verify database integrity",
+	Long: `Verify the database file and the consistency of its contents: SQLite's
+integrity and foreign key checks, plus usage records with negative sizes,
+records or scans timestamped in the future, and scans that completed before
+they started.
+
+Exits non-zero if any problem is found, so it can be used in health checks.`,
+	Args: cobra.NoArgs,
+	RunE: runDBCheck,
+}
+
+func init() {
+	dbInfoCmd.Flags().StringVar(&dbInfoFormat, "format", "text", "output format (text, json)")
+	dbCheckCmd.Flags().StringVar(&dbCheckFormat, "format", "text", "output format (text, json)")
+
+	dbCmd.AddCommand(dbInfoCmd)
+	dbCmd.AddCommand(dbCheckCmd)
+}
+
+func runDBInfo(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+	cfg, store, err := openStorage(ctx)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	info, err := store.Info(ctx)
+	if err != nil {
+		return fmt.Errorf("reading database info: %w", err)
+	}
+
+	fileSize, err := dbFileSize(cfg.Database.Path)
+	if err != nil {
+		return fmt.Errorf("reading database file: %w", err)
+	}
+
+	switch dbInfoFormat {
+	case "json":
+		return outputDBInfoJSON(cfg.Database.Path, fileSize, info)
+	default:
+		return outputDBInfoText(cfg.Database.Path, fileSize, info)
+	}
+}
+
+// dbFileSize returns the on-disk size of the database including its WAL file.
+func dbFileSize(path string) (int64, error) {
+	st, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	size := st.Size()
+	if wal, err := os.Stat(path + "-wal"); err == nil {
+		size += wal.Size()
+	}
+	return size, nil
+}
+
+func outputDBInfoText(path string, fileSize int64, info *storage.DBInfo) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Path:\t%s\n", path)
+	fmt.Fprintf(w, "Size on disk:\t%s\n", humanize.FormatSize(fileSize))
+	fmt.Fprintf(w, "SQLite version:\t%s\n", info.SQLiteVersion)
+	fmt.Fprintf(w, "Schema version:\t%d\n", info.SchemaVersion)
+	fmt.Fprintf(w, "Usage records:\t%d\n", info.UsageRecords)
+	fmt.Fprintf(w, "Scans:\t%d\n", info.Scans)
+	fmt.Fprintf(w, "Daily rollups:\t%d\n", info.Rollups)
+	if info.EarliestRecord != nil {
+		fmt.Fprintf(w, "Earliest record:\t%s\n", info.EarliestRecord.Local().Format("2006-01-02 15:04"))
+		fmt.Fprintf(w, "Latest record:\t%s\n", info.LatestRecord.Local().Format("2006-01-02 15:04"))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if len(info.BasePaths) == 0 {
+		return nil
+	}
+
+	fmt.Println()
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "BASE PATH\tRECORDS")
+	fmt.Fprintln(w, "---------\t-------")
+	for _, bp := range info.BasePaths {
+		fmt.Fprintf(w, "%s\t%d\n", bp.BasePath, bp.Records)
+	}
+	return w.Flush()
+}
+
+type dbInfoJSON struct {
+	Path           string           `json:"path"`
+	SizeBytes      int64            `json:"size_bytes"`
+	SQLiteVersion  string           `json:"sqlite_version"`
+	SchemaVersion  int              `json:"schema_version"`
+	UsageRecords   int64            `json:"usage_records"`
+	Scans          int64            `json:"scans"`
+	Rollups        int64            `json:"daily_rollups"`
+	EarliestRecord string           `json:"earliest_record,omitempty"`
+	LatestRecord   string           `json:"latest_record,omitempty"`
+	BasePaths      []dbBasePathJSON `json:"base_paths"`
+}
+
+type dbBasePathJSON struct {
+	BasePath string `json:"base_path"`
+	Records  int64  `json:"records"`
+}
+
+func outputDBInfoJSON(path string, fileSize int64, info *storage.DBInfo) error {
+	out := dbInfoJSON{
+		Path:          path,
+		SizeBytes:     fileSize,
+		SQLiteVersion: info.SQLiteVersion,
+		SchemaVersion: info.SchemaVersion,
+		UsageRecords:  info.UsageRecords,
+		Scans:         info.Scans,
+		Rollups:       info.Rollups,
+		BasePaths:     make([]dbBasePathJSON, len(info.BasePaths)),
+	}
+	if info.EarliestRecord != nil {
+		out.EarliestRecord = info.EarliestRecord.Format(time.RFC3339)
+		out.LatestRecord = info.LatestRecord.Format(time.RFC3339)
+	}
+	for i, bp := range info.BasePaths {
+		out.BasePaths[i] = dbBasePathJSON{BasePath: bp.BasePath, Records: bp.Records}
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+func runDBCheck(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+	_, store, err := openStorage(ctx)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	problems, err := store.CheckIntegrity(ctx)
+	if err != nil {
+		return fmt.Errorf("checking database: %w", err)
+	}
+
+	switch dbCheckFormat {
+	case "json":
+		err = outputDBCheckJSON(problems)
+	default:
+		err = outputDBCheckText(problems)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("database check found %d problem(s)", len(problems))
+	}
+	return nil
+}
+
+func outputDBCheckText(problems []storage.IntegrityProblem) error {
+	if len(problems) == 0 {
+		fmt.Println("OK")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CHECK\tPROBLEM")
+	fmt.Fprintln(w, "-----\t-------")
+	for _, p := range problems {
+		fmt.Fprintf(w, "%s\t%s\n", p.Check, p.Detail)
+	}
+	return w.Flush()
+}
+
+type dbCheckJSON struct {
+	OK       bool                 `json:"ok"`
+	Problems []dbCheckProblemJSON `json:"problems"`
+}
+
+type dbCheckProblemJSON struct {
+	Check  string `json:"check"`
+	Detail string `json:"detail"`
+}
+
+func outputDBCheckJSON(problems []storage.IntegrityProblem) error {
+	out := dbCheckJSON{
+		OK:       len(problems) == 0,
+		Problems: make([]dbCheckProblemJSON, len(problems)),
+	}
+	for i, p := range problems {
+		out.Problems[i] = dbCheckProblemJSON{Check: p.Check, Detail: p.Detail}
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
@@ -0,0 +1,305 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/jgalley/usgmon/internal/humanize"
+	"github.com/jgalley/usgmon/internal/timespec"
+	"github.com/jgalley/usgmon/pkg/storage"
+	"github.com/spf13/cobra"
+)
+
+var (
+	diffAt        []string
+	diffMinChange string
+	diffLimit     int
+	diffAll       bool
+	diffFormat    string
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff <scan-id> <scan-id>",
+	Short: "Compare directory sizes between two scans",
+	Long: `Compare the size of every directory under a base path between two scans,
+including directories that appeared or disappeared in between, largest
+change first.
+
+Each side is the tree as of the end of its scan: the latest record of each
+directory up to then. With scan.min_change_percent or min_change_bytes a
+scan only records the directories that changed, so this also covers the
+directories it left unrecorded. A directory removed from the filesystem
+shows as removed once a deletion marker is recorded for it
+(scan.reconcile_deleted). Both scans must be of the same base path.
+
+Instead of scan IDs, give a base path and one or two --at times to compare
+the tree at those times; with one, it is compared with the latest records.
+
+Examples:
+  usgmon diff 3f2a9c1e-... 8b41d07a-...
+  usgmon diff 3f2a9c1e-... 8b41d07a-... --min-change 1G
+  usgmon diff /www/users --at 2026-01-01 --at 2026-02-01
+  usgmon diff /www/users --at 7d --format json`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: runDiff,
+}
+
+func init() {
+	diffCmd.Flags().StringArrayVar(&diffAt, "at", nil, "compare the base path at this time (\"YYYY-MM-DD HH:MM\", YYYY-MM-DD, or relative like 48h, 3d); give twice for two times")
+	diffCmd.Flags().StringVar(&diffMinChange, "min-change", "0", "only directories that changed by at least this much (e.g., \"100M\", \"1G\")")
+	diffCmd.Flags().IntVar(&diffLimit, "limit", 0, "maximum number of directories to show (0 for all)")
+	diffCmd.Flags().BoolVar(&diffAll, "all", false, "also show directories whose size did not change")
+	diffCmd.Flags().StringVar(&diffFormat, "format", "text", "output format (text, json, csv)")
+}
+
+// diffSide is one end of a comparison.
+type diffSide struct {
+	label string
+	at    time.Time
+}
+
+func runDiff(cmd *cobra.Command, args []string) error {
+	minChange, err := humanize.ParseSize(diffMinChange)
+	if err != nil {
+		return fmt.Errorf("invalid --min-change value: %w", err)
+	}
+	switch diffFormat {
+	case "text", "json", "csv":
+	default:
+		return fmt.Errorf("invalid --format value %q (valid: text, json, csv)", diffFormat)
+	}
+
+	ctx := context.Background()
+	_, store, err := openStorage(ctx)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	var basePath string
+	var from, to diffSide
+	if len(diffAt) > 0 {
+		if len(args) != 1 || len(diffAt) > 2 {
+			return fmt.Errorf("--at takes a single base path and is given once or twice")
+		}
+		basePath = filepath.Clean(args[0])
+		now := time.Now()
+		times := make([]time.Time, len(diffAt))
+		for i, spec := range diffAt {
+			// A bare date means "as of the end of that day"
+			if times[i], err = timespec.Parse(spec, now, true); err != nil {
+				return fmt.Errorf("invalid --at value: %w", err)
+			}
+		}
+		from = diffSide{label: times[0].Local().Format("2006-01-02 15:04"), at: times[0]}
+		to = diffSide{label: "latest", at: now}
+		if len(times) == 2 {
+			to = diffSide{label: times[1].Local().Format("2006-01-02 15:04"), at: times[1]}
+		}
+	} else {
+		if len(args) != 2 {
+			return fmt.Errorf("give two scan IDs, or a base path with --at")
+		}
+		var scans [2]storage.Scan
+		for i, id := range args {
+			found, err := store.ListScans(ctx, storage.ScanListOptions{ScanID: id, Limit: 1})
+			if err != nil {
+				return fmt.Errorf("looking up scan: %w", err)
+			}
+			if len(found) == 0 {
+				return fmt.Errorf("scan %s not found", id)
+			}
+			if found[0].CompletedAt == nil {
+				return fmt.Errorf("scan %s has not finished", id)
+			}
+			scans[i] = found[0]
+		}
+		if scans[0].BasePath != scans[1].BasePath {
+			return fmt.Errorf("scans are of different base paths: %s and %s", scans[0].BasePath, scans[1].BasePath)
+		}
+		basePath = scans[0].BasePath
+		from = diffSide{label: scanLabel(scans[0]), at: *scans[0].CompletedAt}
+		to = diffSide{label: scanLabel(scans[1]), at: *scans[1].CompletedAt}
+	}
+
+	before, err := store.GetSnapshotAt(ctx, basePath, "", from.at)
+	if err != nil {
+		return fmt.Errorf("querying snapshot: %w", err)
+	}
+	after, err := store.GetSnapshotAt(ctx, basePath, "", to.at)
+	if err != nil {
+		return fmt.Errorf("querying snapshot: %w", err)
+	}
+
+	diffs := diffSnapshots(before, after)
+	var shown []dirDiff
+	for _, d := range diffs {
+		if (d.status() == "unchanged" && !diffAll) || abs(d.change()) < minChange {
+			continue
+		}
+		shown = append(shown, d)
+	}
+	if diffLimit > 0 && len(shown) > diffLimit {
+		shown = shown[:diffLimit]
+	}
+
+	switch diffFormat {
+	case "json":
+		return writeColumnsJSON(diffColumns, shown)
+	case "csv":
+		return writeColumnsCSV(os.Stdout, diffColumns, shown)
+	}
+
+	fmt.Printf("From: %s\nTo:   %s\n\n", from.label, to.label)
+	if len(shown) == 0 {
+		fmt.Println("No changes found")
+	} else if err := writeColumnsText(os.Stdout, diffColumns, shown); err != nil {
+		return err
+	}
+	fmt.Println()
+	fmt.Println(diffSummary(diffs))
+	return nil
+}
+
+// scanLabel describes a scan for the diff header.
+func scanLabel(sc storage.Scan) string {
+	return fmt.Sprintf("scan %s (%s, finished %s)", sc.ScanID, sc.BasePath, sc.CompletedAt.Local().Format("2006-01-02 15:04"))
+}
+
+// dirDiff is a directory's size on both sides of a comparison.
+type dirDiff struct {
+	Directory string
+	Before    *int64 // nil if the directory was not there
+	After     *int64 // nil if the directory is gone
+}
+
+// change returns how much the directory grew.
+func (d dirDiff) change() int64 {
+	var change int64
+	if d.After != nil {
+		change += *d.After
+	}
+	if d.Before != nil {
+		change -= *d.Before
+	}
+	return change
+}
+
+// status classifies the difference.
+func (d dirDiff) status() string {
+	switch change := d.change(); {
+	case d.Before == nil:
+		return "added"
+	case d.After == nil:
+		return "removed"
+	case change > 0:
+		return "grown"
+	case change < 0:
+		return "shrunk"
+	default:
+		return "unchanged"
+	}
+}
+
+// diffSnapshots pairs up the directories of two snapshots, largest change
+// first.
+func diffSnapshots(before, after []storage.UsageRecord) []dirDiff {
+	byDir := make(map[string]*dirDiff, len(after))
+	var diffs []*dirDiff
+	get := func(dir string) *dirDiff {
+		d := byDir[dir]
+		if d == nil {
+			d = &dirDiff{Directory: dir}
+			byDir[dir] = d
+			diffs = append(diffs, d)
+		}
+		return d
+	}
+	for _, r := range before {
+		size := r.SizeBytes
+		get(r.Directory).Before = &size
+	}
+	for _, r := range after {
+		size := r.SizeBytes
+		get(r.Directory).After = &size
+	}
+
+	out := make([]dirDiff, len(diffs))
+	for i, d := range diffs {
+		out[i] = *d
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if ci, cj := abs(out[i].change()), abs(out[j].change()); ci != cj {
+			return ci > cj
+		}
+		return out[i].Directory < out[j].Directory
+	})
+	return out
+}
+
+// diffSummary totals a comparison.
+func diffSummary(diffs []dirDiff) string {
+	var before, after int64
+	counts := make(map[string]int)
+	for _, d := range diffs {
+		if d.Before != nil {
+			before += *d.Before
+		}
+		if d.After != nil {
+			after += *d.After
+		}
+		counts[d.status()]++
+	}
+	return fmt.Sprintf("%d added, %d removed, %d grown, %d shrunk, %d unchanged; total %s -> %s (%s)",
+		counts["added"], counts["removed"], counts["grown"], counts["shrunk"], counts["unchanged"],
+		humanize.FormatSize(before), humanize.FormatSize(after), signedSize(after-before))
+}
+
+// diffColumns are the columns of diff output.
+var diffColumns = []column[dirDiff]{
+	{
+		Name: "directory", Header: "DIRECTORY",
+		Text: func(d dirDiff) string { return d.Directory },
+		JSON: func(d dirDiff) interface{} { return d.Directory },
+	},
+	{
+		Name: "before", Header: "BEFORE",
+		Text: func(d dirDiff) string { return optionalSize(d.Before) },
+		JSON: func(d dirDiff) interface{} { return d.Before },
+	},
+	{
+		Name: "after", Header: "AFTER",
+		Text: func(d dirDiff) string { return optionalSize(d.After) },
+		JSON: func(d dirDiff) interface{} { return d.After },
+	},
+	{
+		Name: "change", Header: "CHANGE",
+		Text: func(d dirDiff) string { return signedSize(d.change()) },
+		JSON: func(d dirDiff) interface{} { return d.change() },
+	},
+	{
+		Name: "status", Header: "STATUS",
+		Text: func(d dirDiff) string { return d.status() },
+		JSON: func(d dirDiff) interface{} { return d.status() },
+	},
+}
+
+// optionalSize formats a size that may be missing.
+func optionalSize(size *int64) string {
+	if size == nil {
+		return "-"
+	}
+	return humanize.FormatSize(*size)
+}
+
+// abs returns the magnitude of n.
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,77 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jgalley/usgmon/internal/daemon"
+	"github.com/jgalley/usgmon/internal/timespec"
+	"github.com/spf13/cobra"
+)
+
+var (
+	digestSince string
+	digestSend  bool
+)
+
+var digestCmd = &cobra.Command{
+	Use:   "digest",
+	Short: "Print or email a usage digest of all configured paths",
+	Long: `Summarize every configured path over a time range: the number of scans, the
+directories whose size changed most, and the directories currently over their
+alert threshold. This is the digest the daemon emails on its
+email.digest_schedule.
+
+The digest is printed unless --send is given, which emails it using the
+email settings instead. Up to email.digest_top changers are listed per path.
+
+Examples:
+  usgmon digest
+  usgmon digest --since 7d
+  usgmon digest --send`,
+	Args: cobra.NoArgs,
+	RunE: runDigest,
+}
+
+func init() {
+	digestCmd.Flags().StringVar(&digestSince, "since", "24h", "start of time range (YYYY-MM-DD or relative like 48h, 3d, 2w)")
+	digestCmd.Flags().BoolVar(&digestSend, "send", false, "email the digest instead of printing it")
+}
+
+func runDigest(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+	cfg, store, err := openStorage(ctx)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	now := time.Now()
+	since, err := timespec.Parse(digestSince, now, false)
+	if err != nil {
+		return fmt.Errorf("invalid --since value: %w", err)
+	}
+	if !since.Before(now) {
+		return fmt.Errorf("--since must be in the past")
+	}
+
+	d := daemon.New(cfg, store, setupLogger(cfg.Logging.Level, cfg.Logging.Format))
+	if digestSend {
+		if !cfg.Email.Enabled() {
+			return fmt.Errorf("email is not configured (set email.smtp_host)")
+		}
+		if err := d.SendDigest(ctx, since, now); err != nil {
+			return fmt.Errorf("sending digest: %w", err)
+		}
+		fmt.Printf("Sent digest to %d recipient(s)\n", len(cfg.Email.To))
+		return nil
+	}
+
+	digest, err := d.BuildDigest(ctx, since, now)
+	if err != nil {
+		return err
+	}
+	fmt.Print(digest.Text())
+	return nil
+}
@@ -0,0 +1,284 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jgalley/usgmon/internal/humanize"
+	"github.com/jgalley/usgmon/internal/timespec"
+	"github.com/jgalley/usgmon/pkg/storage"
+	"github.com/spf13/cobra"
+)
+
+var (
+	forecastHorizon   string
+	forecastUntilSize string
+	forecastSince     string
+	forecastRecent    string
+	forecastFormat    string
+)
+
+var forecastCmd = &cobra.Command{
+	Use:   "forecast <directory>",
+	Short: "Predict a directory's size from its history",
+	Long: `Fit the growth of a directory to its stored history and predict its size at
+a horizon, two ways:
+
+  linear   the least-squares growth rate over the whole history (--since)
+  recent   the growth rate over the last --recent, as measured by check --growth-warn
+
+Both project forward from the latest record. With --until-size, also
+estimate when the directory reaches that size; "never" means it is not
+growing at that rate.
+
+Examples:
+  usgmon forecast /www/users/bob.com
+  usgmon forecast /www/users/bob.com --horizon 90d
+  usgmon forecast /projects/genomics --until-size 5T
+  usgmon forecast /projects/genomics --since 52w --recent 30d --format json`,
+	Args: cobra.ExactArgs(1),
+	RunE: runForecast,
+}
+
+func init() {
+	forecastCmd.Flags().StringVar(&forecastHorizon, "horizon", "30d", "how far ahead to predict (e.g. 30d, 12w)")
+	forecastCmd.Flags().StringVar(&forecastUntilSize, "until-size", "", "also estimate when the directory reaches this size (e.g. \"500G\", \"5T\")")
+	forecastCmd.Flags().StringVar(&forecastSince, "since", "90d", "start of the history to fit (YYYY-MM-DD or relative like 48h, 3d, 2w)")
+	forecastCmd.Flags().StringVar(&forecastRecent, "recent", "7d", "window of the recent growth rate")
+	forecastCmd.Flags().StringVar(&forecastFormat, "format", "text", "output format (text, json)")
+}
+
+// forecastSamples is the most records of a directory fitted.
+const forecastSamples = 10000
+
+// forecast is a prediction of a directory's size by one method.
+type forecast struct {
+	method    string
+	perDay    float64       // growth rate in bytes per day
+	size      int64         // predicted size at the horizon
+	reachesAt *time.Time    // when --until-size is reached; nil if never
+	reachesIn time.Duration // how long from now until then, 0 if reached
+}
+
+func runForecast(cmd *cobra.Command, args []string) error {
+	directory := filepath.Clean(args[0])
+
+	now := time.Now()
+	horizon, err := timespec.ParseDuration(forecastHorizon)
+	if err != nil || horizon <= 0 {
+		return fmt.Errorf("invalid --horizon value %q", forecastHorizon)
+	}
+	recent, err := timespec.ParseDuration(forecastRecent)
+	if err != nil || recent <= 0 {
+		return fmt.Errorf("invalid --recent value %q", forecastRecent)
+	}
+	since, err := timespec.Parse(forecastSince, now, false)
+	if err != nil {
+		return fmt.Errorf("invalid --since value: %w", err)
+	}
+	var threshold int64
+	if forecastUntilSize != "" {
+		threshold, err = humanize.ParseSize(forecastUntilSize)
+		if err != nil || threshold <= 0 {
+			return fmt.Errorf("invalid --until-size value %q", forecastUntilSize)
+		}
+	}
+
+	ctx := context.Background()
+	_, store, err := openStorage(ctx)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	records, err := store.QueryUsage(ctx, storage.QueryOptions{Directory: directory, Since: &since, Limit: forecastSamples})
+	if err != nil {
+		return fmt.Errorf("querying usage: %w", err)
+	}
+	// Oldest first, leaving out measurements with a file filter, which are
+	// not comparable
+	var history []storage.UsageRecord
+	for i := len(records) - 1; i >= 0; i-- {
+		if records[i].FileFilter == "" {
+			history = append(history, records[i])
+		}
+	}
+	if len(history) < 2 {
+		return fmt.Errorf("not enough history for %s since %s: need at least two records", directory, since.Local().Format("2006-01-02"))
+	}
+	latest := history[len(history)-1]
+	if latest.Deleted {
+		return fmt.Errorf("%s was deleted on %s", directory, latest.RecordedAt.Local().Format("2006-01-02 15:04"))
+	}
+
+	target := now.Add(horizon)
+	points := make([]sizePoint, len(history))
+	for i, r := range history {
+		points[i] = sizePoint{at: r.RecordedAt, size: r.SizeBytes}
+	}
+	forecasts := []forecast{project("linear", linearGrowth(points), latest, target, threshold, now)}
+	recentRate, ok, err := growthPerDay(ctx, store, &latest, now, recent)
+	if err != nil {
+		return fmt.Errorf("querying usage: %w", err)
+	}
+	if ok {
+		forecasts = append(forecasts, project("recent", float64(recentRate), latest, target, threshold, now))
+	}
+
+	if forecastFormat == "json" {
+		return outputForecastJSON(history, target, recent, threshold, forecasts)
+	}
+	return outputForecastText(history, horizon, recent, threshold, forecasts)
+}
+
+// linearGrowth fits a least-squares line to points, oldest first, and
+// returns its slope in bytes per day.
+func linearGrowth(points []sizePoint) float64 {
+	origin := points[0].at
+	n := float64(len(points))
+	var sumX, sumY, sumXY, sumXX float64
+	for _, p := range points {
+		x := p.at.Sub(origin).Hours() / 24
+		y := float64(p.size)
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+	denom := n*sumXX - sumX*sumX
+	if denom == 0 {
+		return 0
+	}
+	return (n*sumXY - sumX*sumY) / denom
+}
+
+// project predicts the size at target growing at perDay from the latest
+// record, and when it reaches threshold if that is set.
+func project(method string, perDay float64, latest storage.UsageRecord, target time.Time, threshold int64, now time.Time) forecast {
+	days := target.Sub(latest.RecordedAt).Hours() / 24
+	f := forecast{
+		method: method,
+		perDay: perDay,
+		size:   max(latest.SizeBytes+int64(math.Round(perDay*days)), 0),
+	}
+	if threshold <= 0 {
+		return f
+	}
+	switch {
+	case latest.SizeBytes >= threshold:
+		f.reachesAt = &latest.RecordedAt
+	case perDay > 0:
+		at := latest.RecordedAt.Add(time.Duration(float64(threshold-latest.SizeBytes) / perDay * float64(24*time.Hour)))
+		f.reachesAt = &at
+		f.reachesIn = max(at.Sub(now), 0)
+	}
+	return f
+}
+
+func outputForecastText(history []storage.UsageRecord, horizon, recent time.Duration, threshold int64, forecasts []forecast) error {
+	first, latest := history[0], history[len(history)-1]
+	fmt.Printf("Directory:  %s\n", latest.Directory)
+	fmt.Printf("History:    %d records, %s to %s\n", len(history),
+		first.RecordedAt.Local().Format("2006-01-02"), latest.RecordedAt.Local().Format("2006-01-02"))
+	fmt.Printf("Size:       %s (%s)\n\n", humanize.FormatSize(latest.SizeBytes), latest.RecordedAt.Local().Format("2006-01-02 15:04"))
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	header := "METHOD\tRATE/DAY\tIN " + formatWindow(horizon)
+	if threshold > 0 {
+		header += "\tREACHES " + humanize.FormatSize(threshold)
+	}
+	fmt.Fprintln(w, header)
+	fmt.Fprintln(w, ruleFor(header))
+	for _, f := range forecasts {
+		method := f.method
+		if method == "recent" {
+			method += " (" + formatWindow(recent) + ")"
+		}
+		line := fmt.Sprintf("%s\t%s\t%s", method, signedSize(int64(f.perDay)), humanize.FormatSize(f.size))
+		if threshold > 0 {
+			line += "\t" + reachesText(f)
+		}
+		fmt.Fprintln(w, line)
+	}
+	return w.Flush()
+}
+
+// ruleFor underlines each tab-separated column of header.
+func ruleFor(header string) string {
+	cols := strings.Split(header, "\t")
+	for i, c := range cols {
+		cols[i] = strings.Repeat("-", len(c))
+	}
+	return strings.Join(cols, "\t")
+}
+
+// reachesText describes when a forecast reaches --until-size.
+func reachesText(f forecast) string {
+	switch {
+	case f.reachesAt == nil:
+		return "never"
+	case f.reachesIn == 0:
+		return "reached"
+	}
+	return fmt.Sprintf("in %.0f days (%s)", math.Ceil(f.reachesIn.Hours()/24), f.reachesAt.Local().Format("2006-01-02"))
+}
+
+type forecastJSON struct {
+	Directory      string               `json:"directory"`
+	Samples        int                  `json:"samples"`
+	HistorySince   string               `json:"history_since"`
+	SizeBytes      int64                `json:"size_bytes"`
+	RecordedAt     string               `json:"recorded_at"`
+	PredictedAt    string               `json:"predicted_at"`
+	RecentWindow   string               `json:"recent_window"`
+	ThresholdBytes int64                `json:"threshold_bytes,omitempty"`
+	Forecasts      []forecastMethodJSON `json:"forecasts"`
+}
+
+type forecastMethodJSON struct {
+	Method      string   `json:"method"`
+	BytesPerDay float64  `json:"bytes_per_day"`
+	SizeBytes   int64    `json:"size_bytes"`
+	SizeHuman   string   `json:"size_human"`
+	ReachesAt   string   `json:"reaches_at,omitempty"`
+	DaysUntil   *float64 `json:"days_until,omitempty"`
+}
+
+func outputForecastJSON(history []storage.UsageRecord, target time.Time, recent time.Duration, threshold int64, forecasts []forecast) error {
+	first, latest := history[0], history[len(history)-1]
+	out := forecastJSON{
+		Directory:      latest.Directory,
+		Samples:        len(history),
+		HistorySince:   first.RecordedAt.Format(time.RFC3339),
+		SizeBytes:      latest.SizeBytes,
+		RecordedAt:     latest.RecordedAt.Format(time.RFC3339),
+		PredictedAt:    target.Format(time.RFC3339),
+		RecentWindow:   formatWindow(recent),
+		ThresholdBytes: threshold,
+	}
+	for _, f := range forecasts {
+		m := forecastMethodJSON{
+			Method:      f.method,
+			BytesPerDay: f.perDay,
+			SizeBytes:   f.size,
+			SizeHuman:   humanize.FormatSize(f.size),
+		}
+		if f.reachesAt != nil {
+			days := f.reachesIn.Hours() / 24
+			m.ReachesAt = f.reachesAt.Format(time.RFC3339)
+			m.DaysUntil = &days
+		}
+		out.Forecasts = append(out.Forecasts, m)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
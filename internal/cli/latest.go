@@ -0,0 +1,100 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	latestFormat string
+	latestLimit  int
+	latestHost   string
+
+	latestColumnSpec string
+)
+
+var latestCmd = &cobra.Command{
+	Use:   "latest <base-path>",
+	Short: "Show the latest size of every directory under a base path",
+	Long: `Show the most recent recorded size of every directory under a base path,
+largest first: one row per directory, from the latest record of it. Deleted
+directories and filtered measurements (paths[].exclude_files) are left out.
+
+Examples:
+  usgmon latest /www/users
+  usgmon latest /www/users --limit 20
+  usgmon latest /www/users --format csv > users.csv
+  usgmon latest /www/users --columns directory,size,files,owner --format json
+  usgmon latest /www/users --host fs01`,
+	Args: cobra.ExactArgs(1),
+	RunE: runLatest,
+}
+
+func init() {
+	latestCmd.Flags().StringVar(&latestFormat, "format", "text", "output format (text, json, csv)")
+	latestCmd.Flags().IntVar(&latestLimit, "limit", 0, "maximum number of directories to show (0 for all)")
+	latestCmd.Flags().StringVar(&latestHost, "host", "", "only directories measured by this host")
+	latestCmd.Flags().StringVar(&latestColumnSpec, "columns", "", "comma-separated columns to show (same as query --columns)")
+}
+
+// latestDefaultColumns is the column set used when --columns is not given.
+var latestDefaultColumns = []string{"directory", "size", "timestamp"}
+
+func runLatest(cmd *cobra.Command, args []string) error {
+	basePath := filepath.Clean(args[0])
+
+	cols, err := selectColumns(queryColumns, latestColumnSpec, latestDefaultColumns)
+	if err != nil {
+		return fmt.Errorf("invalid --columns value: %w", err)
+	}
+	switch latestFormat {
+	case "text", "json", "csv":
+	default:
+		return fmt.Errorf("invalid --format value %q (valid: text, json, csv)", latestFormat)
+	}
+
+	ctx := context.Background()
+	_, store, err := openStorage(ctx)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	records, err := store.GetSnapshotAt(ctx, basePath, latestHost, time.Now())
+	if err != nil {
+		return fmt.Errorf("querying snapshot: %w", err)
+	}
+
+	// Hosts that measured the same paths are told apart
+	if latestColumnSpec == "" && multipleHosts(records) {
+		cols, _ = selectColumns(queryColumns, "", []string{"directory", "host", "size", "timestamp"})
+	}
+
+	sort.SliceStable(records, func(i, j int) bool { return records[i].SizeBytes > records[j].SizeBytes })
+	if latestLimit > 0 && len(records) > latestLimit {
+		records = records[:latestLimit]
+	}
+	rows := make([]queryRow, len(records))
+	for i, r := range records {
+		rows[i] = queryRow{UsageRecord: r}
+	}
+
+	switch latestFormat {
+	case "json":
+		return writeColumnsJSON(cols, rows)
+	case "csv":
+		return writeColumnsCSV(os.Stdout, cols, rows)
+	default:
+		if len(rows) == 0 {
+			fmt.Println("No records found")
+			return nil
+		}
+		return writeColumnsText(os.Stdout, cols, rows)
+	}
+}
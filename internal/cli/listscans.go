@@ -0,0 +1,198 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jgalley/usgmon/pkg/storage"
+	"github.com/spf13/cobra"
+)
+
+var (
+	listScansLimit   int
+	listScansFormat  string
+	listScansTrigger string
+)
+
+var listScansCmd = &cobra.Command{
+	Use:   "list-scans [base-path]",
+	Short: "List recorded scans",
+	Long: `List recorded scans, most recent first, including what triggered them
+and any notes attached with scan --note.
+
+Triggers are: scheduled (daemon interval), startup (daemon's first scan of a
+path), once (serve --once), manual (scan --store), api (triggered through
+the daemon's HTTP API), scan-now (usgmon scan-now) and grpc (triggered
+through the gRPC API).
+
+Examples:
+  usgmon list-scans
+  usgmon list-scans /www/users --limit 10
+  usgmon list-scans --trigger scheduled
+  usgmon list-scans --format json
+
+With scan.record_metadata enabled, the JSON output also includes each scan's
+metadata: the usgmon version, hostname and kernel it ran on, and its
+effective options (depth, strategy, exclusions, ...).`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runListScans,
+}
+
+func init() {
+	listScansCmd.Flags().IntVar(&listScansLimit, "limit", 50, "maximum number of scans to show")
+	listScansCmd.Flags().StringVar(&listScansFormat, "format", "text", "output format (text, json, csv)")
+	listScansCmd.Flags().StringVar(&listScansTrigger, "trigger", "", "only show scans with this trigger (scheduled, startup, once, manual, api, scan-now, grpc)")
+}
+
+func runListScans(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+	_, store, err := openStorage(ctx)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	opts := storage.ScanListOptions{
+		Trigger: listScansTrigger,
+		Limit:   listScansLimit,
+	}
+	if len(args) > 0 {
+		opts.BasePath = filepath.Clean(args[0])
+	}
+
+	scans, err := store.ListScans(ctx, opts)
+	if err != nil {
+		return fmt.Errorf("listing scans: %w", err)
+	}
+
+	if len(scans) == 0 && listScansFormat != "csv" {
+		fmt.Println("No scans found")
+		return nil
+	}
+
+	switch listScansFormat {
+	case "json":
+		return outputScansJSON(scans)
+	case "csv":
+		return writeColumnsCSV(os.Stdout, scanListCSVColumns, scans)
+	default:
+		return outputScansText(scans)
+	}
+}
+
+func outputScansText(scans []storage.Scan) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "STARTED\tBASE PATH\tDIRS\tSTATUS\tWALL\tCPU\tTRIGGER\tNOTE")
+	fmt.Fprintln(w, "-------\t---------\t----\t------\t----\t---\t-------\t----")
+
+	for _, sc := range scans {
+		trigger := sc.Trigger
+		if trigger == "" {
+			trigger = "-"
+		}
+		wall, cpu := "-", "-"
+		if sc.CompletedAt != nil {
+			wall = sc.CompletedAt.Sub(sc.StartedAt).Round(time.Second).String()
+		}
+		if sc.CPUUser != nil {
+			cpu = (*sc.CPUUser + *sc.CPUSystem).Round(time.Second).String()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%s\t%s\t%s\n",
+			sc.StartedAt.Local().Format("2006-01-02 15:04"),
+			sc.BasePath,
+			sc.DirectoriesScanned,
+			sc.Status,
+			wall,
+			cpu,
+			trigger,
+			sc.Note,
+		)
+	}
+	return w.Flush()
+}
+
+type scanListJSONRecord struct {
+	ScanID             string                `json:"scan_id"`
+	BasePath           string                `json:"base_path"`
+	StartedAt          string                `json:"started_at"`
+	CompletedAt        string                `json:"completed_at,omitempty"`
+	DirectoriesScanned int                   `json:"directories_scanned"`
+	Status             string                `json:"status"`
+	WallSeconds        *float64              `json:"wall_seconds,omitempty"`
+	CPUUserSeconds     *float64              `json:"cpu_user_seconds,omitempty"`
+	CPUSystemSeconds   *float64              `json:"cpu_system_seconds,omitempty"`
+	Trigger            string                `json:"trigger,omitempty"`
+	Note               string                `json:"note,omitempty"`
+	Metadata           *storage.ScanMetadata `json:"metadata,omitempty"`
+}
+
+// scanListCSVColumns are the columns of list-scans --format csv, those of
+// its JSON output without the metadata.
+var scanListCSVColumns = []column[storage.Scan]{
+	{Name: "scan_id", JSON: func(sc storage.Scan) interface{} { return sc.ScanID }},
+	{Name: "base_path", JSON: func(sc storage.Scan) interface{} { return sc.BasePath }},
+	{Name: "started_at", JSON: func(sc storage.Scan) interface{} { return sc.StartedAt.Format(time.RFC3339) }},
+	{Name: "completed_at", JSON: func(sc storage.Scan) interface{} {
+		if sc.CompletedAt == nil {
+			return nil
+		}
+		return sc.CompletedAt.Format(time.RFC3339)
+	}},
+	{Name: "directories_scanned", JSON: func(sc storage.Scan) interface{} { return sc.DirectoriesScanned }},
+	{Name: "status", JSON: func(sc storage.Scan) interface{} { return sc.Status }},
+	{Name: "wall_seconds", JSON: func(sc storage.Scan) interface{} {
+		if sc.CompletedAt == nil {
+			return nil
+		}
+		return sc.CompletedAt.Sub(sc.StartedAt).Seconds()
+	}},
+	{Name: "cpu_user_seconds", JSON: func(sc storage.Scan) interface{} {
+		if sc.CPUUser == nil {
+			return nil
+		}
+		return sc.CPUUser.Seconds()
+	}},
+	{Name: "cpu_system_seconds", JSON: func(sc storage.Scan) interface{} {
+		if sc.CPUSystem == nil {
+			return nil
+		}
+		return sc.CPUSystem.Seconds()
+	}},
+	{Name: "trigger", JSON: func(sc storage.Scan) interface{} { return sc.Trigger }},
+	{Name: "note", JSON: func(sc storage.Scan) interface{} { return sc.Note }},
+}
+
+func outputScansJSON(scans []storage.Scan) error {
+	records := make([]scanListJSONRecord, len(scans))
+	for i, sc := range scans {
+		records[i] = scanListJSONRecord{
+			ScanID:             sc.ScanID,
+			BasePath:           sc.BasePath,
+			StartedAt:          sc.StartedAt.Format(time.RFC3339),
+			DirectoriesScanned: sc.DirectoriesScanned,
+			Status:             sc.Status,
+			Trigger:            sc.Trigger,
+			Note:               sc.Note,
+			Metadata:           sc.Metadata,
+		}
+		if sc.CompletedAt != nil {
+			records[i].CompletedAt = sc.CompletedAt.Format(time.RFC3339)
+			wall := sc.CompletedAt.Sub(sc.StartedAt).Seconds()
+			records[i].WallSeconds = &wall
+		}
+		if sc.CPUUser != nil {
+			user, system := sc.CPUUser.Seconds(), sc.CPUSystem.Seconds()
+			records[i].CPUUserSeconds = &user
+			records[i].CPUSystemSeconds = &system
+		}
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(records)
+}
@@ -0,0 +1,169 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jgalley/usgmon/internal/humanize"
+	"github.com/jgalley/usgmon/pkg/scanner"
+	"golang.org/x/term"
+)
+
+// How often the progress line is redrawn on a terminal, and printed
+// otherwise, and the span the current throughput is measured over.
+const (
+	progressRedraw     = 250 * time.Millisecond
+	progressLogEvery   = 10 * time.Second
+	progressRateWindow = 5 * time.Second
+)
+
+// scanProgress reports the progress of a streaming scan on stderr. On a
+// terminal it redraws one line in place; otherwise it prints a line every
+// progressLogEvery so logs are not flooded.
+type scanProgress struct {
+	out      io.Writer
+	terminal bool // out is a terminal
+	shared   bool // stdout is the same terminal, so output must clear the line first
+	started  time.Time
+
+	discovered atomic.Int64
+	completed  atomic.Int64
+	bytes      atomic.Int64
+
+	mu      sync.Mutex
+	drawn   bool             // a progress line is on the terminal
+	printed bool             // a progress line was printed (not on a terminal)
+	samples []progressSample // recent completion counts, oldest first
+	stopped chan struct{}
+	done    chan struct{}
+}
+
+// progressSample is the number of directories completed at a time.
+type progressSample struct {
+	at        time.Time
+	completed int64
+}
+
+// startScanProgress starts reporting progress until stop is called.
+func startScanProgress() *scanProgress {
+	terminal := term.IsTerminal(int(os.Stderr.Fd()))
+	p := &scanProgress{
+		out:      os.Stderr,
+		terminal: terminal,
+		shared:   terminal && term.IsTerminal(int(os.Stdout.Fd())),
+		started:  time.Now(),
+		stopped:  make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go p.run()
+	return p
+}
+
+// discover counts a directory found to measure; it is the scanner's
+// ScanOptions.Discovered hook.
+func (p *scanProgress) discover(string) {
+	p.discovered.Add(1)
+}
+
+// complete counts a measured directory.
+func (p *scanProgress) complete(r scanner.Result) {
+	p.completed.Add(1)
+	p.bytes.Add(r.SizeBytes)
+}
+
+// write runs f, which writes to stdout, first clearing the progress line if
+// it shares the terminal. The line is redrawn on the next tick. A nil
+// progress just runs f.
+func (p *scanProgress) write(f func() error) error {
+	if p == nil {
+		return f()
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.shared && p.drawn {
+		fmt.Fprint(p.out, "\r\033[K")
+		p.drawn = false
+	}
+	return f()
+}
+
+func (p *scanProgress) run() {
+	defer close(p.done)
+	ticker := time.NewTicker(progressRedraw)
+	defer ticker.Stop()
+	lastPrint := p.started
+
+	for {
+		select {
+		case <-p.stopped:
+			return
+		case now := <-ticker.C:
+			p.mu.Lock()
+			line := p.line(now)
+			if p.terminal {
+				fmt.Fprintf(p.out, "\r\033[K%s", line)
+				p.drawn = true
+			} else if now.Sub(lastPrint) >= progressLogEvery {
+				fmt.Fprintln(p.out, line)
+				p.printed, lastPrint = true, now
+			}
+			p.mu.Unlock()
+		}
+	}
+}
+
+// line describes the progress at now, recording a sample for the current
+// throughput.
+func (p *scanProgress) line(now time.Time) string {
+	completed := p.completed.Load()
+	p.samples = append(p.samples, progressSample{at: now, completed: completed})
+	// Keep one sample at or beyond the window so the rate spans all of it
+	for len(p.samples) > 2 && now.Sub(p.samples[1].at) >= progressRateWindow {
+		p.samples = p.samples[1:]
+	}
+
+	rate := 0.0
+	first := p.samples[0]
+	if len(p.samples) == 1 {
+		first = progressSample{at: p.started}
+	}
+	if span := now.Sub(first.at).Seconds(); span > 0 {
+		rate = float64(completed-first.completed) / span
+	}
+	return fmt.Sprintf("%d/%d directories, %s, %.1f dirs/s, %s elapsed",
+		completed, p.discovered.Load(), humanize.FormatSize(p.bytes.Load()), rate,
+		now.Sub(p.started).Round(time.Second))
+}
+
+// stop stops reporting and leaves a final summary on the terminal, or in
+// the log if progress was printed there. It may be called more than once,
+// or on a nil progress.
+func (p *scanProgress) stop() {
+	if p == nil {
+		return
+	}
+	select {
+	case <-p.stopped:
+		return
+	default:
+	}
+	close(p.stopped)
+	<-p.done
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	summary := fmt.Sprintf("Scanned %d directories, %s, in %s",
+		p.completed.Load(), humanize.FormatSize(p.bytes.Load()), now.Sub(p.started).Round(time.Second))
+	switch {
+	case p.terminal:
+		fmt.Fprintf(p.out, "\r\033[K%s\n", summary)
+	case p.printed:
+		fmt.Fprintln(p.out, summary)
+	}
+	p.drawn = false
+}
@@ -0,0 +1,142 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	pruneOlderThan time.Duration
+	pruneKeep      int
+	prunePath      string
+	pruneYes       bool
+)
+
+var pruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Delete old scans and their usage records",
+	Long: `Delete finished scans (and their usage records) that started before the
+given age (--older-than), or all but the newest N completed scans per base path
+and host (--keep), keeping records that are still their directory's latest
+value. Asks for confirmation unless --yes is passed; non-interactive
+invocations must pass --yes explicitly.
+
+Examples:
+  usgmon prune --older-than 2160h
+  usgmon prune --older-than 720h --yes
+  usgmon prune --keep 30
+  usgmon prune --keep 10 --path /www/users`,
+	Args: cobra.NoArgs,
+	RunE: runPrune,
+}
+
+func init() {
+	pruneCmd.Flags().DurationVar(&pruneOlderThan, "older-than", 0, "delete scans started longer ago than this (e.g. 720h)")
+	pruneCmd.Flags().IntVar(&pruneKeep, "keep", 0, "keep only the newest N scans per base path and host")
+	pruneCmd.Flags().StringVar(&prunePath, "path", "", "restrict --keep to a single base path")
+	pruneCmd.Flags().BoolVarP(&pruneYes, "yes", "y", false, "skip the confirmation prompt")
+	pruneCmd.MarkFlagsOneRequired("older-than", "keep")
+	pruneCmd.MarkFlagsMutuallyExclusive("older-than", "keep")
+}
+
+func runPrune(cmd *cobra.Command, args []string) error {
+	if cmd.Flags().Changed("keep") {
+		return runPruneKeep()
+	}
+
+	if pruneOlderThan <= 0 {
+		return fmt.Errorf("--older-than must be positive")
+	}
+
+	ctx := context.Background()
+	_, store, err := openStorage(ctx)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	cutoff := time.Now().Add(-pruneOlderThan)
+
+	scans, records, err := store.CountScansBefore(ctx, cutoff)
+	if err != nil {
+		return fmt.Errorf("counting scans: %w", err)
+	}
+	if scans == 0 {
+		fmt.Println("Nothing to prune")
+		return nil
+	}
+
+	prompt := fmt.Sprintf("Delete %d scans and %d usage records started before %s?",
+		scans, records, cutoff.Local().Format("2006-01-02 15:04"))
+	ok, err := confirm(prompt, pruneYes)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		fmt.Println("Aborted")
+		return nil
+	}
+
+	scans, records, err = store.PruneScansBefore(ctx, cutoff)
+	if err != nil {
+		return fmt.Errorf("pruning scans: %w", err)
+	}
+
+	fmt.Printf("Deleted %d scans and %d usage records\n", scans, records)
+	return nil
+}
+
+// runPruneKeep deletes all but the newest --keep scans per base path and host.
+func runPruneKeep() error {
+	if pruneKeep < 1 {
+		return fmt.Errorf("--keep must be at least 1")
+	}
+
+	ctx := context.Background()
+	_, store, err := openStorage(ctx)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	basePath := prunePath
+	if basePath != "" {
+		basePath = filepath.Clean(basePath)
+	}
+
+	scans, records, err := store.CountScansKeepingLatest(ctx, basePath, pruneKeep)
+	if err != nil {
+		return fmt.Errorf("counting scans: %w", err)
+	}
+	if scans == 0 {
+		fmt.Println("Nothing to prune")
+		return nil
+	}
+
+	scope := "each base path"
+	if basePath != "" {
+		scope = basePath
+	}
+	prompt := fmt.Sprintf("Delete %d scans and %d usage records, keeping the newest %d for %s?",
+		scans, records, pruneKeep, scope)
+	ok, err := confirm(prompt, pruneYes)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		fmt.Println("Aborted")
+		return nil
+	}
+
+	scans, records, err = store.PruneScansKeepingLatest(ctx, basePath, pruneKeep)
+	if err != nil {
+		return fmt.Errorf("pruning scans: %w", err)
+	}
+
+	fmt.Printf("Deleted %d scans and %d usage records\n", scans, records)
+	return nil
+}
@@ -5,45 +5,136 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
 	"text/tabwriter"
 	"time"
 
 	"github.com/jgalley/usgmon/internal/config"
-	"github.com/jgalley/usgmon/internal/storage"
+	"github.com/jgalley/usgmon/internal/humanize"
+	"github.com/jgalley/usgmon/internal/timespec"
+	"github.com/jgalley/usgmon/pkg/storage"
 	"github.com/spf13/cobra"
 )
 
 var (
-	queryDays   int
-	querySince  string
-	queryFormat string
-	queryLimit  int
+	queryDays    int
+	querySince   string
+	queryFormat  string
+	queryLimit   int
+	queryOwner   string
+	queryHost    string
+	queryByOwner bool
+	queryUntil   string
+	queryBase    bool
+	queryOffset  int
+	queryCursor  string
+	queryBucket  string
+	queryAgg     string
+	queryMatch   string
+	queryRegex   string
+
+	queryColumnSpec string
 )
 
 var queryCmd = &cobra.Command{
 	Use:   "query <path>",
 	Short: "Query historical usage data",
-	Long: `Query historical usage data for a directory.
+	Long: `Query historical usage data for a directory, newest first. With --base-path,
+list the records of every directory under a base path instead.
+
+Results are paged with --limit. When a page is full, the command prints a
+--cursor for the next one on stderr; --offset skips records instead, but
+shifts as new records are stored.
 
 Examples:
   usgmon query /www/users/bob.com
   usgmon query /www/users/bob.com --days 7
   usgmon query /www/users/bob.com --since "2026-01-01"
-  usgmon query /www/users/bob.com --format json`,
+  usgmon query /www/users/bob.com --since 48h
+  usgmon query /www/users/bob.com --since 2026-01-01 --until 2026-02-01
+  usgmon query /www/users --base-path --since 24h
+  usgmon query /www/users --base-path --limit 500 --cursor <token>
+  usgmon query /www/users --base-path --match-regex '.*/logs$' --since 24h
+  usgmon query /www/users/bob.com --since 365d --bucket 1d --agg max
+  usgmon query /www/users --base-path --since 12w --bucket 1w --agg avg --format csv
+  usgmon query /www/users/bob.com --format json
+  usgmon query /www/users/bob.com --columns timestamp,size,symlinks
+  usgmon query /www/users/bob.com --owner bob --columns timestamp,size,owner
+  usgmon query /www/users/bob.com --host fs01 --columns timestamp,size,host
+  usgmon query /projects/genomics --by-owner
+  usgmon query /projects/genomics --by-owner --days 30 --limit 10`,
 	Args: cobra.ExactArgs(1),
 	RunE: runQuery,
 }
 
 func init() {
-	queryCmd.Flags().IntVar(&queryDays, "days", 0, "show records from the last N days")
-	queryCmd.Flags().StringVar(&querySince, "since", "", "show records since date (YYYY-MM-DD)")
-	queryCmd.Flags().StringVar(&queryFormat, "format", "text", "output format (text, json)")
+	queryCmd.Flags().IntVar(&queryDays, "days", 0, "show records from the last N days (alias for --since Nd)")
+	queryCmd.Flags().StringVar(&querySince, "since", "", "show records since date (YYYY-MM-DD) or relative duration (12h, 3d, 2w)")
+	queryCmd.Flags().StringVar(&queryFormat, "format", "text", "output format (text, json, csv)")
+	queryCmd.Flags().StringVar(&queryUntil, "until", "", "show records up to date (YYYY-MM-DD) or relative duration (12h, 3d, 2w)")
+	queryCmd.Flags().BoolVar(&queryBase, "base-path", false, "treat the path as a base path and show the records of every directory under it")
 	queryCmd.Flags().IntVar(&queryLimit, "limit", 100, "maximum number of records to show")
+	queryCmd.Flags().IntVar(&queryOffset, "offset", 0, "skip this many records first")
+	queryCmd.Flags().StringVar(&queryCursor, "cursor", "", "continue after the last record of a previous page, as printed by it")
+	queryCmd.Flags().StringVar(&queryBucket, "bucket", "", "aggregate records into one value per directory per time bucket of this width (e.g. 1h, 1d, 1w)")
+	queryCmd.Flags().StringVar(&queryAgg, "agg", storage.AggMax, "aggregation of each --bucket (min, max, avg, last)")
+	queryCmd.Flags().StringVar(&queryMatch, "match", "", "with --base-path, only directories whose name matches this glob, or whose path does if it has a slash")
+	queryCmd.Flags().StringVar(&queryRegex, "match-regex", "", "with --base-path, only directories whose path matches this regular expression")
+	queryCmd.Flags().StringVar(&queryOwner, "owner", "", "only records taken while the directory was owned by this user")
+	queryCmd.Flags().StringVar(&queryHost, "host", "", "only records made by this host")
+	queryCmd.Flags().BoolVar(&queryByOwner, "by-owner", false, "show the size per file owner of the newest record that has it (of up to --limit records if given)")
+	queryCmd.Flags().StringVar(&queryColumnSpec, "columns", "", "comma-separated columns to show (timestamp, directory, size, change, range, filter, symlinks, fingerprint, allocated, ratio, files, dirs, owner, group, quota, host, scan_id)")
 }
 
 func runQuery(cmd *cobra.Command, args []string) error {
 	path := args[0]
 
+	defaults := queryDefaultColumns
+	if queryBase {
+		defaults = queryBaseDefaultColumns
+	}
+	cols, err := selectColumns(queryColumns, queryColumnSpec, defaults)
+	if err != nil {
+		return fmt.Errorf("invalid --columns value: %w", err)
+	}
+	if queryByOwner && queryColumnSpec != "" {
+		return fmt.Errorf("--by-owner cannot be combined with --columns")
+	}
+	if queryByOwner && queryBase {
+		return fmt.Errorf("--by-owner cannot be combined with --base-path")
+	}
+	if (queryMatch != "" || queryRegex != "") && !queryBase {
+		return fmt.Errorf("--match and --match-regex require --base-path")
+	}
+	if _, err := regexp.Compile(queryRegex); err != nil {
+		return fmt.Errorf("invalid --match-regex value: %w", err)
+	}
+	if queryOffset < 0 {
+		return fmt.Errorf("--offset must be non-negative")
+	}
+	if queryOffset > 0 && queryCursor != "" {
+		return fmt.Errorf("--offset cannot be combined with --cursor")
+	}
+	var bucket time.Duration
+	if queryBucket != "" {
+		if bucket, err = timespec.ParseDuration(queryBucket); err != nil || bucket < time.Second || bucket%time.Second != 0 {
+			return fmt.Errorf("invalid --bucket value %q (want a whole number of seconds or more, like 1h, 1d, 1w)", queryBucket)
+		}
+		if !slices.Contains(storage.Aggregations, queryAgg) {
+			return fmt.Errorf("invalid --agg value %q (valid: %s)", queryAgg, strings.Join(storage.Aggregations, ", "))
+		}
+		for _, flag := range []string{"columns", "by-owner", "owner", "offset", "cursor"} {
+			if cmd.Flags().Changed(flag) {
+				return fmt.Errorf("--%s cannot be combined with --bucket", flag)
+			}
+		}
+	} else if cmd.Flags().Changed("agg") {
+		return fmt.Errorf("--agg requires --bucket")
+	}
+
 	cfg, err := config.Load(cfgFile)
 	if err != nil {
 		return fmt.Errorf("loading config: %w", err)
@@ -61,8 +152,30 @@ func runQuery(cmd *cobra.Command, args []string) error {
 	}
 
 	opts := storage.QueryOptions{
-		Directory: path,
-		Limit:     queryLimit,
+		Directory:  path,
+		Owner:      queryOwner,
+		Hostname:   queryHost,
+		Match:      queryMatch,
+		MatchRegex: queryRegex,
+		Limit:      queryLimit,
+		Offset:     queryOffset,
+	}
+	if queryBase {
+		opts.Directory, opts.BasePath = "", path
+	}
+	if queryCursor != "" {
+		after, err := storage.ParseUsageCursor(queryCursor)
+		if err != nil {
+			return fmt.Errorf("invalid --cursor value: %w", err)
+		}
+		opts.After = &after
+	}
+	if queryByOwner {
+		opts.ByOwner = true
+		// Chargeback usually wants the current split
+		if !cmd.Flags().Changed("limit") {
+			opts.Limit = 1
+		}
 	}
 
 	// Apply time filters
@@ -70,76 +183,325 @@ func runQuery(cmd *cobra.Command, args []string) error {
 		since := time.Now().AddDate(0, 0, -queryDays)
 		opts.Since = &since
 	} else if querySince != "" {
-		since, err := time.Parse("2006-01-02", querySince)
+		since, err := timespec.Parse(querySince, time.Now(), false)
 		if err != nil {
-			return fmt.Errorf("invalid date format (use YYYY-MM-DD): %w", err)
+			return fmt.Errorf("invalid --since value: %w", err)
 		}
 		opts.Since = &since
 	}
+	if queryUntil != "" {
+		until, err := timespec.Parse(queryUntil, time.Now(), true)
+		if err != nil {
+			return fmt.Errorf("invalid --until value: %w", err)
+		}
+		opts.Until = &until
+	}
+
+	if bucket > 0 {
+		bopts := storage.BucketOptions{
+			Directory:  opts.Directory,
+			BasePath:   opts.BasePath,
+			Since:      opts.Since,
+			Until:      opts.Until,
+			Hostname:   opts.Hostname,
+			Match:      opts.Match,
+			MatchRegex: opts.MatchRegex,
+			Bucket:     bucket,
+			Agg:        queryAgg,
+		}
+		// A year of daily buckets should not need --limit
+		if cmd.Flags().Changed("limit") {
+			bopts.Limit = queryLimit
+		}
+		return outputBuckets(ctx, store, bopts)
+	}
 
 	records, err := store.QueryUsage(ctx, opts)
 	if err != nil {
 		return fmt.Errorf("querying usage: %w", err)
 	}
 
-	if len(records) == 0 {
+	// A full page may have more after it; stderr keeps the output parseable
+	if opts.Limit > 0 && len(records) == opts.Limit {
+		fmt.Fprintf(os.Stderr, "More records: --cursor %s\n", records[len(records)-1].Cursor())
+	}
+
+	// CSV always has its header, so scripts need not special-case no rows
+	if len(records) == 0 && queryFormat != "csv" {
 		fmt.Println("No records found")
 		return nil
 	}
 
+	if queryByOwner {
+		switch queryFormat {
+		case "json":
+			return outputOwnerUsageJSON(records)
+		case "csv":
+			return writeColumnsCSV(os.Stdout, ownerUsageCSVColumns, ownerUsageRows(records))
+		}
+		return outputOwnerUsageText(records)
+	}
+
 	switch queryFormat {
 	case "json":
+		if queryColumnSpec != "" {
+			return writeColumnsJSON(cols, queryRows(records))
+		}
 		return outputJSON(records)
+	case "csv":
+		return writeColumnsCSV(os.Stdout, cols, queryRows(records))
 	default:
-		return outputText(records)
+		return writeColumnsText(os.Stdout, cols, queryRows(records))
 	}
 }
 
-func outputText(records []storage.UsageRecord) error {
-	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	fmt.Fprintln(w, "TIMESTAMP\tSIZE\tCHANGE")
-	fmt.Fprintln(w, "---------\t----\t------")
+// queryRow is a usage record paired with its change from the previous record.
+type queryRow struct {
+	storage.UsageRecord
+	Change *int64 // nil for the oldest record
+}
 
-	for i, r := range records {
-		change := "-"
-		if i < len(records)-1 {
-			prev := records[i+1]
-			diff := r.SizeBytes - prev.SizeBytes
-			if diff != 0 {
-				sign := "+"
-				if diff < 0 {
-					sign = ""
-				}
-				change = fmt.Sprintf("%s%s", sign, formatSize(diff))
+// queryColumns lists the columns selectable with query --columns.
+var queryColumns = []column[queryRow]{
+	{
+		Name: "timestamp", Header: "TIMESTAMP",
+		Text: func(r queryRow) string { return r.RecordedAt.Local().Format("2006-01-02 15:04") },
+		JSON: func(r queryRow) interface{} { return r.RecordedAt.Format(time.RFC3339) },
+	},
+	{
+		Name: "directory", Header: "DIRECTORY",
+		Text: func(r queryRow) string { return r.Directory },
+		JSON: func(r queryRow) interface{} { return r.Directory },
+	},
+	{
+		Name: "size", Header: "SIZE",
+		Text: func(r queryRow) string {
+			if r.Deleted {
+				return "(deleted)"
+			}
+			size := humanize.FormatSize(r.SizeBytes)
+			if r.FileFilter != "" {
+				size += fmt.Sprintf(" (excl. %s)", r.FileFilter)
+			}
+			if r.Rollup != nil {
+				size += " (daily avg)"
+			}
+			return size
+		},
+		JSON: func(r queryRow) interface{} { return r.SizeBytes },
+	},
+	{
+		Name: "change", Header: "CHANGE",
+		Text: func(r queryRow) string {
+			if r.Change == nil || *r.Change == 0 {
+				return "-"
+			}
+			sign := "+"
+			if *r.Change < 0 {
+				sign = ""
+			}
+			return sign + humanize.FormatSize(*r.Change)
+		},
+		JSON: func(r queryRow) interface{} { return r.Change },
+	},
+	{
+		Name: "range", Header: "DAILY RANGE",
+		Text: func(r queryRow) string {
+			if r.Rollup == nil {
+				return "-"
+			}
+			return fmt.Sprintf("%s - %s (%d samples)",
+				humanize.FormatSize(r.Rollup.MinBytes), humanize.FormatSize(r.Rollup.MaxBytes), r.Rollup.Samples)
+		},
+		JSON: func(r queryRow) interface{} { return rollupJSON(r.Rollup) },
+	},
+	{
+		Name: "filter", Header: "FILTER",
+		Text: func(r queryRow) string { return r.FileFilter },
+		JSON: func(r queryRow) interface{} { return r.FileFilter },
+	},
+	{
+		Name: "symlinks", Header: "SYMLINKS",
+		Text: func(r queryRow) string {
+			if r.SymlinkCount == nil {
+				return "-"
+			}
+			return strconv.FormatInt(*r.SymlinkCount, 10)
+		},
+		JSON: func(r queryRow) interface{} { return r.SymlinkCount },
+	},
+	{
+		Name: "fingerprint", Header: "FINGERPRINT",
+		Text: func(r queryRow) string {
+			if r.Fingerprint == "" {
+				return "-"
+			}
+			return r.Fingerprint[:12]
+		},
+		JSON: func(r queryRow) interface{} { return r.Fingerprint },
+	},
+	{
+		Name: "allocated", Header: "ALLOCATED",
+		Text: func(r queryRow) string {
+			if r.AllocatedBytes == nil {
+				return "-"
+			}
+			return humanize.FormatSize(*r.AllocatedBytes)
+		},
+		JSON: func(r queryRow) interface{} { return r.AllocatedBytes },
+	},
+	{
+		Name: "ratio", Header: "ALLOC/APPARENT",
+		Text: func(r queryRow) string {
+			ratio := allocatedRatio(r.UsageRecord)
+			if ratio == nil {
+				return "-"
+			}
+			return fmt.Sprintf("%.2f", *ratio)
+		},
+		JSON: func(r queryRow) interface{} { return allocatedRatio(r.UsageRecord) },
+	},
+	{
+		Name: "files", Header: "FILES",
+		Text: func(r queryRow) string { return countText(r.FileCount) },
+		JSON: func(r queryRow) interface{} { return r.FileCount },
+	},
+	{
+		Name: "dirs", Header: "DIRS",
+		Text: func(r queryRow) string { return countText(r.DirCount) },
+		JSON: func(r queryRow) interface{} { return r.DirCount },
+	},
+	{
+		Name: "owner", Header: "OWNER",
+		Text: func(r queryRow) string { return orDash(r.Owner) },
+		JSON: func(r queryRow) interface{} { return r.Owner },
+	},
+	{
+		Name: "group", Header: "GROUP",
+		Text: func(r queryRow) string { return orDash(r.Group) },
+		JSON: func(r queryRow) interface{} { return r.Group },
+	},
+	{
+		Name: "quota", Header: "QUOTA",
+		Text: func(r queryRow) string {
+			if r.QuotaLimit == nil || *r.QuotaLimit <= 0 {
+				return "-"
 			}
+			used := 100 * float64(r.SizeBytes) / float64(*r.QuotaLimit)
+			return fmt.Sprintf("%s (%.0f%%)", humanize.FormatSize(*r.QuotaLimit), used)
+		},
+		JSON: func(r queryRow) interface{} { return r.QuotaLimit },
+	},
+	{
+		Name: "host", Header: "HOST",
+		Text: func(r queryRow) string { return orDash(r.Hostname) },
+		JSON: func(r queryRow) interface{} { return r.Hostname },
+	},
+	{
+		Name: "scan_id", Header: "SCAN ID",
+		Text: func(r queryRow) string { return r.ScanID },
+		JSON: func(r queryRow) interface{} { return r.ScanID },
+	},
+}
+
+// allocatedRatio returns allocated over apparent size, or nil when the
+// allocated size was not recorded or the directory is empty. Values below 1
+// indicate compression or sparse files.
+func allocatedRatio(r storage.UsageRecord) *float64 {
+	if r.AllocatedBytes == nil || r.SizeBytes == 0 {
+		return nil
+	}
+	ratio := float64(*r.AllocatedBytes) / float64(r.SizeBytes)
+	return &ratio
+}
+
+// countText renders an optional entry count for text columns.
+func countText(n *int64) string {
+	if n == nil {
+		return "-"
+	}
+	return strconv.FormatInt(*n, 10)
+}
+
+// queryDefaultColumns is the text column set used when --columns is not given.
+var queryDefaultColumns = []string{"timestamp", "size", "change"}
+
+// queryBaseDefaultColumns is the default column set with --base-path.
+var queryBaseDefaultColumns = []string{"timestamp", "directory", "size", "change"}
+
+// queryRows pairs each record with its change from the next-older record
+// of the same directory.
+func queryRows(records []storage.UsageRecord) []queryRow {
+	rows := make([]queryRow, len(records))
+	older := make(map[string]int64)
+	for i := len(records) - 1; i >= 0; i-- {
+		r := records[i]
+		rows[i] = queryRow{UsageRecord: r}
+		if size, ok := older[r.Directory]; ok {
+			diff := r.SizeBytes - size
+			rows[i].Change = &diff
 		}
-		fmt.Fprintf(w, "%s\t%s\t%s\n",
-			r.RecordedAt.Local().Format("2006-01-02 15:04"),
-			formatSize(r.SizeBytes),
-			change,
-		)
+		older[r.Directory] = r.SizeBytes
 	}
-	return w.Flush()
+	return rows
 }
 
 type jsonRecord struct {
-	Timestamp  string `json:"timestamp"`
-	SizeBytes  int64  `json:"size_bytes"`
-	SizeHuman  string `json:"size_human"`
-	ChangeFrom *int64 `json:"change_from,omitempty"`
+	Timestamp    string      `json:"timestamp"`
+	Directory    string      `json:"directory,omitempty"` // only with --base-path
+	SizeBytes    int64       `json:"size_bytes"`
+	SizeHuman    string      `json:"size_human"`
+	ChangeFrom   *int64      `json:"change_from,omitempty"`
+	FileFilter   string      `json:"file_filter,omitempty"`
+	SymlinkCount *int64      `json:"symlink_count,omitempty"`
+	Allocated    *int64      `json:"allocated_bytes,omitempty"`
+	Owner        string      `json:"owner,omitempty"`
+	Group        string      `json:"group,omitempty"`
+	QuotaLimit   *int64      `json:"quota_limit_bytes,omitempty"`
+	FileCount    *int64      `json:"file_count,omitempty"`
+	DirCount     *int64      `json:"dir_count,omitempty"`
+	Deleted      bool        `json:"deleted,omitempty"`
+	Hostname     string      `json:"hostname,omitempty"`
+	Rollup       *jsonRollup `json:"rollup,omitempty"`
+}
+
+// jsonRollup describes the raw records a daily rollup replaced.
+type jsonRollup struct {
+	Samples  int   `json:"samples"`
+	MinBytes int64 `json:"min_bytes"`
+	MaxBytes int64 `json:"max_bytes"`
+}
+
+// rollupJSON converts a rollup summary for JSON output; nil stays nil.
+func rollupJSON(r *storage.Rollup) *jsonRollup {
+	if r == nil {
+		return nil
+	}
+	return &jsonRollup{Samples: r.Samples, MinBytes: r.MinBytes, MaxBytes: r.MaxBytes}
 }
 
 func outputJSON(records []storage.UsageRecord) error {
 	jsonRecords := make([]jsonRecord, len(records))
-	for i, r := range records {
+	for i, r := range queryRows(records) {
 		jr := jsonRecord{
-			Timestamp: r.RecordedAt.Format(time.RFC3339),
-			SizeBytes: r.SizeBytes,
-			SizeHuman: formatSize(r.SizeBytes),
+			Timestamp:    r.RecordedAt.Format(time.RFC3339),
+			ChangeFrom:   r.Change,
+			SizeBytes:    r.SizeBytes,
+			SizeHuman:    humanize.FormatSize(r.SizeBytes),
+			FileFilter:   r.FileFilter,
+			SymlinkCount: r.SymlinkCount,
+			Allocated:    r.AllocatedBytes,
+			Owner:        r.Owner,
+			Group:        r.Group,
+			QuotaLimit:   r.QuotaLimit,
+			FileCount:    r.FileCount,
+			DirCount:     r.DirCount,
+			Deleted:      r.Deleted,
+			Hostname:     r.Hostname,
+			Rollup:       rollupJSON(r.Rollup),
 		}
-		if i < len(records)-1 {
-			diff := r.SizeBytes - records[i+1].SizeBytes
-			jr.ChangeFrom = &diff
+		if queryBase {
+			jr.Directory = r.Directory
 		}
 		jsonRecords[i] = jr
 	}
@@ -148,3 +510,78 @@ func outputJSON(records []storage.UsageRecord) error {
 	enc.SetIndent("", "  ")
 	return enc.Encode(jsonRecords)
 }
+
+// outputOwnerUsageText prints each record's size per file owner, largest
+// first, with the owner's share of the directory.
+func outputOwnerUsageText(records []storage.UsageRecord) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIMESTAMP\tOWNER\tSIZE\tSHARE")
+	fmt.Fprintln(w, "---------\t-----\t----\t-----")
+	for _, r := range records {
+		timestamp := r.RecordedAt.Local().Format("2006-01-02 15:04")
+		for _, o := range r.OwnerUsage {
+			share := "-"
+			if r.SizeBytes > 0 {
+				share = fmt.Sprintf("%.1f%%", 100*float64(o.SizeBytes)/float64(r.SizeBytes))
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", timestamp, o.Owner, humanize.FormatSize(o.SizeBytes), share)
+		}
+	}
+	return w.Flush()
+}
+
+// jsonOwnerRecord is a record's size per file owner in query --by-owner
+// --format json output.
+type jsonOwnerRecord struct {
+	Timestamp string               `json:"timestamp"`
+	Directory string               `json:"directory"`
+	SizeBytes int64                `json:"size_bytes"`
+	Hostname  string               `json:"hostname,omitempty"`
+	Owners    []storage.OwnerUsage `json:"owners"`
+}
+
+// ownerUsageRow is one owner's share of a record, a row of query --by-owner
+// --format csv output.
+type ownerUsageRow struct {
+	storage.UsageRecord
+	storage.OwnerUsage
+}
+
+// ownerUsageRows flattens records into a row per owner.
+func ownerUsageRows(records []storage.UsageRecord) []ownerUsageRow {
+	var rows []ownerUsageRow
+	for _, r := range records {
+		for _, o := range r.OwnerUsage {
+			rows = append(rows, ownerUsageRow{UsageRecord: r, OwnerUsage: o})
+		}
+	}
+	return rows
+}
+
+// ownerUsageCSVColumns are the columns of query --by-owner --format csv.
+var ownerUsageCSVColumns = []column[ownerUsageRow]{
+	{Name: "timestamp", JSON: func(r ownerUsageRow) interface{} { return r.RecordedAt.Format(time.RFC3339) }},
+	{Name: "directory", JSON: func(r ownerUsageRow) interface{} { return r.Directory }},
+	{Name: "size_bytes", JSON: func(r ownerUsageRow) interface{} { return r.UsageRecord.SizeBytes }},
+	{Name: "owner", JSON: func(r ownerUsageRow) interface{} { return r.OwnerUsage.Owner }},
+	{Name: "uid", JSON: func(r ownerUsageRow) interface{} { return r.UID }},
+	{Name: "owner_bytes", JSON: func(r ownerUsageRow) interface{} { return r.OwnerUsage.SizeBytes }},
+	{Name: "hostname", JSON: func(r ownerUsageRow) interface{} { return r.Hostname }},
+}
+
+func outputOwnerUsageJSON(records []storage.UsageRecord) error {
+	out := make([]jsonOwnerRecord, len(records))
+	for i, r := range records {
+		out[i] = jsonOwnerRecord{
+			Timestamp: r.RecordedAt.Format(time.RFC3339),
+			Directory: r.Directory,
+			SizeBytes: r.SizeBytes,
+			Hostname:  r.Hostname,
+			Owners:    r.OwnerUsage,
+		}
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
@@ -0,0 +1,67 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/jgalley/usgmon/internal/humanize"
+	"github.com/jgalley/usgmon/pkg/storage"
+)
+
+// outputBuckets prints query --bucket: one aggregated size per directory
+// per bucket, newest first.
+func outputBuckets(ctx context.Context, store storage.Storage, opts storage.BucketOptions) error {
+	buckets, err := store.QueryBuckets(ctx, opts)
+	if err != nil {
+		return fmt.Errorf("querying usage: %w", err)
+	}
+
+	cols := bucketColumns(opts.Bucket, queryBase)
+	switch queryFormat {
+	case "json":
+		return writeColumnsJSON(cols, buckets)
+	case "csv":
+		return writeColumnsCSV(os.Stdout, cols, buckets)
+	}
+	if len(buckets) == 0 {
+		fmt.Println("No records found")
+		return nil
+	}
+	return writeColumnsText(os.Stdout, cols, buckets)
+}
+
+// bucketColumns are the columns of query --bucket output. Buckets are
+// aligned to UTC, so their starts are shown in UTC, as dates when the
+// buckets are whole days.
+func bucketColumns(width time.Duration, withDirectory bool) []column[storage.UsageBucket] {
+	layout := "2006-01-02 15:04 UTC"
+	if width%(24*time.Hour) == 0 {
+		layout = "2006-01-02"
+	}
+	cols := []column[storage.UsageBucket]{{
+		Name: "start", Header: "BUCKET",
+		Text: func(b storage.UsageBucket) string { return b.Start.Format(layout) },
+		JSON: func(b storage.UsageBucket) interface{} { return b.Start.Format(time.RFC3339) },
+	}}
+	if withDirectory {
+		cols = append(cols, column[storage.UsageBucket]{
+			Name: "directory", Header: "DIRECTORY",
+			Text: func(b storage.UsageBucket) string { return b.Directory },
+			JSON: func(b storage.UsageBucket) interface{} { return b.Directory },
+		})
+	}
+	return append(cols,
+		column[storage.UsageBucket]{
+			Name: "size", Header: "SIZE",
+			Text: func(b storage.UsageBucket) string { return humanize.FormatSize(b.SizeBytes) },
+			JSON: func(b storage.UsageBucket) interface{} { return b.SizeBytes },
+		},
+		column[storage.UsageBucket]{
+			Name: "samples", Header: "SAMPLES",
+			Text: func(b storage.UsageBucket) string { return fmt.Sprint(b.Samples) },
+			JSON: func(b storage.UsageBucket) interface{} { return b.Samples },
+		},
+	)
+}
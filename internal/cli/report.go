@@ -0,0 +1,371 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jgalley/usgmon/internal/humanize"
+	"github.com/jgalley/usgmon/internal/timespec"
+	"github.com/jgalley/usgmon/pkg/storage"
+	"github.com/spf13/cobra"
+)
+
+var (
+	reportPeriod string
+	reportUntil  string
+	reportTop    int
+	reportFormat string
+	reportOutput string
+)
+
+var reportCmd = &cobra.Command{
+	Use:   "report <base-path>",
+	Short: "Summarize a base path over the last day or week",
+	Long: `Summarize the usage of the directories under a base path over a day or a
+week, ready to paste into an ops review: the total size and its growth, the
+directories that grew and shrank most, the directories that appeared and
+disappeared, and how reliably the path was scanned.
+
+Sizes are compared between the tree at the start and at the end of the
+period, as usgmon diff compares them. A directory counts as deleted once a
+deletion marker is recorded for it (scan.reconcile_deleted). Watch samples
+are not counted as scans.
+
+Output is plain text, Markdown, or a standalone HTML page. The HTML page
+also charts the total size and each listed directory's size over the
+period, and ends with a sortable table of every directory with its history,
+so it can be emailed or published as is.
+
+Examples:
+  usgmon report /www/users
+  usgmon report /www/users --period daily
+  usgmon report /www/users --until 2026-10-11 --format markdown
+  usgmon report /home --format html --output /var/www/reports/home.html`,
+	Args: cobra.ExactArgs(1),
+	RunE: runReport,
+}
+
+func init() {
+	reportCmd.Flags().StringVar(&reportPeriod, "period", "weekly", "length of the period covered (daily, weekly)")
+	reportCmd.Flags().StringVar(&reportUntil, "until", "", "end of the period (YYYY-MM-DD, \"YYYY-MM-DD HH:MM\", or relative like 1d); default now")
+	reportCmd.Flags().IntVar(&reportTop, "top", 10, "directories listed per section")
+	reportCmd.Flags().StringVar(&reportFormat, "format", "text", "output format (text, markdown, html)")
+	reportCmd.Flags().StringVarP(&reportOutput, "output", "o", "", "write the report to this file instead of standard output")
+}
+
+// reportScanLimit bounds the scans read for a report's reliability stats.
+const reportScanLimit = 10000
+
+// report is a summary of a base path over a period.
+type report struct {
+	BasePath string
+	Period   string
+	Since    time.Time
+	Until    time.Time
+	Sections []reportSection
+
+	// Charted in HTML only
+	Total       []sizePoint            // total size of the tree over the period
+	Directories []dirDiff              // every directory, largest change first
+	History     map[string][]sizePoint // each directory's size over the period
+}
+
+// reportSection is one part of a report: a list of facts, a table, or
+// both. Every output format renders the same sections.
+type reportSection struct {
+	Title   string
+	Facts   []reportFact
+	Headers []string
+	Rows    [][]string
+	History [][]sizePoint // each row's size over the period, charted in HTML; nil for none
+	Left    int           // leading columns of text, aligned left; the rest are sizes
+	Empty   string        // shown instead of the table when it has no rows
+	More    int           // rows left out of the table
+}
+
+// reportFact is a labelled value in a report section.
+type reportFact struct {
+	Label string
+	Value string
+}
+
+func runReport(cmd *cobra.Command, args []string) error {
+	basePath := filepath.Clean(args[0])
+
+	var period time.Duration
+	switch reportPeriod {
+	case "daily":
+		period = 24 * time.Hour
+	case "weekly":
+		period = 7 * 24 * time.Hour
+	default:
+		return fmt.Errorf("invalid --period value %q (valid: daily, weekly)", reportPeriod)
+	}
+	switch reportFormat {
+	case "text", "markdown", "html":
+	default:
+		return fmt.Errorf("invalid --format value %q (valid: text, markdown, html)", reportFormat)
+	}
+	if reportTop <= 0 {
+		return fmt.Errorf("--top must be positive")
+	}
+	now := time.Now()
+	until := now
+	if reportUntil != "" {
+		var err error
+		// A bare date means "up to the end of that day"
+		if until, err = timespec.Parse(reportUntil, now, true); err != nil {
+			return fmt.Errorf("invalid --until value: %w", err)
+		}
+	}
+	since := until.Add(-period)
+
+	ctx := context.Background()
+	_, store, err := openStorage(ctx)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	rep, err := buildReport(ctx, store, basePath, since, until)
+	if err != nil {
+		return err
+	}
+	rep.Period = reportPeriod
+
+	out := os.Stdout
+	if reportOutput != "" {
+		if out, err = os.Create(reportOutput); err != nil {
+			return fmt.Errorf("creating report: %w", err)
+		}
+	}
+	switch reportFormat {
+	case "markdown":
+		_, err = io.WriteString(out, rep.markdown())
+	case "html":
+		err = rep.writeHTML(out)
+	default:
+		_, err = io.WriteString(out, rep.text())
+	}
+	if reportOutput != "" {
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("writing report: %w", err)
+	}
+	return nil
+}
+
+// buildReport compares the tree under basePath at since and until and
+// collects the records and scans in between.
+func buildReport(ctx context.Context, store storage.Storage, basePath string, since, until time.Time) (*report, error) {
+	before, err := store.GetSnapshotAt(ctx, basePath, "", since)
+	if err != nil {
+		return nil, fmt.Errorf("querying snapshot: %w", err)
+	}
+	after, err := store.GetSnapshotAt(ctx, basePath, "", until)
+	if err != nil {
+		return nil, fmt.Errorf("querying snapshot: %w", err)
+	}
+	records, err := store.QueryUsage(ctx, storage.QueryOptions{BasePath: basePath, Since: &since, Until: &until})
+	if err != nil {
+		return nil, fmt.Errorf("querying usage: %w", err)
+	}
+	scans, err := store.ListScans(ctx, storage.ScanListOptions{BasePath: basePath, Limit: reportScanLimit})
+	if err != nil {
+		return nil, fmt.Errorf("listing scans: %w", err)
+	}
+
+	diffs := diffSnapshots(before, after)
+	rep := &report{BasePath: basePath, Since: since, Until: until, Directories: diffs}
+	rep.Total, rep.History = reportHistory(before, records, diffs, since, until)
+	rep.Sections = append(rep.Sections, summarySection(diffs))
+
+	byStatus := make(map[string][]dirDiff)
+	for _, d := range diffs {
+		byStatus[d.status()] = append(byStatus[d.status()], d)
+	}
+	// diffs are ordered by the size of their change; new and deleted
+	// directories are listed largest first too
+	rep.Sections = append(rep.Sections,
+		changeSection("Top increases", byStatus["grown"], rep.History, "No directory grew."),
+		changeSection("Top decreases", byStatus["shrunk"], rep.History, "No directory shrank."),
+		sizeSection("New directories", byStatus["added"], rep.History, "No new directories.", func(d dirDiff) int64 { return *d.After }),
+		sizeSection("Deleted directories", byStatus["removed"], rep.History, "No directories were deleted.", func(d dirDiff) int64 { return *d.Before }),
+		scanSection(scans, since, until),
+	)
+	return rep, nil
+}
+
+// reportHistory replays the records of the period, newest first as
+// QueryUsage returns them, over the tree at its start, before. It returns
+// the total size after each scan and every directory's size over the
+// period; both start at since and end at until.
+func reportHistory(before, records []storage.UsageRecord, diffs []dirDiff, since, until time.Time) ([]sizePoint, map[string][]sizePoint) {
+	sizes := make(map[string]int64, len(before))
+	var total int64
+	for _, r := range before {
+		sizes[r.Directory] = r.SizeBytes
+		total += r.SizeBytes
+	}
+	totals := []sizePoint{{at: since, size: total}}
+	history := make(map[string][]sizePoint, len(diffs))
+	for _, d := range diffs {
+		if d.Before != nil {
+			history[d.Directory] = []sizePoint{{at: since, size: *d.Before}}
+		}
+	}
+
+	for i := len(records) - 1; i >= 0; i-- {
+		r := records[i]
+		if r.FileFilter != "" {
+			continue
+		}
+		size := r.SizeBytes
+		if r.Deleted {
+			size = 0
+		}
+		total += size - sizes[r.Directory]
+		sizes[r.Directory] = size
+		history[r.Directory] = append(history[r.Directory], sizePoint{at: r.RecordedAt, size: size})
+		// One total per scan: its records share a timestamp
+		if i == 0 || !records[i-1].RecordedAt.Equal(r.RecordedAt) {
+			totals = append(totals, sizePoint{at: r.RecordedAt, size: total})
+		}
+	}
+
+	totals = append(totals, sizePoint{at: until, size: total})
+	for dir, points := range history {
+		history[dir] = append(points, sizePoint{at: until, size: points[len(points)-1].size})
+	}
+	return totals, history
+}
+
+// summarySection totals the change of the whole tree.
+func summarySection(diffs []dirDiff) reportSection {
+	var before, after int64
+	var dirs, added, removed int
+	for _, d := range diffs {
+		if d.Before != nil {
+			before += *d.Before
+		}
+		if d.After != nil {
+			after += *d.After
+			dirs++
+		}
+		switch d.status() {
+		case "added":
+			added++
+		case "removed":
+			removed++
+		}
+	}
+	growth := signedSize(after - before)
+	if before > 0 {
+		growth += fmt.Sprintf(" (%+.1f%%)", 100*float64(after-before)/float64(before))
+	}
+	return reportSection{
+		Title: "Summary",
+		Facts: []reportFact{
+			{"Size at start", humanize.FormatSize(before)},
+			{"Size at end", humanize.FormatSize(after)},
+			{"Growth", growth},
+			{"Directories", fmt.Sprintf("%d (%d new, %d deleted)", dirs, added, removed)},
+		},
+	}
+}
+
+// changeSection lists the directories with the largest changes.
+func changeSection(title string, diffs []dirDiff, history map[string][]sizePoint, empty string) reportSection {
+	s := reportSection{Title: title, Headers: []string{"Directory", "Before", "After", "Change"}, Left: 1, Empty: empty}
+	for i, d := range diffs {
+		if i == reportTop {
+			s.More = len(diffs) - i
+			break
+		}
+		s.Rows = append(s.Rows, []string{d.Directory, optionalSize(d.Before), optionalSize(d.After), signedSize(d.change())})
+		s.History = append(s.History, history[d.Directory])
+	}
+	return s
+}
+
+// sizeSection lists the largest of directories that appeared or
+// disappeared, by the size size returns.
+func sizeSection(title string, diffs []dirDiff, history map[string][]sizePoint, empty string, size func(dirDiff) int64) reportSection {
+	s := reportSection{Title: title, Headers: []string{"Directory", "Size"}, Left: 1, Empty: empty}
+	for i, d := range diffs {
+		if i == reportTop {
+			s.More = len(diffs) - i
+			break
+		}
+		s.Rows = append(s.Rows, []string{d.Directory, humanize.FormatSize(size(d))})
+		s.History = append(s.History, history[d.Directory])
+	}
+	return s
+}
+
+// scanSection reports how reliably the path was scanned, listing the
+// failed scans.
+func scanSection(scans []storage.Scan, since, until time.Time) reportSection {
+	var completed, failed, skipped, interrupted, running int
+	var total, longest time.Duration
+	var failures []storage.Scan
+	for _, sc := range scans {
+		if sc.Trigger == storage.TriggerWatch || sc.StartedAt.Before(since) || sc.StartedAt.After(until) {
+			continue
+		}
+		switch {
+		case sc.Status == "completed":
+			completed++
+			if sc.CompletedAt != nil {
+				took := sc.CompletedAt.Sub(sc.StartedAt)
+				total += took
+				longest = max(longest, took)
+			}
+		case sc.Status == "running":
+			running++
+		case sc.Status == "interrupted":
+			interrupted++
+		case strings.HasPrefix(sc.Status, "skipped: "):
+			skipped++
+		default:
+			failed++
+			failures = append(failures, sc)
+		}
+	}
+
+	s := reportSection{Title: "Scans", Headers: []string{"Started", "Status"}, Left: 2, Empty: "No scans failed."}
+	attempted := completed + failed + interrupted
+	s.Facts = append(s.Facts, reportFact{"Scans", fmt.Sprintf("%d completed, %d failed, %d interrupted, %d skipped", completed, failed, interrupted, skipped)})
+	if running > 0 {
+		s.Facts[0].Value += fmt.Sprintf(", %d running", running)
+	}
+	if attempted > 0 {
+		s.Facts = append(s.Facts, reportFact{"Success rate", fmt.Sprintf("%.1f%%", 100*float64(completed)/float64(attempted))})
+	}
+	if completed > 0 {
+		s.Facts = append(s.Facts,
+			reportFact{"Average duration", (total / time.Duration(completed)).Round(time.Second).String()},
+			reportFact{"Longest duration", longest.Round(time.Second).String()},
+		)
+	}
+
+	// Oldest failure first, like the rest of the period
+	sort.Slice(failures, func(i, j int) bool { return failures[i].StartedAt.Before(failures[j].StartedAt) })
+	for i, sc := range failures {
+		if i == reportTop {
+			s.More = len(failures) - i
+			break
+		}
+		s.Rows = append(s.Rows, []string{sc.StartedAt.Local().Format("2006-01-02 15:04"), sc.Status})
+	}
+	return s
+}
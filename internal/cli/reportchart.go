@@ -0,0 +1,138 @@
+package cli
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"strings"
+	"time"
+
+	"github.com/jgalley/usgmon/internal/humanize"
+)
+
+// Most points drawn per chart, keeping reports with long or dense histories
+// small enough to email.
+const (
+	chartPoints     = 400
+	sparklinePoints = 60
+)
+
+// Sizes of the charts in HTML reports, in pixels.
+const (
+	chartWidth      = 720
+	chartHeight     = 220
+	chartMarginLeft = 80 // room for size labels
+	chartMarginEdge = 10
+	chartMarginAxis = 24 // room for date labels
+	sparklineWidth  = 120
+	sparklineHeight = 24
+)
+
+// thinPoints returns at most n of points, evenly spread and always keeping
+// the first and last.
+func thinPoints(points []sizePoint, n int) []sizePoint {
+	if len(points) <= n {
+		return points
+	}
+	out := make([]sizePoint, 0, n)
+	step := float64(len(points)-1) / float64(n-1)
+	for i := 0; i < n; i++ {
+		out = append(out, points[int(float64(i)*step+0.5)])
+	}
+	return out
+}
+
+// chartScale maps sizes over the period from since to until onto a plot of
+// the given size, whose top left corner is at x0, y0.
+type chartScale struct {
+	since, until  time.Time
+	lo, hi        int64
+	x0, y0        float64
+	width, height float64
+}
+
+// newChartScale fits points into a plot, padding a range of one size so the
+// line runs through the middle.
+func newChartScale(points []sizePoint, since, until time.Time, x0, y0, width, height float64) chartScale {
+	lo, hi := points[0].size, points[0].size
+	for _, p := range points {
+		lo, hi = min(lo, p.size), max(hi, p.size)
+	}
+	if lo == hi {
+		lo, hi = max(lo-1, 0), hi+1
+	}
+	return chartScale{since: since, until: until, lo: lo, hi: hi, x0: x0, y0: y0, width: width, height: height}
+}
+
+func (c chartScale) x(t time.Time) float64 {
+	span := c.until.Sub(c.since)
+	if span <= 0 {
+		return c.x0
+	}
+	return c.x0 + c.width*float64(t.Sub(c.since))/float64(span)
+}
+
+func (c chartScale) y(size int64) float64 {
+	return c.y0 + c.height*(1-float64(size-c.lo)/float64(c.hi-c.lo))
+}
+
+// polyline returns the SVG points attribute of a line through points.
+func (c chartScale) polyline(points []sizePoint) string {
+	coords := make([]string, len(points))
+	for i, p := range points {
+		coords[i] = fmt.Sprintf("%.1f,%.1f", c.x(p.at), c.y(p.size))
+	}
+	return strings.Join(coords, " ")
+}
+
+// svgChart draws points over the period from since to until as a line
+// chart labelled with its size range and dates.
+func svgChart(points []sizePoint, since, until time.Time) template.HTML {
+	if len(points) == 0 {
+		return ""
+	}
+	points = thinPoints(points, chartPoints)
+	plotW := float64(chartWidth - chartMarginLeft - chartMarginEdge)
+	plotH := float64(chartHeight - chartMarginEdge - chartMarginAxis)
+	c := newChartScale(points, since, until, chartMarginLeft, chartMarginEdge, plotW, plotH)
+	bottom := c.y0 + plotH
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg class="chart" xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`,
+		chartWidth, chartHeight, chartWidth, chartHeight)
+	// Gridlines and size labels at the top, middle and bottom
+	for _, frac := range []float64{0, 0.5, 1} {
+		size := c.hi - int64(frac*float64(c.hi-c.lo))
+		y := c.y0 + frac*plotH
+		fmt.Fprintf(&b, `<line x1="%d" y1="%.1f" x2="%.1f" y2="%.1f" stroke="#e4e4e4"/>`, chartMarginLeft, y, c.x0+plotW, y)
+		fmt.Fprintf(&b, `<text x="%d" y="%.1f" text-anchor="end" font-size="11" fill="#666">%s</text>`,
+			chartMarginLeft-6, y+4, html.EscapeString(humanize.FormatSize(size)))
+	}
+	fmt.Fprintf(&b, `<text x="%d" y="%d" font-size="11" fill="#666">%s</text>`,
+		chartMarginLeft, chartHeight-6, html.EscapeString(since.Local().Format("2006-01-02 15:04")))
+	fmt.Fprintf(&b, `<text x="%.1f" y="%d" text-anchor="end" font-size="11" fill="#666">%s</text>`,
+		c.x0+plotW, chartHeight-6, html.EscapeString(until.Local().Format("2006-01-02 15:04")))
+	fmt.Fprintf(&b, `<line x1="%d" y1="%.1f" x2="%.1f" y2="%.1f" stroke="#999"/>`, chartMarginLeft, bottom, c.x0+plotW, bottom)
+	fmt.Fprintf(&b, `<polyline fill="none" stroke="#2a6fdb" stroke-width="2" points="%s"/>`, c.polyline(points))
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+// svgSparkline draws points over the period from since to until as a small
+// unlabelled line, titled with its first and last size.
+func svgSparkline(points []sizePoint, since, until time.Time) template.HTML {
+	if len(points) == 0 {
+		return ""
+	}
+	points = thinPoints(points, sparklinePoints)
+	c := newChartScale(points, since, until, 1, 2, sparklineWidth-2, sparklineHeight-4)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg class="sparkline" xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`,
+		sparklineWidth, sparklineHeight, sparklineWidth, sparklineHeight)
+	fmt.Fprintf(&b, `<title>%s</title>`, html.EscapeString(fmt.Sprintf("%s → %s",
+		humanize.FormatSize(points[0].size), humanize.FormatSize(points[len(points)-1].size))))
+	fmt.Fprintf(&b, `<polyline fill="none" stroke="#2a6fdb" stroke-width="1.5" points="%s"/>`, c.polyline(points))
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
@@ -0,0 +1,267 @@
+package cli
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// heading returns the report's title and the period it covers.
+func (r *report) heading() (string, string) {
+	period := strings.ToUpper(r.Period[:1]) + r.Period[1:]
+	return "Usage report: " + r.BasePath,
+		fmt.Sprintf("%s, %s to %s", period, r.Since.Local().Format("2006-01-02 15:04"), r.Until.Local().Format("2006-01-02 15:04"))
+}
+
+// moreText describes the rows left out of a section's table.
+func (s reportSection) moreText() string {
+	return fmt.Sprintf("... and %d more", s.More)
+}
+
+// text renders the report as plain text.
+func (r *report) text() string {
+	var b strings.Builder
+	title, period := r.heading()
+	fmt.Fprintf(&b, "%s\n%s\n%s\n", title, strings.Repeat("=", len(title)), period)
+
+	for _, s := range r.Sections {
+		fmt.Fprintf(&b, "\n%s\n%s\n", s.Title, strings.Repeat("-", len(s.Title)))
+		w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+		for _, f := range s.Facts {
+			fmt.Fprintf(w, "%s:\t%s\n", f.Label, f.Value)
+		}
+		w.Flush()
+		if s.Headers == nil {
+			continue
+		}
+		if len(s.Facts) > 0 {
+			b.WriteString("\n")
+		}
+		if len(s.Rows) == 0 {
+			b.WriteString(s.Empty + "\n")
+			continue
+		}
+		w = tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+		header := strings.ToUpper(strings.Join(s.Headers, "\t"))
+		fmt.Fprintln(w, header)
+		fmt.Fprintln(w, ruleFor(header))
+		for _, row := range s.Rows {
+			fmt.Fprintln(w, strings.Join(row, "\t"))
+		}
+		w.Flush()
+		if s.More > 0 {
+			b.WriteString(s.moreText() + "\n")
+		}
+	}
+	return b.String()
+}
+
+// markdown renders the report as GitHub-flavored Markdown.
+func (r *report) markdown() string {
+	var b strings.Builder
+	title, period := r.heading()
+	fmt.Fprintf(&b, "# %s\n\n_%s_\n", markdownEscape(title), period)
+
+	for _, s := range r.Sections {
+		fmt.Fprintf(&b, "\n## %s\n\n", s.Title)
+		for _, f := range s.Facts {
+			fmt.Fprintf(&b, "- **%s:** %s\n", f.Label, f.Value)
+		}
+		if s.Headers == nil {
+			continue
+		}
+		if len(s.Facts) > 0 {
+			b.WriteString("\n")
+		}
+		if len(s.Rows) == 0 {
+			b.WriteString(s.Empty + "\n")
+			continue
+		}
+		fmt.Fprintf(&b, "| %s |\n|", strings.Join(s.Headers, " | "))
+		for i := range s.Headers {
+			if i < s.Left {
+				b.WriteString(" --- |")
+			} else {
+				b.WriteString(" ---: |")
+			}
+		}
+		b.WriteString("\n")
+		for _, row := range s.Rows {
+			cells := make([]string, len(row))
+			for i, c := range row {
+				cells[i] = markdownEscape(c)
+			}
+			fmt.Fprintf(&b, "| %s |\n", strings.Join(cells, " | "))
+		}
+		if s.More > 0 {
+			fmt.Fprintf(&b, "\n_%s_\n", s.moreText())
+		}
+	}
+	return b.String()
+}
+
+// markdownEscaper escapes the characters that change the meaning of
+// Markdown text and table cells.
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "|", `\|`, "*", `\*`, "_", `\_`, "`", "\\`", "[", `\[`, "]", `\]`, "<", `\<`, ">", `\>`,
+)
+
+// markdownEscape escapes s for use as Markdown text.
+func markdownEscape(s string) string {
+	return markdownEscaper.Replace(s)
+}
+
+// reportTemplate is a standalone HTML page of a report. The directory
+// table sorts by a column when its header is clicked, on the cell's
+// data-sort value if it has one.
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin: 0.5em 0; }
+th, td { padding: 0.25em 0.75em; border-bottom: 1px solid #ddd; }
+th { text-align: left; background: #f4f4f4; }
+.size { text-align: right; white-space: nowrap; }
+table.sortable th { cursor: pointer; }
+table.sortable th[aria-sort=ascending]::after { content: " \25B2"; }
+table.sortable th[aria-sort=descending]::after { content: " \25BC"; }
+dt { font-weight: bold; float: left; clear: left; width: 11em; }
+dd { margin: 0 0 0.25em 11em; }
+svg { display: block; }
+.period, .more, .empty { color: #666; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p class="period">{{.Period}}</p>
+{{- range .Sections}}
+<h2>{{.Title}}</h2>
+{{- if .Facts}}
+<dl>
+{{- range .Facts}}
+<dt>{{.Label}}</dt><dd>{{.Value}}</dd>
+{{- end}}
+</dl>
+{{- end}}
+{{- with .Chart}}
+{{.}}
+{{- end}}
+{{- if .Headers}}
+{{- if .Rows}}
+<table>
+{{- $left := .Left}}
+<tr>{{range $i, $h := .Headers}}<th{{if ge $i $left}} class="size"{{end}}>{{$h}}</th>{{end}}{{if .Charted}}<th>History</th>{{end}}</tr>
+{{- range .Rows}}
+<tr>{{range $i, $c := .Cells}}<td{{if ge $i $left}} class="size"{{end}}>{{$c}}</td>{{end}}{{with .Chart}}<td>{{.}}</td>{{end}}</tr>
+{{- end}}
+</table>
+{{- if .More}}
+<p class="more">{{.MoreText}}</p>
+{{- end}}
+{{- else}}
+<p class="empty">{{.Empty}}</p>
+{{- end}}
+{{- end}}
+{{- end}}
+{{- if .Directories}}
+<h2>All directories</h2>
+<table class="sortable">
+<tr><th>Directory</th><th class="size">Before</th><th class="size">After</th><th class="size">Change</th><th>Status</th><th>History</th></tr>
+{{- range .Directories}}
+<tr><td>{{.Directory}}</td><td class="size" data-sort="{{.BeforeBytes}}">{{.Before}}</td><td class="size" data-sort="{{.AfterBytes}}">{{.After}}</td><td class="size" data-sort="{{.ChangeBytes}}">{{.Change}}</td><td>{{.Status}}</td><td>{{.Chart}}</td></tr>
+{{- end}}
+</table>
+<script>
+document.querySelectorAll("table.sortable").forEach(function (table) {
+  var headers = table.querySelectorAll("th");
+  headers.forEach(function (th, col) {
+    th.addEventListener("click", function () {
+      var dir = th.getAttribute("aria-sort") === "descending" ? 1 : -1;
+      headers.forEach(function (h) { h.removeAttribute("aria-sort"); });
+      th.setAttribute("aria-sort", dir > 0 ? "ascending" : "descending");
+      var rows = Array.prototype.slice.call(table.rows, 1);
+      rows.sort(function (a, b) {
+        var x = a.cells[col], y = b.cells[col];
+        var xs = x.getAttribute("data-sort"), ys = y.getAttribute("data-sort");
+        if (xs !== null && ys !== null) {
+          return dir * (Number(xs) - Number(ys));
+        }
+        return dir * x.textContent.localeCompare(y.textContent);
+      });
+      rows.forEach(function (row) { table.tBodies[0].appendChild(row); });
+    });
+  });
+});
+</script>
+{{- end}}
+</body>
+</html>
+`))
+
+// writeHTML renders the report as a standalone HTML page.
+func (r *report) writeHTML(out io.Writer) error {
+	type row struct {
+		Cells []string
+		Chart template.HTML
+	}
+	type section struct {
+		reportSection
+		Rows     []row
+		Charted  bool
+		Chart    template.HTML
+		MoreText string
+	}
+	type directory struct {
+		Directory                            string
+		Before, After, Change, Status        string
+		BeforeBytes, AfterBytes, ChangeBytes int64
+		Chart                                template.HTML
+	}
+	title, period := r.heading()
+	data := struct {
+		Title       string
+		Period      string
+		Sections    []section
+		Directories []directory
+	}{Title: title, Period: period}
+
+	for i, s := range r.Sections {
+		sec := section{reportSection: s, Charted: s.History != nil, MoreText: s.moreText()}
+		// The summary charts the whole tree
+		if i == 0 {
+			sec.Chart = svgChart(r.Total, r.Since, r.Until)
+		}
+		for j, cells := range s.Rows {
+			rw := row{Cells: cells}
+			if s.History != nil {
+				rw.Chart = svgSparkline(s.History[j], r.Since, r.Until)
+			}
+			sec.Rows = append(sec.Rows, rw)
+		}
+		data.Sections = append(data.Sections, sec)
+	}
+	for _, d := range r.Directories {
+		dir := directory{
+			Directory:   d.Directory,
+			Before:      optionalSize(d.Before),
+			After:       optionalSize(d.After),
+			Change:      signedSize(d.change()),
+			Status:      d.status(),
+			ChangeBytes: d.change(),
+			Chart:       svgSparkline(r.History[d.Directory], r.Since, r.Until),
+		}
+		if d.Before != nil {
+			dir.BeforeBytes = *d.Before
+		}
+		if d.After != nil {
+			dir.AfterBytes = *d.After
+		}
+		data.Directories = append(data.Directories, dir)
+	}
+	return reportTemplate.Execute(out, data)
+}
@@ -0,0 +1,90 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	rollupOlderThan time.Duration
+	rollupPath      string
+	rollupYes       bool
+)
+
+var rollupCmd = &cobra.Command{
+	Use:   "rollup",
+	Short: "Replace old usage records with daily rollups",
+	Long: `Replace usage records older than --older-than with one record per directory,
+host and day holding the day's minimum, maximum and average size. Only whole UTC
+days are rolled up; measurements taken with file exclusions and deletion
+markers are kept as they are. Query commands read rollups alongside the
+remaining records, so long-term history survives with far fewer rows.
+
+The daemon can do this automatically after each scan with scan.rollup_after.
+Asks for confirmation unless --yes is passed; non-interactive invocations must
+pass --yes explicitly.
+
+Examples:
+  usgmon rollup --older-than 720h
+  usgmon rollup --older-than 2160h --path /www/users --yes`,
+	Args: cobra.NoArgs,
+	RunE: runRollup,
+}
+
+func init() {
+	rollupCmd.Flags().DurationVar(&rollupOlderThan, "older-than", 0, "roll up records older than this (e.g. 720h)")
+	rollupCmd.Flags().StringVar(&rollupPath, "path", "", "restrict to a single base path")
+	rollupCmd.Flags().BoolVarP(&rollupYes, "yes", "y", false, "skip the confirmation prompt")
+	rollupCmd.MarkFlagRequired("older-than")
+}
+
+func runRollup(cmd *cobra.Command, args []string) error {
+	if rollupOlderThan <= 0 {
+		return fmt.Errorf("--older-than must be positive")
+	}
+
+	ctx := context.Background()
+	_, store, err := openStorage(ctx)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	basePath := rollupPath
+	if basePath != "" {
+		basePath = filepath.Clean(basePath)
+	}
+	cutoff := time.Now().Add(-rollupOlderThan)
+
+	records, err := store.CountUsageToRollUp(ctx, basePath, cutoff)
+	if err != nil {
+		return fmt.Errorf("counting usage records: %w", err)
+	}
+	if records == 0 {
+		fmt.Println("Nothing to roll up")
+		return nil
+	}
+
+	prompt := fmt.Sprintf("Replace %d usage records from before %s with daily rollups?",
+		records, cutoff.UTC().Format("2006-01-02")+" UTC")
+	ok, err := confirm(prompt, rollupYes)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		fmt.Println("Aborted")
+		return nil
+	}
+
+	days, records, err := store.RollupUsageBefore(ctx, basePath, cutoff)
+	if err != nil {
+		return fmt.Errorf("rolling up usage: %w", err)
+	}
+
+	fmt.Printf("Replaced %d usage records with %d daily rollups\n", records, days)
+	return nil
+}
@@ -1,10 +1,14 @@
 package cli
 
 import (
+	"context"
+	"fmt"
 	"log/slog"
 	"os"
 	"strings"
 
+	"github.com/jgalley/usgmon/internal/config"
+	"github.com/jgalley/usgmon/pkg/storage"
 	"github.com/spf13/cobra"
 )
 
@@ -14,6 +18,16 @@ var (
 	rootCmd  *cobra.Command
 )
 
+// ExitError asks the caller of Execute to exit with Code. The command has
+// already reported its outcome, so there is nothing more to print.
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
 // Execute runs the root command.
 func Execute() error {
 	return rootCmd.Execute()
@@ -32,12 +46,54 @@ at configurable depths and stores historical data in SQLite for trend analysis.`
 	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "info", "log level (debug, info, warn, error)")
 
 	rootCmd.AddCommand(serveCmd)
+	rootCmd.AddCommand(statusCmd)
+	rootCmd.AddCommand(tuiCmd)
+	rootCmd.AddCommand(browseCmd)
+	rootCmd.AddCommand(checkCmd)
+	rootCmd.AddCommand(scanNowCmd)
 	rootCmd.AddCommand(scanCmd)
 	rootCmd.AddCommand(queryCmd)
 	rootCmd.AddCommand(topCmd)
+	rootCmd.AddCommand(topFilesCmd)
+	rootCmd.AddCommand(coldCmd)
+	rootCmd.AddCommand(atCmd)
+	rootCmd.AddCommand(latestCmd)
+	rootCmd.AddCommand(diffCmd)
+	rootCmd.AddCommand(anomaliesCmd)
+	rootCmd.AddCommand(reportCmd)
+	rootCmd.AddCommand(trendsCmd)
+	rootCmd.AddCommand(forecastCmd)
+	rootCmd.AddCommand(capacityCmd)
+	rootCmd.AddCommand(digestCmd)
+	rootCmd.AddCommand(listScansCmd)
+	rootCmd.AddCommand(pruneCmd)
+	rootCmd.AddCommand(rollupCmd)
+	rootCmd.AddCommand(skipCmd)
+	rootCmd.AddCommand(dbCmd)
 	rootCmd.AddCommand(versionCmd)
 }
 
+// openStorage loads the config and opens an initialized database.
+// The caller is responsible for closing the returned storage.
+func openStorage(ctx context.Context) (*config.Config, *storage.SQLiteStorage, error) {
+	cfg, err := config.Load(cfgFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("loading config: %w", err)
+	}
+
+	store, err := storage.NewSQLiteStorage(cfg.Database.Path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("opening database: %w", err)
+	}
+
+	if err := store.Initialize(ctx); err != nil {
+		store.Close()
+		return nil, nil, fmt.Errorf("initializing database: %w", err)
+	}
+
+	return cfg, store, nil
+}
+
 // setupLogger creates a logger based on the configured level.
 func setupLogger(level string, format string) *slog.Logger {
 	var lvl slog.Level
@@ -2,15 +2,18 @@ package cli
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
 	"sort"
+	"strings"
 	"text/tabwriter"
 	"time"
 
-	"github.com/jgalley/usgmon/internal/config"
-	"github.com/jgalley/usgmon/internal/scanner"
-	"github.com/jgalley/usgmon/internal/storage"
+	"github.com/jgalley/usgmon/internal/humanize"
+	"github.com/jgalley/usgmon/pkg/scanner"
+	"github.com/jgalley/usgmon/pkg/storage"
 	"github.com/spf13/cobra"
 )
 
@@ -18,6 +21,32 @@ var (
 	scanDepth          int
 	scanStore          bool
 	scanFollowSymlinks bool
+	scanOneFS          bool
+	scanFormat         string
+	scanExclude        []string
+	scanExcludeFiles   []string
+	scanNote           string
+	scanQuiet          bool
+	scanWorkers        int
+	scanTimeout        time.Duration
+	scanSort           string
+	scanMinSize        string
+	scanFingerprint    bool
+	scanAllocated      bool
+	scanSizeMode       string
+	scanLooseFiles     bool
+	scanOwner          bool
+	scanCounts         bool
+	scanDuBatch        int
+	scanWalkWorkers    int
+	scanDirTimeout     time.Duration
+	scanOpsLimit       int
+	scanDuWrapper      string
+	scanHardlinks      bool
+	scanByOwner        bool
+	scanLargest        int
+	scanAgeBreakdown   string
+	scanAgeBuckets     []int
 )
 
 var scanCmd = &cobra.Command{
@@ -29,15 +58,57 @@ Examples:
   usgmon scan /www/users/bob.com
   usgmon scan /www/users --depth 1
   usgmon scan /www/users --depth 1 --store
-  usgmon scan /www/users --depth 1 --follow-symlinks`,
+  usgmon scan /www/users --depth 1 --store --note "before archiving 2024 data"
+  usgmon scan /www/users --depth 1 --sort size --min-size 1G
+  usgmon scan /www/users --depth 1 --follow-symlinks
+  usgmon scan /data --depth 2 --store --workers 16 --timeout 4h
+  usgmon scan / --depth 1 -x
+  usgmon scan /www/users --depth 2 --format tree-json
+  usgmon scan /www/users --depth 1 --exclude '*.tmp' --exclude /www/users/old
+  usgmon scan /www/users --depth 1 --exclude-files '*.log'
+  usgmon scan /www/users --depth 1 --loose-files
+  usgmon scan /www/users --depth 1 --counts
+  usgmon scan /projects --depth 1 --by-owner --store
+  usgmon scan /www/users --depth 1 --largest-files 10
+  usgmon scan /projects --depth 1 --age-breakdown atime --age-buckets 30,90,365
+  usgmon scan /var/lib/libvirt/images --size-mode allocated
+  usgmon scan /www/users --depth 1 --du-batch 32
+  usgmon scan /www/users/huge --walk-workers 8
+  usgmon scan /mnt/nfs/home --depth 1 --du-wrapper "ionice -c3 nice -n19"`,
 	Args: cobra.ExactArgs(1),
 	RunE: runScan,
 }
 
 func init() {
-	scanCmd.Flags().IntVar(&scanDepth, "depth", 0, "scan depth (0 = scan the path itself)")
+	scanCmd.Flags().IntVar(&scanDepth, "depth", 0, "scan depth (0 = scan the path itself, -1 = leaf directories)")
 	scanCmd.Flags().BoolVar(&scanStore, "store", false, "store results in database")
+	scanCmd.Flags().IntVar(&scanWorkers, "workers", 4, "directories measured concurrently")
+	scanCmd.Flags().DurationVar(&scanTimeout, "timeout", 10*time.Minute, "give up on the scan after this long (0 = no limit)")
 	scanCmd.Flags().BoolVarP(&scanFollowSymlinks, "follow-symlinks", "L", false, "follow symbolic links")
+	scanCmd.Flags().BoolVarP(&scanOneFS, "one-file-system", "x", false, "skip directories on different file systems, like du -x")
+	scanCmd.Flags().StringVar(&scanFormat, "format", "text", "output format (text, json, ndjson, tree-json, csv)")
+	scanCmd.Flags().StringVar(&scanSort, "sort", "name", "order of the output: name, size (largest first) or duration (slowest first)")
+	scanCmd.Flags().StringVar(&scanMinSize, "min-size", "0", "only show directories of at least this size (e.g., \"100M\", \"1G\")")
+	scanCmd.Flags().BoolVar(&scanFingerprint, "fingerprint", false, "compute change fingerprints per directory (forces walk strategy)")
+	scanCmd.Flags().BoolVar(&scanAllocated, "allocated", false, "also measure allocated (on-disk) size (forces walk strategy); same as --size-mode both")
+	scanCmd.Flags().StringVar(&scanSizeMode, "size-mode", "", "what sizes measure: apparent (sum of file lengths), allocated (disk blocks), or both (forces walk strategy)")
+	scanCmd.Flags().BoolVar(&scanLooseFiles, "loose-files", false, "also report files directly in intermediate directories as <dir>/(files)")
+	scanCmd.Flags().BoolVar(&scanOwner, "owner", false, "also record each directory's owning user and group")
+	scanCmd.Flags().BoolVar(&scanCounts, "counts", false, "also count files and subdirectories (uses walk unless CephFS xattrs are available)")
+	scanCmd.Flags().BoolVar(&scanByOwner, "by-owner", false, "also measure each directory's size per file owner (forces walk strategy)")
+	scanCmd.Flags().IntVar(&scanLargest, "largest-files", 0, "also find the N largest files under each directory (forces walk strategy)")
+	scanCmd.Flags().StringVar(&scanAgeBreakdown, "age-breakdown", "", "also split each directory's size by file age, taken from mtime or atime (forces walk strategy)")
+	scanCmd.Flags().IntSliceVar(&scanAgeBuckets, "age-buckets", scanner.DefaultAgeDays, "comma-separated age bucket bounds in days for --age-breakdown")
+	scanCmd.Flags().BoolVar(&scanHardlinks, "dedupe-hardlinks", false, "count hard-linked files once per directory when walking, as du does")
+	scanCmd.Flags().IntVar(&scanDuBatch, "du-batch", scanner.DefaultDuBatchSize, "measure up to N directories per du invocation (0 = one at a time)")
+	scanCmd.Flags().DurationVar(&scanDirTimeout, "dir-timeout", 0, "give up measuring any one directory after this long and report it as an error (0 = no limit)")
+	scanCmd.Flags().IntVar(&scanOpsLimit, "ops-limit", 0, "read at most N entries per second when walking (0 = unlimited)")
+	scanCmd.Flags().StringVar(&scanDuWrapper, "du-wrapper", "", "command to run du under, e.g. \"ionice -c3 nice -n19\"")
+	scanCmd.Flags().IntVar(&scanWalkWorkers, "walk-workers", 1, "goroutines walking each directory's tree (plain walks on Linux only)")
+	scanCmd.Flags().BoolVarP(&scanQuiet, "quiet", "q", false, "do not report progress on stderr")
+	scanCmd.Flags().StringVar(&scanNote, "note", "", "free-text note attached to the stored scan")
+	scanCmd.Flags().StringSliceVar(&scanExclude, "exclude", nil, "paths to skip, or du-style globs to leave out of sizes")
+	scanCmd.Flags().StringSliceVar(&scanExcludeFiles, "exclude-files", nil, "file name globs to leave out of sizes (forces walk strategy)")
 }
 
 func runScan(cmd *cobra.Command, args []string) error {
@@ -52,121 +123,383 @@ func runScan(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("%s is not a directory", path)
 	}
 
+	switch scanFormat {
+	case "text", "json", "ndjson", "tree-json", "csv":
+	default:
+		return fmt.Errorf("invalid --format value: must be \"text\", \"json\", \"ndjson\", \"tree-json\", or \"csv\"")
+	}
+
+	switch scanSort {
+	case "name", "size", "duration":
+	default:
+		return fmt.Errorf("invalid --sort value %q (valid: name, size, duration)", scanSort)
+	}
+	minSize, err := humanize.ParseSize(scanMinSize)
+	if err != nil {
+		return fmt.Errorf("invalid --min-size value: %w", err)
+	}
+	// ndjson prints in completion order and trees roll sizes up to parents
+	if scanFormat == "ndjson" && cmd.Flags().Changed("sort") {
+		return fmt.Errorf("--sort cannot be used with --format ndjson")
+	}
+	if scanFormat == "tree-json" && (cmd.Flags().Changed("sort") || minSize > 0) {
+		return fmt.Errorf("--sort and --min-size cannot be used with --format tree-json")
+	}
+
+	sizeMode := scanSizeMode
+	switch {
+	case sizeMode == "" && scanAllocated:
+		sizeMode = scanner.SizeBoth
+	case sizeMode == "":
+		sizeMode = scanner.SizeApparent
+	case !scanner.ValidSizeMode(sizeMode):
+		return fmt.Errorf("invalid --size-mode value: must be one of %s", strings.Join(scanner.SizeModes, ", "))
+	case scanAllocated && sizeMode != scanner.SizeBoth:
+		return fmt.Errorf("--allocated cannot be combined with --size-mode %s", sizeMode)
+	}
+
+	if scanWorkers < 1 {
+		return fmt.Errorf("--workers must be at least 1")
+	}
+	if scanTimeout < 0 {
+		return fmt.Errorf("--timeout must be non-negative")
+	}
+
 	logger := setupLogger(logLevel, "text")
 
 	// Create scanner
-	s := scanner.New(4, nil) // auto-detect strategy
+	s := scanner.New(scanWorkers, nil) // auto-detect strategy
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
-	defer cancel()
+	ctx := context.Background()
+	if scanTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, scanTimeout)
+		defer cancel()
+	}
 
 	opts := scanner.ScanOptions{
-		FollowSymlinks: scanFollowSymlinks,
+		FollowSymlinks:  scanFollowSymlinks,
+		OneFileSystem:   scanOneFS,
+		Exclude:         scanExclude,
+		ExcludeFiles:    scanExcludeFiles,
+		Fingerprint:     scanFingerprint,
+		SizeMode:        sizeMode,
+		LooseFiles:      scanLooseFiles,
+		Owner:           scanOwner,
+		Counts:          scanCounts,
+		BatchSize:       scanDuBatch,
+		WalkWorkers:     scanWalkWorkers,
+		DirTimeout:      scanDirTimeout,
+		DuWrapper:       strings.Fields(scanDuWrapper),
+		DedupeHardlinks: scanHardlinks,
+		ByOwner:         scanByOwner,
+		LargestFiles:    scanLargest,
+	}
+	if scanAgeBreakdown != "" {
+		opts.AgeDays, opts.AgeTime = scanAgeBuckets, scanAgeBreakdown
+	}
+	if scanDuBatch < 0 {
+		return fmt.Errorf("--du-batch must be non-negative")
+	}
+	if scanWalkWorkers < 1 {
+		return fmt.Errorf("--walk-workers must be at least 1")
+	}
+	if scanDirTimeout < 0 {
+		return fmt.Errorf("--dir-timeout must be non-negative")
+	}
+	if scanOpsLimit < 0 {
+		return fmt.Errorf("--ops-limit must be non-negative")
+	}
+	if scanOpsLimit > 0 {
+		opts.Limiter = scanner.NewRateLimiter(scanOpsLimit)
+	}
+	if scanLargest < 0 || scanLargest > scanner.MaxLargestFiles {
+		return fmt.Errorf("--largest-files must be between 0 and %d", scanner.MaxLargestFiles)
+	}
+	if scanAgeBreakdown != "" {
+		if !scanner.ValidAgeTime(scanAgeBreakdown) {
+			return fmt.Errorf("invalid --age-breakdown value: must be one of %s", strings.Join(scanner.AgeTimes, ", "))
+		}
+		if err := scanner.ValidateAgeDays(scanAgeBuckets); err != nil {
+			return fmt.Errorf("invalid --age-buckets value: %w", err)
+		}
+	}
+	for _, exc := range scanExclude {
+		if err := scanner.ValidateExclude(exc); err != nil {
+			return fmt.Errorf("--exclude: %w", err)
+		}
+	}
+	for _, pattern := range scanExcludeFiles {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid --exclude-files pattern %q: %w", pattern, err)
+		}
+	}
+
+	// Scans below the path report their progress as results stream in
+	var progress *scanProgress
+	if scanDepth != 0 && !scanQuiet {
+		progress = startScanProgress()
+		opts.Discovered = progress.discover
+	}
+
+	var rec *scanRecorder
+	if scanStore {
+		if rec, err = startScanRecorder(ctx, path, s, sizeMode); err != nil {
+			progress.stop()
+			return err
+		}
+	}
+	results, found, err := streamScan(ctx, s, path, opts, minSize, progress, rec)
+	progress.stop()
+	if rec != nil {
+		if err == nil {
+			err = rec.complete(ctx, logger)
+		}
+		rec.close(err, logger)
+	}
+	if err != nil {
+		return err
 	}
 
+	if found == 0 && scanDepth > 0 {
+		if deepest, err := scanner.DeepestLevel(path, scanDepth, opts); err == nil && deepest < scanDepth {
+			logger.Warn("depth exceeds tree depth, no directories found",
+				"depth", scanDepth,
+				"deepest_level", deepest,
+				"hint", fmt.Sprintf("use --depth %d, or --depth -1 for leaf directories", deepest),
+			)
+		}
+	}
+
+	results = filterScanResults(results, minSize)
+	sortScanResults(results, scanSort)
+
+	// Print results
+	var outErr error
+	switch scanFormat {
+	case "ndjson":
+		// Already printed
+	case "json":
+		outErr = outputScanJSON(results)
+	case "tree-json":
+		outErr = outputScanTreeJSON(path, results)
+	case "csv":
+		outErr = writeColumnsCSV(os.Stdout, scanCSVColumns, results)
+	default:
+		outErr = outputScanText(results, sizeMode)
+	}
+	return outErr
+}
+
+// streamScan scans path, passing each result to progress and rec and
+// printing it at once for ndjson if it is at least minSize. It returns the
+// results, unless they were printed, and how many there were.
+func streamScan(ctx context.Context, s *scanner.Scanner, path string, opts scanner.ScanOptions, minSize int64, progress *scanProgress, rec *scanRecorder) ([]scanner.Result, int, error) {
 	var results []scanner.Result
+	found := 0
+	ndjson := json.NewEncoder(os.Stdout)
+
+	handle := func(r scanner.Result) error {
+		found++
+		if progress != nil {
+			progress.complete(r)
+		}
+		if rec != nil {
+			if err := rec.add(ctx, r); err != nil {
+				return err
+			}
+		}
+		if scanFormat != "ndjson" {
+			results = append(results, r)
+			return nil
+		}
+		if !shownScanResult(r, minSize) {
+			return nil
+		}
+		return progress.write(func() error { return ndjson.Encode(scanJSONRecordOf(r)) })
+	}
 
 	if scanDepth == 0 {
 		// Scan single directory
 		result, err := s.ScanSingleWithOptions(ctx, path, opts)
 		if err != nil {
-			return fmt.Errorf("scan failed: %w", err)
+			return nil, 0, fmt.Errorf("scan failed: %w", err)
 		}
-		results = []scanner.Result{result}
-	} else {
-		// Scan at depth
-		var err error
-		results, err = s.ScanPathWithOptions(ctx, path, scanDepth, opts)
-		if err != nil {
-			return fmt.Errorf("scan failed: %w", err)
+		return results, found, handle(result)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	resultCh, err := s.ScanPathStreaming(ctx, path, scanDepth, opts)
+	if err != nil {
+		return nil, 0, fmt.Errorf("scan failed: %w", err)
+	}
+	for r := range resultCh {
+		if err := handle(r); err != nil {
+			return nil, 0, err
 		}
 	}
+	if err := ctx.Err(); err != nil {
+		return nil, 0, fmt.Errorf("scan failed: %w", err)
+	}
+	return results, found, nil
+}
 
-	// Sort results by path
+// shownScanResult reports whether a result is printed under --min-size.
+// Failed directories are always shown, as their size is unknown.
+func shownScanResult(r scanner.Result, minSize int64) bool {
+	return r.Error != nil || r.SizeBytes >= minSize
+}
+
+// filterScanResults drops the results too small to show.
+func filterScanResults(results []scanner.Result, minSize int64) []scanner.Result {
+	if minSize <= 0 {
+		return results
+	}
+	kept := results[:0]
+	for _, r := range results {
+		if shownScanResult(r, minSize) {
+			kept = append(kept, r)
+		}
+	}
+	return kept
+}
+
+// sortScanResults orders results by path, by size largest first, or by
+// duration slowest first, breaking ties by path.
+func sortScanResults(results []scanner.Result, by string) {
 	sort.Slice(results, func(i, j int) bool {
-		return results[i].Path < results[j].Path
+		a, b := results[i], results[j]
+		switch {
+		case by == "size" && a.SizeBytes != b.SizeBytes:
+			return a.SizeBytes > b.SizeBytes
+		case by == "duration" && a.Duration != b.Duration:
+			return a.Duration > b.Duration
+		}
+		return a.Path < b.Path
 	})
+}
 
-	// Print results
+func outputScanText(results []scanner.Result, sizeMode string) error {
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
 	for _, r := range results {
 		if r.Error != nil {
 			fmt.Fprintf(w, "%s\t(error: %v)\n", r.Path, r.Error)
 		} else {
-			fmt.Fprintf(w, "%s\t%s\n", r.Path, formatSize(r.SizeBytes))
+			size := humanize.FormatSize(r.SizeBytes)
+			// In the allocated mode the size already is the allocated size
+			if r.AllocatedBytes != nil && sizeMode != scanner.SizeAllocated {
+				size += fmt.Sprintf("\t(%s allocated)", humanize.FormatSize(*r.AllocatedBytes))
+			}
+			if r.FileCount != nil && r.DirCount != nil {
+				size += fmt.Sprintf("\t%d files, %d dirs", *r.FileCount, *r.DirCount)
+			}
+			if r.Owner != "" {
+				size += fmt.Sprintf("\t%s:%s", r.Owner, r.Group)
+			}
+			if used := r.QuotaUsed(); used >= 0 {
+				size += fmt.Sprintf("\t(quota %s, %.0f%% used)", humanize.FormatSize(r.QuotaLimit), used)
+			}
+			if scanSort == "duration" {
+				size += fmt.Sprintf("\t%s", r.Duration.Round(time.Millisecond))
+			}
+			fmt.Fprintf(w, "%s\t%s\n", r.Path, size)
+			for _, o := range storage.NewOwnerUsage(r.OwnerBytes, scanner.UserName) {
+				fmt.Fprintf(w, "  %s\t%s\n", o.Owner, humanize.FormatSize(o.SizeBytes))
+			}
+			for _, f := range r.LargestFiles {
+				fmt.Fprintf(w, "  %s\t%s\n", f.Path, humanize.FormatSize(f.SizeBytes))
+			}
+			for _, a := range storage.NewAgeUsage(scanAgeBreakdown, scanAgeBuckets, r.AgeBytes) {
+				fmt.Fprintf(w, "  %s %s\t%s\n", a.Basis, a.Label(), humanize.FormatSize(a.SizeBytes))
+			}
 		}
 	}
-	w.Flush()
-
-	// Store results if requested
-	if scanStore {
-		cfg, err := config.Load(cfgFile)
-		if err != nil {
-			return fmt.Errorf("loading config: %w", err)
-		}
-
-		store, err := storage.NewSQLiteStorage(cfg.Database.Path)
-		if err != nil {
-			return fmt.Errorf("opening database: %w", err)
-		}
-		defer store.Close()
+	return w.Flush()
+}
 
-		if err := store.Initialize(ctx); err != nil {
-			return fmt.Errorf("initializing database: %w", err)
-		}
+type scanJSONRecord struct {
+	Path         string  `json:"path"`
+	SizeBytes    int64   `json:"size_bytes"`
+	SizeHuman    string  `json:"size_human"`
+	SymlinkCount *int64  `json:"symlink_count,omitempty"`
+	Fingerprint  string  `json:"fingerprint,omitempty"`
+	Allocated    *int64  `json:"allocated_bytes,omitempty"`
+	Owner        string  `json:"owner,omitempty"`
+	Group        string  `json:"group,omitempty"`
+	QuotaLimit   int64   `json:"quota_limit_bytes,omitempty"`
+	FileCount    *int64  `json:"file_count,omitempty"`
+	DirCount     *int64  `json:"dir_count,omitempty"`
+	Strategy     string  `json:"strategy"`
+	Duration     float64 `json:"duration_seconds"`
+	Error        string  `json:"error,omitempty"`
 
-		scanID, err := store.StartScan(ctx, path)
-		if err != nil {
-			return fmt.Errorf("creating scan record: %w", err)
-		}
-
-		now := time.Now().UTC()
-		records := make([]storage.UsageRecord, 0, len(results))
-		for _, r := range results {
-			if r.Error == nil {
-				records = append(records, storage.UsageRecord{
-					BasePath:   path,
-					Directory:  r.Path,
-					SizeBytes:  r.SizeBytes,
-					RecordedAt: now,
-					ScanID:     scanID,
-				})
-			}
-		}
+	OwnerUsage []storage.OwnerUsage `json:"owner_usage,omitempty"`
+	LargeFiles []storage.LargeFile  `json:"largest_files,omitempty"`
+	AgeUsage   []storage.AgeUsage   `json:"age_usage,omitempty"`
+}
 
-		if err := store.RecordUsageBatch(ctx, records); err != nil {
-			return fmt.Errorf("storing results: %w", err)
+// scanCSVColumns are the columns of scan --format csv, the single-valued
+// fields of its JSON output.
+var scanCSVColumns = []column[scanner.Result]{
+	{Name: "path", JSON: func(r scanner.Result) interface{} { return r.Path }},
+	{Name: "size_bytes", JSON: func(r scanner.Result) interface{} { return r.SizeBytes }},
+	{Name: "allocated_bytes", JSON: func(r scanner.Result) interface{} { return r.AllocatedBytes }},
+	{Name: "symlink_count", JSON: func(r scanner.Result) interface{} { return r.SymlinkCount }},
+	{Name: "file_count", JSON: func(r scanner.Result) interface{} { return r.FileCount }},
+	{Name: "dir_count", JSON: func(r scanner.Result) interface{} { return r.DirCount }},
+	{Name: "owner", JSON: func(r scanner.Result) interface{} { return r.Owner }},
+	{Name: "group", JSON: func(r scanner.Result) interface{} { return r.Group }},
+	{Name: "quota_limit_bytes", JSON: func(r scanner.Result) interface{} {
+		if r.QuotaLimit <= 0 {
+			return nil
 		}
-
-		if err := store.CompleteScan(ctx, scanID, len(records)); err != nil {
-			return fmt.Errorf("completing scan: %w", err)
+		return r.QuotaLimit
+	}},
+	{Name: "fingerprint", JSON: func(r scanner.Result) interface{} { return r.Fingerprint }},
+	{Name: "strategy", JSON: func(r scanner.Result) interface{} { return r.Strategy }},
+	{Name: "duration_seconds", JSON: func(r scanner.Result) interface{} { return r.Duration.Seconds() }},
+	{Name: "error", JSON: func(r scanner.Result) interface{} {
+		if r.Error == nil {
+			return nil
 		}
+		return r.Error.Error()
+	}},
+}
 
-		logger.Info("results stored", "count", len(records))
+// scanJSONRecordOf converts a result for JSON and ndjson output.
+func scanJSONRecordOf(r scanner.Result) scanJSONRecord {
+	record := scanJSONRecord{
+		Path:         r.Path,
+		SizeBytes:    r.SizeBytes,
+		SizeHuman:    humanize.FormatSize(r.SizeBytes),
+		SymlinkCount: r.SymlinkCount,
+		Fingerprint:  r.Fingerprint,
+		Allocated:    r.AllocatedBytes,
+		Owner:        r.Owner,
+		Group:        r.Group,
+		QuotaLimit:   r.QuotaLimit,
+		FileCount:    r.FileCount,
+		DirCount:     r.DirCount,
+		Strategy:     r.Strategy,
+		Duration:     r.Duration.Seconds(),
+		OwnerUsage:   storage.NewOwnerUsage(r.OwnerBytes, scanner.UserName),
+		LargeFiles:   largeFiles(r.LargestFiles),
+		AgeUsage:     storage.NewAgeUsage(scanAgeBreakdown, scanAgeBuckets, r.AgeBytes),
 	}
-
-	return nil
+	if r.Error != nil {
+		record.Error = r.Error.Error()
+	}
+	return record
 }
 
-// formatSize formats bytes as human-readable size.
-func formatSize(bytes int64) string {
-	const (
-		KiB = 1024
-		MiB = KiB * 1024
-		GiB = MiB * 1024
-		TiB = GiB * 1024
-	)
-
-	switch {
-	case bytes >= TiB:
-		return fmt.Sprintf("%.2f TiB", float64(bytes)/float64(TiB))
-	case bytes >= GiB:
-		return fmt.Sprintf("%.2f GiB", float64(bytes)/float64(GiB))
-	case bytes >= MiB:
-		return fmt.Sprintf("%.2f MiB", float64(bytes)/float64(MiB))
-	case bytes >= KiB:
-		return fmt.Sprintf("%.2f KiB", float64(bytes)/float64(KiB))
-	default:
-		return fmt.Sprintf("%d B", bytes)
+func outputScanJSON(results []scanner.Result) error {
+	records := make([]scanJSONRecord, len(results))
+	for i, r := range results {
+		records[i] = scanJSONRecordOf(r)
 	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(records)
 }
@@ -0,0 +1,147 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"time"
+
+	"github.com/jgalley/usgmon/internal/daemon"
+	"github.com/spf13/cobra"
+)
+
+var (
+	scanNowDepth  int
+	scanNowWait   bool
+	scanNowFormat string
+	scanNowSocket string
+)
+
+var scanNowCmd = &cobra.Command{
+	Use:   "scan-now <path>",
+	Short: "Ask the running daemon to scan a configured path now",
+	Long: `Ask the running daemon, over its control socket, to scan a configured path
+immediately, outside its schedule, and print the new scan's ID. The scan is
+recorded like any other with the scan-now trigger, and the path's regular
+schedule is unaffected.
+
+Pass --depth when the path is configured more than once. Fails if the path is
+not configured or is already being scanned. With --wait, the command returns
+once the scan has finished and exits non-zero if it failed.
+
+Examples:
+  usgmon scan-now /www/users
+  usgmon scan-now /www/users --depth 2
+  usgmon scan-now /www/users --wait`,
+	Args: cobra.ExactArgs(1),
+	RunE: runScanNow,
+}
+
+func init() {
+	scanNowCmd.Flags().IntVar(&scanNowDepth, "depth", 0, "depth of the path's configuration, if it is configured more than once")
+	scanNowCmd.Flags().BoolVar(&scanNowWait, "wait", false, "wait for the scan to finish")
+	scanNowCmd.Flags().StringVar(&scanNowFormat, "format", "text", "output format (text, json)")
+	scanNowCmd.Flags().StringVar(&scanNowSocket, "socket", "", "control socket path (default: control.socket from the config)")
+}
+
+func runScanNow(cmd *cobra.Command, args []string) error {
+	path, err := filepath.Abs(args[0])
+	if err != nil {
+		return fmt.Errorf("resolving path: %w", err)
+	}
+
+	socket, err := controlSocket(scanNowSocket)
+	if err != nil {
+		return err
+	}
+
+	var depth *int
+	if cmd.Flags().Changed("depth") {
+		depth = &scanNowDepth
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	reqCtx, reqCancel := context.WithTimeout(ctx, 30*time.Second)
+	ts, err := daemon.TriggerScan(reqCtx, socket, path, depth)
+	reqCancel()
+	if err != nil {
+		return err
+	}
+
+	if !scanNowWait {
+		if scanNowFormat == "json" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(ts)
+		}
+		fmt.Printf("Started scan %s of %s (depth %d)\n", ts.ScanID, ts.Path, ts.Depth)
+		return nil
+	}
+
+	if scanNowFormat != "json" {
+		fmt.Printf("Started scan %s of %s (depth %d), waiting for it to finish\n", ts.ScanID, ts.Path, ts.Depth)
+	}
+	last, err := waitForScan(ctx, socket, ts)
+	if err != nil {
+		return err
+	}
+
+	if scanNowFormat == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(last); err != nil {
+			return err
+		}
+	} else {
+		duration := time.Duration(last.DurationSeconds * float64(time.Second)).Round(time.Second)
+		fmt.Printf("Scan %s %s after %s, %d directories\n", last.ScanID, last.Status, duration, last.Directories)
+	}
+	if last.Status != "completed" {
+		msg := last.Status
+		if last.Error != "" {
+			msg += ": " + last.Error
+		}
+		return fmt.Errorf("scan %s", msg)
+	}
+	return nil
+}
+
+// waitForScan polls the daemon's status until the triggered scan has
+// finished and returns its outcome.
+func waitForScan(ctx context.Context, socket string, ts *daemon.TriggeredScan) (*daemon.LastScan, error) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("stopped waiting; scan %s continues in the daemon", ts.ScanID)
+		case <-ticker.C:
+		}
+
+		st, err := daemon.FetchStatus(ctx, socket)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range st.Paths {
+			if p.Path == ts.Path && p.Depth == ts.Depth && p.LastScan != nil && p.LastScan.ScanID == ts.ScanID {
+				return p.LastScan, nil
+			}
+		}
+		running := false
+		for _, sc := range st.Scans {
+			if sc.ScanID == ts.ScanID {
+				running = true
+			}
+		}
+		if !running {
+			// Finished, but the path was removed or the daemon restarted
+			return nil, fmt.Errorf("scan %s is no longer running; see usgmon list-scans for its outcome", ts.ScanID)
+		}
+	}
+}
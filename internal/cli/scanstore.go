@@ -0,0 +1,200 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/jgalley/usgmon/internal/config"
+	"github.com/jgalley/usgmon/internal/rusage"
+	"github.com/jgalley/usgmon/pkg/scanner"
+	"github.com/jgalley/usgmon/pkg/storage"
+)
+
+// scanStoreBatch is the number of records scan --store accumulates before
+// inserting them, as the daemon does.
+const scanStoreBatch = 100
+
+// scanRecorder stores the results of scan --store as they arrive, so a
+// large scan neither holds every record nor loses them all if it fails.
+type scanRecorder struct {
+	cfg      *config.Config
+	store    *storage.SQLiteStorage
+	path     string
+	hostname string
+	scanID   string
+	batch    []storage.UsageRecord
+	stored   int
+	done     bool // the scan was completed or failed
+
+	// CPU time used before the scan, for recording its cost as the daemon does
+	cpuBefore rusage.CPUTime
+	cpuErr    error
+}
+
+// startScanRecorder opens the database and starts a manual scan of path,
+// recording the scan's options as metadata if the config asks for it.
+func startScanRecorder(ctx context.Context, path string, s *scanner.Scanner, sizeMode string) (*scanRecorder, error) {
+	cfg, err := config.Load(cfgFile)
+	if err != nil {
+		return nil, fmt.Errorf("loading config: %w", err)
+	}
+
+	store, err := storage.NewSQLiteStorage(cfg.Database.Path)
+	if err != nil {
+		return nil, fmt.Errorf("opening database: %w", err)
+	}
+	if err := store.Initialize(ctx); err != nil {
+		store.Close()
+		return nil, fmt.Errorf("initializing database: %w", err)
+	}
+
+	rec := &scanRecorder{
+		cfg:      cfg,
+		store:    store,
+		path:     path,
+		hostname: cfg.Scan.Host(),
+		batch:    make([]storage.UsageRecord, 0, scanStoreBatch),
+	}
+	startOpts := storage.StartScanOptions{
+		Note:     scanNote,
+		Trigger:  storage.TriggerManual,
+		Hostname: rec.hostname,
+	}
+	if cfg.Scan.RecordMetadata {
+		m := storage.NewScanMetadata(Version)
+		m.Depth = scanDepth
+		m.FollowSymlinks = scanFollowSymlinks
+		m.OneFileSystem = scanOneFS
+		m.Strategy = s.Strategy()
+		m.Workers = scanWorkers
+		m.Exclude = scanExclude
+		m.ExcludeFiles = scanExcludeFiles
+		m.LooseFiles = scanLooseFiles
+		m.Fingerprint = scanFingerprint
+		m.AllocatedSize = sizeMode == scanner.SizeBoth
+		m.SizeMode = sizeMode
+		m.CountEntries = scanCounts
+		m.DuBatchSize = scanDuBatch
+		if scanWalkWorkers > 1 {
+			m.WalkWorkers = scanWalkWorkers
+		}
+		m.DedupeHardlinks = scanHardlinks
+		m.UsageByOwner = scanByOwner
+		m.LargestFiles = scanLargest
+		if scanAgeBreakdown != "" {
+			m.AgeBreakdown, m.AgeBuckets = scanAgeBreakdown, scanAgeBuckets
+		}
+		startOpts.Metadata = m
+	}
+	if rec.scanID, err = store.StartScan(ctx, path, startOpts); err != nil {
+		store.Close()
+		return nil, fmt.Errorf("creating scan record: %w", err)
+	}
+	rec.cpuBefore, rec.cpuErr = rusage.Read()
+	return rec, nil
+}
+
+// add queues the record of a result, writing the batch once it is full.
+// Failed directories are not recorded.
+func (rec *scanRecorder) add(ctx context.Context, r scanner.Result) error {
+	if r.Error != nil {
+		return nil
+	}
+	var quota *int64
+	if r.QuotaLimit > 0 {
+		quota = &r.QuotaLimit
+	}
+	rec.batch = append(rec.batch, storage.UsageRecord{
+		BasePath:       rec.path,
+		Directory:      r.Path,
+		SizeBytes:      r.SizeBytes,
+		RecordedAt:     time.Now().UTC(),
+		ScanID:         rec.scanID,
+		FileFilter:     strings.Join(scanExcludeFiles, ","),
+		SymlinkCount:   r.SymlinkCount,
+		Fingerprint:    r.Fingerprint,
+		AllocatedBytes: r.AllocatedBytes,
+		Owner:          r.Owner,
+		Group:          r.Group,
+		QuotaLimit:     quota,
+		FileCount:      r.FileCount,
+		DirCount:       r.DirCount,
+		Hostname:       rec.hostname,
+		OwnerUsage:     storage.NewOwnerUsage(r.OwnerBytes, scanner.UserName),
+		LargeFiles:     largeFiles(r.LargestFiles),
+		AgeUsage:       storage.NewAgeUsage(scanAgeBreakdown, scanAgeBuckets, r.AgeBytes),
+	})
+	if len(rec.batch) >= scanStoreBatch {
+		return rec.flush(ctx)
+	}
+	return nil
+}
+
+// flush writes the queued records.
+func (rec *scanRecorder) flush(ctx context.Context) error {
+	if len(rec.batch) == 0 {
+		return nil
+	}
+	if err := rec.store.RecordUsageBatch(ctx, rec.batch); err != nil {
+		return fmt.Errorf("storing results: %w", err)
+	}
+	rec.stored += len(rec.batch)
+	rec.batch = rec.batch[:0]
+	return nil
+}
+
+// complete writes the last batch, completes the scan and records its CPU
+// time and the capacity of the path's filesystem.
+func (rec *scanRecorder) complete(ctx context.Context, logger *slog.Logger) error {
+	if err := rec.flush(ctx); err != nil {
+		return err
+	}
+	rec.done = true
+	if err := rec.store.CompleteScan(ctx, rec.scanID, rec.stored); err != nil {
+		return fmt.Errorf("completing scan: %w", err)
+	}
+
+	if rec.cpuErr == nil {
+		if cpuAfter, err := rusage.Read(); err == nil {
+			cpu := cpuAfter.Sub(rec.cpuBefore)
+			if err := rec.store.RecordScanCPU(ctx, rec.scanID, cpu.User, cpu.System); err != nil {
+				logger.Warn("failed to record scan cpu time", "error", err)
+			}
+		}
+	}
+
+	if fs, err := scanner.StatFilesystem(rec.path, rec.cfg.Scan.StatfsTimeout); err != nil {
+		logger.Warn("failed to read filesystem capacity", "path", rec.path, "error", err)
+	} else if err := rec.store.RecordFilesystemStats(ctx, storage.FilesystemStats{
+		BasePath:       rec.path,
+		RecordedAt:     time.Now(),
+		ScanID:         rec.scanID,
+		Hostname:       rec.hostname,
+		TotalBytes:     fs.TotalBytes,
+		UsedBytes:      fs.UsedBytes,
+		AvailableBytes: fs.AvailableBytes,
+	}); err != nil {
+		logger.Warn("failed to record filesystem capacity", "path", rec.path, "error", err)
+	}
+
+	logger.Info("results stored", "count", rec.stored)
+	return nil
+}
+
+// close marks the scan failed with err if it was not completed, keeping
+// the records already written, and closes the database.
+func (rec *scanRecorder) close(err error, logger *slog.Logger) {
+	if !rec.done {
+		reason := "scan did not complete"
+		if err != nil {
+			reason = err.Error()
+		}
+		if ferr := rec.store.FailScan(context.Background(), rec.scanID, reason); ferr != nil {
+			logger.Error("failed to mark scan as failed", "error", ferr)
+		}
+	}
+	rec.store.Close()
+}
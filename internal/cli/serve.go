@@ -6,18 +6,50 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/jgalley/usgmon/internal/config"
 	"github.com/jgalley/usgmon/internal/daemon"
-	"github.com/jgalley/usgmon/internal/storage"
+	"github.com/jgalley/usgmon/pkg/storage"
 	"github.com/spf13/cobra"
 )
 
+var (
+	serveOnce             bool
+	serveIntervalOverride time.Duration
+	serveNoStore          bool
+)
+
 var serveCmd = &cobra.Command{
 	Use:   "serve",
 	Short: "Start the daemon",
-	Long:  `Start the usgmon daemon. This is typically invoked by systemd.`,
-	RunE:  runServe,
+	Long: `Start the usgmon daemon. This is typically invoked by systemd.
+
+With --once, every configured path is scanned a single time and the command
+exits, returning a non-zero status if any scan failed. This suits cron or
+systemd timer deployments that do not want a long-running process.
+
+--interval-override replaces every path's scan interval, including per-path
+overrides, so a config can be validated by watching several scan cycles
+without editing it. It is meant for testing only.
+
+--no-store runs every scan in full, so timing and errors are realistic, but
+logs each measurement instead of writing it to the database. Nothing is
+recorded: no scans, usage, skip-list changes or alert state, and scan
+rotation and the post-scan hook are skipped. Agent pushes are refused with
+503 Service Unavailable.
+
+On SIGHUP the daemon re-reads its config file and applies changes to paths
+and scan.interval without interrupting scans in progress: new paths start
+scanning, removed paths stop, and changed paths are rescheduled. Other
+settings require a restart. An invalid config is logged and ignored.`,
+	RunE: runServe,
+}
+
+func init() {
+	serveCmd.Flags().BoolVar(&serveOnce, "once", false, "scan all configured paths once, then exit")
+	serveCmd.Flags().DurationVar(&serveIntervalOverride, "interval-override", 0, "force every path's scan interval to this value (testing only)")
+	serveCmd.Flags().BoolVar(&serveNoStore, "no-store", false, "measure but log results instead of storing them")
 }
 
 func runServe(cmd *cobra.Command, args []string) error {
@@ -27,13 +59,19 @@ func runServe(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("loading config: %w", err)
 	}
 
-	// Override log level from flag if specified
-	if cmd.Flags().Changed("log-level") {
-		cfg.Logging.Level = logLevel
+	if cmd.Flags().Changed("interval-override") && serveIntervalOverride < time.Second {
+		return fmt.Errorf("--interval-override must be at least 1s")
 	}
 
+	applyServeFlags(cmd, cfg)
 	logger := setupLogger(cfg.Logging.Level, cfg.Logging.Format)
 
+	if serveIntervalOverride > 0 {
+		logger.Warn("TESTING: scan intervals overridden for all paths; not for production use",
+			"interval", serveIntervalOverride,
+		)
+	}
+
 	logger.Info("starting usgmon daemon",
 		"config", cfgFile,
 		"db", cfg.Database.Path,
@@ -55,6 +93,11 @@ func runServe(cmd *cobra.Command, args []string) error {
 
 	// Create daemon
 	d := daemon.New(cfg, store, logger)
+	d.SetVersion(Version)
+	if serveNoStore {
+		d.DiscardResults()
+		logger.Warn("no-store mode: scan results will be logged, not stored")
+	}
 
 	// Setup signal handling
 	ctx, cancel := context.WithCancel(ctx)
@@ -69,6 +112,33 @@ func runServe(cmd *cobra.Command, args []string) error {
 		cancel()
 	}()
 
+	if !serveOnce {
+		hupCh := make(chan os.Signal, 1)
+		signal.Notify(hupCh, syscall.SIGHUP)
+		defer signal.Stop(hupCh)
+
+		go func() {
+			for range hupCh {
+				logger.Info("received SIGHUP, reloading configuration", "config", cfgFile)
+				newCfg, err := config.Load(cfgFile)
+				if err != nil {
+					logger.Error("failed to reload configuration, keeping the current one", "error", err)
+					continue
+				}
+				applyServeFlags(cmd, newCfg)
+				d.Reload(newCfg)
+			}
+		}()
+	}
+
+	if serveOnce {
+		if err := d.RunOnce(ctx); err != nil {
+			return fmt.Errorf("one-shot scan failed: %w", err)
+		}
+		logger.Info("one-shot scan completed")
+		return nil
+	}
+
 	// Run daemon
 	if err := d.Run(ctx); err != nil && err != context.Canceled {
 		return fmt.Errorf("daemon error: %w", err)
@@ -77,3 +147,19 @@ func runServe(cmd *cobra.Command, args []string) error {
 	logger.Info("daemon stopped")
 	return nil
 }
+
+// applyServeFlags overrides config values with the flags given to serve.
+func applyServeFlags(cmd *cobra.Command, cfg *config.Config) {
+	// Override log level from flag if specified
+	if cmd.Flags().Changed("log-level") {
+		cfg.Logging.Level = logLevel
+	}
+
+	if serveIntervalOverride > 0 {
+		cfg.Scan.Interval = serveIntervalOverride
+		for i := range cfg.Paths {
+			cfg.Paths[i].Interval = 0
+			cfg.Paths[i].Schedule = ""
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var skipReason string
+
+var skipCmd = &cobra.Command{
+	Use:   "skip",
+	Short: "Manage the persistent scan-skip list",
+	Long: `Manage directories that the daemon skips during scans.
+
+Directories are added automatically after repeated scan errors
+(scan.skip_after_errors) and re-probed every scan.skip_probe_interval.
+Manually added directories are never re-probed.
+
+Examples:
+  usgmon skip list
+  usgmon skip list /www/users
+  usgmon skip add /www/users/locked.com --reason "root-owned"
+  usgmon skip remove /www/users/locked.com`,
+}
+
+var skipListCmd = &cobra.Command{
+	Use:   "list [path]",
+	Short: "List skip-list entries",
+	Args:  cobra.MaximumNArgs(1),
+	RunE:  runSkipList,
+}
+
+var skipAddCmd = &cobra.Command{
+	Use:   "add <directory>",
+	Short: "Add a directory to the skip list",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runSkipAdd,
+}
+
+var skipRemoveCmd = &cobra.Command{
+	Use:   "remove <directory>",
+	Short: "Remove a directory from the skip list",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runSkipRemove,
+}
+
+func init() {
+	skipAddCmd.Flags().StringVar(&skipReason, "reason", "added manually", "reason for skipping")
+
+	skipCmd.AddCommand(skipListCmd)
+	skipCmd.AddCommand(skipAddCmd)
+	skipCmd.AddCommand(skipRemoveCmd)
+}
+
+func runSkipList(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+	_, store, err := openStorage(ctx)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	var prefix string
+	if len(args) > 0 {
+		prefix = filepath.Clean(args[0])
+	}
+
+	entries, err := store.ListSkipEntries(ctx, prefix)
+	if err != nil {
+		return fmt.Errorf("listing skip list: %w", err)
+	}
+
+	if len(entries) == 0 {
+		fmt.Println("No skip-list entries found")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "DIRECTORY\tSTATE\tERRORS\tUPDATED\tREASON")
+	fmt.Fprintln(w, "---------\t-----\t------\t-------\t------")
+	for _, e := range entries {
+		state := "tracking"
+		switch {
+		case e.Manual:
+			state = "manual"
+		case e.Skipped:
+			state = "skipped"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n",
+			e.Directory,
+			state,
+			e.ConsecutiveErrors,
+			e.UpdatedAt.Local().Format("2006-01-02 15:04"),
+			e.LastError,
+		)
+	}
+	return w.Flush()
+}
+
+func runSkipAdd(cmd *cobra.Command, args []string) error {
+	dir := filepath.Clean(args[0])
+
+	ctx := context.Background()
+	_, store, err := openStorage(ctx)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	if err := store.AddSkipEntry(ctx, dir, skipReason); err != nil {
+		return fmt.Errorf("adding skip entry: %w", err)
+	}
+
+	fmt.Printf("Added %s to skip list\n", dir)
+	return nil
+}
+
+func runSkipRemove(cmd *cobra.Command, args []string) error {
+	dir := filepath.Clean(args[0])
+
+	ctx := context.Background()
+	_, store, err := openStorage(ctx)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	removed, err := store.RemoveSkipEntry(ctx, dir)
+	if err != nil {
+		return fmt.Errorf("removing skip entry: %w", err)
+	}
+	if !removed {
+		return fmt.Errorf("%s is not on the skip list", dir)
+	}
+
+	fmt.Printf("Removed %s from skip list\n", dir)
+	return nil
+}
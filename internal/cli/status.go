@@ -0,0 +1,159 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jgalley/usgmon/internal/config"
+	"github.com/jgalley/usgmon/internal/daemon"
+	"github.com/jgalley/usgmon/internal/humanize"
+	"github.com/spf13/cobra"
+)
+
+var (
+	statusFormat string
+	statusSocket string
+)
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the state of the running daemon",
+	Long: `Ask the running daemon, over its control socket, for its uptime, the
+configured paths with their last scan, the scans in progress and database
+statistics.
+
+Paths the daemon has not scanned since it started show their last scan
+recorded in the database. Exits non-zero if the daemon cannot be reached.
+
+Examples:
+  usgmon status
+  usgmon status --format json
+  usgmon status --socket /run/usgmon/usgmon.sock`,
+	Args: cobra.NoArgs,
+	RunE: runStatus,
+}
+
+func init() {
+	statusCmd.Flags().StringVar(&statusFormat, "format", "text", "output format (text, json)")
+	statusCmd.Flags().StringVar(&statusSocket, "socket", "", "control socket path (default: control.socket from the config)")
+}
+
+func runStatus(cmd *cobra.Command, args []string) error {
+	socket, err := controlSocket(statusSocket)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	st, err := daemon.FetchStatus(ctx, socket)
+	if err != nil {
+		return fmt.Errorf("%w (is the daemon running?)", err)
+	}
+
+	switch statusFormat {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(st)
+	default:
+		return outputStatusText(st)
+	}
+}
+
+// controlSocket returns the control socket to use: flag if set, otherwise
+// control.socket from the config.
+func controlSocket(flag string) (string, error) {
+	if flag != "" {
+		return flag, nil
+	}
+	cfg, err := config.Load(cfgFile)
+	if err != nil {
+		return "", fmt.Errorf("loading config: %w", err)
+	}
+	if cfg.Control.Socket == "" {
+		return "", fmt.Errorf("control.socket is not set; the daemon has no control socket")
+	}
+	return cfg.Control.Socket, nil
+}
+
+func outputStatusText(st *daemon.Status) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Version:\t%s\n", orDash(st.Version))
+	fmt.Fprintf(w, "PID:\t%d\n", st.PID)
+	fmt.Fprintf(w, "Started:\t%s\n", st.StartedAt.Local().Format("2006-01-02 15:04"))
+	fmt.Fprintf(w, "Uptime:\t%s\n", time.Duration(st.UptimeSeconds)*time.Second)
+	fmt.Fprintf(w, "Database:\t%s (%s)\n", st.Database.Path, humanize.FormatSize(st.Database.SizeBytes))
+	if st.Database.Error != "" {
+		fmt.Fprintf(w, "Database error:\t%s\n", st.Database.Error)
+	} else {
+		fmt.Fprintf(w, "Usage records:\t%d\n", st.Database.UsageRecords)
+		fmt.Fprintf(w, "Scans:\t%d\n", st.Database.Scans)
+		fmt.Fprintf(w, "Daily rollups:\t%d\n", st.Database.Rollups)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Println()
+	if len(st.Paths) == 0 {
+		fmt.Println("No paths configured")
+	} else {
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "PATH\tDEPTH\tSCHEDULE\tLAST SCAN\tDURATION\tDIRS\tSTATUS")
+		fmt.Fprintln(w, "----\t-----\t--------\t---------\t--------\t----\t------")
+		for _, p := range st.Paths {
+			schedule := p.Schedule
+			if schedule == "" {
+				schedule = "every " + p.Interval
+			}
+			last, duration, dirs, status := "never", "-", "-", "-"
+			if ls := p.LastScan; ls != nil {
+				last = ls.FinishedAt.Local().Format("2006-01-02 15:04")
+				duration = time.Duration(ls.DurationSeconds * float64(time.Second)).Round(time.Second).String()
+				dirs = fmt.Sprint(ls.Directories)
+				status = ls.Status
+				if ls.Error != "" {
+					status += ": " + ls.Error
+				}
+			}
+			if p.Scanning {
+				if p.LastScan == nil {
+					status = "scanning"
+				} else {
+					status += " (scanning now)"
+				}
+			}
+			fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\t%s\n", p.Path, p.Depth, schedule, last, duration, dirs, status)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+
+	fmt.Println()
+	if len(st.Scans) == 0 {
+		fmt.Println("No scans in progress")
+		return nil
+	}
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SCANNING\tDEPTH\tTRIGGER\tSTARTED\tELAPSED\tDIRS SO FAR")
+	fmt.Fprintln(w, "--------\t-----\t-------\t-------\t-------\t-----------")
+	for _, sc := range st.Scans {
+		elapsed := time.Duration(sc.ElapsedSeconds * float64(time.Second)).Round(time.Second)
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%d\n",
+			sc.Path,
+			sc.Depth,
+			sc.Trigger,
+			sc.StartedAt.Local().Format("2006-01-02 15:04"),
+			elapsed,
+			sc.Directories,
+		)
+	}
+	return w.Flush()
+}
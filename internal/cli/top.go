@@ -6,13 +6,14 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"strconv"
-	"strings"
-	"text/tabwriter"
+	"regexp"
+	"slices"
 	"time"
 
 	"github.com/jgalley/usgmon/internal/config"
-	"github.com/jgalley/usgmon/internal/storage"
+	"github.com/jgalley/usgmon/internal/humanize"
+	"github.com/jgalley/usgmon/internal/timespec"
+	"github.com/jgalley/usgmon/pkg/storage"
 	"github.com/spf13/cobra"
 )
 
@@ -22,8 +23,17 @@ var (
 	topUntil     string
 	topDirection string
 	topMinChange string
+	topMinPct    float64
 	topLimit     int
 	topFormat    string
+	topOwner     string
+	topHost      string
+	topMatch     string
+	topRegex     string
+	topNew       bool
+	topDeleted   bool
+
+	topColumnSpec string
 )
 
 var topCmd = &cobra.Command{
@@ -35,24 +45,52 @@ Examples:
   usgmon top /www/users --days 7
   usgmon top /www/users --direction increase --limit 5
   usgmon top /www/users --min-change 1G --format json
-  usgmon top /www/users --since "2026-01-01" --until "2026-01-31"`,
+  usgmon top /www/users --min-change 10G --min-change-percent 100
+  usgmon top /www/users --since "2026-01-01" --until "2026-01-31"
+  usgmon top /www/users --since 2w --until 1w
+  usgmon top /www/users --owner bob --columns directory,owner,change
+  usgmon top /www/users --columns directory,change,percent
+  usgmon top /www/users --host fs01
+  usgmon top /www/users --match 'bob*'
+  usgmon top /www/users --match-regex '/logs$'
+  usgmon top /www/users --include-new --include-deleted`,
 	Args: cobra.ExactArgs(1),
 	RunE: runTop,
 }
 
 func init() {
-	topCmd.Flags().IntVar(&topDays, "days", 7, "look back N days from now")
-	topCmd.Flags().StringVar(&topSince, "since", "", "start of time range (YYYY-MM-DD)")
-	topCmd.Flags().StringVar(&topUntil, "until", "", "end of time range (YYYY-MM-DD)")
+	topCmd.Flags().IntVar(&topDays, "days", 7, "look back N days from now (alias for --since Nd)")
+	topCmd.Flags().StringVar(&topSince, "since", "", "start of time range (YYYY-MM-DD or relative like 48h, 3d, 2w)")
+	topCmd.Flags().StringVar(&topUntil, "until", "", "end of time range (YYYY-MM-DD or relative like 48h, 3d, 2w)")
 	topCmd.Flags().StringVar(&topDirection, "direction", "both", "filter: \"increase\", \"decrease\", \"both\"")
 	topCmd.Flags().StringVar(&topMinChange, "min-change", "0", "minimum change threshold (e.g., \"100M\", \"1G\")")
+	topCmd.Flags().Float64Var(&topMinPct, "min-change-percent", 0, "minimum change as a percentage of the starting size; with --min-change, either threshold is enough")
 	topCmd.Flags().IntVar(&topLimit, "limit", 10, "maximum results")
-	topCmd.Flags().StringVar(&topFormat, "format", "text", "output format (text, json)")
+	topCmd.Flags().StringVar(&topFormat, "format", "text", "output format (text, json, csv)")
+	topCmd.Flags().StringVar(&topOwner, "owner", "", "only directories owned by this user (requires scan.record_owner)")
+	topCmd.Flags().StringVar(&topHost, "host", "", "only directories measured by this host")
+	topCmd.Flags().StringVar(&topMatch, "match", "", "only directories whose name matches this glob, or whose path does if it has a slash")
+	topCmd.Flags().StringVar(&topRegex, "match-regex", "", "only directories whose path matches this regular expression")
+	topCmd.Flags().BoolVar(&topNew, "include-new", false, "count directories the first scan in range did not have as growing from nothing")
+	topCmd.Flags().BoolVar(&topDeleted, "include-deleted", false, "count directories the last scan in range did not have as shrinking to nothing")
+	topCmd.Flags().StringVar(&topColumnSpec, "columns", "", "comma-separated columns to show (directory, base_path, before, after, change, percent, start_time, end_time, owner, group, host, status)")
 }
 
 func runTop(cmd *cobra.Command, args []string) error {
 	basePath := filepath.Clean(args[0])
 
+	defaults := topDefaultColumns
+	if topNew || topDeleted {
+		defaults = append(slices.Clone(defaults), "status")
+	}
+	cols, err := selectColumns(topColumns, topColumnSpec, defaults)
+	if err != nil {
+		return fmt.Errorf("invalid --columns value: %w", err)
+	}
+	if _, err := regexp.Compile(topRegex); err != nil {
+		return fmt.Errorf("invalid --match-regex value: %w", err)
+	}
+
 	cfg, err := config.Load(cfgFile)
 	if err != nil {
 		return fmt.Errorf("loading config: %w", err)
@@ -70,32 +108,38 @@ func runTop(cmd *cobra.Command, args []string) error {
 	}
 
 	// Parse time range
+	now := time.Now()
 	var since, until time.Time
 	if topSince != "" {
-		since, err = time.Parse("2006-01-02", topSince)
+		since, err = timespec.Parse(topSince, now, false)
 		if err != nil {
-			return fmt.Errorf("invalid --since date format (use YYYY-MM-DD): %w", err)
+			return fmt.Errorf("invalid --since value: %w", err)
 		}
 	} else {
-		since = time.Now().AddDate(0, 0, -topDays)
+		since = now.AddDate(0, 0, -topDays)
 	}
 
 	if topUntil != "" {
-		until, err = time.Parse("2006-01-02", topUntil)
+		until, err = timespec.Parse(topUntil, now, true)
 		if err != nil {
-			return fmt.Errorf("invalid --until date format (use YYYY-MM-DD): %w", err)
+			return fmt.Errorf("invalid --until value: %w", err)
 		}
-		// Set to end of day
-		until = until.Add(24*time.Hour - time.Second)
 	} else {
-		until = time.Now()
+		until = now
+	}
+
+	if !since.Before(until) {
+		return fmt.Errorf("--since must be before --until")
 	}
 
 	// Parse min-change
-	minChangeBytes, err := parseSize(topMinChange)
+	minChangeBytes, err := humanize.ParseSize(topMinChange)
 	if err != nil {
 		return fmt.Errorf("invalid --min-change value: %w", err)
 	}
+	if topMinPct < 0 {
+		return fmt.Errorf("--min-change-percent must be non-negative")
+	}
 
 	// Validate direction
 	if topDirection != "increase" && topDirection != "decrease" && topDirection != "both" {
@@ -103,12 +147,19 @@ func runTop(cmd *cobra.Command, args []string) error {
 	}
 
 	opts := storage.TopChangerOptions{
-		BasePath:       basePath,
-		Since:          since,
-		Until:          until,
-		Direction:      topDirection,
-		MinChangeBytes: minChangeBytes,
-		Limit:          topLimit,
+		BasePath:         basePath,
+		Since:            since,
+		Until:            until,
+		Direction:        topDirection,
+		MinChangeBytes:   minChangeBytes,
+		MinChangePercent: topMinPct,
+		Owner:            topOwner,
+		Hostname:         topHost,
+		Match:            topMatch,
+		MatchRegex:       topRegex,
+		IncludeNew:       topNew,
+		IncludeDeleted:   topDeleted,
+		Limit:            topLimit,
 	}
 
 	changes, err := store.GetTopChangers(ctx, opts)
@@ -116,53 +167,113 @@ func runTop(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("querying top changers: %w", err)
 	}
 
-	if len(changes) == 0 {
+	if len(changes) == 0 && topFormat != "csv" {
 		fmt.Println("No changes found")
 		return nil
 	}
 
 	switch topFormat {
 	case "json":
+		if topColumnSpec != "" {
+			return writeColumnsJSON(cols, changes)
+		}
 		return outputTopJSON(changes)
+	case "csv":
+		return writeColumnsCSV(os.Stdout, cols, changes)
 	default:
-		return outputTopText(changes)
+		return writeColumnsText(os.Stdout, cols, changes)
 	}
 }
 
-func outputTopText(changes []storage.DirectoryChange) error {
-	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	fmt.Fprintln(w, "DIRECTORY\tBEFORE\tAFTER\tCHANGE\t%")
-	fmt.Fprintln(w, "---------\t------\t-----\t------\t-")
-
-	for _, c := range changes {
-		sign := "+"
-		if c.ChangeBytes < 0 {
-			sign = ""
-		}
-		percentStr := fmt.Sprintf("%+.0f%%", c.ChangePercent)
-		fmt.Fprintf(w, "%s\t%s\t%s\t%s%s\t%s\n",
-			c.Directory,
-			formatSize(c.StartSize),
-			formatSize(c.EndSize),
-			sign, formatSize(c.ChangeBytes),
-			percentStr,
-		)
-	}
-	return w.Flush()
+// topColumns lists the columns selectable with top --columns.
+var topColumns = []column[storage.DirectoryChange]{
+	{
+		Name: "directory", Header: "DIRECTORY",
+		Text: func(c storage.DirectoryChange) string { return c.Directory },
+		JSON: func(c storage.DirectoryChange) interface{} { return c.Directory },
+	},
+	{
+		Name: "base_path", Header: "BASE PATH",
+		Text: func(c storage.DirectoryChange) string { return c.BasePath },
+		JSON: func(c storage.DirectoryChange) interface{} { return c.BasePath },
+	},
+	{
+		Name: "before", Header: "BEFORE",
+		Text: func(c storage.DirectoryChange) string { return humanize.FormatSize(c.StartSize) },
+		JSON: func(c storage.DirectoryChange) interface{} { return c.StartSize },
+	},
+	{
+		Name: "after", Header: "AFTER",
+		Text: func(c storage.DirectoryChange) string { return humanize.FormatSize(c.EndSize) },
+		JSON: func(c storage.DirectoryChange) interface{} { return c.EndSize },
+	},
+	{
+		Name: "change", Header: "CHANGE",
+		Text: func(c storage.DirectoryChange) string {
+			sign := "+"
+			if c.ChangeBytes < 0 {
+				sign = ""
+			}
+			return sign + humanize.FormatSize(c.ChangeBytes)
+		},
+		JSON: func(c storage.DirectoryChange) interface{} { return c.ChangeBytes },
+	},
+	{
+		Name: "percent", Header: "%",
+		Text: func(c storage.DirectoryChange) string { return fmt.Sprintf("%+.0f%%", c.ChangePercent) },
+		JSON: func(c storage.DirectoryChange) interface{} { return c.ChangePercent },
+	},
+	{
+		Name: "start_time", Header: "START",
+		Text: func(c storage.DirectoryChange) string { return c.StartTime.Local().Format("2006-01-02 15:04") },
+		JSON: func(c storage.DirectoryChange) interface{} { return c.StartTime.Format(time.RFC3339) },
+	},
+	{
+		Name: "end_time", Header: "END",
+		Text: func(c storage.DirectoryChange) string { return c.EndTime.Local().Format("2006-01-02 15:04") },
+		JSON: func(c storage.DirectoryChange) interface{} { return c.EndTime.Format(time.RFC3339) },
+	},
+	{
+		Name: "owner", Header: "OWNER",
+		Text: func(c storage.DirectoryChange) string { return orDash(c.Owner) },
+		JSON: func(c storage.DirectoryChange) interface{} { return c.Owner },
+	},
+	{
+		Name: "group", Header: "GROUP",
+		Text: func(c storage.DirectoryChange) string { return orDash(c.Group) },
+		JSON: func(c storage.DirectoryChange) interface{} { return c.Group },
+	},
+	{
+		Name: "host", Header: "HOST",
+		Text: func(c storage.DirectoryChange) string { return orDash(c.Hostname) },
+		JSON: func(c storage.DirectoryChange) interface{} { return c.Hostname },
+	},
+	{
+		Name: "status", Header: "STATUS",
+		Text: func(c storage.DirectoryChange) string { return orDash(c.Status) },
+		JSON: func(c storage.DirectoryChange) interface{} { return c.Status },
+	},
 }
 
+// topDefaultColumns is the text column set used when --columns is not given.
+var topDefaultColumns = []string{"directory", "before", "after", "change", "percent"}
+
 type topJSONRecord struct {
-	Directory       string  `json:"directory"`
-	BasePath        string  `json:"base_path"`
-	StartSize       int64   `json:"start_size_bytes"`
-	StartSizeHuman  string  `json:"start_size_human"`
-	EndSize         int64   `json:"end_size_bytes"`
-	EndSizeHuman    string  `json:"end_size_human"`
-	StartTime       string  `json:"start_time"`
-	EndTime         string  `json:"end_time"`
-	ChangeBytes     int64   `json:"change_bytes"`
-	ChangeHuman     string  `json:"change_human"`
-	ChangePercent   float64 `json:"change_percent"`
+	Directory      string  `json:"directory"`
+	BasePath       string  `json:"base_path"`
+	StartSize      int64   `json:"start_size_bytes"`
+	StartSizeHuman string  `json:"start_size_human"`
+	EndSize        int64   `json:"end_size_bytes"`
+	EndSizeHuman   string  `json:"end_size_human"`
+	StartTime      string  `json:"start_time"`
+	EndTime        string  `json:"end_time"`
+	ChangeBytes    int64   `json:"change_bytes"`
+	ChangeHuman    string  `json:"change_human"`
+	ChangePercent  float64 `json:"change_percent"`
+	Owner          string  `json:"owner,omitempty"`
+	Group          string  `json:"group,omitempty"`
+	Hostname       string  `json:"hostname,omitempty"`
+	Status         string  `json:"status,omitempty"`
 }
 
 func outputTopJSON(changes []storage.DirectoryChange) error {
@@ -172,14 +283,18 @@ func outputTopJSON(changes []storage.DirectoryChange) error {
 			Directory:      c.Directory,
 			BasePath:       c.BasePath,
 			StartSize:      c.StartSize,
-			StartSizeHuman: formatSize(c.StartSize),
+			StartSizeHuman: humanize.FormatSize(c.StartSize),
 			EndSize:        c.EndSize,
-			EndSizeHuman:   formatSize(c.EndSize),
+			EndSizeHuman:   humanize.FormatSize(c.EndSize),
 			StartTime:      c.StartTime.Format(time.RFC3339),
 			EndTime:        c.EndTime.Format(time.RFC3339),
 			ChangeBytes:    c.ChangeBytes,
-			ChangeHuman:    formatSize(c.ChangeBytes),
+			ChangeHuman:    humanize.FormatSize(c.ChangeBytes),
 			ChangePercent:  c.ChangePercent,
+			Owner:          c.Owner,
+			Group:          c.Group,
+			Hostname:       c.Hostname,
+			Status:         c.Status,
 		}
 	}
 
@@ -187,57 +302,3 @@ func outputTopJSON(changes []storage.DirectoryChange) error {
 	enc.SetIndent("", "  ")
 	return enc.Encode(records)
 }
-
-// parseSize parses a human-readable size string (e.g., "100M", "1G") into bytes.
-func parseSize(s string) (int64, error) {
-	s = strings.TrimSpace(s)
-	if s == "" || s == "0" {
-		return 0, nil
-	}
-
-	// Find where the number ends and the suffix begins
-	var numStr string
-	var suffix string
-	for i, c := range s {
-		if c < '0' || c > '9' {
-			if c != '.' {
-				numStr = s[:i]
-				suffix = strings.ToUpper(strings.TrimSpace(s[i:]))
-				break
-			}
-		}
-	}
-	if numStr == "" {
-		numStr = s
-	}
-
-	num, err := strconv.ParseFloat(numStr, 64)
-	if err != nil {
-		return 0, fmt.Errorf("invalid number: %s", numStr)
-	}
-
-	const (
-		KiB = 1024
-		MiB = KiB * 1024
-		GiB = MiB * 1024
-		TiB = GiB * 1024
-	)
-
-	var multiplier float64 = 1
-	switch suffix {
-	case "K", "KB", "KIB":
-		multiplier = KiB
-	case "M", "MB", "MIB":
-		multiplier = MiB
-	case "G", "GB", "GIB":
-		multiplier = GiB
-	case "T", "TB", "TIB":
-		multiplier = TiB
-	case "":
-		multiplier = 1
-	default:
-		return 0, fmt.Errorf("unknown size suffix: %s", suffix)
-	}
-
-	return int64(num * multiplier), nil
-}
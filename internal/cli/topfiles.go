@@ -0,0 +1,151 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jgalley/usgmon/internal/humanize"
+	"github.com/jgalley/usgmon/internal/timespec"
+	"github.com/jgalley/usgmon/pkg/scanner"
+	"github.com/jgalley/usgmon/pkg/storage"
+	"github.com/spf13/cobra"
+)
+
+var (
+	topFilesLimit  int
+	topFilesAt     string
+	topFilesFormat string
+)
+
+var topFilesCmd = &cobra.Command{
+	Use:   "top-files <dir>",
+	Short: "Show the largest files found in a directory",
+	Long: `Show the largest files found under a directory by its most recent scan that
+captured them (scan.largest_files, or scan --largest-files --store).
+
+Examples:
+  usgmon top-files /www/users/bob.com
+  usgmon top-files /www/users/bob.com --limit 5
+  usgmon top-files /www/users/bob.com --at 7d
+  usgmon top-files /www/users/bob.com --format json`,
+	Args: cobra.ExactArgs(1),
+	RunE: runTopFiles,
+}
+
+func init() {
+	topFilesCmd.Flags().IntVar(&topFilesLimit, "limit", 0, "maximum number of files to show (0 = all captured)")
+	topFilesCmd.Flags().StringVar(&topFilesAt, "at", "", "use the newest capture at or before this time (\"YYYY-MM-DD HH:MM\", YYYY-MM-DD, or relative like 48h, 3d)")
+	topFilesCmd.Flags().StringVar(&topFilesFormat, "format", "text", "output format (text, json, csv)")
+}
+
+func runTopFiles(cmd *cobra.Command, args []string) error {
+	dir := filepath.Clean(args[0])
+
+	opts := storage.QueryOptions{
+		Directory:  dir,
+		LargeFiles: true,
+		Limit:      1,
+	}
+	if topFilesAt != "" {
+		at, err := timespec.Parse(topFilesAt, time.Now(), true)
+		if err != nil {
+			return fmt.Errorf("invalid --at value: %w", err)
+		}
+		opts.Until = &at
+	}
+
+	ctx := context.Background()
+	_, store, err := openStorage(ctx)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	records, err := store.QueryUsage(ctx, opts)
+	if err != nil {
+		return fmt.Errorf("querying large files: %w", err)
+	}
+	if len(records) == 0 {
+		if topFilesFormat == "csv" {
+			return writeColumnsCSV(os.Stdout, largeFileCSVColumns, nil)
+		}
+		fmt.Println("No large files recorded")
+		return nil
+	}
+
+	r := records[0]
+	files := r.LargeFiles
+	if topFilesLimit > 0 && len(files) > topFilesLimit {
+		files = files[:topFilesLimit]
+	}
+
+	switch topFilesFormat {
+	case "json":
+		return outputTopFilesJSON(r, files)
+	case "csv":
+		return writeColumnsCSV(os.Stdout, largeFileCSVColumns, files)
+	default:
+		return outputTopFilesText(r, files)
+	}
+}
+
+func outputTopFilesText(r storage.UsageRecord, files []storage.LargeFile) error {
+	fmt.Printf("%s: %s as of %s\n\n", r.Directory, humanize.FormatSize(r.SizeBytes), r.RecordedAt.Local().Format("2006-01-02 15:04"))
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SIZE\tMODIFIED\tPATH")
+	fmt.Fprintln(w, "----\t--------\t----")
+	for _, f := range files {
+		fmt.Fprintf(w, "%s\t%s\t%s\n",
+			humanize.FormatSize(f.SizeBytes),
+			f.ModTime.Local().Format("2006-01-02 15:04"),
+			f.Path,
+		)
+	}
+	return w.Flush()
+}
+
+type topFilesJSON struct {
+	Directory  string              `json:"directory"`
+	SizeBytes  int64               `json:"size_bytes"`
+	RecordedAt string              `json:"recorded_at"`
+	ScanID     string              `json:"scan_id"`
+	Files      []storage.LargeFile `json:"files"`
+}
+
+// largeFileCSVColumns are the columns of top-files --format csv: the files
+// of its JSON output, without the directory's own size.
+var largeFileCSVColumns = []column[storage.LargeFile]{
+	{Name: "path", JSON: func(f storage.LargeFile) interface{} { return f.Path }},
+	{Name: "size_bytes", JSON: func(f storage.LargeFile) interface{} { return f.SizeBytes }},
+	{Name: "mtime", JSON: func(f storage.LargeFile) interface{} { return f.ModTime.Format(time.RFC3339) }},
+}
+
+func outputTopFilesJSON(r storage.UsageRecord, files []storage.LargeFile) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(topFilesJSON{
+		Directory:  r.Directory,
+		SizeBytes:  r.SizeBytes,
+		RecordedAt: r.RecordedAt.Format(time.RFC3339),
+		ScanID:     r.ScanID,
+		Files:      files,
+	})
+}
+
+// largeFiles converts the largest files of a scan result for storage.
+func largeFiles(found []scanner.FileSize) []storage.LargeFile {
+	if len(found) == 0 {
+		return nil
+	}
+	files := make([]storage.LargeFile, len(found))
+	for i, f := range found {
+		files[i] = storage.LargeFile(f)
+	}
+	return files
+}
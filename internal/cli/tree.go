@@ -0,0 +1,88 @@
+package cli
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jgalley/usgmon/internal/humanize"
+	"github.com/jgalley/usgmon/pkg/scanner"
+)
+
+// treeNode is a directory in the nested tree-json output.
+type treeNode struct {
+	Name          string      `json:"name"`
+	Path          string      `json:"path"`
+	SizeBytes     int64       `json:"size_bytes"`
+	SizeHuman     string      `json:"size_human"`
+	AggregateOnly bool        `json:"aggregate_only,omitempty"`
+	Error         string      `json:"error,omitempty"`
+	Children      []*treeNode `json:"children,omitempty"`
+
+	measured bool
+}
+
+// buildTree assembles a nested tree from flat scan results rooted at basePath.
+// Directories that were not measured themselves (intermediate levels) are
+// marked aggregate-only and sized as the sum of their children.
+func buildTree(basePath string, results []scanner.Result) *treeNode {
+	basePath = filepath.Clean(basePath)
+	root := &treeNode{Name: filepath.Base(basePath), Path: basePath}
+	nodes := map[string]*treeNode{basePath: root}
+
+	var getNode func(path string) *treeNode
+	getNode = func(path string) *treeNode {
+		if n, ok := nodes[path]; ok {
+			return n
+		}
+		n := &treeNode{Name: filepath.Base(path), Path: path}
+		nodes[path] = n
+		parent := getNode(filepath.Dir(path))
+		parent.Children = append(parent.Children, n)
+		return n
+	}
+
+	for _, r := range results {
+		p := filepath.Clean(r.Path)
+		if rel, err := filepath.Rel(basePath, p); err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+			continue
+		}
+		n := getNode(p)
+		n.measured = true
+		if r.Error != nil {
+			n.Error = r.Error.Error()
+			continue
+		}
+		n.SizeBytes = r.SizeBytes
+	}
+
+	rollupTree(root)
+	return root
+}
+
+// rollupTree fills in sizes for unmeasured nodes and sorts children by name.
+func rollupTree(n *treeNode) int64 {
+	sort.Slice(n.Children, func(i, j int) bool {
+		return n.Children[i].Name < n.Children[j].Name
+	})
+
+	var childTotal int64
+	for _, c := range n.Children {
+		childTotal += rollupTree(c)
+	}
+
+	if !n.measured {
+		n.SizeBytes = childTotal
+		n.AggregateOnly = true
+	}
+	n.SizeHuman = humanize.FormatSize(n.SizeBytes)
+	return n.SizeBytes
+}
+
+func outputScanTreeJSON(basePath string, results []scanner.Result) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(buildTree(basePath, results))
+}
@@ -0,0 +1,196 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jgalley/usgmon/internal/humanize"
+	"github.com/jgalley/usgmon/internal/timespec"
+	"github.com/jgalley/usgmon/pkg/storage"
+	"github.com/spf13/cobra"
+)
+
+var (
+	trendsSince  string
+	trendsUntil  string
+	trendsFilter string
+	trendsFormat string
+)
+
+var trendsCmd = &cobra.Command{
+	Use:   "trends <base-path>",
+	Short: "Classify directories by their usage trend",
+	Long: `Classify each directory under a base path by its recent usage history:
+
+  growing    most significant changes were increases, with net growth
+  shrinking  most significant changes were decreases, with net shrinkage
+  flat       no change larger than 1% of the directory's mean size
+  volatile   significant changes in both directions
+  spiked     the latest change is an increase far larger than earlier ones
+  unknown    fewer than three records in the time range
+
+RATE/DAY is the least-squares growth rate over the time range.
+
+Examples:
+  usgmon trends /www/users
+  usgmon trends /www/users --since 90d
+  usgmon trends /www/users --trend growing --format json`,
+	Args: cobra.ExactArgs(1),
+	RunE: runTrends,
+}
+
+func init() {
+	trendsCmd.Flags().StringVar(&trendsSince, "since", "30d", "start of time range (YYYY-MM-DD or relative like 48h, 3d, 2w)")
+	trendsCmd.Flags().StringVar(&trendsUntil, "until", "", "end of time range (YYYY-MM-DD or relative like 48h, 3d, 2w)")
+	trendsCmd.Flags().StringVar(&trendsFilter, "trend", "", "only show directories with this trend (growing, shrinking, flat, volatile, spiked, unknown)")
+	trendsCmd.Flags().StringVar(&trendsFormat, "format", "text", "output format (text, json, csv)")
+}
+
+func runTrends(cmd *cobra.Command, args []string) error {
+	basePath := filepath.Clean(args[0])
+
+	switch trendsFilter {
+	case "", storage.TrendGrowing, storage.TrendShrinking, storage.TrendFlat,
+		storage.TrendVolatile, storage.TrendSpiked, storage.TrendUnknown:
+	default:
+		return fmt.Errorf("invalid --trend value %q", trendsFilter)
+	}
+
+	now := time.Now()
+	since, err := timespec.Parse(trendsSince, now, false)
+	if err != nil {
+		return fmt.Errorf("invalid --since value: %w", err)
+	}
+	until := now
+	if trendsUntil != "" {
+		until, err = timespec.Parse(trendsUntil, now, true)
+		if err != nil {
+			return fmt.Errorf("invalid --until value: %w", err)
+		}
+	}
+	if !since.Before(until) {
+		return fmt.Errorf("--since must be before --until")
+	}
+
+	ctx := context.Background()
+	_, store, err := openStorage(ctx)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	trends, err := store.GetTrends(ctx, storage.TrendOptions{
+		BasePath: basePath,
+		Since:    since,
+		Until:    until,
+	})
+	if err != nil {
+		return fmt.Errorf("querying trends: %w", err)
+	}
+
+	if trendsFilter != "" {
+		filtered := trends[:0]
+		for _, t := range trends {
+			if t.Trend == trendsFilter {
+				filtered = append(filtered, t)
+			}
+		}
+		trends = filtered
+	}
+
+	if len(trends) == 0 && trendsFormat != "csv" {
+		fmt.Println("No records found")
+		return nil
+	}
+
+	switch trendsFormat {
+	case "json":
+		return outputTrendsJSON(trends)
+	case "csv":
+		return writeColumnsCSV(os.Stdout, trendCSVColumns, trends)
+	default:
+		return outputTrendsText(trends)
+	}
+}
+
+func outputTrendsText(trends []storage.DirectoryTrend) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "DIRECTORY\tTREND\tSAMPLES\tSIZE\tCHANGE\tRATE/DAY")
+	fmt.Fprintln(w, "---------\t-----\t-------\t----\t------\t--------")
+
+	for _, t := range trends {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%s\n",
+			t.Directory,
+			t.Trend,
+			t.Samples,
+			humanize.FormatSize(t.EndSize),
+			signedSize(t.EndSize-t.StartSize),
+			signedSize(int64(t.BytesPerDay)),
+		)
+	}
+	return w.Flush()
+}
+
+type trendJSONRecord struct {
+	Directory   string  `json:"directory"`
+	BasePath    string  `json:"base_path"`
+	Trend       string  `json:"trend"`
+	Samples     int     `json:"samples"`
+	StartSize   int64   `json:"start_size_bytes"`
+	EndSize     int64   `json:"end_size_bytes"`
+	ChangeBytes int64   `json:"change_bytes"`
+	BytesPerDay float64 `json:"bytes_per_day"`
+	StartTime   string  `json:"start_time"`
+	EndTime     string  `json:"end_time"`
+}
+
+// trendCSVColumns are the columns of trends --format csv, those of its JSON
+// output.
+var trendCSVColumns = []column[storage.DirectoryTrend]{
+	{Name: "directory", JSON: func(t storage.DirectoryTrend) interface{} { return t.Directory }},
+	{Name: "base_path", JSON: func(t storage.DirectoryTrend) interface{} { return t.BasePath }},
+	{Name: "trend", JSON: func(t storage.DirectoryTrend) interface{} { return t.Trend }},
+	{Name: "samples", JSON: func(t storage.DirectoryTrend) interface{} { return t.Samples }},
+	{Name: "start_size_bytes", JSON: func(t storage.DirectoryTrend) interface{} { return t.StartSize }},
+	{Name: "end_size_bytes", JSON: func(t storage.DirectoryTrend) interface{} { return t.EndSize }},
+	{Name: "change_bytes", JSON: func(t storage.DirectoryTrend) interface{} { return t.EndSize - t.StartSize }},
+	{Name: "bytes_per_day", JSON: func(t storage.DirectoryTrend) interface{} { return t.BytesPerDay }},
+	{Name: "start_time", JSON: func(t storage.DirectoryTrend) interface{} { return t.StartTime.Format(time.RFC3339) }},
+	{Name: "end_time", JSON: func(t storage.DirectoryTrend) interface{} { return t.EndTime.Format(time.RFC3339) }},
+}
+
+func outputTrendsJSON(trends []storage.DirectoryTrend) error {
+	out := make([]trendJSONRecord, len(trends))
+	for i, t := range trends {
+		out[i] = trendJSONRecord{
+			Directory:   t.Directory,
+			BasePath:    t.BasePath,
+			Trend:       t.Trend,
+			Samples:     t.Samples,
+			StartSize:   t.StartSize,
+			EndSize:     t.EndSize,
+			ChangeBytes: t.EndSize - t.StartSize,
+			BytesPerDay: t.BytesPerDay,
+			StartTime:   t.StartTime.Format(time.RFC3339),
+			EndTime:     t.EndTime.Format(time.RFC3339),
+		}
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// signedSize formats a size change with an explicit "+" for growth.
+func signedSize(b int64) string {
+	sign := "+"
+	if b < 0 {
+		sign = ""
+	}
+	return sign + humanize.FormatSize(b)
+}
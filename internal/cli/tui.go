@@ -0,0 +1,604 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/jgalley/usgmon/internal/config"
+	"github.com/jgalley/usgmon/internal/daemon"
+	"github.com/jgalley/usgmon/internal/humanize"
+	"github.com/jgalley/usgmon/internal/timespec"
+	"github.com/jgalley/usgmon/pkg/storage"
+	"github.com/spf13/cobra"
+)
+
+var (
+	tuiSince   string
+	tuiSocket  string
+	tuiRefresh time.Duration
+)
+
+var tuiCmd = &cobra.Command{
+	Use:   "tui",
+	Short: "Interactive dashboard of monitored paths",
+	Long: `Show the configured base paths in an interactive terminal dashboard: their
+latest total size, the change and a sparkline of the total over the history
+range, and their last scan. Select a path to drill down into its
+directories, sorted by size or by growth over the range.
+
+When the daemon's control socket can be reached, scans in progress are shown
+live with the directories measured so far, and a path's history is reloaded
+when its scan finishes. Without the daemon, the dashboard shows what the
+database holds.
+
+Keys:
+  up/down, j/k      move
+  enter, right, l   open the selected path
+  esc, left, h      back to the paths
+  s                 sort directories by size or growth
+  r                 reload history from the database
+  q, ctrl+c         quit
+
+Examples:
+  usgmon tui
+  usgmon tui --since 7d
+  usgmon tui --socket /run/usgmon/usgmon.sock`,
+	Args: cobra.NoArgs,
+	RunE: runTUI,
+}
+
+func init() {
+	tuiCmd.Flags().StringVar(&tuiSince, "since", "30d", "start of the history range (YYYY-MM-DD or relative like 48h, 3d, 2w)")
+	tuiCmd.Flags().StringVar(&tuiSocket, "socket", "", "control socket path (default: control.socket from the config)")
+	tuiCmd.Flags().DurationVar(&tuiRefresh, "refresh", 2*time.Second, "how often to poll the daemon for scan progress")
+}
+
+func runTUI(cmd *cobra.Command, args []string) error {
+	now := time.Now()
+	since, err := timespec.Parse(tuiSince, now, false)
+	if err != nil {
+		return fmt.Errorf("invalid --since value: %w", err)
+	}
+	if !since.Before(now) {
+		return fmt.Errorf("--since must be in the past")
+	}
+	if tuiRefresh < 100*time.Millisecond {
+		return fmt.Errorf("--refresh must be at least 100ms")
+	}
+
+	ctx := context.Background()
+	cfg, store, err := openStorage(ctx)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	socket := tuiSocket
+	if socket == "" {
+		socket = cfg.Control.Socket
+	}
+	m := newTUIModel(ctx, store, cfg.Paths, socket, now.Sub(since))
+	_, err = tea.NewProgram(m, tea.WithAltScreen(), tea.WithContext(ctx)).Run()
+	return err
+}
+
+// tuiBuckets is the number of points in each sparkline.
+const tuiBuckets = 24
+
+// Widths of the dashboard's fixed columns.
+const (
+	tuiSizeWidth   = 10
+	tuiChangeWidth = 11
+	tuiStatusWidth = 32
+)
+
+var (
+	tuiTitleStyle    = lipgloss.NewStyle().Bold(true)
+	tuiHeaderStyle   = lipgloss.NewStyle().Bold(true).Underline(true)
+	tuiSelectedStyle = lipgloss.NewStyle().Reverse(true)
+	tuiHelpStyle     = lipgloss.NewStyle().Faint(true)
+	tuiErrorStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("1"))
+)
+
+// Messages delivered to the dashboard by its commands.
+type (
+	tuiStatusMsg struct {
+		st  *daemon.Status
+		err error
+	}
+	tuiHistoryMsg struct {
+		basePath string
+		h        *pathHistory
+		err      error
+	}
+	tuiTickMsg struct{}
+)
+
+// tuiModel is the state of the dashboard.
+type tuiModel struct {
+	ctx    context.Context
+	store  storage.Storage
+	socket string        // "" when the daemon has no control socket
+	span   time.Duration // length of the history range, ending now
+
+	width, height int
+	paths         []string // base paths, in configured order
+	histories     map[string]*pathHistory
+	loading       map[string]bool
+	errs          map[string]error
+	status        *daemon.Status // nil while the daemon cannot be reached
+	statusErr     error
+	lastScanIDs   map[string]string // latest finished scan the daemon reported, by base path
+	cursor        int
+
+	// Drill-down into one base path's directories
+	open      string // "" on the path list
+	byGrowth  bool
+	dirs      []*dirHistory // open path's directories, in display order
+	dirCursor int
+	dirOffset int
+}
+
+// newTUIModel returns a dashboard of the base paths of paths, with history
+// over span.
+func newTUIModel(ctx context.Context, store storage.Storage, paths []config.PathConfig, socket string, span time.Duration) *tuiModel {
+	m := &tuiModel{
+		ctx:         ctx,
+		store:       store,
+		socket:      socket,
+		span:        span,
+		histories:   make(map[string]*pathHistory),
+		loading:     make(map[string]bool),
+		errs:        make(map[string]error),
+		lastScanIDs: make(map[string]string),
+	}
+	for _, p := range paths {
+		m.addPath(p.Path)
+	}
+	return m
+}
+
+// addPath adds basePath to the list unless it is there already; a path
+// configured at several depths is shown once.
+func (m *tuiModel) addPath(basePath string) bool {
+	for _, p := range m.paths {
+		if p == basePath {
+			return false
+		}
+	}
+	m.paths = append(m.paths, basePath)
+	return true
+}
+
+func (m *tuiModel) Init() tea.Cmd {
+	cmds := []tea.Cmd{m.fetchStatus()}
+	for _, p := range m.paths {
+		cmds = append(cmds, m.loadHistory(p))
+	}
+	return tea.Batch(cmds...)
+}
+
+// loadHistory loads basePath's history in the background.
+func (m *tuiModel) loadHistory(basePath string) tea.Cmd {
+	m.loading[basePath] = true
+	since := time.Now().Add(-m.span)
+	return func() tea.Msg {
+		h, err := loadPathHistory(m.ctx, m.store, basePath, since, tuiBuckets)
+		return tuiHistoryMsg{basePath: basePath, h: h, err: err}
+	}
+}
+
+// fetchStatus asks the daemon for its status in the background.
+func (m *tuiModel) fetchStatus() tea.Cmd {
+	if m.socket == "" {
+		return nil
+	}
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(m.ctx, 5*time.Second)
+		defer cancel()
+		st, err := daemon.FetchStatus(ctx, m.socket)
+		return tuiStatusMsg{st: st, err: err}
+	}
+}
+
+func (m *tuiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		m.scrollDirs()
+		return m, nil
+
+	case tuiHistoryMsg:
+		delete(m.loading, msg.basePath)
+		if msg.err != nil {
+			m.errs[msg.basePath] = msg.err
+			return m, nil
+		}
+		delete(m.errs, msg.basePath)
+		m.histories[msg.basePath] = msg.h
+		if m.open == msg.basePath {
+			m.sortDirs()
+		}
+		return m, nil
+
+	case tuiStatusMsg:
+		return m, tea.Batch(m.applyStatus(msg), tea.Tick(tuiRefresh, func(time.Time) tea.Msg { return tuiTickMsg{} }))
+
+	case tuiTickMsg:
+		return m, m.fetchStatus()
+
+	case tea.KeyMsg:
+		return m, m.handleKey(msg.String())
+	}
+	return m, nil
+}
+
+// applyStatus takes in a status reply from the daemon, adding paths it
+// monitors and reloading the history of those whose scan has finished
+// since the last reply.
+func (m *tuiModel) applyStatus(msg tuiStatusMsg) tea.Cmd {
+	m.status, m.statusErr = msg.st, msg.err
+	if msg.st == nil {
+		return nil
+	}
+
+	var cmds []tea.Cmd
+	for _, p := range msg.st.Paths {
+		if m.addPath(p.Path) {
+			cmds = append(cmds, m.loadHistory(p.Path))
+		}
+	}
+	for _, p := range m.paths {
+		last := m.lastScan(p)
+		if last == nil || last.ScanID == "" {
+			continue
+		}
+		prev, known := m.lastScanIDs[p]
+		if prev == last.ScanID {
+			continue
+		}
+		m.lastScanIDs[p] = last.ScanID
+		if known && !m.loading[p] {
+			cmds = append(cmds, m.loadHistory(p))
+		}
+	}
+	return tea.Batch(cmds...)
+}
+
+// lastScan returns the latest finished scan of basePath the daemon
+// reported, at any of the depths it is configured at, or nil.
+func (m *tuiModel) lastScan(basePath string) *daemon.LastScan {
+	if m.status == nil {
+		return nil
+	}
+	var last *daemon.LastScan
+	for _, p := range m.status.Paths {
+		if p.Path == basePath && p.LastScan != nil && (last == nil || p.LastScan.FinishedAt.After(last.FinishedAt)) {
+			last = p.LastScan
+		}
+	}
+	return last
+}
+
+// handleKey acts on a key press.
+func (m *tuiModel) handleKey(key string) tea.Cmd {
+	switch key {
+	case "q", "ctrl+c":
+		return tea.Quit
+	}
+
+	if m.open == "" {
+		switch key {
+		case "up", "k":
+			m.cursor = max(m.cursor-1, 0)
+		case "down", "j":
+			m.cursor = min(m.cursor+1, len(m.paths)-1)
+		case "enter", "right", "l":
+			if m.cursor < len(m.paths) {
+				m.open = m.paths[m.cursor]
+				m.dirCursor, m.dirOffset = 0, 0
+				m.sortDirs()
+			}
+		case "r":
+			var cmds []tea.Cmd
+			for _, p := range m.paths {
+				if !m.loading[p] {
+					cmds = append(cmds, m.loadHistory(p))
+				}
+			}
+			return tea.Batch(cmds...)
+		}
+		return nil
+	}
+
+	page := m.dirRows()
+	switch key {
+	case "esc", "left", "h", "backspace":
+		m.open, m.dirs = "", nil
+	case "up", "k":
+		m.dirCursor--
+	case "down", "j":
+		m.dirCursor++
+	case "pgup":
+		m.dirCursor -= page
+	case "pgdown", " ":
+		m.dirCursor += page
+	case "home", "g":
+		m.dirCursor = 0
+	case "end", "G":
+		m.dirCursor = len(m.dirs) - 1
+	case "s":
+		m.byGrowth = !m.byGrowth
+		m.sortDirs()
+	case "r":
+		if !m.loading[m.open] {
+			return m.loadHistory(m.open)
+		}
+	}
+	m.scrollDirs()
+	return nil
+}
+
+// sortDirs orders the open path's directories for display.
+func (m *tuiModel) sortDirs() {
+	m.dirs = nil
+	if h := m.histories[m.open]; h != nil {
+		m.dirs = h.sorted(m.byGrowth)
+	}
+	m.scrollDirs()
+}
+
+// dirRows returns how many directories fit on screen.
+func (m *tuiModel) dirRows() int {
+	// Title, blank line, column header, blank line and help
+	return max(m.height-5, 1)
+}
+
+// scrollDirs keeps the directory cursor in range and on screen.
+func (m *tuiModel) scrollDirs() {
+	m.dirCursor = max(min(m.dirCursor, len(m.dirs)-1), 0)
+	rows := m.dirRows()
+	if m.dirCursor < m.dirOffset {
+		m.dirOffset = m.dirCursor
+	}
+	if m.dirCursor >= m.dirOffset+rows {
+		m.dirOffset = m.dirCursor - rows + 1
+	}
+}
+
+func (m *tuiModel) View() string {
+	if m.width == 0 {
+		return ""
+	}
+	if m.open != "" {
+		return m.viewDirs()
+	}
+	return m.viewPaths()
+}
+
+// nameWidth returns the width left for the name column next to the fixed
+// ones and extraWidth more.
+func (m *tuiModel) nameWidth(extraWidth int) int {
+	return max(m.width-tuiSizeWidth-tuiChangeWidth-tuiBuckets-extraWidth-4, 16)
+}
+
+// viewPaths draws the list of base paths.
+func (m *tuiModel) viewPaths() string {
+	var b strings.Builder
+	b.WriteString(tuiTitleStyle.Render("usgmon") + "  " + m.daemonLine() + "\n\n")
+
+	nameWidth := m.nameWidth(tuiStatusWidth + 1)
+	b.WriteString(tuiHeaderStyle.Render(m.row(nameWidth, "PATH", "SIZE", "CHANGE", "HISTORY", "STATUS")) + "\n")
+	if len(m.paths) == 0 {
+		b.WriteString("No paths configured\n")
+	}
+	for i, p := range m.paths {
+		size, change, spark := "loading…", "", ""
+		if h := m.histories[p]; h != nil {
+			size = humanize.FormatSize(h.total)
+			change = signedSize(h.total - h.start)
+			spark = humanize.Sparkline(h.totals)
+		} else if !m.loading[p] {
+			size = "-"
+		}
+		line := m.row(nameWidth, p, size, change, spark, m.pathStatus(p))
+		if i == m.cursor {
+			line = tuiSelectedStyle.Render(line)
+		}
+		b.WriteString(line + "\n")
+	}
+
+	b.WriteString("\n" + tuiHelpStyle.Render(fmt.Sprintf("history: last %s · enter: open · r: reload · q: quit", m.spanText())))
+	return b.String()
+}
+
+// viewDirs draws the directories of the open base path.
+func (m *tuiModel) viewDirs() string {
+	var b strings.Builder
+	h := m.histories[m.open]
+	sortBy := "size"
+	if m.byGrowth {
+		sortBy = "growth"
+	}
+	title := tuiTitleStyle.Render(m.open)
+	switch {
+	case m.errs[m.open] != nil:
+		title += "  " + tuiErrorStyle.Render(m.errs[m.open].Error())
+	case h != nil:
+		title += fmt.Sprintf("  %s in %d directories, %s over the last %s · sorted by %s",
+			humanize.FormatSize(h.total), len(h.dirs), signedSize(h.total-h.start), m.spanText(), sortBy)
+	default:
+		title += "  loading…"
+	}
+	if scan := m.runningScan(m.open); scan != "" {
+		title += " · " + scan
+	}
+	b.WriteString(title + "\n\n")
+
+	nameWidth := m.nameWidth(0)
+	b.WriteString(tuiHeaderStyle.Render(m.row(nameWidth, "DIRECTORY", "SIZE", "CHANGE", "HISTORY", "")) + "\n")
+	rows := m.dirRows()
+	for i := m.dirOffset; i < len(m.dirs) && i < m.dirOffset+rows; i++ {
+		d := m.dirs[i]
+		name := d.directory
+		if rel, err := filepath.Rel(m.open, d.directory); err == nil {
+			name = rel
+		}
+		line := m.row(nameWidth, name, humanize.FormatSize(d.size()), signedSize(d.change()),
+			humanize.Sparkline(d.sizesAt(h.buckets)), "")
+		if i == m.dirCursor {
+			line = tuiSelectedStyle.Render(line)
+		}
+		b.WriteString(line + "\n")
+	}
+	for i := len(m.dirs) - m.dirOffset; i < rows; i++ {
+		b.WriteString("\n")
+	}
+
+	position := ""
+	if len(m.dirs) > 0 {
+		position = fmt.Sprintf("%d/%d · ", m.dirCursor+1, len(m.dirs))
+	}
+	b.WriteString("\n" + tuiHelpStyle.Render(position+"s: sort by size/growth · r: reload · esc: back · q: quit"))
+	return b.String()
+}
+
+// row lays out one line of the dashboard's columns.
+func (m *tuiModel) row(nameWidth int, name, size, change, spark, status string) string {
+	line := fmt.Sprintf("%s %s %s %s",
+		padRight(truncateLeft(name, nameWidth), nameWidth),
+		padLeft(size, tuiSizeWidth),
+		padLeft(change, tuiChangeWidth),
+		padRight(spark, tuiBuckets),
+	)
+	if status != "" {
+		line += " " + padRight(truncateRight(status, tuiStatusWidth), tuiStatusWidth)
+	}
+	return line
+}
+
+// daemonLine describes the connection to the daemon.
+func (m *tuiModel) daemonLine() string {
+	switch {
+	case m.socket == "":
+		return tuiHelpStyle.Render("no control socket configured; showing the database only")
+	case m.status != nil:
+		st := m.status
+		line := fmt.Sprintf("daemon %s, pid %d, up %s", orDash(st.Version), st.PID, time.Duration(st.UptimeSeconds)*time.Second)
+		if n := len(st.Scans); n > 0 {
+			line += fmt.Sprintf(", %d scans running", n)
+		}
+		return line
+	case m.statusErr != nil:
+		return tuiErrorStyle.Render("daemon not reachable: " + m.statusErr.Error())
+	default:
+		return tuiHelpStyle.Render("connecting to daemon…")
+	}
+}
+
+// pathStatus describes the scan in progress or the last scan of basePath.
+func (m *tuiModel) pathStatus(basePath string) string {
+	if err := m.errs[basePath]; err != nil {
+		return "error: " + err.Error()
+	}
+	if scan := m.runningScan(basePath); scan != "" {
+		return scan
+	}
+
+	if last := m.lastScan(basePath); last != nil {
+		return fmt.Sprintf("%s %s ago", last.Status, sinceText(last.FinishedAt))
+	}
+
+	h := m.histories[basePath]
+	if h == nil || h.lastScan == nil {
+		return "never scanned"
+	}
+	sc := h.lastScan
+	status, _, _ := strings.Cut(sc.Status, ":")
+	if sc.CompletedAt == nil {
+		return fmt.Sprintf("%s since %s ago", status, sinceText(sc.StartedAt))
+	}
+	return fmt.Sprintf("%s %s ago", status, sinceText(*sc.CompletedAt))
+}
+
+// runningScan describes the daemon's scans of basePath in progress, or
+// returns "" if there are none.
+func (m *tuiModel) runningScan(basePath string) string {
+	if m.status == nil {
+		return ""
+	}
+	var dirs int64
+	var started time.Time
+	for _, sc := range m.status.Scans {
+		if sc.Path != basePath {
+			continue
+		}
+		dirs += sc.Directories
+		if started.IsZero() || sc.StartedAt.Before(started) {
+			started = sc.StartedAt
+		}
+	}
+	if started.IsZero() {
+		return ""
+	}
+	return fmt.Sprintf("scanning: %d dirs, %s", dirs, time.Since(started).Round(time.Second))
+}
+
+// spanText formats the history range.
+func (m *tuiModel) spanText() string {
+	return durationText(m.span)
+}
+
+// sinceText formats how long ago t was, coarsely.
+func sinceText(t time.Time) string {
+	return durationText(time.Since(t))
+}
+
+// durationText formats d in its largest whole unit of days, hours, minutes
+// or seconds.
+func durationText(d time.Duration) string {
+	switch {
+	case d >= 48*time.Hour:
+		return fmt.Sprintf("%dd", int(d/(24*time.Hour)))
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh", int(d/time.Hour))
+	case d >= time.Minute:
+		return fmt.Sprintf("%dm", int(d/time.Minute))
+	default:
+		return fmt.Sprintf("%ds", int(max(d, 0)/time.Second))
+	}
+}
+
+// truncateLeft shortens s to width runes, keeping its end, which for paths
+// is the part that tells them apart.
+func truncateLeft(s string, width int) string {
+	r := []rune(s)
+	if len(r) <= width {
+		return s
+	}
+	return "…" + string(r[len(r)-width+1:])
+}
+
+// truncateRight shortens s to width runes, keeping its start.
+func truncateRight(s string, width int) string {
+	r := []rune(s)
+	if len(r) <= width {
+		return s
+	}
+	return string(r[:width-1]) + "…"
+}
+
+// padRight pads s with spaces to width runes.
+func padRight(s string, width int) string {
+	return s + strings.Repeat(" ", max(width-len([]rune(s)), 0))
+}
+
+// padLeft right-aligns s in width runes.
+func padLeft(s string, width int) string {
+	return strings.Repeat(" ", max(width-len([]rune(s)), 0)) + s
+}
@@ -0,0 +1,149 @@
+package cli
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jgalley/usgmon/pkg/storage"
+)
+
+// sizePoint is a directory's size as of a time.
+type sizePoint struct {
+	at   time.Time
+	size int64 // 0 once the directory is gone
+}
+
+// dirHistory is one directory's size over the dashboard's time range.
+type dirHistory struct {
+	directory string
+	start     int64       // size at the start of the range; 0 if not yet recorded
+	points    []sizePoint // records within the range, oldest first
+	gone      bool        // last recorded as deleted
+}
+
+// size returns the directory's latest size.
+func (h *dirHistory) size() int64 {
+	if len(h.points) == 0 {
+		return h.start
+	}
+	return h.points[len(h.points)-1].size
+}
+
+// change returns how much the directory grew over the range.
+func (h *dirHistory) change() int64 {
+	return h.size() - h.start
+}
+
+// sizesAt returns the directory's size at each of times, which are in
+// ascending order.
+func (h *dirHistory) sizesAt(times []time.Time) []int64 {
+	sizes := make([]int64, len(times))
+	size, next := h.start, 0
+	for i, t := range times {
+		for next < len(h.points) && !h.points[next].at.After(t) {
+			size = h.points[next].size
+			next++
+		}
+		sizes[i] = size
+	}
+	return sizes
+}
+
+// pathHistory is the usage history of a base path over the dashboard's
+// time range, replayed from its records.
+type pathHistory struct {
+	basePath string
+	since    time.Time
+	until    time.Time
+	dirs     []*dirHistory // directories that still exist, by name
+	buckets  []time.Time   // ends of the sparkline's intervals
+	totals   []int64       // total size at the end of each bucket
+	start    int64         // total size at the start of the range
+	total    int64         // latest total size
+	lastScan *storage.Scan // latest scan that ran, nil if none
+}
+
+// sorted returns the directories largest first, or fastest growing first
+// with byGrowth.
+func (h *pathHistory) sorted(byGrowth bool) []*dirHistory {
+	dirs := append([]*dirHistory(nil), h.dirs...)
+	key := (*dirHistory).size
+	if byGrowth {
+		key = (*dirHistory).change
+	}
+	sort.SliceStable(dirs, func(i, j int) bool { return key(dirs[i]) > key(dirs[j]) })
+	return dirs
+}
+
+// loadPathHistory loads the history of basePath from since to now in
+// buckets intervals: the snapshot at since, then every record after it.
+// Filtered measurements (paths[].exclude_files) are left out, as by
+// snapshots.
+func loadPathHistory(ctx context.Context, store storage.Storage, basePath string, since time.Time, buckets int) (*pathHistory, error) {
+	now := time.Now()
+	h := &pathHistory{basePath: basePath, since: since, until: now}
+	for i := 1; i <= buckets; i++ {
+		h.buckets = append(h.buckets, since.Add(now.Sub(since)*time.Duration(i)/time.Duration(buckets)))
+	}
+
+	snapshot, err := store.GetSnapshotAt(ctx, basePath, "", since)
+	if err != nil {
+		return nil, err
+	}
+	records, err := store.QueryUsage(ctx, storage.QueryOptions{BasePath: basePath, Since: &since})
+	if err != nil {
+		return nil, err
+	}
+
+	byDir := make(map[string]*dirHistory, len(snapshot))
+	for _, r := range snapshot {
+		byDir[r.Directory] = &dirHistory{directory: r.Directory, start: r.SizeBytes}
+	}
+	// Records come newest first
+	for i := len(records) - 1; i >= 0; i-- {
+		r := records[i]
+		if r.FileFilter != "" {
+			continue
+		}
+		d := byDir[r.Directory]
+		if d == nil {
+			d = &dirHistory{directory: r.Directory}
+			byDir[r.Directory] = d
+		}
+		size := r.SizeBytes
+		if r.Deleted {
+			size = 0
+		}
+		d.points = append(d.points, sizePoint{at: r.RecordedAt, size: size})
+		d.gone = r.Deleted
+	}
+
+	h.totals = make([]int64, buckets)
+	for _, d := range byDir {
+		for i, size := range d.sizesAt(h.buckets) {
+			h.totals[i] += size
+		}
+		h.start += d.start
+		h.total += d.size()
+		if !d.gone {
+			h.dirs = append(h.dirs, d)
+		}
+	}
+	sort.Slice(h.dirs, func(i, j int) bool { return h.dirs[i].directory < h.dirs[j].directory })
+
+	scans, err := store.ListScans(ctx, storage.ScanListOptions{BasePath: basePath, Limit: 10})
+	if err != nil {
+		return nil, err
+	}
+	for i, sc := range scans {
+		// Skipped scans never ran, and watch samples are not full scans
+		if strings.HasPrefix(sc.Status, "skipped: ") || sc.Trigger == storage.TriggerWatch {
+			continue
+		}
+		h.lastScan = &scans[i]
+		break
+	}
+	return h, nil
+}
@@ -2,8 +2,20 @@ package config
 
 import (
 	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/jgalley/usgmon/internal/anomaly"
+	"github.com/jgalley/usgmon/internal/humanize"
+	"github.com/jgalley/usgmon/pkg/scanner"
+	"github.com/mitchellh/mapstructure"
+	"github.com/robfig/cron/v3"
 	"github.com/spf13/viper"
 )
 
@@ -12,6 +24,13 @@ type Config struct {
 	Database DatabaseConfig `mapstructure:"database"`
 	Logging  LoggingConfig  `mapstructure:"logging"`
 	Scan     ScanConfig     `mapstructure:"scan"`
+	Alerts   AlertsConfig   `mapstructure:"alerts"`
+	API      APIConfig      `mapstructure:"api"`
+	Control  ControlConfig  `mapstructure:"control"`
+	Webhooks WebhooksConfig `mapstructure:"webhooks"`
+	Slack    SlackConfig    `mapstructure:"slack"`
+	Email    EmailConfig    `mapstructure:"email"`
+	Agent    AgentConfig    `mapstructure:"agent"`
 	Paths    []PathConfig   `mapstructure:"paths"`
 }
 
@@ -20,6 +39,117 @@ type DatabaseConfig struct {
 	Path string `mapstructure:"path"`
 }
 
+// APIConfig holds settings for the daemon's HTTP API.
+type APIConfig struct {
+	// Listen is the address (host:port) the API listens on; empty disables it.
+	Listen string `mapstructure:"listen"`
+	// GRPCListen is the address the gRPC API listens on; empty disables it.
+	GRPCListen string `mapstructure:"grpc_listen"`
+	// AgentToken enables the ingest endpoints agents push scans to; agents
+	// must present it as a bearer token. Empty disables ingest.
+	AgentToken string `mapstructure:"agent_token"`
+}
+
+// AgentConfig holds settings for pushing scan results to a central usgmon
+// server. The daemon still keeps its own database for skip-list, alert and
+// change-filtering state.
+type AgentConfig struct {
+	// Server is the base URL of the central server's API; empty disables
+	// pushing.
+	Server     string        `mapstructure:"server"`
+	Token      string        `mapstructure:"token"`
+	Timeout    time.Duration `mapstructure:"timeout"`
+	Retries    int           `mapstructure:"retries"`
+	RetryDelay time.Duration `mapstructure:"retry_delay"`
+}
+
+// ControlConfig holds settings for the daemon's control socket.
+type ControlConfig struct {
+	// Socket is the Unix socket path that usgmon status queries; empty
+	// disables it.
+	Socket string `mapstructure:"socket"`
+}
+
+// maxSocketPath is the longest Unix socket path that fits in sockaddr_un on
+// every supported platform.
+const maxSocketPath = 103
+
+// Webhook event types.
+const (
+	WebhookScanCompleted = "scan_completed"
+	WebhookAlert         = "alert"
+)
+
+// WebhooksConfig holds settings for webhook notifications.
+type WebhooksConfig struct {
+	// URLs receive a JSON POST for every event; none disables webhooks.
+	URLs []string `mapstructure:"urls"`
+	// Events selects which event types are sent.
+	Events      []string      `mapstructure:"events"`
+	Timeout     time.Duration `mapstructure:"timeout"`
+	Retries     int           `mapstructure:"retries"`
+	RetryDelay  time.Duration `mapstructure:"retry_delay"`
+	TopChangers int           `mapstructure:"top_changers"`
+}
+
+// Sends reports whether webhooks are configured for the given event type.
+func (w WebhooksConfig) Sends(event string) bool {
+	if len(w.URLs) == 0 {
+		return false
+	}
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// SlackConfig holds settings for alert messages posted to Slack-compatible
+// incoming webhooks. Delivery uses the webhooks timeout and retry settings.
+type SlackConfig struct {
+	// URLs are incoming webhook URLs; none disables Slack messages.
+	URLs     []string `mapstructure:"urls"`
+	Channel  string   `mapstructure:"channel"`
+	Username string   `mapstructure:"username"`
+	// History is the number of recorded sizes drawn as a sparkline.
+	History int `mapstructure:"history"`
+}
+
+// Email TLS modes.
+const (
+	EmailTLSStartTLS = "starttls"
+	EmailTLSImplicit = "tls"
+	EmailTLSNone     = "none"
+)
+
+// EmailConfig holds settings for alert emails and usage digests sent over
+// SMTP. The username and password may also be set with the
+// USGMON_SMTP_USERNAME and USGMON_SMTP_PASSWORD environment variables.
+type EmailConfig struct {
+	// SMTPHost is the mail server; empty disables email.
+	SMTPHost string        `mapstructure:"smtp_host"`
+	SMTPPort int           `mapstructure:"smtp_port"`
+	Username string        `mapstructure:"username"`
+	Password string        `mapstructure:"password"`
+	TLS      string        `mapstructure:"tls"`
+	Timeout  time.Duration `mapstructure:"timeout"`
+	From     string        `mapstructure:"from"`
+	To       []string      `mapstructure:"to"`
+	// Alerts emails each alert event as it happens.
+	Alerts bool `mapstructure:"alerts"`
+	// DigestSchedule is a cron expression for the usage digest; empty
+	// disables digests.
+	DigestSchedule string `mapstructure:"digest_schedule"`
+	// DigestTop is the number of top changers listed per path in a digest.
+	DigestTop int `mapstructure:"digest_top"`
+}
+
+// Enabled reports whether an SMTP server is configured.
+func (e EmailConfig) Enabled() bool {
+	return e.SMTPHost != ""
+}
+
 // LoggingConfig holds logging-related settings.
 type LoggingConfig struct {
 	Level  string `mapstructure:"level"`
@@ -28,8 +158,143 @@ type LoggingConfig struct {
 
 // ScanConfig holds default scan settings.
 type ScanConfig struct {
-	Interval time.Duration `mapstructure:"interval"`
-	Workers  int           `mapstructure:"workers"`
+	Interval          time.Duration `mapstructure:"interval"`
+	Workers           int           `mapstructure:"workers"`
+	SkipAfterErrors   int           `mapstructure:"skip_after_errors"`
+	SkipProbeInterval time.Duration `mapstructure:"skip_probe_interval"`
+	SharedPool        bool          `mapstructure:"shared_pool"`
+	DropCache         bool          `mapstructure:"drop_cache"`
+	Fingerprint       bool          `mapstructure:"fingerprint"`
+	AllocatedSize     bool          `mapstructure:"allocated_size"`
+	CountEntries      bool          `mapstructure:"count_entries"`
+	MtimeShortcut     bool          `mapstructure:"mtime_shortcut"`
+	RctimeShortcut    bool          `mapstructure:"rctime_shortcut"`
+	RctimeCopyForward bool          `mapstructure:"rctime_copy_forward"`
+	RecordOwner       bool          `mapstructure:"record_owner"`
+	IOPressureLimit   float64       `mapstructure:"io_pressure_limit"`
+	WalkOpsLimit      int           `mapstructure:"walk_ops_limit"`
+	DuWrapper         string        `mapstructure:"du_wrapper"`
+	KeepScans         int           `mapstructure:"keep_scans"`
+	RollupAfter       time.Duration `mapstructure:"rollup_after"`
+	StatfsTimeout     time.Duration `mapstructure:"statfs_timeout"`
+	DirTimeout        time.Duration `mapstructure:"dir_timeout"`
+	MinChangePercent  float64       `mapstructure:"min_change_percent"`
+	MinChangeBytes    humanize.Size `mapstructure:"min_change_bytes"`
+	PostHook          string        `mapstructure:"post_hook"`
+	PostHookTimeout   time.Duration `mapstructure:"post_hook_timeout"`
+	DuBatchSize       int           `mapstructure:"du_batch_size"`
+	WalkWorkers       int           `mapstructure:"walk_workers"`
+	DedupePaths       bool          `mapstructure:"dedupe_paths"`
+	DedupeHardlinks   bool          `mapstructure:"dedupe_hardlinks"`
+	UsageByOwner      bool          `mapstructure:"usage_by_owner"`
+	LargestFiles      int           `mapstructure:"largest_files"`
+	AgeBreakdown      string        `mapstructure:"age_breakdown"`
+	AgeBuckets        []int         `mapstructure:"age_buckets"`
+	RecordMetadata    bool          `mapstructure:"record_metadata"`
+	ReconcileDeleted  bool          `mapstructure:"reconcile_deleted"`
+	WatchInterval     time.Duration `mapstructure:"watch_interval"`
+	WatchThreshold    humanize.Size `mapstructure:"watch_threshold"`
+	// Hostname tags scans and usage records with the host that made them;
+	// empty means the system host name.
+	Hostname string `mapstructure:"hostname"`
+	// MaxConcurrentPerDevice limits the scans running at once on each
+	// filesystem; 0 means no limit.
+	MaxConcurrentPerDevice int `mapstructure:"max_concurrent_per_device"`
+	// DeleteStaleRecords deletes the usage records of scans a crashed
+	// daemon left running when the next one starts.
+	DeleteStaleRecords bool `mapstructure:"delete_stale_records"`
+	// ResumeScans makes scans cut short by shutdown or a crash resume under
+	// the same scan ID, measuring only the directories they had not
+	// finished, if the path is scanned again within ResumeMaxAge.
+	ResumeScans  bool          `mapstructure:"resume_scans"`
+	ResumeMaxAge time.Duration `mapstructure:"resume_max_age"`
+}
+
+// Host returns the name scans and usage records are tagged with.
+func (s ScanConfig) Host() string {
+	if s.Hostname != "" {
+		return s.Hostname
+	}
+	name, _ := os.Hostname()
+	return name
+}
+
+// DuWrapperArgs returns the command and arguments du runs under, or nil to
+// run it directly.
+func (s ScanConfig) DuWrapperArgs() []string {
+	return strings.Fields(s.DuWrapper)
+}
+
+// AgeDays returns the bucket bounds of the age breakdown, or nil if it is
+// disabled.
+func (s ScanConfig) AgeDays() []int {
+	if s.AgeBreakdown == "" {
+		return nil
+	}
+	return s.AgeBuckets
+}
+
+// ChangeThresholdEnabled reports whether small size changes are left unrecorded.
+func (s ScanConfig) ChangeThresholdEnabled() bool {
+	return s.MinChangePercent > 0 || s.MinChangeBytes > 0
+}
+
+// SignificantChange reports whether a change from prev to cur bytes should be
+// recorded. A change is insignificant only if it is below every configured
+// threshold; an unset (zero) threshold is ignored.
+func (s ScanConfig) SignificantChange(prev, cur int64) bool {
+	if !s.ChangeThresholdEnabled() {
+		return true
+	}
+	delta := cur - prev
+	if delta < 0 {
+		delta = -delta
+	}
+	if s.MinChangeBytes > 0 && delta >= int64(s.MinChangeBytes) {
+		return true
+	}
+	if s.MinChangePercent > 0 {
+		if prev == 0 {
+			return delta != 0
+		}
+		if float64(delta)*100/float64(prev) >= s.MinChangePercent {
+			return true
+		}
+	}
+	return false
+}
+
+// AlertsConfig holds settings shared by all size alerts.
+type AlertsConfig struct {
+	// ReminderInterval re-notifies about a directory that stays over its
+	// threshold this long after the last notification; 0 disables reminders.
+	ReminderInterval time.Duration `mapstructure:"reminder_interval"`
+
+	// QuotaPercent alerts when a directory uses this percentage of the quota
+	// its filesystem enforces (CephFS only); 0 disables quota alerts.
+	QuotaPercent float64 `mapstructure:"quota_percent"`
+
+	// AnomalySigma alerts when a scan finds a directory grown this many
+	// standard deviations above its usual change between records; 0
+	// disables growth anomaly alerts.
+	AnomalySigma float64 `mapstructure:"anomaly_sigma"`
+
+	// AnomalyMinGrowth is the smallest growth that raises an anomaly alert,
+	// so directories that barely ever change do not page on small writes.
+	AnomalyMinGrowth humanize.Size `mapstructure:"anomaly_min_growth"`
+
+	// AnomalyHistory is how many of a directory's latest records its growth
+	// baseline is computed from.
+	AnomalyHistory int `mapstructure:"anomaly_history"`
+}
+
+// QuotaThreshold returns the size at which a directory with the given quota
+// alerts, or 0 if quota alerts are disabled or there is no quota.
+func (a AlertsConfig) QuotaThreshold(quota int64) int64 {
+	if a.QuotaPercent <= 0 || quota <= 0 {
+		return 0
+	}
+	return int64(float64(quota) * a.QuotaPercent / 100)
 }
 
 // PathConfig holds configuration for a monitored path.
@@ -37,8 +302,20 @@ type PathConfig struct {
 	Path           string        `mapstructure:"path"`
 	Depth          int           `mapstructure:"depth"`
 	Interval       time.Duration `mapstructure:"interval"`
+	Schedule       string        `mapstructure:"schedule"`
 	FollowSymlinks bool          `mapstructure:"follow_symlinks"`
+	OneFileSystem  bool          `mapstructure:"one_file_system"`
+	MtimeCache     bool          `mapstructure:"mtime_cache"`
+	Watch          bool          `mapstructure:"watch"`
 	Exclude        []string      `mapstructure:"exclude"`
+	ExcludeFiles   []string      `mapstructure:"exclude_files"`
+	LooseFiles     bool          `mapstructure:"loose_files"`
+	Strategy       string        `mapstructure:"strategy"`
+	SizeMode       string        `mapstructure:"size_mode"`
+	Priority       int           `mapstructure:"priority"`
+	KeepScans      int           `mapstructure:"keep_scans"`
+	DirTimeout     time.Duration `mapstructure:"dir_timeout"`
+	AlertAbove     humanize.Size `mapstructure:"alert_above"`
 }
 
 // EffectiveInterval returns the interval for this path, falling back to the default.
@@ -49,16 +326,103 @@ func (p PathConfig) EffectiveInterval(defaultInterval time.Duration) time.Durati
 	return defaultInterval
 }
 
-// Load reads configuration from the specified file path.
-func Load(configPath string) (*Config, error) {
-	v := viper.New()
+// EffectiveSizeMode returns what this path's sizes measure, one of
+// scanner.SizeModes: its own size_mode if set, otherwise both apparent and
+// allocated when scan.allocated_size is set, and apparent size alone if not.
+func (p PathConfig) EffectiveSizeMode(allocatedSize bool) string {
+	switch {
+	case p.SizeMode != "":
+		return p.SizeMode
+	case allocatedSize:
+		return scanner.SizeBoth
+	}
+	return scanner.SizeApparent
+}
+
+// Schedule yields successive scan times.
+type Schedule interface {
+	// Next returns the first scan time after t.
+	Next(t time.Time) time.Time
+}
 
-	// Set defaults
+// EffectiveSchedule returns the scan schedule for this path: its cron
+// schedule if set, otherwise a fixed interval falling back to the default.
+func (p PathConfig) EffectiveSchedule(defaultInterval time.Duration) (Schedule, error) {
+	if p.Schedule != "" {
+		sched, err := cron.ParseStandard(p.Schedule)
+		if err != nil {
+			return nil, fmt.Errorf("parsing schedule %q: %w", p.Schedule, err)
+		}
+		return sched, nil
+	}
+	return cron.Every(p.EffectiveInterval(defaultInterval)), nil
+}
+
+// EffectiveKeepScans returns the number of scans to retain for this path,
+// falling back to the default. Zero means unlimited.
+func (p PathConfig) EffectiveKeepScans(defaultKeep int) int {
+	if p.KeepScans > 0 {
+		return p.KeepScans
+	}
+	return defaultKeep
+}
+
+// EffectiveDirTimeout returns how long one directory of this path may take
+// to measure, falling back to the default. Zero means no limit.
+func (p PathConfig) EffectiveDirTimeout(defaultTimeout time.Duration) time.Duration {
+	if p.DirTimeout > 0 {
+		return p.DirTimeout
+	}
+	return defaultTimeout
+}
+
+// setDefaults sets the values of settings a configuration file leaves out.
+func setDefaults(v *viper.Viper) {
 	v.SetDefault("database.path", "/var/lib/usgmon/usgmon.db")
 	v.SetDefault("logging.level", "info")
 	v.SetDefault("logging.format", "text")
+	v.SetDefault("control.socket", "/run/usgmon/usgmon.sock")
 	v.SetDefault("scan.interval", "1h")
 	v.SetDefault("scan.workers", 4)
+	v.SetDefault("scan.walk_workers", 1)
+	v.SetDefault("scan.du_batch_size", scanner.DefaultDuBatchSize)
+	v.SetDefault("scan.resume_max_age", "24h")
+	v.SetDefault("scan.skip_after_errors", 3)
+	v.SetDefault("scan.skip_probe_interval", "24h")
+	v.SetDefault("scan.statfs_timeout", "5s")
+	v.SetDefault("scan.post_hook_timeout", "30s")
+	v.SetDefault("scan.age_buckets", scanner.DefaultAgeDays)
+	v.SetDefault("scan.watch_interval", "1m")
+	v.SetDefault("scan.watch_threshold", "1G")
+	v.SetDefault("alerts.anomaly_min_growth", "1G")
+	v.SetDefault("alerts.anomaly_history", 30)
+	v.SetDefault("webhooks.events", []string{WebhookScanCompleted, WebhookAlert})
+	v.SetDefault("webhooks.timeout", "10s")
+	v.SetDefault("webhooks.retries", 3)
+	v.SetDefault("webhooks.retry_delay", "5s")
+	v.SetDefault("webhooks.top_changers", 10)
+	v.SetDefault("slack.history", 12)
+	v.SetDefault("email.smtp_port", 587)
+	v.SetDefault("email.tls", EmailTLSStartTLS)
+	v.SetDefault("email.timeout", "30s")
+	v.SetDefault("email.alerts", true)
+	v.SetDefault("email.digest_top", 10)
+
+	v.SetDefault("agent.timeout", "30s")
+	v.SetDefault("agent.retries", 5)
+	v.SetDefault("agent.retry_delay", "5s")
+}
+
+// Load reads configuration from the specified file path.
+func Load(configPath string) (*Config, error) {
+	v := viper.New()
+	setDefaults(v)
+
+	// Keep credentials out of the config file if preferred
+	v.BindEnv("agent.token", "USGMON_AGENT_TOKEN")
+	v.BindEnv("api.agent_token", "USGMON_API_AGENT_TOKEN")
+	v.BindEnv("email.username", "USGMON_SMTP_USERNAME")
+	v.BindEnv("email.password", "USGMON_SMTP_PASSWORD")
 
 	if configPath != "" {
 		v.SetConfigFile(configPath)
@@ -77,15 +441,31 @@ func Load(configPath string) (*Config, error) {
 		// Config file not found is OK if using defaults
 	}
 
-	var cfg Config
-	if err := v.Unmarshal(&cfg); err != nil {
-		return nil, fmt.Errorf("unmarshaling config: %w", err)
+	cfg, err := unmarshal(v)
+	if err != nil {
+		return nil, err
 	}
 
 	if err := cfg.Validate(); err != nil {
 		return nil, fmt.Errorf("validating config: %w", err)
 	}
 
+	return cfg, nil
+}
+
+// unmarshal decodes the settings held by v into a Config.
+func unmarshal(v *viper.Viper) (*Config, error) {
+	var cfg Config
+	// Sizes may be written as "500G"; keep viper's default duration and
+	// slice hooks alongside the text unmarshaler for humanize.Size
+	decodeHook := viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToSliceHookFunc(","),
+		mapstructure.TextUnmarshallerHookFunc(),
+	))
+	if err := v.Unmarshal(&cfg, decodeHook); err != nil {
+		return nil, fmt.Errorf("unmarshaling config: %w", err)
+	}
 	return &cfg, nil
 }
 
@@ -103,32 +483,313 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("scan.interval must be at least 1s")
 	}
 
+	if c.Scan.IOPressureLimit < 0 || c.Scan.IOPressureLimit > 100 {
+		return fmt.Errorf("scan.io_pressure_limit must be between 0 and 100")
+	}
+
+	if c.Scan.KeepScans < 0 {
+		return fmt.Errorf("scan.keep_scans must be non-negative")
+	}
+
+	if c.Scan.RollupAfter != 0 && c.Scan.RollupAfter < 24*time.Hour {
+		return fmt.Errorf("scan.rollup_after must be 0 or at least 24h")
+	}
+
+	if c.Scan.MinChangePercent < 0 {
+		return fmt.Errorf("scan.min_change_percent must be non-negative")
+	}
+
+	if c.Scan.MinChangeBytes < 0 {
+		return fmt.Errorf("scan.min_change_bytes must be non-negative")
+	}
+
+	if c.Scan.DuBatchSize < 0 {
+		return fmt.Errorf("scan.du_batch_size must be non-negative")
+	}
+
+	if c.Scan.WalkOpsLimit < 0 {
+		return fmt.Errorf("scan.walk_ops_limit must be non-negative")
+	}
+	if args := c.Scan.DuWrapperArgs(); len(args) > 0 {
+		if _, err := exec.LookPath(args[0]); err != nil {
+			return fmt.Errorf("scan.du_wrapper: %w", err)
+		}
+	}
+
+	if c.Scan.MaxConcurrentPerDevice < 0 {
+		return fmt.Errorf("scan.max_concurrent_per_device must be non-negative")
+	}
+
+	if c.Scan.ResumeScans && c.Scan.ResumeMaxAge <= 0 {
+		return fmt.Errorf("scan.resume_max_age must be positive")
+	}
+
+	if c.Scan.WalkWorkers < 1 {
+		return fmt.Errorf("scan.walk_workers must be at least 1")
+	}
+
+	if c.Scan.LargestFiles < 0 || c.Scan.LargestFiles > scanner.MaxLargestFiles {
+		return fmt.Errorf("scan.largest_files must be between 0 and %d", scanner.MaxLargestFiles)
+	}
+
+	if c.Scan.RctimeCopyForward && !c.Scan.RctimeShortcut {
+		return fmt.Errorf("scan.rctime_copy_forward requires scan.rctime_shortcut")
+	}
+
+	if c.Scan.AgeBreakdown != "" {
+		if !scanner.ValidAgeTime(c.Scan.AgeBreakdown) {
+			return fmt.Errorf("scan.age_breakdown must be one of %s", strings.Join(scanner.AgeTimes, ", "))
+		}
+		if err := scanner.ValidateAgeDays(c.Scan.AgeBuckets); err != nil {
+			return fmt.Errorf("scan.age_buckets: %w", err)
+		}
+	}
+
+	if c.Scan.WatchInterval < time.Second {
+		return fmt.Errorf("scan.watch_interval must be at least 1s")
+	}
+	if c.Scan.WatchThreshold <= 0 {
+		return fmt.Errorf("scan.watch_threshold must be positive")
+	}
+
+	if c.Scan.StatfsTimeout <= 0 {
+		return fmt.Errorf("scan.statfs_timeout must be positive")
+	}
+	if c.Scan.DirTimeout < 0 {
+		return fmt.Errorf("scan.dir_timeout must be non-negative")
+	}
+	if c.Scan.PostHook != "" && c.Scan.PostHookTimeout <= 0 {
+		return fmt.Errorf("scan.post_hook_timeout must be positive")
+	}
+
+	if c.API.Listen != "" {
+		if _, _, err := net.SplitHostPort(c.API.Listen); err != nil {
+			return fmt.Errorf("api.listen must be host:port: %w", err)
+		}
+	}
+
+	if c.API.AgentToken != "" && c.API.Listen == "" {
+		return fmt.Errorf("api.agent_token requires api.listen")
+	}
+
+	if c.Agent.Server != "" {
+		if !isHTTPURL(c.Agent.Server) {
+			return fmt.Errorf("agent.server must be an http or https URL")
+		}
+		if c.Agent.Token == "" {
+			return fmt.Errorf("agent.token is required when agent.server is set")
+		}
+		if c.Agent.Timeout <= 0 {
+			return fmt.Errorf("agent.timeout must be positive")
+		}
+		if c.Agent.Retries < 0 {
+			return fmt.Errorf("agent.retries must be non-negative")
+		}
+		if c.Agent.RetryDelay < 0 {
+			return fmt.Errorf("agent.retry_delay must be non-negative")
+		}
+	}
+
+	if len(c.Control.Socket) > maxSocketPath {
+		return fmt.Errorf("control.socket must be at most %d bytes long", maxSocketPath)
+	}
+
+	for i, u := range c.Webhooks.URLs {
+		if !isHTTPURL(u) {
+			return fmt.Errorf("webhooks.urls[%d] must be an http or https URL", i)
+		}
+	}
+	for i, u := range c.Slack.URLs {
+		if !isHTTPURL(u) {
+			return fmt.Errorf("slack.urls[%d] must be an http or https URL", i)
+		}
+	}
+	if c.Slack.History < 0 {
+		return fmt.Errorf("slack.history must be non-negative")
+	}
+	for _, e := range c.Webhooks.Events {
+		if e != WebhookScanCompleted && e != WebhookAlert {
+			return fmt.Errorf("webhooks.events: unknown event %q (valid: %s, %s)", e, WebhookScanCompleted, WebhookAlert)
+		}
+	}
+	if c.Webhooks.Timeout <= 0 {
+		return fmt.Errorf("webhooks.timeout must be positive")
+	}
+	if c.Webhooks.Retries < 0 {
+		return fmt.Errorf("webhooks.retries must be non-negative")
+	}
+	if c.Webhooks.RetryDelay < 0 {
+		return fmt.Errorf("webhooks.retry_delay must be non-negative")
+	}
+	if c.Webhooks.TopChangers < 0 {
+		return fmt.Errorf("webhooks.top_changers must be non-negative")
+	}
+
+	if c.Email.Enabled() {
+		if err := c.Email.validate(); err != nil {
+			return err
+		}
+	}
+
+	if c.Alerts.ReminderInterval < 0 {
+		return fmt.Errorf("alerts.reminder_interval must be non-negative")
+	}
+	if c.Alerts.QuotaPercent < 0 || c.Alerts.QuotaPercent > 100 {
+		return fmt.Errorf("alerts.quota_percent must be between 0 and 100")
+	}
+	if c.Alerts.AnomalySigma < 0 {
+		return fmt.Errorf("alerts.anomaly_sigma must be non-negative")
+	}
+	if c.Alerts.AnomalyMinGrowth < 0 {
+		return fmt.Errorf("alerts.anomaly_min_growth must be non-negative")
+	}
+	if c.Alerts.AnomalySigma > 0 && c.Alerts.AnomalyHistory <= anomaly.MinSamples {
+		return fmt.Errorf("alerts.anomaly_history must be greater than %d", anomaly.MinSamples)
+	}
+
+	if c.Scan.SkipAfterErrors < 0 {
+		return fmt.Errorf("scan.skip_after_errors must be non-negative")
+	}
+
 	for i, p := range c.Paths {
 		if p.Path == "" {
 			return fmt.Errorf("paths[%d].path is required", i)
 		}
-		if p.Depth < 0 {
-			return fmt.Errorf("paths[%d].depth must be non-negative", i)
+		if p.Depth < -1 {
+			return fmt.Errorf("paths[%d].depth must be non-negative, or -1 for leaf directories", i)
+		}
+		if p.KeepScans < 0 {
+			return fmt.Errorf("paths[%d].keep_scans must be non-negative", i)
+		}
+		if p.DirTimeout < 0 {
+			return fmt.Errorf("paths[%d].dir_timeout must be non-negative", i)
+		}
+		// 0 falls back to scan.interval
+		if p.Interval != 0 && p.Interval < time.Second {
+			return fmt.Errorf("paths[%d].interval must be at least 1s", i)
+		}
+		if p.Schedule != "" {
+			if p.Interval > 0 {
+				return fmt.Errorf("paths[%d]: set either interval or schedule, not both", i)
+			}
+			if _, err := cron.ParseStandard(p.Schedule); err != nil {
+				return fmt.Errorf("paths[%d].schedule: %w", i, err)
+			}
+		}
+		if p.Strategy != "" {
+			if !scanner.ValidStrategy(p.Strategy) {
+				return fmt.Errorf("paths[%d].strategy must be one of %s", i, strings.Join(scanner.StrategyNames, ", "))
+			}
+			// Strategies reading the filesystem's own accounting
+			accounting := p.Strategy == "btrfs" || p.Strategy == "lustre" || p.Strategy == "gpfs"
+			if p.Strategy == "ceph" || accounting {
+				for _, exc := range p.Exclude {
+					if scanner.IsExcludeGlob(exc) {
+						return fmt.Errorf("paths[%d]: strategy %q cannot be used with exclude pattern %q, as recursive stats cannot leave anything out", i, p.Strategy, exc)
+					}
+				}
+			}
+			if p.Strategy == "du" || p.Strategy == "ceph" || accounting {
+				switch {
+				case len(p.ExcludeFiles) > 0:
+					return fmt.Errorf("paths[%d]: strategy %q cannot be used with exclude_files, which requires walk", i, p.Strategy)
+				case c.Scan.Fingerprint:
+					return fmt.Errorf("paths[%d]: strategy %q cannot be used with scan.fingerprint, which requires walk", i, p.Strategy)
+				case c.Scan.UsageByOwner:
+					return fmt.Errorf("paths[%d]: strategy %q cannot be used with scan.usage_by_owner, which requires walk", i, p.Strategy)
+				case c.Scan.LargestFiles > 0:
+					return fmt.Errorf("paths[%d]: strategy %q cannot be used with scan.largest_files, which requires walk", i, p.Strategy)
+				case c.Scan.AgeBreakdown != "":
+					return fmt.Errorf("paths[%d]: strategy %q cannot be used with scan.age_breakdown, which requires walk", i, p.Strategy)
+				case p.MtimeCache:
+					return fmt.Errorf("paths[%d]: strategy %q cannot be used with mtime_cache, which requires walk", i, p.Strategy)
+				case p.EffectiveSizeMode(c.Scan.AllocatedSize) == scanner.SizeBoth:
+					return fmt.Errorf("paths[%d]: strategy %q cannot be used with size_mode both or scan.allocated_size, which requires walk", i, p.Strategy)
+				}
+			}
+			if p.Strategy == "ceph" && p.SizeMode == scanner.SizeAllocated {
+				return fmt.Errorf("paths[%d]: strategy \"ceph\" cannot be used with size_mode allocated, as CephFS only reports apparent size", i)
+			}
+			if (p.Strategy == "du" || accounting) && c.Scan.CountEntries {
+				return fmt.Errorf("paths[%d]: strategy %q cannot be used with scan.count_entries, which requires walk or ceph", i, p.Strategy)
+			}
+		}
+		if p.SizeMode != "" && !scanner.ValidSizeMode(p.SizeMode) {
+			return fmt.Errorf("paths[%d].size_mode must be one of %s", i, strings.Join(scanner.SizeModes, ", "))
+		}
+		if p.LooseFiles && p.Depth == 0 {
+			return fmt.Errorf("paths[%d]: loose_files requires a depth other than 0", i)
+		}
+		if p.AlertAbove < 0 {
+			return fmt.Errorf("paths[%d].alert_above must be non-negative", i)
+		}
+		for _, exc := range p.Exclude {
+			if err := scanner.ValidateExclude(exc); err != nil {
+				return fmt.Errorf("paths[%d].exclude: %w", i, err)
+			}
+		}
+		for _, pattern := range p.ExcludeFiles {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				return fmt.Errorf("paths[%d].exclude_files: invalid pattern %q: %w", i, pattern, err)
+			}
 		}
 	}
 
 	return nil
 }
 
-// Default returns a default configuration suitable for testing or initial setup.
+// validate checks the email settings of an enabled SMTP server.
+func (e EmailConfig) validate() error {
+	if e.SMTPPort < 1 || e.SMTPPort > 65535 {
+		return fmt.Errorf("email.smtp_port must be between 1 and 65535")
+	}
+	switch e.TLS {
+	case EmailTLSStartTLS, EmailTLSImplicit, EmailTLSNone:
+	default:
+		return fmt.Errorf("email.tls must be one of %s, %s, %s", EmailTLSStartTLS, EmailTLSImplicit, EmailTLSNone)
+	}
+	if e.Timeout <= 0 {
+		return fmt.Errorf("email.timeout must be positive")
+	}
+	if e.Password != "" && e.Username == "" {
+		return fmt.Errorf("email.password requires email.username")
+	}
+	if e.From == "" {
+		return fmt.Errorf("email.from is required when email.smtp_host is set")
+	}
+	if len(e.To) == 0 {
+		return fmt.Errorf("email.to is required when email.smtp_host is set")
+	}
+	for _, addr := range append([]string{e.From}, e.To...) {
+		if _, err := mail.ParseAddress(addr); err != nil {
+			return fmt.Errorf("email: invalid address %q: %w", addr, err)
+		}
+	}
+	if e.DigestSchedule != "" {
+		if _, err := cron.ParseStandard(e.DigestSchedule); err != nil {
+			return fmt.Errorf("email.digest_schedule: %w", err)
+		}
+	}
+	if e.DigestTop < 1 {
+		return fmt.Errorf("email.digest_top must be at least 1")
+	}
+	return nil
+}
+
+// Default returns a default configuration suitable for testing or initial
+// setup: the one Load returns when no configuration file is found.
 func Default() *Config {
-	return &Config{
-		Database: DatabaseConfig{
-			Path: "/var/lib/usgmon/usgmon.db",
-		},
-		Logging: LoggingConfig{
-			Level:  "info",
-			Format: "text",
-		},
-		Scan: ScanConfig{
-			Interval: time.Hour,
-			Workers:  4,
-		},
-		Paths: []PathConfig{},
+	v := viper.New()
+	setDefaults(v)
+	cfg, err := unmarshal(v)
+	if err != nil {
+		panic(fmt.Sprintf("config: unmarshaling defaults: %v", err))
 	}
+	return cfg
+}
+
+// isHTTPURL reports whether s is an absolute http or https URL.
+func isHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
 }
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDefaultValidates(t *testing.T) {
+	if err := Default().Validate(); err != nil {
+		t.Errorf("Default().Validate() = %v, want nil", err)
+	}
+}
+
+func TestDefaultMatchesLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usgmon.yaml")
+	if err := os.WriteFile(path, []byte("{}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, env := range []string{"USGMON_AGENT_TOKEN", "USGMON_API_AGENT_TOKEN", "USGMON_SMTP_USERNAME", "USGMON_SMTP_PASSWORD"} {
+		t.Setenv(env, "")
+		os.Unsetenv(env)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := Default(); !reflect.DeepEqual(got, loaded) {
+		t.Errorf("Default() = %+v\nwant the configuration Load reads from an empty file, %+v", got, loaded)
+	}
+}
+
+func TestValidatePathInterval(t *testing.T) {
+	tests := []struct {
+		interval time.Duration
+		wantErr  bool
+	}{
+		{0, false},
+		{time.Second, false},
+		{time.Hour, false},
+		{time.Second - time.Nanosecond, true},
+		{time.Millisecond, true},
+		{-time.Hour, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.interval.String(), func(t *testing.T) {
+			cfg := Default()
+			cfg.Paths = []PathConfig{{Path: "/srv", Interval: tt.interval}}
+			err := cfg.Validate()
+			if tt.wantErr && err == nil {
+				t.Errorf("Validate() with paths[0].interval %v = nil, want error", tt.interval)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("Validate() with paths[0].interval %v = %v, want nil", tt.interval, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,375 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/jgalley/usgmon/internal/config"
+	"github.com/jgalley/usgmon/internal/timespec"
+	"github.com/jgalley/usgmon/pkg/storage"
+)
+
+// apiShutdownTimeout bounds how long in-flight API requests may take to
+// finish when the daemon stops.
+const apiShutdownTimeout = 5 * time.Second
+
+// startAPI starts the HTTP API on api.listen. Scans triggered through it run
+// under ctx. It returns a function that shuts the server down.
+func (d *Daemon) startAPI(ctx context.Context) (func(), error) {
+	ln, err := net.Listen("tcp", d.cfg.API.Listen)
+	if err != nil {
+		return nil, fmt.Errorf("starting api server: %w", err)
+	}
+
+	srv := &http.Server{
+		Handler:           d.apiHandler(ctx),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			d.logger.Error("api server failed", "error", err)
+		}
+	}()
+	d.logger.Info("api server listening", "addr", ln.Addr().String())
+
+	return func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), apiShutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			d.logger.Warn("api server did not shut down cleanly", "error", err)
+		}
+	}, nil
+}
+
+// apiHandler routes the API endpoints.
+func (d *Daemon) apiHandler(ctx context.Context) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/paths", d.handlePaths)
+	mux.HandleFunc("GET /api/v1/usage", d.handleUsage)
+	mux.HandleFunc("GET /api/v1/scans", d.handleListScans)
+	mux.HandleFunc("POST /api/v1/scans", func(w http.ResponseWriter, r *http.Request) {
+		d.handleTriggerScan(ctx, w, r, storage.TriggerAPI)
+	})
+	if d.cfg.API.AgentToken != "" {
+		d.registerIngest(mux)
+	}
+	return mux
+}
+
+type apiPath struct {
+	Path     string `json:"path"`
+	Depth    int    `json:"depth"`
+	Schedule string `json:"schedule,omitempty"`
+	Interval string `json:"interval,omitempty"`
+	Scanning bool   `json:"scanning"`
+}
+
+// handlePaths lists the configured paths and whether each is being scanned.
+func (d *Daemon) handlePaths(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	paths := make([]apiPath, len(d.paths))
+	for i, p := range d.paths {
+		_, running := d.scanners[scanKey(p)]
+		paths[i] = apiPath{Path: p.Path, Depth: p.Depth, Schedule: p.Schedule, Scanning: running}
+		if p.Schedule == "" {
+			paths[i].Interval = p.EffectiveInterval(d.interval).String()
+		}
+	}
+	d.mu.Unlock()
+	writeJSON(w, http.StatusOK, paths)
+}
+
+type apiUsageRecord struct {
+	BasePath       string     `json:"base_path"`
+	Directory      string     `json:"directory"`
+	SizeBytes      int64      `json:"size_bytes"`
+	RecordedAt     string     `json:"recorded_at"`
+	ScanID         string     `json:"scan_id"`
+	FileFilter     string     `json:"file_filter,omitempty"`
+	SymlinkCount   *int64     `json:"symlink_count,omitempty"`
+	Fingerprint    string     `json:"fingerprint,omitempty"`
+	AllocatedBytes *int64     `json:"allocated_bytes,omitempty"`
+	Owner          string     `json:"owner,omitempty"`
+	Group          string     `json:"group,omitempty"`
+	QuotaLimit     *int64     `json:"quota_limit_bytes,omitempty"`
+	Deleted        bool       `json:"deleted,omitempty"`
+	FileCount      *int64     `json:"file_count,omitempty"`
+	DirCount       *int64     `json:"dir_count,omitempty"`
+	Hostname       string     `json:"hostname,omitempty"`
+	Rollup         *apiRollup `json:"rollup,omitempty"`
+}
+
+// apiRollup describes the raw records a daily rollup replaced.
+type apiRollup struct {
+	Samples  int   `json:"samples"`
+	MinBytes int64 `json:"min_bytes"`
+	MaxBytes int64 `json:"max_bytes"`
+}
+
+// handleUsage returns usage records for a directory or base path, newest
+// first. Parameters: directory, base_path (at least one is required), owner,
+// hostname, since, until (RFC 3339 or a duration ago such as 24h) and limit.
+func (d *Daemon) handleUsage(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	opts := storage.QueryOptions{
+		Directory: q.Get("directory"),
+		BasePath:  q.Get("base_path"),
+		Owner:     q.Get("owner"),
+		Hostname:  q.Get("hostname"),
+	}
+	if opts.Directory == "" && opts.BasePath == "" {
+		writeError(w, http.StatusBadRequest, "directory or base_path is required")
+		return
+	}
+	var err error
+	if opts.Limit, err = queryInt(q.Get("limit"), 100); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid limit: "+err.Error())
+		return
+	}
+	if opts.Since, err = queryTime(q.Get("since"), false); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid since: "+err.Error())
+		return
+	}
+	if opts.Until, err = queryTime(q.Get("until"), true); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid until: "+err.Error())
+		return
+	}
+
+	records, err := d.storage.QueryUsage(r.Context(), opts)
+	if err != nil {
+		d.logger.Error("api usage query failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "querying usage failed")
+		return
+	}
+
+	out := make([]apiUsageRecord, len(records))
+	for i, rec := range records {
+		out[i] = apiUsageRecord{
+			BasePath:       rec.BasePath,
+			Directory:      rec.Directory,
+			SizeBytes:      rec.SizeBytes,
+			RecordedAt:     rec.RecordedAt.Format(time.RFC3339),
+			ScanID:         rec.ScanID,
+			FileFilter:     rec.FileFilter,
+			SymlinkCount:   rec.SymlinkCount,
+			Fingerprint:    rec.Fingerprint,
+			AllocatedBytes: rec.AllocatedBytes,
+			Owner:          rec.Owner,
+			Group:          rec.Group,
+			QuotaLimit:     rec.QuotaLimit,
+			Deleted:        rec.Deleted,
+			FileCount:      rec.FileCount,
+			DirCount:       rec.DirCount,
+			Hostname:       rec.Hostname,
+		}
+		if rec.Rollup != nil {
+			out[i].Rollup = &apiRollup{Samples: rec.Rollup.Samples, MinBytes: rec.Rollup.MinBytes, MaxBytes: rec.Rollup.MaxBytes}
+		}
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+type apiScan struct {
+	ScanID             string                `json:"scan_id"`
+	BasePath           string                `json:"base_path"`
+	StartedAt          string                `json:"started_at"`
+	CompletedAt        string                `json:"completed_at,omitempty"`
+	DirectoriesScanned int                   `json:"directories_scanned"`
+	Status             string                `json:"status"`
+	Trigger            string                `json:"trigger,omitempty"`
+	Note               string                `json:"note,omitempty"`
+	Metadata           *storage.ScanMetadata `json:"metadata,omitempty"`
+	Hostname           string                `json:"hostname,omitempty"`
+}
+
+// handleListScans returns recorded scans, most recent first. Parameters:
+// base_path, trigger, hostname and limit.
+func (d *Daemon) handleListScans(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	opts := storage.ScanListOptions{
+		BasePath: q.Get("base_path"),
+		Trigger:  q.Get("trigger"),
+		Hostname: q.Get("hostname"),
+	}
+	var err error
+	if opts.Limit, err = queryInt(q.Get("limit"), 50); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid limit: "+err.Error())
+		return
+	}
+
+	scans, err := d.storage.ListScans(r.Context(), opts)
+	if err != nil {
+		d.logger.Error("api scan listing failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "listing scans failed")
+		return
+	}
+
+	out := make([]apiScan, len(scans))
+	for i, sc := range scans {
+		out[i] = apiScan{
+			ScanID:             sc.ScanID,
+			BasePath:           sc.BasePath,
+			StartedAt:          sc.StartedAt.Format(time.RFC3339),
+			DirectoriesScanned: sc.DirectoriesScanned,
+			Status:             sc.Status,
+			Trigger:            sc.Trigger,
+			Note:               sc.Note,
+			Metadata:           sc.Metadata,
+			Hostname:           sc.Hostname,
+		}
+		if sc.CompletedAt != nil {
+			out[i].CompletedAt = sc.CompletedAt.Format(time.RFC3339)
+		}
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// handleTriggerScan starts an on-demand scan of a configured path, given by
+// the path parameter (and depth, when the path is configured more than once),
+// recorded with trigger. The scan runs in the background; the response is
+// sent once its scan record exists and carries the scan ID.
+func (d *Daemon) handleTriggerScan(ctx context.Context, w http.ResponseWriter, r *http.Request, trigger string) {
+	if ctx.Err() != nil {
+		writeError(w, http.StatusServiceUnavailable, "daemon is shutting down")
+		return
+	}
+
+	q := r.URL.Query()
+	path := q.Get("path")
+	if path == "" {
+		writeError(w, http.StatusBadRequest, "path is required")
+		return
+	}
+	pathCfg, ok, err := d.findPath(filepath.Clean(path), q.Get("depth"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !ok {
+		writeError(w, http.StatusNotFound, "path is not configured")
+		return
+	}
+
+	scanID, err := d.triggerScan(ctx, r.Context(), pathCfg, trigger)
+	switch {
+	case errors.Is(err, errScanRunning):
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	case r.Context().Err() != nil:
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, TriggeredScan{
+		ScanID: scanID,
+		Path:   pathCfg.Path,
+		Depth:  pathCfg.Depth,
+		Status: "started",
+	})
+}
+
+// errScanNotStarted is returned by triggerScan when the scan record could
+// not be created.
+var errScanNotStarted = errors.New("starting scan failed")
+
+// triggerScan starts a scan of pathCfg, recorded with trigger, in the
+// background under ctx and waits until its scan record exists, returning
+// the scan ID. It returns errScanRunning if the path is already being
+// scanned, and waitCtx's error if waitCtx ends first.
+func (d *Daemon) triggerScan(ctx, waitCtx context.Context, pathCfg config.PathConfig, trigger string) (string, error) {
+	scan, scanCtx, done, ok := d.registerScan(ctx, pathCfg, trigger)
+	if !ok {
+		return "", errScanRunning
+	}
+	go func() {
+		defer done()
+		d.scanPath(ctx, scanCtx, pathCfg, trigger)
+	}()
+
+	select {
+	case <-scan.recorded:
+	case <-waitCtx.Done():
+		return "", waitCtx.Err()
+	}
+	if scan.scanID == "" {
+		return "", errScanNotStarted
+	}
+	return scan.scanID, nil
+}
+
+// TriggeredScan is the response to a request to scan a path now.
+type TriggeredScan struct {
+	ScanID string `json:"scan_id"`
+	Path   string `json:"path"`
+	Depth  int    `json:"depth"`
+	Status string `json:"status"`
+}
+
+// findPath returns the configured path matching path and, if given, depth.
+func (d *Daemon) findPath(path, depth string) (config.PathConfig, bool, error) {
+	var want *int
+	if depth != "" {
+		n, err := strconv.Atoi(depth)
+		if err != nil {
+			return config.PathConfig{}, false, fmt.Errorf("invalid depth: %w", err)
+		}
+		want = &n
+	}
+	paths, _ := d.pathSettings()
+	for _, p := range paths {
+		if filepath.Clean(p.Path) == path && (want == nil || p.Depth == *want) {
+			return p, true, nil
+		}
+	}
+	return config.PathConfig{}, false, nil
+}
+
+// queryInt parses a non-negative integer parameter, returning def when empty.
+func queryInt(s string, def int) (int, error) {
+	if s == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 {
+		return 0, errors.New("must be non-negative")
+	}
+	return n, nil
+}
+
+// queryTime parses a time as the CLI's --since and --until do (see
+// timespec.Parse), a bare date meaning the end of that day when endOfDay is
+// set. An empty string yields nil.
+func queryTime(s string, endOfDay bool) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+	t, err := timespec.Parse(s, time.Now(), endOfDay)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
@@ -0,0 +1,32 @@
+package daemon
+
+import (
+	"context"
+	"time"
+
+	"github.com/jgalley/usgmon/pkg/scanner"
+	"github.com/jgalley/usgmon/pkg/storage"
+)
+
+// recordFilesystemStats records the capacity of the filesystem holding
+// basePath for the completed scan scanID, for usgmon capacity. Failures are
+// logged; the scan itself has succeeded.
+func (d *Daemon) recordFilesystemStats(ctx context.Context, basePath, scanID string) {
+	fs, err := scanner.StatFilesystem(basePath, d.cfg.Scan.StatfsTimeout)
+	if err != nil {
+		d.logger.Warn("failed to read filesystem capacity", "path", basePath, "error", err)
+		return
+	}
+	err = d.storage.RecordFilesystemStats(ctx, storage.FilesystemStats{
+		BasePath:       basePath,
+		RecordedAt:     time.Now(),
+		ScanID:         scanID,
+		Hostname:       d.hostname,
+		TotalBytes:     fs.TotalBytes,
+		UsedBytes:      fs.UsedBytes,
+		AvailableBytes: fs.AvailableBytes,
+	})
+	if err != nil {
+		d.logger.Warn("failed to record filesystem capacity", "path", basePath, "error", err)
+	}
+}
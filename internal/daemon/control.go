@@ -0,0 +1,321 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jgalley/usgmon/internal/config"
+	"github.com/jgalley/usgmon/pkg/storage"
+)
+
+// Status is the daemon state reported over the control socket.
+type Status struct {
+	Version       string         `json:"version"`
+	PID           int            `json:"pid"`
+	StartedAt     time.Time      `json:"started_at"`
+	UptimeSeconds int64          `json:"uptime_seconds"`
+	Paths         []PathStatus   `json:"paths"`
+	Scans         []ScanStatus   `json:"scans"`
+	Database      DatabaseStatus `json:"database"`
+}
+
+// PathStatus describes a configured path and its most recent scan.
+type PathStatus struct {
+	Path     string    `json:"path"`
+	Depth    int       `json:"depth"`
+	Schedule string    `json:"schedule,omitempty"`
+	Interval string    `json:"interval,omitempty"`
+	Scanning bool      `json:"scanning"`
+	LastScan *LastScan `json:"last_scan,omitempty"`
+}
+
+// LastScan summarizes the most recent finished scan of a path.
+type LastScan struct {
+	ScanID          string    `json:"scan_id,omitempty"`
+	Trigger         string    `json:"trigger,omitempty"`
+	StartedAt       time.Time `json:"started_at"`
+	FinishedAt      time.Time `json:"finished_at"`
+	DurationSeconds float64   `json:"duration_seconds"`
+	Status          string    `json:"status"` // completed, failed or cancelled
+	Directories     int       `json:"directories"`
+	Error           string    `json:"error,omitempty"`
+}
+
+// ScanStatus describes a scan in progress.
+type ScanStatus struct {
+	Path           string    `json:"path"`
+	Depth          int       `json:"depth"`
+	ScanID         string    `json:"scan_id,omitempty"`
+	Trigger        string    `json:"trigger"`
+	StartedAt      time.Time `json:"started_at"`
+	ElapsedSeconds float64   `json:"elapsed_seconds"`
+	Directories    int64     `json:"directories"` // measured so far
+}
+
+// DatabaseStatus holds database statistics. Error is set, and the counts
+// left zero, when they could not be read.
+type DatabaseStatus struct {
+	Path          string `json:"path"`
+	SizeBytes     int64  `json:"size_bytes"`
+	SchemaVersion int    `json:"schema_version"`
+	UsageRecords  int64  `json:"usage_records"`
+	Scans         int64  `json:"scans"`
+	Rollups       int64  `json:"daily_rollups"`
+	Error         string `json:"error,omitempty"`
+}
+
+// startControl serves the daemon status and on-demand scans on the
+// control.socket Unix socket. Scans triggered through it run under ctx. It
+// returns a function that shuts the server down and removes the socket.
+func (d *Daemon) startControl(ctx context.Context) (func(), error) {
+	path := d.cfg.Control.Socket
+
+	// A socket left behind by a daemon that did not exit cleanly is removed;
+	// one that still answers belongs to another running daemon
+	if _, err := os.Lstat(path); err == nil {
+		if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("control socket %s is in use by another daemon", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("removing stale control socket: %w", err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("creating control socket directory: %w", err)
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("starting control socket: %w", err)
+	}
+	if err := os.Chmod(path, 0o660); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("setting control socket permissions: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /status", d.handleStatus)
+	mux.HandleFunc("POST /scans", func(w http.ResponseWriter, r *http.Request) {
+		d.handleTriggerScan(ctx, w, r, storage.TriggerScanNow)
+	})
+	srv := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			d.logger.Error("control socket failed", "error", err)
+		}
+	}()
+	d.logger.Info("control socket listening", "socket", path)
+
+	return func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), apiShutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			d.logger.Warn("control socket did not shut down cleanly", "error", err)
+		}
+	}, nil
+}
+
+func (d *Daemon) handleStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, d.status(r.Context()))
+}
+
+// status reports the daemon's current state.
+func (d *Daemon) status(ctx context.Context) *Status {
+	now := time.Now()
+	st := &Status{
+		Version:       d.version,
+		PID:           os.Getpid(),
+		StartedAt:     d.started,
+		UptimeSeconds: int64(now.Sub(d.started).Seconds()),
+		Scans:         []ScanStatus{},
+	}
+
+	d.mu.Lock()
+	paths := d.paths
+	st.Paths = make([]PathStatus, len(paths))
+	for i, p := range paths {
+		key := scanKey(p)
+		_, running := d.scanners[key]
+		st.Paths[i] = PathStatus{Path: p.Path, Depth: p.Depth, Schedule: p.Schedule, Scanning: running}
+		if p.Schedule == "" {
+			st.Paths[i].Interval = p.EffectiveInterval(d.interval).String()
+		}
+		if o, ok := d.lastScans[key]; ok {
+			st.Paths[i].LastScan = &LastScan{
+				ScanID:          o.scanID,
+				Trigger:         o.trigger,
+				StartedAt:       o.started,
+				FinishedAt:      o.finished,
+				DurationSeconds: o.finished.Sub(o.started).Seconds(),
+				Status:          o.status,
+				Directories:     o.directories,
+				Error:           o.err,
+			}
+		}
+	}
+	for _, scan := range d.scanners {
+		st.Scans = append(st.Scans, ScanStatus{
+			Path:           scan.cfg.Path,
+			Depth:          scan.cfg.Depth,
+			ScanID:         scan.id(),
+			Trigger:        scan.trigger,
+			StartedAt:      scan.started,
+			ElapsedSeconds: now.Sub(scan.started).Seconds(),
+			Directories:    scan.directories.Load(),
+		})
+	}
+	d.mu.Unlock()
+
+	// Paths not scanned since the daemon started report their last recorded
+	// scan, which may be from a previous run
+	for i := range st.Paths {
+		if st.Paths[i].LastScan == nil {
+			st.Paths[i].LastScan = d.lastRecordedScan(ctx, paths[i])
+		}
+	}
+	sort.Slice(st.Scans, func(i, j int) bool {
+		return st.Scans[i].StartedAt.Before(st.Scans[j].StartedAt)
+	})
+
+	st.Database = d.databaseStatus(ctx)
+	return st
+}
+
+// lastRecordedScan returns the most recent finished scan of pathCfg's base
+// path in the database, or nil if there is none. Scans do not record their
+// depth, so a path configured at several depths reports the latest of them.
+func (d *Daemon) lastRecordedScan(ctx context.Context, pathCfg config.PathConfig) *LastScan {
+	scans, err := d.storage.ListScans(ctx, storage.ScanListOptions{BasePath: pathCfg.Path, Limit: 10})
+	if err != nil {
+		d.logger.Warn("failed to load last scan for status", "path", pathCfg.Path, "error", err)
+		return nil
+	}
+	for _, sc := range scans {
+		// Skipped scans never ran; the scan before them is the last one
+		if sc.CompletedAt == nil || strings.HasPrefix(sc.Status, "skipped: ") {
+			continue
+		}
+		last := &LastScan{
+			ScanID:          sc.ScanID,
+			Trigger:         sc.Trigger,
+			StartedAt:       sc.StartedAt,
+			FinishedAt:      *sc.CompletedAt,
+			DurationSeconds: sc.CompletedAt.Sub(sc.StartedAt).Seconds(),
+			Status:          sc.Status,
+			Directories:     sc.DirectoriesScanned,
+		}
+		if reason, failed := strings.CutPrefix(sc.Status, "failed: "); failed {
+			last.Status = "failed"
+			if reason == "cancelled" {
+				last.Status = reason
+			} else {
+				last.Error = reason
+			}
+		}
+		return last
+	}
+	return nil
+}
+
+// databaseStatus collects database statistics.
+func (d *Daemon) databaseStatus(ctx context.Context) DatabaseStatus {
+	db := DatabaseStatus{Path: d.cfg.Database.Path}
+	if st, err := os.Stat(db.Path); err == nil {
+		db.SizeBytes = st.Size()
+	}
+	if wal, err := os.Stat(db.Path + "-wal"); err == nil {
+		db.SizeBytes += wal.Size()
+	}
+
+	info, err := d.storage.Info(ctx)
+	if err != nil {
+		d.logger.Warn("failed to read database info for status", "error", err)
+		db.Error = err.Error()
+		return db
+	}
+	db.SchemaVersion = info.SchemaVersion
+	db.UsageRecords = info.UsageRecords
+	db.Scans = info.Scans
+	db.Rollups = info.Rollups
+	return db
+}
+
+// FetchStatus asks the daemon listening on the control socket at path for
+// its status.
+func FetchStatus(ctx context.Context, path string) (*Status, error) {
+	var st Status
+	if err := controlRequest(ctx, path, http.MethodGet, "/status", &st); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+// TriggerScan asks the daemon listening on the control socket at path to
+// scan the configured path scanPath now. depth selects among several
+// configurations of the same path; nil requires scanPath to be configured
+// once. It returns once the daemon has created the scan record.
+func TriggerScan(ctx context.Context, path, scanPath string, depth *int) (*TriggeredScan, error) {
+	q := url.Values{"path": {scanPath}}
+	if depth != nil {
+		q.Set("depth", strconv.Itoa(*depth))
+	}
+	var ts TriggeredScan
+	if err := controlRequest(ctx, path, http.MethodPost, "/scans?"+q.Encode(), &ts); err != nil {
+		return nil, err
+	}
+	return &ts, nil
+}
+
+// controlRequest sends a request to the control socket at path and decodes
+// the JSON response into v. Errors reported by the daemon are returned with
+// their message.
+func controlRequest(ctx context.Context, path, method, target string, v any) error {
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", path)
+			},
+		},
+	}
+
+	// The host is ignored; every request goes to the socket
+	req, err := http.NewRequestWithContext(ctx, method, "http://usgmon"+target, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("connecting to daemon at %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&apiErr) == nil && apiErr.Error != "" {
+			return fmt.Errorf("daemon: %s", apiErr.Error)
+		}
+		return fmt.Errorf("daemon returned %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+	return nil
+}
@@ -2,38 +2,182 @@ package daemon
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
+	"path/filepath"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/jgalley/usgmon/internal/agent"
+	"github.com/jgalley/usgmon/internal/alert"
+	"github.com/jgalley/usgmon/internal/anomaly"
+	"github.com/jgalley/usgmon/internal/cgroup"
 	"github.com/jgalley/usgmon/internal/config"
-	"github.com/jgalley/usgmon/internal/scanner"
-	"github.com/jgalley/usgmon/internal/storage"
+	"github.com/jgalley/usgmon/internal/email"
+	"github.com/jgalley/usgmon/internal/humanize"
+	"github.com/jgalley/usgmon/internal/rusage"
+	"github.com/jgalley/usgmon/internal/systemd"
+	"github.com/jgalley/usgmon/internal/webhook"
+	"github.com/jgalley/usgmon/pkg/scanner"
+	"github.com/jgalley/usgmon/pkg/storage"
 )
 
 // Daemon manages periodic directory scanning.
 type Daemon struct {
-	cfg     *config.Config
-	storage storage.Storage
-	scanner *scanner.Scanner
-	logger  *slog.Logger
+	cfg      *config.Config
+	storage  storage.Storage
+	scanner  *scanner.Scanner
+	logger   *slog.Logger
+	ioGate   *cgroup.PressureGate // nil unless scan.io_pressure_limit is set
+	limiter  *scanner.RateLimiter // nil unless scan.walk_ops_limit is set
+	devices  *deviceSlots         // nil unless scan.max_concurrent_per_device is set
+	alerts   *alert.Tracker
+	webhook  *webhook.Sender      // nil unless webhooks.urls is set
+	slack    *webhook.Slack       // nil unless slack.urls is set
+	mailer   *email.Mailer        // nil unless email.smtp_host is set
+	emailed  *email.AlertNotifier // nil unless email alerts are enabled
+	pusher   *agent.Pusher        // nil unless agent.server is set
+	sd       *systemd.Notifier    // no-op unless started by systemd with Type=notify
+	statusCh chan struct{}        // nudges superviseSystemd to send a fresh status
+	discard  bool                 // measure without storing; see DiscardResults
+	version  string               // usgmon version recorded in scan metadata
+	hostname string               // tags scans and usage records; see scan.hostname
+	started  time.Time
 
-	mu       sync.Mutex
-	running  bool
-	stopCh   chan struct{}
-	doneCh   chan struct{}
-	scanners map[string]context.CancelFunc // active scans
+	mu        sync.Mutex
+	running   bool
+	stopCh    chan struct{}
+	doneCh    chan struct{}
+	scanners  map[string]*activeScan // active scans by path and depth
+	lastScans map[string]scanOutcome // most recent finished scan by path and depth
+
+	// Path settings that Reload can change; guarded by mu
+	paths    []config.PathConfig
+	interval time.Duration // scan.interval
+
+	runCtx context.Context      // parent of path scanners and scans while Run is active
+	loops  map[string]*pathLoop // path scanners by path and depth while Run is active
+	loopWG sync.WaitGroup
+}
+
+// activeScan is a scan in progress.
+type activeScan struct {
+	cfg         config.PathConfig
+	cancel      context.CancelFunc
+	trigger     string
+	started     time.Time
+	directories atomic.Int64 // directories measured so far
+
+	// scanID is set, and recorded closed, once the scan record is created
+	// or creating it failed (scanID left empty)
+	scanID       string
+	recorded     chan struct{}
+	recordedOnce sync.Once
+}
+
+// setScanID publishes the scan's ID; only the first call has an effect.
+func (s *activeScan) setScanID(id string) {
+	s.recordedOnce.Do(func() {
+		s.scanID = id
+		close(s.recorded)
+	})
+}
+
+// id returns the scan's ID, or "" while it is not yet known.
+func (s *activeScan) id() string {
+	select {
+	case <-s.recorded:
+		return s.scanID
+	default:
+		return ""
+	}
+}
+
+// scanOutcome summarizes a finished scan for status reports.
+type scanOutcome struct {
+	cfg         config.PathConfig
+	scanID      string // empty if the scan record was never created
+	trigger     string
+	started     time.Time
+	finished    time.Time
+	status      string // completed, failed or cancelled
+	directories int
+	err         string
+}
+
+// pathLoop is the scheduling loop of one configured path.
+type pathLoop struct {
+	cfg    config.PathConfig
+	cancel context.CancelFunc // stops the loop; a scan in progress continues
 }
 
 // New creates a new Daemon instance.
 func New(cfg *config.Config, store storage.Storage, logger *slog.Logger) *Daemon {
-	return &Daemon{
-		cfg:      cfg,
-		storage:  store,
-		scanner:  scanner.New(cfg.Scan.Workers, nil), // auto-detect strategy
-		logger:   logger,
-		scanners: make(map[string]context.CancelFunc),
+	d := &Daemon{
+		cfg:       cfg,
+		storage:   store,
+		scanner:   scanner.New(cfg.Scan.Workers, nil), // auto-detect strategy
+		logger:    logger,
+		scanners:  make(map[string]*activeScan),
+		lastScans: make(map[string]scanOutcome),
+		paths:     cfg.Paths,
+		interval:  cfg.Scan.Interval,
+		loops:     make(map[string]*pathLoop),
+		started:   time.Now(),
+		hostname:  cfg.Scan.Host(),
+		sd:        systemd.NewNotifier(),
+		statusCh:  make(chan struct{}, 1),
+	}
+	if cfg.Agent.Server != "" {
+		d.pusher = agent.New(d.agentOptions(), logger)
+		d.storage = &pushStorage{Storage: store, pusher: d.pusher}
+	}
+	notifiers := alert.MultiNotifier{&alert.LogNotifier{Logger: logger}}
+	if len(cfg.Webhooks.URLs) > 0 {
+		d.webhook = webhook.New(d.webhookOptions(cfg.Webhooks.URLs), logger)
+		if cfg.Webhooks.Sends(config.WebhookAlert) {
+			notifiers = append(notifiers, d.webhook)
+		}
+	}
+	if len(cfg.Slack.URLs) > 0 {
+		d.slack = webhook.NewSlack(webhook.SlackOptions{
+			Options:  d.webhookOptions(cfg.Slack.URLs),
+			Channel:  cfg.Slack.Channel,
+			Username: cfg.Slack.Username,
+			History:  cfg.Slack.History,
+		}, d.sizeHistory, logger)
+		notifiers = append(notifiers, d.slack)
+	}
+	if cfg.Email.Enabled() {
+		d.mailer = email.NewMailer(email.Options{
+			Host:     cfg.Email.SMTPHost,
+			Port:     cfg.Email.SMTPPort,
+			Username: cfg.Email.Username,
+			Password: cfg.Email.Password,
+			From:     cfg.Email.From,
+			To:       cfg.Email.To,
+			TLS:      cfg.Email.TLS,
+			Timeout:  cfg.Email.Timeout,
+		})
+		if cfg.Email.Alerts {
+			d.emailed = email.NewAlertNotifier(d.mailer, logger)
+			notifiers = append(notifiers, d.emailed)
+		}
 	}
+	d.alerts = alert.NewTracker(store, notifiers, cfg.Alerts.ReminderInterval)
+	if cfg.Scan.IOPressureLimit > 0 {
+		d.ioGate = cgroup.NewPressureGate(cfg.Scan.IOPressureLimit, time.Second)
+	}
+	if cfg.Scan.MaxConcurrentPerDevice > 0 {
+		d.devices = newDeviceSlots(cfg.Scan.MaxConcurrentPerDevice)
+	}
+	if cfg.Scan.WalkOpsLimit > 0 {
+		d.limiter = scanner.NewRateLimiter(cfg.Scan.WalkOpsLimit)
+	}
+	return d
 }
 
 // Run starts the daemon and blocks until Stop is called or the context is cancelled.
@@ -55,23 +199,75 @@ func (d *Daemon) Run(ctx context.Context) error {
 		d.mu.Unlock()
 	}()
 
-	if len(d.cfg.Paths) == 0 {
-		d.logger.Warn("no paths configured for monitoring")
-		<-ctx.Done()
-		return ctx.Err()
-	}
+	defer d.startPool()()
+	defer d.closeNotifiers()
+
+	d.recoverStaleScans(ctx)
 
-	// Start a timer for each configured path
-	var wg sync.WaitGroup
+	// Every scan, including those triggered through the API, runs under
+	// pathCtx so shutdown cancels it
 	pathCtx, pathCancel := context.WithCancel(ctx)
 	defer pathCancel()
 
-	for _, p := range d.cfg.Paths {
-		wg.Add(1)
-		go func(pathCfg config.PathConfig) {
-			defer wg.Done()
-			d.runPathScanner(pathCtx, pathCfg)
-		}(p)
+	if d.cfg.Control.Socket != "" {
+		// The daemon can run without it, e.g. unprivileged under a default
+		// socket path it cannot create
+		if stop, err := d.startControl(pathCtx); err != nil {
+			d.logger.Warn("control socket unavailable, usgmon status will not work", "error", err)
+		} else {
+			defer stop()
+		}
+	}
+
+	stopAPI := func() {}
+	if d.cfg.API.Listen != "" {
+		stop, err := d.startAPI(pathCtx)
+		if err != nil {
+			return err
+		}
+		stopAPI = stop
+	}
+	defer stopAPI()
+
+	stopGRPC := func() {}
+	if d.cfg.API.GRPCListen != "" {
+		stop, err := d.startGRPC(pathCtx)
+		if err != nil {
+			return err
+		}
+		stopGRPC = stop
+	}
+	defer stopGRPC()
+
+	// Start a scheduling loop for each configured path
+	d.mu.Lock()
+	if len(d.paths) == 0 {
+		d.logger.Warn("no paths configured for monitoring")
+	}
+	d.warnOverlappingPaths(d.paths)
+	d.runCtx = pathCtx
+	for _, p := range d.paths {
+		d.startLoop(p, true)
+	}
+	d.mu.Unlock()
+
+	if d.mailer != nil && d.cfg.Email.DigestSchedule != "" {
+		d.loopWG.Add(1)
+		go func() {
+			defer d.loopWG.Done()
+			d.runDigests(pathCtx)
+		}()
+	}
+
+	if d.sd.Enabled() {
+		if err := d.sd.Ready(); err != nil {
+			d.logger.Warn("failed to notify systemd of readiness", "error", err)
+		}
+		d.loopWG.Add(1)
+		go func() {
+			defer d.loopWG.Done()
+			d.superviseSystemd(pathCtx)
+		}()
 	}
 
 	// Wait for shutdown signal
@@ -81,10 +277,15 @@ func (d *Daemon) Run(ctx context.Context) error {
 	case <-d.stopCh:
 		d.logger.Info("stop requested, shutting down")
 	}
+	d.sd.Stopping()
 
 	// Cancel all path scanners and wait
 	pathCancel()
-	wg.Wait()
+	d.mu.Lock()
+	d.runCtx = nil
+	clear(d.loops)
+	d.mu.Unlock()
+	d.loopWG.Wait()
 
 	// Wait for any in-progress scans to complete
 	d.waitForScans()
@@ -92,6 +293,95 @@ func (d *Daemon) Run(ctx context.Context) error {
 	return nil
 }
 
+// staleScanReason is recorded on scans left running by a previous process.
+const staleScanReason = "stale: usgmon exited before the scan finished"
+
+// recoverStaleScans marks scans this host left running, because a previous
+// daemon crashed or was killed mid-scan, as interrupted with
+// scan.resume_scans, so they are resumed, and as failed otherwise; with
+// scan.delete_stale_records the partial records of failed ones are deleted
+// too.
+func (d *Daemon) recoverStaleScans(ctx context.Context) {
+	if d.cfg.Scan.ResumeScans {
+		ids, err := d.storage.InterruptStaleScans(ctx, d.hostname)
+		if err != nil {
+			d.logger.Warn("failed to recover stale scans", "error", err)
+			return
+		}
+		for _, id := range ids {
+			d.logger.Warn("marked scan left running by a previous process as interrupted", "scan_id", id)
+		}
+		return
+	}
+
+	ids, records, err := d.storage.FailStaleScans(ctx, d.hostname, staleScanReason, d.cfg.Scan.DeleteStaleRecords)
+	if err != nil {
+		d.logger.Warn("failed to recover stale scans", "error", err)
+		return
+	}
+	for _, id := range ids {
+		d.logger.Warn("marked scan left running by a previous process as failed", "scan_id", id)
+	}
+	if len(ids) > 0 && d.cfg.Scan.DeleteStaleRecords {
+		d.logger.Info("deleted partial records of stale scans", "scans", len(ids), "records", records)
+	}
+}
+
+// RunOnce scans every configured path exactly once and returns when all scans
+// have finished. It returns an error if any path's scan failed.
+func (d *Daemon) RunOnce(ctx context.Context) error {
+	paths, _ := d.pathSettings()
+	if len(paths) == 0 {
+		d.logger.Warn("no paths configured for monitoring")
+		return nil
+	}
+
+	defer d.startPool()()
+	defer d.closeNotifiers()
+	d.warnOverlappingPaths(paths)
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+
+	for _, p := range paths {
+		wg.Add(1)
+		go func(pathCfg config.PathConfig) {
+			defer wg.Done()
+			d.checkStrategy(pathCfg)
+			if err := d.runScan(ctx, pathCfg, storage.TriggerOnce); err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("%s: %w", pathCfg.Path, err))
+				mu.Unlock()
+			}
+		}(p)
+	}
+
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// startPool attaches a shared worker pool to the scanner when scan.shared_pool
+// is enabled, so all path scans reuse the same workers. It returns a function
+// that detaches and stops the pool.
+func (d *Daemon) startPool() func() {
+	if !d.cfg.Scan.SharedPool {
+		return func() {}
+	}
+
+	pool := scanner.NewPool(d.cfg.Scan.Workers)
+	d.scanner.UsePool(pool)
+	d.logger.Debug("started shared worker pool", "workers", d.cfg.Scan.Workers)
+
+	return func() {
+		d.scanner.UsePool(nil)
+		pool.Close()
+	}
+}
+
 // Stop signals the daemon to stop gracefully.
 func (d *Daemon) Stop() {
 	d.mu.Lock()
@@ -112,67 +402,341 @@ func (d *Daemon) Wait() {
 	}
 }
 
-// runPathScanner runs the scan loop for a single path configuration.
-func (d *Daemon) runPathScanner(ctx context.Context, pathCfg config.PathConfig) {
-	interval := pathCfg.EffectiveInterval(d.cfg.Scan.Interval)
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
+// pathSettings returns the configured paths and default scan interval.
+func (d *Daemon) pathSettings() ([]config.PathConfig, time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.paths, d.interval
+}
 
-	d.logger.Info("starting path scanner",
+// startLoop starts the scheduling loop of a path while Run is active. Its
+// scans run under the daemon context rather than the loop's, so stopping
+// the loop lets a scan in progress finish. With startup set,
+// interval-based paths scan immediately. Watched paths are also watched
+// until the loop stops. d.mu must be held.
+func (d *Daemon) startLoop(pathCfg config.PathConfig, startup bool) {
+	ctx := d.runCtx
+	loopCtx, cancel := context.WithCancel(ctx)
+	d.loops[scanKey(pathCfg)] = &pathLoop{cfg: pathCfg, cancel: cancel}
+
+	interval := d.interval
+	d.loopWG.Add(1)
+	go func() {
+		defer d.loopWG.Done()
+		defer cancel()
+		d.runPathScanner(ctx, loopCtx, pathCfg, interval, startup)
+	}()
+
+	if pathCfg.Watch {
+		d.loopWG.Add(1)
+		go func() {
+			defer d.loopWG.Done()
+			d.watchPath(loopCtx, pathCfg)
+		}()
+	}
+}
+
+// runPathScanner runs the scan loop for a single path configuration until
+// loopCtx is done; scans run under ctx. Interval-based paths scan
+// immediately (with startup set) and then every interval; paths with a
+// cron schedule only scan at their scheduled times.
+func (d *Daemon) runPathScanner(ctx, loopCtx context.Context, pathCfg config.PathConfig, interval time.Duration, startup bool) {
+	sched, err := pathCfg.EffectiveSchedule(interval)
+	if err != nil {
+		// Validated at config load, so this should not happen
+		d.logger.Error("invalid scan schedule", "path", pathCfg.Path, "error", err)
+		return
+	}
+
+	logAttrs := []any{
 		"path", pathCfg.Path,
 		"depth", pathCfg.Depth,
-		"interval", interval,
 		"follow_symlinks", pathCfg.FollowSymlinks,
-	)
+	}
+	if pathCfg.Schedule != "" {
+		logAttrs = append(logAttrs, "schedule", pathCfg.Schedule)
+	} else {
+		logAttrs = append(logAttrs, "interval", pathCfg.EffectiveInterval(interval))
+	}
+	if pathCfg.OneFileSystem {
+		logAttrs = append(logAttrs, "one_file_system", true)
+	}
+	if pathCfg.Strategy != "" {
+		logAttrs = append(logAttrs, "strategy", pathCfg.Strategy)
+	}
+	d.logger.Info("starting path scanner", logAttrs...)
+	d.checkStrategy(pathCfg)
 
-	// Run initial scan immediately
-	d.runScan(ctx, pathCfg)
+	now := time.Now()
+	if pathCfg.Schedule == "" && startup {
+		// Run initial scan immediately
+		d.runScan(ctx, pathCfg, storage.TriggerStartup)
+	}
 
+	next := sched.Next(now)
 	for {
+		// Skip slots missed while a long scan was running, recording them
+		// as one skipped scan
+		if now := time.Now(); next.Before(now) {
+			first, missed := next, 0
+			for ; next.Before(now); next = sched.Next(next) {
+				missed++
+			}
+			reason := "previous scan still running"
+			if missed > 1 {
+				reason = fmt.Sprintf("%s (%d scheduled scans missed)", reason, missed)
+			}
+			d.recordSkippedScan(ctx, pathCfg, storage.TriggerScheduled, first, reason)
+		}
+		d.logger.Debug("next scan scheduled", "path", pathCfg.Path, "at", next)
+
+		timer := time.NewTimer(time.Until(next))
 		select {
-		case <-ctx.Done():
+		case <-loopCtx.Done():
+			timer.Stop()
 			return
-		case <-ticker.C:
-			d.runScan(ctx, pathCfg)
+		case <-timer.C:
+			d.runScan(ctx, pathCfg, storage.TriggerScheduled)
 		}
+		if loopCtx.Err() != nil {
+			return
+		}
+		next = sched.Next(next)
 	}
 }
 
 // batchSize is the number of records to accumulate before inserting to the database.
 const batchSize = 100
 
-// runScan performs a single scan of the configured path.
-func (d *Daemon) runScan(ctx context.Context, pathCfg config.PathConfig) {
-	scanCtx, cancel := context.WithCancel(ctx)
+// errScanRunning is returned when a scan is requested for a path that is
+// already being scanned.
+var errScanRunning = errors.New("a scan of this path is already running")
+
+// runScan performs a single scan of the configured path, recording trigger as
+// what initiated it. The returned error is already logged; callers only need
+// it to report status.
+func (d *Daemon) runScan(ctx context.Context, pathCfg config.PathConfig, trigger string) error {
+	_, scanCtx, done, ok := d.registerScan(ctx, pathCfg, trigger)
+	if !ok {
+		d.recordSkippedScan(ctx, pathCfg, trigger, time.Now(), errScanRunning.Error())
+		return errScanRunning
+	}
+	defer done()
+	return d.scanPath(ctx, scanCtx, pathCfg, trigger)
+}
+
+// recordSkippedScan records a scan of pathCfg, due at startedAt, that was
+// not run for reason, so the gap shows in the scan history instead of
+// passing unnoticed.
+func (d *Daemon) recordSkippedScan(ctx context.Context, pathCfg config.PathConfig, trigger string, startedAt time.Time, reason string) {
+	d.logger.Warn("skipping scan", "path", pathCfg.Path, "trigger", trigger, "reason", reason)
+	scanID, err := d.storage.StartScan(ctx, pathCfg.Path, storage.StartScanOptions{
+		Trigger:   trigger,
+		Hostname:  d.hostname,
+		StartedAt: startedAt,
+	})
+	if err != nil {
+		d.logger.Error("failed to create scan record", "error", err)
+		return
+	}
+	if err := d.storage.SkipScan(ctx, scanID, reason); err != nil {
+		d.logger.Error("failed to mark scan as skipped", "error", err)
+	}
+}
+
+// registerScan marks a scan of pathCfg, started by trigger, as running and
+// returns it, its context and a function that unregisters it. It returns
+// false if a scan of the same path and depth is already running.
+func (d *Daemon) registerScan(ctx context.Context, pathCfg config.PathConfig, trigger string) (*activeScan, context.Context, func(), bool) {
+	key := scanKey(pathCfg)
 
-	// Register this scan
 	d.mu.Lock()
-	d.scanners[pathCfg.Path] = cancel
-	d.mu.Unlock()
+	defer d.mu.Unlock()
+	if _, running := d.scanners[key]; running {
+		return nil, nil, nil, false
+	}
+	scanCtx, cancel := context.WithCancel(ctx)
+	scan := &activeScan{cfg: pathCfg, cancel: cancel, trigger: trigger, started: time.Now(), recorded: make(chan struct{})}
+	d.scanners[key] = scan
+	d.nudgeStatus()
 
-	defer func() {
+	return scan, scanCtx, func() {
 		d.mu.Lock()
-		delete(d.scanners, pathCfg.Path)
+		delete(d.scanners, key)
 		d.mu.Unlock()
+		d.nudgeStatus()
 		cancel()
-	}()
+	}, true
+}
 
+// recordOutcome remembers how the scan of pathCfg tracked by active ended,
+// for status reports.
+func (d *Daemon) recordOutcome(scanCtx context.Context, pathCfg config.PathConfig, active *activeScan, err error) {
+	if active == nil {
+		return
+	}
+	active.setScanID("")
+	outcome := scanOutcome{
+		cfg:         pathCfg,
+		scanID:      active.id(),
+		trigger:     active.trigger,
+		started:     active.started,
+		finished:    time.Now(),
+		status:      "completed",
+		directories: int(active.directories.Load()),
+	}
+	switch {
+	case scanCtx.Err() != nil:
+		outcome.status = "cancelled"
+	case err != nil:
+		outcome.status = "failed"
+		outcome.err = err.Error()
+	}
+
+	d.mu.Lock()
+	d.lastScans[scanKey(pathCfg)] = outcome
+	d.mu.Unlock()
+}
+
+// scanKey identifies a configured path in the set of running scans.
+func scanKey(pathCfg config.PathConfig) string {
+	return fmt.Sprintf("%s (depth %d)", pathCfg.Path, pathCfg.Depth)
+}
+
+// scanPath runs a scan registered with registerScan; scanCtx is its context
+// and ctx the daemon context it was derived from.
+func (d *Daemon) scanPath(ctx, scanCtx context.Context, pathCfg config.PathConfig, trigger string) (err error) {
 	d.logger.Info("starting scan",
 		"path", pathCfg.Path,
 		"depth", pathCfg.Depth,
+		"trigger", trigger,
 	)
 
-	// Create scan record
-	scanID, err := d.storage.StartScan(scanCtx, pathCfg.Path)
+	d.mu.Lock()
+	active := d.scanners[scanKey(pathCfg)]
+	d.mu.Unlock()
+	defer func() { d.recordOutcome(scanCtx, pathCfg, active, err) }()
+
+	// Load the skip list; skipped directories are left out unless due for a
+	// re-probe. They are paths, kept apart from the exclude globs
+	tracked := make(map[string]bool)
+	var skipped []string
+	entries, err := d.storage.ListSkipEntries(scanCtx, pathCfg.Path)
 	if err != nil {
-		d.logger.Error("failed to create scan record", "error", err)
-		return
+		d.logger.Warn("failed to load skip list", "path", pathCfg.Path, "error", err)
+	}
+	for _, e := range entries {
+		tracked[e.Directory] = true
+		if !e.Skipped {
+			continue
+		}
+		if !e.Manual && d.cfg.Scan.SkipProbeInterval > 0 && time.Since(e.UpdatedAt) >= d.cfg.Scan.SkipProbeInterval {
+			d.logger.Debug("re-probing skipped directory", "directory", e.Directory)
+			continue
+		}
+		skipped = append(skipped, e.Directory)
+	}
+
+	// Pick up the scan shutdown or a crash interrupted, or create a scan record
+	var done []string
+	scanID := d.resumableScan(scanCtx, pathCfg)
+	if scanID != "" {
+		done, err = d.storage.ResumeScan(scanCtx, scanID)
+		if err != nil {
+			d.logger.Warn("failed to resume interrupted scan", "path", pathCfg.Path, "scan_id", scanID, "error", err)
+			scanID = ""
+		} else {
+			d.logger.Info("resuming interrupted scan",
+				"path", pathCfg.Path,
+				"scan_id", scanID,
+				"finished_directories", len(done),
+			)
+		}
+	}
+	if scanID == "" {
+		startOpts := storage.StartScanOptions{Trigger: trigger, Hostname: d.hostname}
+		if d.cfg.Scan.RecordMetadata {
+			startOpts.Metadata = d.scanMetadata(pathCfg, skipped)
+		}
+		scanID, err = d.storage.StartScan(scanCtx, pathCfg.Path, startOpts)
+		if err != nil {
+			d.logger.Error("failed to create scan record", "error", err)
+			return fmt.Errorf("creating scan record: %w", err)
+		}
+	}
+	if active != nil {
+		active.setScanID(scanID)
+		active.directories.Add(int64(len(done)))
+	}
+
+	release, err := d.waitForDevice(scanCtx, pathCfg.Path)
+	if err != nil {
+		d.abandonScan(ctx, scanID, "cancelled")
+		return err
+	}
+	defer release()
+
+	// Load the last stored values when small changes should not be recorded,
+	// unmodified directories may reuse them, vanished directories are to be
+	// marked deleted, or growth is checked for anomalies. Filtered
+	// measurements are not comparable with the unfiltered history.
+	shortcut := d.cfg.Scan.MtimeShortcut || d.cfg.Scan.RctimeShortcut
+	var previous map[string]storage.UsageRecord
+	if (d.cfg.Scan.ChangeThresholdEnabled() || shortcut || d.cfg.Scan.ReconcileDeleted || d.cfg.Alerts.AnomalySigma > 0) &&
+		len(pathCfg.ExcludeFiles) == 0 {
+		records, err := d.storage.GetSnapshotAt(scanCtx, pathCfg.Path, d.hostname, time.Now())
+		if err != nil {
+			d.logger.Warn("failed to load previous sizes", "path", pathCfg.Path, "error", err)
+		}
+		previous = make(map[string]storage.UsageRecord, len(records))
+		for _, r := range records {
+			previous[r.Directory] = r
+		}
 	}
 
 	// Start streaming scan
-	opts := scanner.ScanOptions{
-		FollowSymlinks: pathCfg.FollowSymlinks,
-		Exclude:        pathCfg.Exclude,
+	opts := d.scanOptions(pathCfg, skipped)
+	if d.cfg.Scan.DedupePaths {
+		if claimed := d.claimedDirs(pathCfg); claimed != nil {
+			opts.SkipDirs = claimed
+			d.logger.Info("deduplicating against overlapping paths",
+				"path", pathCfg.Path, "claimed_directories", claimed.Len())
+		}
+	}
+	if len(done) > 0 {
+		if opts.SkipDirs == nil {
+			opts.SkipDirs = scanner.NewDirSet()
+		}
+		for _, dir := range done {
+			opts.SkipDirs.Add(dir)
+		}
+	}
+	if d.ioGate != nil {
+		opts.Throttle = d.ioGate
+	}
+	if shortcut && previous != nil {
+		opts.Rctime = d.cfg.Scan.RctimeShortcut
+		opts.RctimeOnly = !d.cfg.Scan.MtimeShortcut
+		opts.Baseline = make(map[string]scanner.Baseline, len(previous))
+		for dir, r := range previous {
+			b := scanner.Baseline{
+				Measurement: scanner.Measurement{
+					SizeBytes:      r.SizeBytes,
+					SymlinkCount:   r.SymlinkCount,
+					AllocatedBytes: r.AllocatedBytes,
+					FileCount:      r.FileCount,
+					DirCount:       r.DirCount,
+				},
+				MeasuredAt: r.RecordedAt,
+			}
+			if r.QuotaLimit != nil {
+				b.QuotaLimit = *r.QuotaLimit
+			}
+			opts.Baseline[dir] = b
+		}
+	}
+	if pathCfg.MtimeCache {
+		opts.DirCache = d.loadDirCache(scanCtx, pathCfg.Path)
 	}
 	resultCh, err := d.scanner.ScanPathStreaming(scanCtx, pathCfg.Path, pathCfg.Depth, opts)
 	if err != nil {
@@ -180,39 +744,81 @@ func (d *Daemon) runScan(ctx context.Context, pathCfg config.PathConfig) {
 		if err := d.storage.FailScan(context.Background(), scanID, err.Error()); err != nil {
 			d.logger.Error("failed to mark scan as failed", "error", err)
 		}
-		return
+		return fmt.Errorf("scanning %s: %w", pathCfg.Path, err)
+	}
+
+	// Load alert state so only transitions notify
+	var alerting map[string]storage.AlertState
+	if pathCfg.AlertAbove > 0 || d.cfg.Alerts.QuotaPercent > 0 {
+		alerting, err = d.alerts.Load(scanCtx, pathCfg.Path)
+		if err != nil {
+			d.logger.Warn("failed to load alert state", "path", pathCfg.Path, "error", err)
+		}
 	}
 
-	// Process results incrementally
-	var totalRecords int
+	// Process results incrementally. With scan.resume_scans, directories
+	// finished without a record to store are noted in progress, so a resumed
+	// scan skips them along with the stored ones.
+	var totalRecords, unchanged int
+	var summary scanSummary
+	var progress []string
+	seen := make(map[string]bool, len(done))
+	for _, dir := range done {
+		seen[dir] = true
+	}
+	started := time.Now()
+	ioBefore, ioErr := cgroup.ReadIOStat()
+	cpuBefore, cpuErr := rusage.Read()
 	batch := make([]storage.UsageRecord, 0, batchSize)
 
 	flushBatch := func() error {
-		if len(batch) == 0 {
-			return nil
+		if len(batch) > 0 {
+			if err := d.storage.RecordUsageBatch(scanCtx, batch); err != nil {
+				return err
+			}
+			totalRecords += len(batch)
+			d.logger.Debug("flushed batch",
+				"path", pathCfg.Path,
+				"batch_size", len(batch),
+				"total", totalRecords,
+			)
+			batch = batch[:0]
 		}
-		if err := d.storage.RecordUsageBatch(scanCtx, batch); err != nil {
-			return err
+		if len(progress) > 0 {
+			if err := d.storage.RecordScanProgress(scanCtx, scanID, progress); err != nil {
+				return err
+			}
+			progress = progress[:0]
 		}
-		totalRecords += len(batch)
-		d.logger.Debug("flushed batch",
-			"path", pathCfg.Path,
-			"batch_size", len(batch),
-			"total", totalRecords,
-		)
-		batch = batch[:0]
 		return nil
 	}
 
 	for r := range resultCh {
+		seen[r.Path] = true
 		if r.Error != nil {
 			d.logger.Warn("scan error for directory",
 				"directory", r.Path,
 				"error", r.Error,
 			)
+			// Errors caused by cancellation say nothing about the directory
+			if scanCtx.Err() == nil {
+				d.recordDirectoryError(scanCtx, r.Path, r.Error)
+			}
+			summary.errors++
 			continue
 		}
 
+		summary.add(r)
+		if active != nil {
+			active.directories.Add(1)
+		}
+
+		if tracked[r.Path] {
+			if err := d.storage.ClearDirectoryErrors(scanCtx, r.Path); err != nil {
+				d.logger.Warn("failed to clear directory errors", "directory", r.Path, "error", err)
+			}
+		}
+
 		d.logger.Debug("scanned directory",
 			"directory", r.Path,
 			"size_bytes", r.SizeBytes,
@@ -220,21 +826,34 @@ func (d *Daemon) runScan(ctx context.Context, pathCfg config.PathConfig) {
 			"duration", r.Duration,
 		)
 
-		batch = append(batch, storage.UsageRecord{
-			BasePath:   pathCfg.Path,
-			Directory:  r.Path,
-			SizeBytes:  r.SizeBytes,
-			RecordedAt: time.Now().UTC(),
-			ScanID:     scanID,
-		})
+		d.evaluateAlert(scanCtx, pathCfg, r, alerting)
+		if prev, ok := previous[r.Path]; ok {
+			d.evaluateAnomaly(scanCtx, pathCfg, r, scanID, &prev)
+		}
 
-		if len(batch) >= batchSize {
+		// Idle CephFS trees keep their last record unless it is copied forward
+		store := r.Strategy != scanner.RctimeShortcut || d.cfg.Scan.RctimeCopyForward
+		if prev, ok := previous[r.Path]; store && ok && prev.Fingerprint == r.Fingerprint &&
+			prev.Owner == r.Owner && prev.Group == r.Group &&
+			equalInt64Ptr(prev.QuotaLimit, quotaLimit(r)) &&
+			!d.cfg.Scan.SignificantChange(prev.SizeBytes, r.SizeBytes) {
+			store = false
+		}
+
+		if store {
+			batch = append(batch, d.usageRecord(pathCfg, r, scanID))
+		} else {
+			unchanged++
+			if d.cfg.Scan.ResumeScans {
+				progress = append(progress, r.Path)
+			}
+		}
+
+		if len(batch)+len(progress) >= batchSize {
 			if err := flushBatch(); err != nil {
 				d.logger.Error("failed to store batch", "error", err)
-				if err := d.storage.FailScan(context.Background(), scanID, err.Error()); err != nil {
-					d.logger.Error("failed to mark scan as failed", "error", err)
-				}
-				return
+				d.abandonScan(ctx, scanID, err.Error())
+				return fmt.Errorf("storing batch: %w", err)
 			}
 		}
 	}
@@ -242,10 +861,8 @@ func (d *Daemon) runScan(ctx context.Context, pathCfg config.PathConfig) {
 	// Flush remaining records
 	if err := flushBatch(); err != nil {
 		d.logger.Error("failed to store final batch", "error", err)
-		if err := d.storage.FailScan(context.Background(), scanID, err.Error()); err != nil {
-			d.logger.Error("failed to mark scan as failed", "error", err)
-		}
-		return
+		d.abandonScan(ctx, scanID, err.Error())
+		return fmt.Errorf("storing final batch: %w", err)
 	}
 
 	// Check if scan was cancelled
@@ -254,24 +871,445 @@ func (d *Daemon) runScan(ctx context.Context, pathCfg config.PathConfig) {
 			"path", pathCfg.Path,
 			"directories_saved", totalRecords,
 		)
-		if err := d.storage.FailScan(context.Background(), scanID, "cancelled"); err != nil {
-			d.logger.Error("failed to mark scan as failed", "error", err)
+		d.abandonScan(ctx, scanID, "cancelled")
+		return scanCtx.Err()
+	}
+
+	if opts.DirCache != nil {
+		d.saveDirCache(pathCfg.Path, opts.DirCache)
+	}
+
+	measured := totalRecords + unchanged + len(done)
+	var gone int
+	if d.cfg.Scan.ReconcileDeleted && previous != nil {
+		markers := d.goneRecords(previous, seen, opts, pathCfg.Path, scanID)
+		if err := d.storage.RecordUsageBatch(scanCtx, markers); err != nil {
+			d.logger.Warn("failed to record deleted directories", "path", pathCfg.Path, "error", err)
+		} else {
+			gone = len(markers)
+		}
+		for _, m := range markers {
+			d.logger.Debug("directory gone since last scan", "path", pathCfg.Path, "directory", m.Directory)
 		}
-		return
+	}
+	if measured == 0 && pathCfg.Depth > 0 {
+		d.warnShallowTree(pathCfg.Path, pathCfg.Depth, opts)
 	}
 
-	if err := d.storage.CompleteScan(scanCtx, scanID, totalRecords); err != nil {
+	if err := d.storage.CompleteScan(scanCtx, scanID, measured); err != nil {
 		d.logger.Error("failed to complete scan", "error", err)
-		return
+		return fmt.Errorf("completing scan: %w", err)
 	}
+	d.recordFilesystemStats(scanCtx, pathCfg.Path, scanID)
 
+	if !d.discard {
+		d.rotateScans(pathCfg)
+		d.rollupUsage(pathCfg)
+	}
+
+	elapsed := time.Since(started)
+	var cpu rusage.CPUTime
+	if cpuErr == nil {
+		if cpuAfter, err := rusage.Read(); err == nil {
+			// Rusage is per process, so concurrent scans share the delta
+			cpu = cpuAfter.Sub(cpuBefore)
+			if err := d.storage.RecordScanCPU(context.Background(), scanID, cpu.User, cpu.System); err != nil {
+				d.logger.Warn("failed to record scan cpu time", "error", err)
+			}
+		}
+	}
+	if ioErr == nil {
+		if ioAfter, err := cgroup.ReadIOStat(); err == nil {
+			// Counters are per cgroup, so concurrent scans share the delta
+			io := ioAfter.Sub(ioBefore)
+			d.logger.Info("scan cgroup io",
+				"path", pathCfg.Path,
+				"read_bytes", io.ReadBytes,
+				"write_bytes", io.WriteBytes,
+				"read_ios", io.ReadIOs,
+				"write_ios", io.WriteIOs,
+			)
+		}
+	}
 	d.logger.Info("scan completed",
 		"path", pathCfg.Path,
-		"directories", totalRecords,
-		"strategy", d.scanner.Strategy(),
+		"directories", measured,
+		"stored", totalRecords,
+		"unchanged", unchanged,
+		"resumed", len(done),
+		"gone", gone,
+		"mtime_reused", summary.reused,
+		"rctime_reused", summary.rctimeReused,
+		"errors", summary.errors,
+		"total_bytes", summary.totalBytes,
+		"total_human", humanize.FormatSize(summary.totalBytes),
+		"largest_directory", summary.largestDir,
+		"largest_bytes", summary.largestBytes,
+		"duration", elapsed.Round(time.Millisecond),
+		"cpu_user", cpu.User.Round(time.Millisecond),
+		"cpu_system", cpu.System.Round(time.Millisecond),
+		"cpu_pct", fmt.Sprintf("%.1f", 100*cpu.Total().Seconds()/elapsed.Seconds()),
+		"dirs_per_sec", fmt.Sprintf("%.1f", float64(measured)/elapsed.Seconds()),
+		"strategy", d.strategyName(pathCfg),
+	)
+
+	if d.discard {
+		return nil
+	}
+	d.runPostHook(ctx, scanHookInfo{
+		basePath:    pathCfg.Path,
+		scanID:      scanID,
+		trigger:     trigger,
+		directories: measured,
+		totalBytes:  summary.totalBytes,
+		duration:    elapsed,
+	})
+	if d.cfg.Webhooks.Sends(config.WebhookScanCompleted) {
+		d.webhook.ScanCompleted(webhook.Scan{
+			ScanID:          scanID,
+			BasePath:        pathCfg.Path,
+			Depth:           pathCfg.Depth,
+			Trigger:         trigger,
+			Directories:     measured,
+			Errors:          summary.errors,
+			TotalBytes:      summary.totalBytes,
+			DurationSeconds: elapsed.Seconds(),
+			TopChangers:     d.topChangers(ctx, pathCfg, scanID),
+		})
+	}
+
+	return nil
+}
+
+// scanOptions returns the options a path's directories are measured with,
+// leaving out its exclusions and the skip-listed directories in skipped.
+func (d *Daemon) scanOptions(pathCfg config.PathConfig, skipped []string) scanner.ScanOptions {
+	return scanner.ScanOptions{
+		FollowSymlinks:  pathCfg.FollowSymlinks,
+		OneFileSystem:   pathCfg.OneFileSystem,
+		Exclude:         pathCfg.Exclude,
+		SkipPaths:       skipped,
+		ExcludeFiles:    pathCfg.ExcludeFiles,
+		DropCache:       d.cfg.Scan.DropCache,
+		Fingerprint:     d.cfg.Scan.Fingerprint,
+		SizeMode:        pathCfg.EffectiveSizeMode(d.cfg.Scan.AllocatedSize),
+		Counts:          d.cfg.Scan.CountEntries,
+		DedupeHardlinks: d.cfg.Scan.DedupeHardlinks,
+		ByOwner:         d.cfg.Scan.UsageByOwner,
+		LargestFiles:    d.cfg.Scan.LargestFiles,
+		AgeDays:         d.cfg.Scan.AgeDays(),
+		AgeTime:         d.cfg.Scan.AgeBreakdown,
+		Logger:          d.logger,
+		StatfsTimeout:   d.cfg.Scan.StatfsTimeout,
+		DirTimeout:      pathCfg.EffectiveDirTimeout(d.cfg.Scan.DirTimeout),
+		LooseFiles:      pathCfg.LooseFiles,
+		Strategy:        pathCfg.Strategy,
+		Owner:           d.cfg.Scan.RecordOwner,
+		Priority:        pathCfg.Priority,
+		BatchSize:       d.cfg.Scan.DuBatchSize,
+		WalkWorkers:     d.cfg.Scan.WalkWorkers,
+		Limiter:         d.limiter,
+		DuWrapper:       d.cfg.Scan.DuWrapperArgs(),
+	}
+}
+
+// usageRecord returns the record storing a measured directory's result in
+// scan scanID.
+func (d *Daemon) usageRecord(pathCfg config.PathConfig, r scanner.Result, scanID string) storage.UsageRecord {
+	return storage.UsageRecord{
+		BasePath:       pathCfg.Path,
+		Directory:      r.Path,
+		SizeBytes:      r.SizeBytes,
+		RecordedAt:     time.Now().UTC(),
+		ScanID:         scanID,
+		FileFilter:     strings.Join(pathCfg.ExcludeFiles, ","),
+		SymlinkCount:   r.SymlinkCount,
+		Fingerprint:    r.Fingerprint,
+		AllocatedBytes: r.AllocatedBytes,
+		Owner:          r.Owner,
+		Group:          r.Group,
+		QuotaLimit:     quotaLimit(r),
+		FileCount:      r.FileCount,
+		DirCount:       r.DirCount,
+		Hostname:       d.hostname,
+		OwnerUsage:     storage.NewOwnerUsage(r.OwnerBytes, scanner.UserName),
+		LargeFiles:     largeFiles(r),
+		AgeUsage:       storage.NewAgeUsage(d.cfg.Scan.AgeBreakdown, d.cfg.Scan.AgeBuckets, r.AgeBytes),
+	}
+}
+
+// evaluateAlert checks a measured directory against its size alert
+// threshold; alerting holds the path's current alert states.
+func (d *Daemon) evaluateAlert(ctx context.Context, pathCfg config.PathConfig, r scanner.Result, alerting map[string]storage.AlertState) {
+	// The filesystem's own quota, when known, can lower the threshold
+	threshold := int64(pathCfg.AlertAbove)
+	if q := d.cfg.Alerts.QuotaThreshold(r.QuotaLimit); q > 0 && (threshold == 0 || q < threshold) {
+		threshold = q
+	}
+	if threshold <= 0 {
+		return
+	}
+	var prev *storage.AlertState
+	if a, ok := alerting[r.Path]; ok {
+		prev = &a
+	}
+	if err := d.alerts.Evaluate(ctx, pathCfg.Path, r.Path, r.SizeBytes, threshold, prev, time.Now()); err != nil {
+		d.logger.Warn("failed to evaluate size alert", "directory", r.Path, "error", err)
+	}
+}
+
+// evaluateAnomaly checks a measured directory's growth against its
+// history. prev is its latest stored record, if known, to skip looking up
+// the history of directories that did not grow enough to matter.
+func (d *Daemon) evaluateAnomaly(ctx context.Context, pathCfg config.PathConfig, r scanner.Result, scanID string, prev *storage.UsageRecord) {
+	cfg := d.cfg.Alerts
+	if cfg.AnomalySigma <= 0 || len(pathCfg.ExcludeFiles) > 0 {
+		return
+	}
+	if prev != nil && (prev.Deleted || r.SizeBytes-prev.SizeBytes < int64(cfg.AnomalyMinGrowth)) {
+		return
+	}
+
+	records, err := d.storage.QueryUsage(ctx, storage.QueryOptions{Directory: r.Path, Limit: cfg.AnomalyHistory + 1})
+	if err != nil {
+		d.logger.Warn("failed to load size history", "directory", r.Path, "error", err)
+		return
+	}
+	// Oldest first, back to the directory's last deletion, leaving out
+	// filtered measurements and this scan's own record
+	var sizes []int64
+	for _, rec := range records {
+		if rec.Deleted {
+			break
+		}
+		if rec.FileFilter == "" && rec.ScanID != scanID {
+			sizes = append([]int64{rec.SizeBytes}, sizes...)
+		}
+	}
+	if len(sizes) == 0 {
+		return
+	}
+	sizes = sizes[max(len(sizes)-cfg.AnomalyHistory, 0):]
+
+	growth := r.SizeBytes - sizes[len(sizes)-1]
+	baseline := anomaly.NewBaseline(sizes)
+	detector := anomaly.Detector{Sigma: cfg.AnomalySigma, MinGrowth: int64(cfg.AnomalyMinGrowth)}
+	if !detector.Anomalous(baseline, growth) {
+		return
+	}
+	if err := d.alerts.Anomaly(ctx, pathCfg.Path, r.Path, r.SizeBytes, growth, baseline, time.Now()); err != nil {
+		d.logger.Warn("failed to send anomaly alert", "directory", r.Path, "error", err)
+	}
+}
+
+// quotaLimit returns a result's quota for storage, nil when it has none.
+func quotaLimit(r scanner.Result) *int64 {
+	if r.QuotaLimit <= 0 {
+		return nil
+	}
+	q := r.QuotaLimit
+	return &q
+}
+
+// largeFiles returns a result's largest files for storage.
+func largeFiles(r scanner.Result) []storage.LargeFile {
+	if len(r.LargestFiles) == 0 {
+		return nil
+	}
+	files := make([]storage.LargeFile, len(r.LargestFiles))
+	for i, f := range r.LargestFiles {
+		files[i] = storage.LargeFile(f)
+	}
+	return files
+}
+
+// equalInt64Ptr reports whether a and b are both nil or point to equal values.
+func equalInt64Ptr(a, b *int64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// strategyName returns the name of the strategy configured for a path.
+func (d *Daemon) strategyName(pathCfg config.PathConfig) string {
+	if pathCfg.Strategy != "" {
+		return pathCfg.Strategy
+	}
+	return d.scanner.Strategy()
+}
+
+// SetVersion sets the usgmon version recorded in scan metadata.
+func (d *Daemon) SetVersion(version string) {
+	d.version = version
+}
+
+// scanMetadata describes the host and the effective options of a scan of
+// pathCfg that leaves out the skip-listed directories in skipped.
+func (d *Daemon) scanMetadata(pathCfg config.PathConfig, skipped []string) *storage.ScanMetadata {
+	m := storage.NewScanMetadata(d.version)
+	m.Depth = pathCfg.Depth
+	m.FollowSymlinks = pathCfg.FollowSymlinks
+	m.OneFileSystem = pathCfg.OneFileSystem
+	m.MtimeCache = pathCfg.MtimeCache
+	m.Strategy = d.strategyName(pathCfg)
+	m.Workers = d.cfg.Scan.Workers
+	m.Exclude = append(append([]string(nil), pathCfg.Exclude...), skipped...)
+	m.ExcludeFiles = pathCfg.ExcludeFiles
+	m.LooseFiles = pathCfg.LooseFiles
+	m.Fingerprint = d.cfg.Scan.Fingerprint
+	m.SizeMode = pathCfg.EffectiveSizeMode(d.cfg.Scan.AllocatedSize)
+	m.AllocatedSize = m.SizeMode == scanner.SizeBoth
+	m.CountEntries = d.cfg.Scan.CountEntries
+	m.MtimeShortcut = d.cfg.Scan.MtimeShortcut
+	m.RctimeShortcut = d.cfg.Scan.RctimeShortcut
+	m.DuBatchSize = d.cfg.Scan.DuBatchSize
+	if d.cfg.Scan.WalkWorkers > 1 {
+		m.WalkWorkers = d.cfg.Scan.WalkWorkers
+	}
+	m.DedupeHardlinks = d.cfg.Scan.DedupeHardlinks
+	m.UsageByOwner = d.cfg.Scan.UsageByOwner
+	m.LargestFiles = d.cfg.Scan.LargestFiles
+	if d.cfg.Scan.AgeBreakdown != "" {
+		m.AgeBreakdown = d.cfg.Scan.AgeBreakdown
+		m.AgeBuckets = d.cfg.Scan.AgeBuckets
+	}
+	return m
+}
+
+// checkStrategy warns when a path forced to a filesystem-specific strategy is
+// not on that filesystem: with ceph every measurement would then fail, and
+// the strategies reading filesystem accounting would always fall back to du.
+func (d *Daemon) checkStrategy(pathCfg config.PathConfig) {
+	var onFS func(string, time.Duration) (bool, error)
+	var fsName, consequence string
+	switch pathCfg.Strategy {
+	case "ceph":
+		onFS, fsName, consequence = scanner.IsCephFS, "CephFS", "measurements will fail"
+	case "btrfs":
+		onFS, fsName, consequence = scanner.IsBtrfs, "Btrfs", "directories will be measured with du"
+	case "lustre":
+		onFS, fsName, consequence = scanner.IsLustre, "Lustre", "directories will be measured with du"
+	case "gpfs":
+		onFS, fsName, consequence = scanner.IsGPFS, "GPFS", "directories will be measured with du"
+	default:
+		return
+	}
+
+	resolved, err := filepath.EvalSymlinks(pathCfg.Path)
+	if err != nil {
+		resolved = pathCfg.Path
+	}
+	ok, err := onFS(resolved, d.cfg.Scan.StatfsTimeout)
+	if err != nil {
+		d.logger.Warn("could not verify path is on "+fsName, "path", pathCfg.Path, "error", err)
+		return
+	}
+	if !ok {
+		d.logger.Warn(fmt.Sprintf("path uses the %s strategy but is not on %s; %s", pathCfg.Strategy, fsName, consequence),
+			"path", pathCfg.Path)
+	}
+}
+
+// rotateScans deletes scans beyond the configured number to keep for a path.
+func (d *Daemon) rotateScans(pathCfg config.PathConfig) {
+	keep := pathCfg.EffectiveKeepScans(d.cfg.Scan.KeepScans)
+	if keep <= 0 {
+		return
+	}
+
+	scans, records, err := d.storage.PruneScansKeepingLatest(context.Background(), pathCfg.Path, keep)
+	if err != nil {
+		d.logger.Error("failed to rotate scans", "path", pathCfg.Path, "error", err)
+		return
+	}
+	if scans > 0 {
+		d.logger.Info("rotated old scans",
+			"path", pathCfg.Path,
+			"keep", keep,
+			"scans_removed", scans,
+			"records_removed", records,
+		)
+	}
+}
+
+// rollupUsage replaces a path's records older than scan.rollup_after with
+// daily rollups.
+func (d *Daemon) rollupUsage(pathCfg config.PathConfig) {
+	if d.cfg.Scan.RollupAfter <= 0 {
+		return
+	}
+
+	days, records, err := d.storage.RollupUsageBefore(context.Background(), pathCfg.Path, time.Now().Add(-d.cfg.Scan.RollupAfter))
+	if err != nil {
+		d.logger.Error("failed to roll up old usage records", "path", pathCfg.Path, "error", err)
+		return
+	}
+	if records > 0 {
+		d.logger.Info("rolled up old usage records",
+			"path", pathCfg.Path,
+			"records_removed", records,
+			"daily_rollups", days,
+		)
+	}
+}
+
+// scanSummary accumulates aggregates over a scan's results for the completion log.
+type scanSummary struct {
+	totalBytes   int64
+	largestDir   string
+	largestBytes int64
+	errors       int
+	reused       int // results taken from the mtime shortcut
+	rctimeReused int // results taken from the rctime shortcut
+}
+
+// add folds a successful result into the summary.
+func (s *scanSummary) add(r scanner.Result) {
+	s.totalBytes += r.SizeBytes
+	switch r.Strategy {
+	case scanner.MtimeShortcut:
+		s.reused++
+	case scanner.RctimeShortcut:
+		s.rctimeReused++
+	}
+	if s.largestDir == "" || r.SizeBytes > s.largestBytes {
+		s.largestDir = r.Path
+		s.largestBytes = r.SizeBytes
+	}
+}
+
+// warnShallowTree logs a warning when a scan found nothing because the
+// configured depth is deeper than the tree, suggesting the likely depth.
+func (d *Daemon) warnShallowTree(path string, depth int, opts scanner.ScanOptions) {
+	deepest, err := scanner.DeepestLevel(path, depth, opts)
+	if err != nil || deepest >= depth {
+		return
+	}
+	d.logger.Warn("configured depth exceeds tree depth, no directories found",
+		"path", path,
+		"depth", depth,
+		"deepest_level", deepest,
+		"hint", fmt.Sprintf("set depth: %d, or depth: -1 for leaf directories", deepest),
 	)
 }
 
+// recordDirectoryError tracks a failed directory and logs when it joins the skip list.
+func (d *Daemon) recordDirectoryError(ctx context.Context, dir string, scanErr error) {
+	entry, err := d.storage.RecordDirectoryError(ctx, dir, scanErr.Error(), d.cfg.Scan.SkipAfterErrors)
+	if err != nil {
+		d.logger.Warn("failed to record directory error", "directory", dir, "error", err)
+		return
+	}
+	if entry.Skipped && entry.ConsecutiveErrors == d.cfg.Scan.SkipAfterErrors {
+		d.logger.Warn("directory added to skip list",
+			"directory", dir,
+			"consecutive_errors", entry.ConsecutiveErrors,
+			"probe_interval", d.cfg.Scan.SkipProbeInterval,
+		)
+	}
+}
+
 // waitForScans waits for all in-progress scans to complete.
 func (d *Daemon) waitForScans() {
 	d.mu.Lock()
@@ -294,8 +1332,8 @@ func (d *Daemon) waitForScans() {
 		case <-timeout:
 			d.logger.Warn("timeout waiting for scans, forcing shutdown")
 			d.mu.Lock()
-			for _, cancel := range d.scanners {
-				cancel()
+			for _, scan := range d.scanners {
+				scan.cancel()
 			}
 			d.mu.Unlock()
 			return
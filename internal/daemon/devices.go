@@ -0,0 +1,87 @@
+package daemon
+
+import (
+	"context"
+	"os"
+	"sync"
+	"syscall"
+)
+
+// deviceSlots limits how many scans run at once on each filesystem
+// (scan.max_concurrent_per_device), so paths on the same disks that come
+// due together take turns instead of thrashing them.
+type deviceSlots struct {
+	limit int
+
+	mu    sync.Mutex
+	slots map[uint64]chan struct{} // by device ID
+}
+
+// newDeviceSlots returns slots allowing limit scans per device.
+func newDeviceSlots(limit int) *deviceSlots {
+	return &deviceSlots{limit: limit, slots: make(map[uint64]chan struct{})}
+}
+
+// acquire waits for a free slot on the device path is on and returns a
+// function releasing it. Paths that cannot be stat'ed are not limited;
+// their scans fail on their own. It returns ctx's error if ctx ends first.
+func (s *deviceSlots) acquire(ctx context.Context, path string) (func(), error) {
+	dev, ok := deviceID(path)
+	if !ok {
+		return func() {}, nil
+	}
+
+	s.mu.Lock()
+	slot, exists := s.slots[dev]
+	if !exists {
+		slot = make(chan struct{}, s.limit)
+		s.slots[dev] = slot
+	}
+	s.mu.Unlock()
+
+	select {
+	case slot <- struct{}{}:
+		return func() { <-slot }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// busy reports whether every slot on path's device is taken.
+func (s *deviceSlots) busy(path string) bool {
+	dev, ok := deviceID(path)
+	if !ok {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	slot := s.slots[dev]
+	return slot != nil && len(slot) == cap(slot)
+}
+
+// deviceID returns the ID of the device the file at path is on.
+func deviceID(path string) (uint64, bool) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, false
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return uint64(st.Dev), true
+}
+
+// waitForDevice holds a scan of path until the device it is on has a free
+// slot, logging when it has to wait. It returns a function releasing the
+// slot.
+func (d *Daemon) waitForDevice(ctx context.Context, path string) (func(), error) {
+	if d.devices == nil {
+		return func() {}, nil
+	}
+	if d.devices.busy(path) {
+		d.logger.Info("waiting for other scans on the same device",
+			"path", path, "max_concurrent_per_device", d.cfg.Scan.MaxConcurrentPerDevice)
+	}
+	return d.devices.acquire(ctx, path)
+}
@@ -0,0 +1,78 @@
+package daemon
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/jgalley/usgmon/internal/email"
+	"github.com/robfig/cron/v3"
+)
+
+// runDigests emails a usage digest at each time of the email.digest_schedule
+// until ctx is done. Each digest covers the time since the previous one, or
+// the preceding day for the first.
+func (d *Daemon) runDigests(ctx context.Context) {
+	sched, err := cron.ParseStandard(d.cfg.Email.DigestSchedule)
+	if err != nil {
+		// Validated at config load, so this should not happen
+		d.logger.Error("invalid digest schedule", "error", err)
+		return
+	}
+	d.logger.Info("starting email digests", "schedule", d.cfg.Email.DigestSchedule, "to", d.cfg.Email.To)
+
+	var last time.Time
+	for {
+		next := sched.Next(time.Now())
+		d.logger.Debug("next digest scheduled", "at", next)
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		since := last
+		if since.IsZero() {
+			since = next.Add(-24 * time.Hour)
+		}
+		if err := d.SendDigest(ctx, since, next); err != nil {
+			d.logger.Warn("failed to send email digest", "error", err)
+			continue
+		}
+		last = next
+	}
+}
+
+// SendDigest builds the usage digest of every configured path between since
+// and until and emails it. Email must be configured.
+func (d *Daemon) SendDigest(ctx context.Context, since, until time.Time) error {
+	digest, err := d.BuildDigest(ctx, since, until)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, d.cfg.Email.Timeout)
+	defer cancel()
+	if err := d.mailer.Send(ctx, digest.Subject(d.mailer.Hostname()), digest.Text()); err != nil {
+		return err
+	}
+	d.logger.Info("sent email digest", "since", since, "until", until, "to", d.cfg.Email.To)
+	return nil
+}
+
+// BuildDigest returns the usage digest of every configured path between
+// since and until.
+func (d *Daemon) BuildDigest(ctx context.Context, since, until time.Time) (*email.Digest, error) {
+	paths, _ := d.pathSettings()
+	var basePaths []string
+	for _, p := range paths {
+		// A path configured at several depths is summarized once
+		if !slices.Contains(basePaths, p.Path) {
+			basePaths = append(basePaths, p.Path)
+		}
+	}
+	return email.BuildDigest(ctx, d.storage, basePaths, since, until, d.cfg.Email.DigestTop)
+}
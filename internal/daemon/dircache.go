@@ -0,0 +1,51 @@
+package daemon
+
+import (
+	"context"
+
+	"github.com/jgalley/usgmon/pkg/scanner"
+	"github.com/jgalley/usgmon/pkg/storage"
+)
+
+// loadDirCache returns the directory cache of a path's earlier walks. A cache
+// that cannot be read starts out empty, so the scan reads every file.
+func (d *Daemon) loadDirCache(ctx context.Context, path string) *scanner.DirCache {
+	entries, err := d.storage.LoadDirCache(ctx, path, d.hostname)
+	if err != nil {
+		d.logger.Warn("failed to load directory cache", "path", path, "error", err)
+	}
+	cached := make(map[string]scanner.CachedDir, len(entries))
+	for _, e := range entries {
+		cached[e.Directory] = scanner.CachedDir{
+			Dev:            e.Dev,
+			Ino:            e.Ino,
+			ModTime:        e.ModTime,
+			SizeBytes:      e.SizeBytes,
+			AllocatedBytes: e.AllocatedBytes,
+		}
+	}
+	return scanner.NewDirCache(cached)
+}
+
+// saveDirCache stores the directories a scan read in full and forgets those
+// that have been removed.
+func (d *Daemon) saveDirCache(path string, cache *scanner.DirCache) {
+	updated := cache.Updated()
+	entries := make([]storage.DirCacheEntry, 0, len(updated))
+	for dir, e := range updated {
+		entries = append(entries, storage.DirCacheEntry{
+			Directory:      dir,
+			Dev:            e.Dev,
+			Ino:            e.Ino,
+			ModTime:        e.ModTime,
+			SizeBytes:      e.SizeBytes,
+			AllocatedBytes: e.AllocatedBytes,
+		})
+	}
+	gone := cache.Gone()
+	if err := d.storage.SaveDirCache(context.Background(), path, d.hostname, entries, gone); err != nil {
+		d.logger.Warn("failed to save directory cache", "path", path, "error", err)
+		return
+	}
+	d.logger.Debug("saved directory cache", "path", path, "updated", len(entries), "removed", len(gone))
+}
@@ -0,0 +1,151 @@
+package daemon
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jgalley/usgmon/internal/alert"
+	"github.com/jgalley/usgmon/internal/humanize"
+	"github.com/jgalley/usgmon/pkg/storage"
+)
+
+// DiscardResults makes the daemon scan normally but log what it would store
+// instead of writing it. Scan and usage records, skip-list updates, alert
+// state, scan rotation and the post-scan hook are all skipped, and agent
+// pushes are refused; existing data is still read, so the skip list and
+// significant-change filtering behave as they would for real. Call before
+// Run or RunOnce.
+func (d *Daemon) DiscardResults() {
+	d.storage = &discardStorage{Storage: d.storage, logger: d.logger}
+	d.alerts = alert.NewTracker(d.storage, &alert.LogNotifier{Logger: d.logger}, d.cfg.Alerts.ReminderInterval)
+	d.discard = true
+}
+
+// discardStorage passes reads through to the underlying storage and turns
+// every write the daemon makes into a no-op, logging usage records instead.
+type discardStorage struct {
+	storage.Storage
+	logger *slog.Logger
+}
+
+func (s *discardStorage) StartScan(ctx context.Context, basePath string, opts storage.StartScanOptions) (string, error) {
+	return "dry-run-" + uuid.New().String(), nil
+}
+
+func (s *discardStorage) CompleteScan(ctx context.Context, scanID string, directoriesScanned int) error {
+	return nil
+}
+
+func (s *discardStorage) RecordScanCPU(ctx context.Context, scanID string, user, system time.Duration) error {
+	return nil
+}
+
+func (s *discardStorage) SaveDirCache(ctx context.Context, basePath, hostname string, entries []storage.DirCacheEntry, removed []string) error {
+	return nil
+}
+
+func (s *discardStorage) FailScan(ctx context.Context, scanID string, reason string) error {
+	return nil
+}
+
+func (s *discardStorage) SkipScan(ctx context.Context, scanID string, reason string) error {
+	return nil
+}
+
+func (s *discardStorage) FailStaleScans(ctx context.Context, hostname, reason string, deleteRecords bool) ([]string, int64, error) {
+	return nil, 0, nil
+}
+
+func (s *discardStorage) InterruptScan(ctx context.Context, scanID string) error {
+	return nil
+}
+
+func (s *discardStorage) InterruptStaleScans(ctx context.Context, hostname string) ([]string, error) {
+	return nil, nil
+}
+
+func (s *discardStorage) RecordScanProgress(ctx context.Context, scanID string, directories []string) error {
+	return nil
+}
+
+func (s *discardStorage) ResumeScan(ctx context.Context, scanID string) ([]string, error) {
+	return nil, nil
+}
+
+func (s *discardStorage) RecordFilesystemStats(ctx context.Context, stats storage.FilesystemStats) error {
+	return nil
+}
+
+func (s *discardStorage) RecordUsage(ctx context.Context, record storage.UsageRecord) error {
+	return s.RecordUsageBatch(ctx, []storage.UsageRecord{record})
+}
+
+func (s *discardStorage) RecordUsageBatch(ctx context.Context, records []storage.UsageRecord) error {
+	for _, r := range records {
+		attrs := []any{
+			"path", r.BasePath,
+			"directory", r.Directory,
+			"size_bytes", r.SizeBytes,
+			"size_human", humanize.FormatSize(r.SizeBytes),
+		}
+		if r.AllocatedBytes != nil {
+			attrs = append(attrs, "allocated_bytes", *r.AllocatedBytes)
+		}
+		if r.Owner != "" {
+			attrs = append(attrs, "owner", r.Owner, "group", r.Group)
+		}
+		if r.QuotaLimit != nil {
+			attrs = append(attrs, "quota_limit", *r.QuotaLimit)
+		}
+		if r.FileCount != nil && r.DirCount != nil {
+			attrs = append(attrs, "file_count", *r.FileCount, "dir_count", *r.DirCount)
+		}
+		if r.Deleted {
+			attrs = append(attrs, "deleted", true)
+		}
+		s.logger.Info("dry run: would record usage", attrs...)
+	}
+	return nil
+}
+
+func (s *discardStorage) RecordIngestedUsage(ctx context.Context, scanID string, batch int, records []storage.UsageRecord) (bool, error) {
+	return true, s.RecordUsageBatch(ctx, records)
+}
+
+func (s *discardStorage) PruneScansKeepingLatest(ctx context.Context, basePath string, keepN int) (int64, int64, error) {
+	return 0, 0, nil
+}
+
+func (s *discardStorage) RollupUsageBefore(ctx context.Context, basePath string, cutoff time.Time) (int64, int64, error) {
+	return 0, 0, nil
+}
+
+func (s *discardStorage) PruneScansBefore(ctx context.Context, cutoff time.Time) (int64, int64, error) {
+	return 0, 0, nil
+}
+
+func (s *discardStorage) RecordDirectoryError(ctx context.Context, directory, message string, threshold int) (*storage.SkipEntry, error) {
+	return &storage.SkipEntry{Directory: directory, ConsecutiveErrors: 1, LastError: message, UpdatedAt: time.Now()}, nil
+}
+
+func (s *discardStorage) ClearDirectoryErrors(ctx context.Context, directory string) error {
+	return nil
+}
+
+func (s *discardStorage) AddSkipEntry(ctx context.Context, directory, reason string) error {
+	return nil
+}
+
+func (s *discardStorage) RemoveSkipEntry(ctx context.Context, directory string) (bool, error) {
+	return false, nil
+}
+
+func (s *discardStorage) SaveAlertState(ctx context.Context, state storage.AlertState) error {
+	return nil
+}
+
+func (s *discardStorage) DeleteAlertState(ctx context.Context, directory string) error {
+	return nil
+}
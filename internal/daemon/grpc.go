@@ -0,0 +1,348 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	usgmonv1 "github.com/jgalley/usgmon/api/usgmon/v1"
+	"github.com/jgalley/usgmon/pkg/storage"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// minProgressInterval bounds how often StreamScanProgress may report.
+const minProgressInterval = 100 * time.Millisecond
+
+// startGRPC starts the gRPC API on api.grpc_listen. Scans triggered through
+// it run under ctx, and progress streams end with it. It returns a function
+// that shuts the server down.
+func (d *Daemon) startGRPC(ctx context.Context) (func(), error) {
+	ln, err := net.Listen("tcp", d.cfg.API.GRPCListen)
+	if err != nil {
+		return nil, fmt.Errorf("starting grpc server: %w", err)
+	}
+
+	srv := grpc.NewServer()
+	usgmonv1.RegisterUsageServiceServer(srv, &grpcServer{d: d, ctx: ctx})
+	go func() {
+		if err := srv.Serve(ln); err != nil {
+			d.logger.Error("grpc server failed", "error", err)
+		}
+	}()
+	d.logger.Info("grpc server listening", "addr", ln.Addr().String())
+
+	return func() {
+		stopped := make(chan struct{})
+		go func() {
+			srv.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-time.After(apiShutdownTimeout):
+			d.logger.Warn("grpc server did not shut down cleanly")
+			srv.Stop()
+		}
+	}, nil
+}
+
+// grpcServer implements the usgmon.v1 UsageService.
+type grpcServer struct {
+	usgmonv1.UnimplementedUsageServiceServer
+	d   *Daemon
+	ctx context.Context
+}
+
+func (s *grpcServer) QueryUsage(ctx context.Context, req *usgmonv1.QueryUsageRequest) (*usgmonv1.QueryUsageResponse, error) {
+	opts := storage.QueryOptions{
+		Directory: req.GetDirectory(),
+		BasePath:  req.GetBasePath(),
+		Owner:     req.GetOwner(),
+		Hostname:  req.GetHostname(),
+		Since:     timeOrNil(req.GetSince()),
+		Until:     timeOrNil(req.GetUntil()),
+	}
+	if opts.Directory == "" && opts.BasePath == "" {
+		return nil, status.Error(codes.InvalidArgument, "directory or base_path is required")
+	}
+	limit, err := grpcLimit(req.GetLimit(), 100)
+	if err != nil {
+		return nil, err
+	}
+	opts.Limit = limit
+
+	records, err := s.d.storage.QueryUsage(ctx, opts)
+	if err != nil {
+		s.d.logger.Error("grpc usage query failed", "error", err)
+		return nil, status.Error(codes.Internal, "querying usage failed")
+	}
+
+	resp := &usgmonv1.QueryUsageResponse{Records: make([]*usgmonv1.UsageRecord, len(records))}
+	for i, rec := range records {
+		resp.Records[i] = &usgmonv1.UsageRecord{
+			BasePath:        rec.BasePath,
+			Directory:       rec.Directory,
+			SizeBytes:       rec.SizeBytes,
+			RecordedAt:      timestamppb.New(rec.RecordedAt),
+			ScanId:          rec.ScanID,
+			FileFilter:      rec.FileFilter,
+			SymlinkCount:    rec.SymlinkCount,
+			Fingerprint:     rec.Fingerprint,
+			AllocatedBytes:  rec.AllocatedBytes,
+			Owner:           rec.Owner,
+			Group:           rec.Group,
+			QuotaLimitBytes: rec.QuotaLimit,
+			Deleted:         rec.Deleted,
+			FileCount:       rec.FileCount,
+			DirCount:        rec.DirCount,
+			Hostname:        rec.Hostname,
+		}
+		if rec.Rollup != nil {
+			resp.Records[i].Rollup = &usgmonv1.Rollup{
+				Samples:  int32(rec.Rollup.Samples),
+				MinBytes: rec.Rollup.MinBytes,
+				MaxBytes: rec.Rollup.MaxBytes,
+			}
+		}
+	}
+	return resp, nil
+}
+
+func (s *grpcServer) GetTopChangers(ctx context.Context, req *usgmonv1.GetTopChangersRequest) (*usgmonv1.GetTopChangersResponse, error) {
+	if req.GetBasePath() == "" {
+		return nil, status.Error(codes.InvalidArgument, "base_path is required")
+	}
+	until := time.Now()
+	if req.GetUntil() != nil {
+		until = req.GetUntil().AsTime()
+	}
+	since := until.AddDate(0, 0, -7)
+	if req.GetSince() != nil {
+		since = req.GetSince().AsTime()
+	}
+	if !since.Before(until) {
+		return nil, status.Error(codes.InvalidArgument, "since must be before until")
+	}
+	if req.GetMinChangeBytes() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "min_change_bytes must be non-negative")
+	}
+	direction, ok := grpcDirections[req.GetDirection()]
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "unknown direction %v", req.GetDirection())
+	}
+	limit, err := grpcLimit(req.GetLimit(), 10)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := s.d.storage.GetTopChangers(ctx, storage.TopChangerOptions{
+		BasePath:       filepath.Clean(req.GetBasePath()),
+		Since:          since,
+		Until:          until,
+		Direction:      direction,
+		MinChangeBytes: req.GetMinChangeBytes(),
+		Owner:          req.GetOwner(),
+		Hostname:       req.GetHostname(),
+		Limit:          limit,
+	})
+	if err != nil {
+		s.d.logger.Error("grpc top changers query failed", "error", err)
+		return nil, status.Error(codes.Internal, "querying top changers failed")
+	}
+
+	resp := &usgmonv1.GetTopChangersResponse{Changes: make([]*usgmonv1.DirectoryChange, len(changes))}
+	for i, c := range changes {
+		resp.Changes[i] = &usgmonv1.DirectoryChange{
+			Directory:      c.Directory,
+			BasePath:       c.BasePath,
+			StartSizeBytes: c.StartSize,
+			EndSizeBytes:   c.EndSize,
+			StartTime:      timestamppb.New(c.StartTime),
+			EndTime:        timestamppb.New(c.EndTime),
+			ChangeBytes:    c.ChangeBytes,
+			ChangePercent:  c.ChangePercent,
+			Owner:          c.Owner,
+			Group:          c.Group,
+			Hostname:       c.Hostname,
+		}
+	}
+	return resp, nil
+}
+
+// grpcDirections maps API directions to GetTopChangers directions.
+var grpcDirections = map[usgmonv1.Direction]string{
+	usgmonv1.Direction_DIRECTION_UNSPECIFIED: "both",
+	usgmonv1.Direction_DIRECTION_BOTH:        "both",
+	usgmonv1.Direction_DIRECTION_INCREASE:    "increase",
+	usgmonv1.Direction_DIRECTION_DECREASE:    "decrease",
+}
+
+func (s *grpcServer) ListScans(ctx context.Context, req *usgmonv1.ListScansRequest) (*usgmonv1.ListScansResponse, error) {
+	limit, err := grpcLimit(req.GetLimit(), 50)
+	if err != nil {
+		return nil, err
+	}
+	scans, err := s.d.storage.ListScans(ctx, storage.ScanListOptions{
+		BasePath: req.GetBasePath(),
+		Trigger:  req.GetTrigger(),
+		Limit:    limit,
+	})
+	if err != nil {
+		s.d.logger.Error("grpc scan listing failed", "error", err)
+		return nil, status.Error(codes.Internal, "listing scans failed")
+	}
+
+	resp := &usgmonv1.ListScansResponse{Scans: make([]*usgmonv1.Scan, len(scans))}
+	for i, sc := range scans {
+		resp.Scans[i] = &usgmonv1.Scan{
+			ScanId:             sc.ScanID,
+			BasePath:           sc.BasePath,
+			StartedAt:          timestamppb.New(sc.StartedAt),
+			DirectoriesScanned: int32(sc.DirectoriesScanned),
+			Status:             sc.Status,
+			Trigger:            sc.Trigger,
+			Note:               sc.Note,
+			Hostname:           sc.Hostname,
+		}
+		if sc.CompletedAt != nil {
+			resp.Scans[i].CompletedAt = timestamppb.New(*sc.CompletedAt)
+		}
+		if sc.CPUUser != nil && sc.CPUSystem != nil {
+			resp.Scans[i].CpuUser = durationpb.New(*sc.CPUUser)
+			resp.Scans[i].CpuSystem = durationpb.New(*sc.CPUSystem)
+		}
+	}
+	return resp, nil
+}
+
+func (s *grpcServer) TriggerScan(ctx context.Context, req *usgmonv1.TriggerScanRequest) (*usgmonv1.TriggerScanResponse, error) {
+	if s.ctx.Err() != nil {
+		return nil, status.Error(codes.Unavailable, "daemon is shutting down")
+	}
+	if req.GetPath() == "" {
+		return nil, status.Error(codes.InvalidArgument, "path is required")
+	}
+	var depth string
+	if req.Depth != nil {
+		depth = strconv.Itoa(int(req.GetDepth()))
+	}
+	pathCfg, ok, err := s.d.findPath(filepath.Clean(req.GetPath()), depth)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if !ok {
+		return nil, status.Error(codes.NotFound, "path is not configured")
+	}
+
+	scanID, err := s.d.triggerScan(s.ctx, ctx, pathCfg, storage.TriggerGRPC)
+	switch {
+	case errors.Is(err, errScanRunning):
+		return nil, status.Error(codes.AlreadyExists, err.Error())
+	case err != nil:
+		return nil, status.FromContextError(err).Err()
+	}
+	return &usgmonv1.TriggerScanResponse{
+		ScanId: scanID,
+		Path:   pathCfg.Path,
+		Depth:  int32(pathCfg.Depth),
+	}, nil
+}
+
+func (s *grpcServer) StreamScanProgress(req *usgmonv1.StreamScanProgressRequest, stream usgmonv1.UsageService_StreamScanProgressServer) error {
+	if req.GetScanId() == "" {
+		return status.Error(codes.InvalidArgument, "scan_id is required")
+	}
+	interval := time.Second
+	if req.GetInterval() != nil {
+		interval = max(req.GetInterval().AsDuration(), minProgressInterval)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		progress, ok := s.d.scanProgress(req.GetScanId())
+		if !ok {
+			return status.Error(codes.NotFound, "scan is neither running nor the last finished scan of its path")
+		}
+		if err := stream.Send(progress); err != nil {
+			return err
+		}
+		if progress.Status != "running" {
+			return nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-stream.Context().Done():
+			return status.FromContextError(stream.Context().Err()).Err()
+		case <-s.ctx.Done():
+			return status.Error(codes.Unavailable, "daemon is shutting down")
+		}
+	}
+}
+
+// scanProgress reports on the scan with the given ID if it is running or is
+// the last finished scan of its path.
+func (d *Daemon) scanProgress(scanID string) (*usgmonv1.ScanProgress, bool) {
+	now := time.Now()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, scan := range d.scanners {
+		if scan.id() == scanID {
+			return &usgmonv1.ScanProgress{
+				ScanId:      scanID,
+				Path:        scan.cfg.Path,
+				Depth:       int32(scan.cfg.Depth),
+				Trigger:     scan.trigger,
+				Status:      "running",
+				Directories: scan.directories.Load(),
+				StartedAt:   timestamppb.New(scan.started),
+				Elapsed:     durationpb.New(now.Sub(scan.started)),
+			}, true
+		}
+	}
+	for _, o := range d.lastScans {
+		if o.scanID == scanID {
+			return &usgmonv1.ScanProgress{
+				ScanId:      scanID,
+				Path:        o.cfg.Path,
+				Depth:       int32(o.cfg.Depth),
+				Trigger:     o.trigger,
+				Status:      o.status,
+				Directories: int64(o.directories),
+				StartedAt:   timestamppb.New(o.started),
+				Elapsed:     durationpb.New(o.finished.Sub(o.started)),
+				Error:       o.err,
+			}, true
+		}
+	}
+	return nil, false
+}
+
+// grpcLimit validates a request's limit, returning def when it is unset.
+func grpcLimit(limit int32, def int) (int, error) {
+	switch {
+	case limit < 0:
+		return 0, status.Error(codes.InvalidArgument, "limit must be non-negative")
+	case limit == 0:
+		return def, nil
+	}
+	return int(limit), nil
+}
+
+// timeOrNil converts an optional timestamp.
+func timeOrNil(ts *timestamppb.Timestamp) *time.Time {
+	if ts == nil {
+		return nil
+	}
+	t := ts.AsTime()
+	return &t
+}
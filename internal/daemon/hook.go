@@ -0,0 +1,91 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// hookOutputLimit caps how much hook output is included in log lines.
+const hookOutputLimit = 1024
+
+// scanHookInfo describes a completed scan to the post-scan hook.
+type scanHookInfo struct {
+	basePath    string
+	scanID      string
+	trigger     string
+	directories int
+	totalBytes  int64
+	duration    time.Duration
+}
+
+// env returns the hook's USGMON_* environment variables.
+func (h scanHookInfo) env() []string {
+	return []string{
+		"USGMON_BASE_PATH=" + h.basePath,
+		"USGMON_SCAN_ID=" + h.scanID,
+		"USGMON_TRIGGER=" + h.trigger,
+		"USGMON_DIRECTORIES=" + strconv.Itoa(h.directories),
+		"USGMON_TOTAL_BYTES=" + strconv.FormatInt(h.totalBytes, 10),
+		"USGMON_DURATION_SECONDS=" + strconv.FormatFloat(h.duration.Seconds(), 'f', 3, 64),
+	}
+}
+
+// runPostHook runs the configured post-scan hook through /bin/sh with the
+// scan described in its environment. Failures and timeouts are logged; they
+// never affect the scan, which has already been stored.
+func (d *Daemon) runPostHook(ctx context.Context, h scanHookInfo) {
+	command := d.cfg.Scan.PostHook
+	if command == "" {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, d.cfg.Scan.PostHookTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", command)
+	cmd.Env = append(os.Environ(), h.env()...)
+	// Run the hook in its own process group so a timeout kills everything it
+	// started, not just the shell
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	// Don't wait forever on pipes held open by processes that escaped the group
+	cmd.WaitDelay = time.Second
+
+	start := time.Now()
+	output, err := cmd.CombinedOutput()
+	elapsed := time.Since(start).Round(time.Millisecond)
+
+	out := strings.TrimSpace(string(output))
+	if len(out) > hookOutputLimit {
+		out = out[:hookOutputLimit] + "..."
+	}
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		d.logger.Debug("post-scan hook completed", "path", h.basePath, "duration", elapsed)
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		d.logger.Warn("post-scan hook timed out",
+			"path", h.basePath,
+			"timeout", d.cfg.Scan.PostHookTimeout,
+			"output", out,
+		)
+	case errors.As(err, &exitErr):
+		d.logger.Warn("post-scan hook failed",
+			"path", h.basePath,
+			"exit_code", exitErr.ExitCode(),
+			"duration", elapsed,
+			"output", out,
+		)
+	default:
+		d.logger.Warn("post-scan hook could not be run", "path", h.basePath, "error", err)
+	}
+}
@@ -0,0 +1,202 @@
+package daemon
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/jgalley/usgmon/internal/agent"
+	"github.com/jgalley/usgmon/pkg/storage"
+)
+
+// maxIngestBody bounds the size of a push from an agent.
+const maxIngestBody = 32 << 20
+
+// registerIngest adds the endpoints agents push scans to. Each requires
+// api.agent_token as a bearer token.
+func (d *Daemon) registerIngest(mux *http.ServeMux) {
+	mux.HandleFunc("POST /api/v1/ingest/scans", d.requireAgent(d.handleIngestScan))
+	mux.HandleFunc("POST /api/v1/ingest/scans/{id}/usage", d.requireAgent(d.handleIngestUsage))
+	mux.HandleFunc("POST /api/v1/ingest/scans/{id}/cpu", d.requireAgent(d.handleIngestCPU))
+	mux.HandleFunc("POST /api/v1/ingest/scans/{id}/finish", d.requireAgent(d.handleIngestFinish))
+}
+
+// requireAgent rejects requests without the agent token, and all pushes
+// while the daemon is discarding results, as it has nowhere to put them.
+func (d *Daemon) requireAgent(next http.HandlerFunc) http.HandlerFunc {
+	want := []byte("Bearer " + d.cfg.API.AgentToken)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			writeError(w, http.StatusUnauthorized, "invalid agent token")
+			return
+		}
+		if d.discard {
+			writeError(w, http.StatusServiceUnavailable, "server is not storing results")
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxIngestBody)
+		next(w, r)
+	}
+}
+
+// handleIngestScan records the start of an agent's scan. Repeating it for the
+// same scan ID is harmless, but another host cannot reuse the ID.
+func (d *Daemon) handleIngestScan(w http.ResponseWriter, r *http.Request) {
+	var s agent.Scan
+	if !decodeIngest(w, r, &s) {
+		return
+	}
+	if s.ScanID == "" || s.Hostname == "" || s.BasePath == "" || s.StartedAt.IsZero() {
+		writeError(w, http.StatusBadRequest, "scan_id, hostname, base_path and started_at are required")
+		return
+	}
+	found, err := d.storage.ListScans(r.Context(), storage.ScanListOptions{ScanID: s.ScanID, Limit: 1})
+	if err != nil {
+		d.logger.Error("failed to look up agent scan", "scan_id", s.ScanID, "error", err)
+		writeError(w, http.StatusInternalServerError, "looking up scan failed")
+		return
+	}
+	if len(found) > 0 && found[0].Hostname != s.Hostname {
+		writeError(w, http.StatusConflict, "scan belongs to another host")
+		return
+	}
+
+	if _, err := d.storage.StartScan(r.Context(), s.BasePath, storage.StartScanOptions{
+		ScanID:    s.ScanID,
+		StartedAt: s.StartedAt,
+		Hostname:  s.Hostname,
+		Trigger:   s.Trigger,
+		Note:      s.Note,
+		Metadata:  s.Metadata,
+	}); err != nil {
+		d.logger.Error("failed to record agent scan", "hostname", s.Hostname, "scan_id", s.ScanID, "error", err)
+		writeError(w, http.StatusInternalServerError, "recording scan failed")
+		return
+	}
+	d.logger.Debug("agent scan started", "hostname", s.Hostname, "path", s.BasePath, "scan_id", s.ScanID)
+	writeJSON(w, http.StatusCreated, map[string]string{"scan_id": s.ScanID})
+}
+
+// handleIngestUsage records a batch of an agent scan's measurements.
+func (d *Daemon) handleIngestUsage(w http.ResponseWriter, r *http.Request) {
+	scanID := r.PathValue("id")
+	var u agent.Usage
+	if !decodeIngest(w, r, &u) {
+		return
+	}
+	if u.Hostname == "" || u.Batch < 1 {
+		writeError(w, http.StatusBadRequest, "hostname and a batch number of at least 1 are required")
+		return
+	}
+	if !d.ingestScan(w, r, scanID, u.Hostname) {
+		return
+	}
+
+	records := make([]storage.UsageRecord, len(u.Records))
+	for i, rec := range u.Records {
+		if rec.Directory == "" || rec.BasePath == "" || rec.RecordedAt.IsZero() {
+			writeError(w, http.StatusBadRequest, "records need base_path, directory and recorded_at")
+			return
+		}
+		records[i] = rec.UsageRecord(scanID, u.Hostname)
+	}
+	stored, err := d.storage.RecordIngestedUsage(r.Context(), scanID, u.Batch, records)
+	if err != nil {
+		d.logger.Error("failed to record agent usage", "hostname", u.Hostname, "scan_id", scanID, "error", err)
+		writeError(w, http.StatusInternalServerError, "recording usage failed")
+		return
+	}
+	if !stored {
+		d.logger.Debug("agent usage batch already recorded", "hostname", u.Hostname, "scan_id", scanID, "batch", u.Batch)
+		writeJSON(w, http.StatusOK, map[string]int{"recorded": 0})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]int{"recorded": len(records)})
+}
+
+// handleIngestCPU records the CPU time an agent's scan consumed.
+func (d *Daemon) handleIngestCPU(w http.ResponseWriter, r *http.Request) {
+	scanID := r.PathValue("id")
+	var c agent.CPU
+	if !decodeIngest(w, r, &c) || !d.ingestScan(w, r, scanID, c.Hostname) {
+		return
+	}
+	user := time.Duration(c.UserMS) * time.Millisecond
+	system := time.Duration(c.SystemMS) * time.Millisecond
+	if err := d.storage.RecordScanCPU(r.Context(), scanID, user, system); err != nil {
+		d.logger.Error("failed to record agent scan cpu time", "scan_id", scanID, "error", err)
+		writeError(w, http.StatusInternalServerError, "recording cpu time failed")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleIngestFinish marks an agent's scan completed, failed or skipped.
+func (d *Daemon) handleIngestFinish(w http.ResponseWriter, r *http.Request) {
+	scanID := r.PathValue("id")
+	var f agent.Finish
+	if !decodeIngest(w, r, &f) || !d.ingestScan(w, r, scanID, f.Hostname) {
+		return
+	}
+
+	var err error
+	switch f.Status {
+	case "completed":
+		err = d.storage.CompleteScan(r.Context(), scanID, f.Directories)
+	case "failed":
+		err = d.storage.FailScan(r.Context(), scanID, f.Reason)
+	case "skipped":
+		err = d.storage.SkipScan(r.Context(), scanID, f.Reason)
+	default:
+		writeError(w, http.StatusBadRequest, "status must be completed, failed or skipped")
+		return
+	}
+	if err != nil {
+		d.logger.Error("failed to finish agent scan", "scan_id", scanID, "error", err)
+		writeError(w, http.StatusInternalServerError, "finishing scan failed")
+		return
+	}
+	d.logger.Info("agent scan finished", "scan_id", scanID, "status", f.Status, "directories", f.Directories)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ingestScan checks that a push is for a scan started by the pushing host,
+// answering the request itself if it is not.
+func (d *Daemon) ingestScan(w http.ResponseWriter, r *http.Request, scanID, hostname string) bool {
+	if hostname == "" {
+		writeError(w, http.StatusBadRequest, "hostname is required")
+		return false
+	}
+	found, err := d.storage.ListScans(r.Context(), storage.ScanListOptions{ScanID: scanID, Limit: 1})
+	if err != nil {
+		d.logger.Error("failed to look up agent scan", "scan_id", scanID, "error", err)
+		writeError(w, http.StatusInternalServerError, "looking up scan failed")
+		return false
+	}
+	if len(found) == 0 {
+		writeError(w, http.StatusNotFound, "scan not found")
+		return false
+	}
+	if found[0].Hostname != hostname {
+		writeError(w, http.StatusConflict, "scan belongs to another host")
+		return false
+	}
+	return true
+}
+
+// decodeIngest decodes a push body into v, answering the request itself if
+// the body is invalid.
+func decodeIngest(w http.ResponseWriter, r *http.Request, v any) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		status := http.StatusBadRequest
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		writeError(w, status, "invalid body: "+err.Error())
+		return false
+	}
+	return true
+}
@@ -0,0 +1,105 @@
+package daemon
+
+import (
+	"context"
+	"time"
+
+	"github.com/jgalley/usgmon/internal/config"
+	"github.com/jgalley/usgmon/internal/webhook"
+	"github.com/jgalley/usgmon/pkg/storage"
+)
+
+// webhookOptions returns the delivery settings for webhooks posting to urls.
+func (d *Daemon) webhookOptions(urls []string) webhook.Options {
+	return webhook.Options{
+		URLs:       urls,
+		Timeout:    d.cfg.Webhooks.Timeout,
+		Retries:    d.cfg.Webhooks.Retries,
+		RetryDelay: d.cfg.Webhooks.RetryDelay,
+	}
+}
+
+// closeNotifiers waits for pending webhook, Slack and email deliveries and
+// pushes to the central server, giving up on retries after one delivery
+// timeout so shutdown is not held up for long.
+func (d *Daemon) closeNotifiers() {
+	ctx, cancel := context.WithTimeout(context.Background(), max(d.cfg.Webhooks.Timeout, d.cfg.Email.Timeout, d.cfg.Agent.Timeout))
+	defer cancel()
+	if d.webhook != nil {
+		d.webhook.Close(ctx)
+	}
+	if d.slack != nil {
+		d.slack.Close(ctx)
+	}
+	if d.emailed != nil {
+		d.emailed.Close(ctx)
+	}
+	if d.pusher != nil {
+		d.pusher.Close(ctx)
+	}
+}
+
+// sizeHistory returns up to limit stored sizes of directory, newest first,
+// for Slack sparklines.
+func (d *Daemon) sizeHistory(ctx context.Context, directory string, limit int) ([]int64, error) {
+	records, err := d.storage.QueryUsage(ctx, storage.QueryOptions{Directory: directory, Limit: limit})
+	if err != nil {
+		return nil, err
+	}
+	sizes := make([]int64, 0, len(records))
+	for _, r := range records {
+		if !r.Deleted && r.FileFilter == "" {
+			sizes = append(sizes, r.SizeBytes)
+		}
+	}
+	return sizes, nil
+}
+
+// topChangers returns the directories under pathCfg whose size changed most
+// between the previous completed scan and scanID, for the scan_completed
+// webhook. It returns nil on the first scan of a path or on error.
+func (d *Daemon) topChangers(ctx context.Context, pathCfg config.PathConfig, scanID string) []webhook.Changer {
+	if d.cfg.Webhooks.TopChangers == 0 {
+		return nil
+	}
+
+	scans, err := d.storage.ListScans(ctx, storage.ScanListOptions{BasePath: pathCfg.Path, Limit: 10})
+	if err != nil {
+		d.logger.Warn("failed to list scans for webhook", "path", pathCfg.Path, "error", err)
+		return nil
+	}
+	var since time.Time
+	for _, sc := range scans {
+		if sc.ScanID != scanID && sc.Status == "completed" {
+			since = sc.StartedAt
+			break
+		}
+	}
+	if since.IsZero() {
+		return nil
+	}
+
+	changes, err := d.storage.GetTopChangers(ctx, storage.TopChangerOptions{
+		BasePath:       pathCfg.Path,
+		Since:          since,
+		Until:          time.Now(),
+		Direction:      "both",
+		MinChangeBytes: 1,
+		Limit:          d.cfg.Webhooks.TopChangers,
+	})
+	if err != nil {
+		d.logger.Warn("failed to find top changers for webhook", "path", pathCfg.Path, "error", err)
+		return nil
+	}
+
+	changers := make([]webhook.Changer, len(changes))
+	for i, c := range changes {
+		changers[i] = webhook.Changer{
+			Directory:   c.Directory,
+			OldBytes:    c.StartSize,
+			NewBytes:    c.EndSize,
+			ChangeBytes: c.ChangeBytes,
+		}
+	}
+	return changers
+}
@@ -0,0 +1,85 @@
+package daemon
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/jgalley/usgmon/internal/config"
+	"github.com/jgalley/usgmon/pkg/scanner"
+)
+
+// resolvedRoot returns a configured path with symlinks resolved, so paths
+// that reach the same tree by different names are compared correctly.
+func resolvedRoot(path string) string {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		return resolved
+	}
+	return filepath.Clean(path)
+}
+
+// withinTree reports whether path is root or lies below it.
+func withinTree(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, "../")
+}
+
+// pathsOverlap reports whether one configured path lies within the other.
+func pathsOverlap(a, b config.PathConfig) bool {
+	ra, rb := resolvedRoot(a.Path), resolvedRoot(b.Path)
+	return withinTree(ra, rb) || withinTree(rb, ra)
+}
+
+// warnOverlappingPaths logs every pair of configured paths where one lies
+// within the other, since directories reachable from both are measured and
+// stored under each base path unless scan.dedupe_paths is set.
+func (d *Daemon) warnOverlappingPaths(paths []config.PathConfig) {
+	for i, a := range paths {
+		for _, b := range paths[i+1:] {
+			if !pathsOverlap(a, b) {
+				continue
+			}
+			if d.cfg.Scan.DedupePaths {
+				d.logger.Info("configured paths overlap; directories reachable from both are measured only under the first",
+					"first", a.Path, "second", b.Path)
+				continue
+			}
+			d.logger.Warn("configured paths overlap; directories reachable from both may be measured and stored twice",
+				"first", a.Path, "second", b.Path,
+				"hint", "set scan.dedupe_paths to measure each directory once")
+		}
+	}
+}
+
+// claimedDirs returns the directories that paths configured before pathCfg
+// and overlapping it would measure, for pathCfg's scan to leave out. It
+// returns nil when no earlier path overlaps. Each directory is thereby
+// measured by the first configured path that reaches it.
+func (d *Daemon) claimedDirs(pathCfg config.PathConfig) *scanner.DirSet {
+	var claimed *scanner.DirSet
+	paths, _ := d.pathSettings()
+	for _, p := range paths {
+		if p.Path == pathCfg.Path && p.Depth == pathCfg.Depth {
+			break
+		}
+		if !pathsOverlap(p, pathCfg) {
+			continue
+		}
+		dirs, err := scanner.Directories(p.Path, p.Depth, scanner.ScanOptions{
+			FollowSymlinks: p.FollowSymlinks,
+			OneFileSystem:  p.OneFileSystem,
+			Exclude:        p.Exclude,
+		})
+		if err != nil {
+			d.logger.Warn("failed to list overlapping path; its directories will not be deduplicated",
+				"path", pathCfg.Path, "overlapping_path", p.Path, "error", err)
+			continue
+		}
+		if claimed == nil {
+			claimed = scanner.NewDirSet()
+		}
+		for _, dir := range dirs {
+			claimed.Add(dir)
+		}
+	}
+	return claimed
+}
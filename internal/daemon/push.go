@@ -0,0 +1,107 @@
+package daemon
+
+import (
+	"context"
+	"time"
+
+	"github.com/jgalley/usgmon/internal/agent"
+	"github.com/jgalley/usgmon/pkg/storage"
+)
+
+// agentOptions returns the push settings of agent mode.
+func (d *Daemon) agentOptions() agent.Options {
+	return agent.Options{
+		Server:     d.cfg.Agent.Server,
+		Token:      d.cfg.Agent.Token,
+		Hostname:   d.hostname,
+		Timeout:    d.cfg.Agent.Timeout,
+		Retries:    d.cfg.Agent.Retries,
+		RetryDelay: d.cfg.Agent.RetryDelay,
+	}
+}
+
+// pushStorage writes scans and usage records to the underlying storage and
+// also pushes them to the central server. Everything else, including the
+// reads the daemon relies on, stays local.
+type pushStorage struct {
+	storage.Storage
+	pusher *agent.Pusher
+}
+
+func (s *pushStorage) StartScan(ctx context.Context, basePath string, opts storage.StartScanOptions) (string, error) {
+	// Share the start time so both databases agree
+	if opts.StartedAt.IsZero() {
+		opts.StartedAt = time.Now()
+	}
+	scanID, err := s.Storage.StartScan(ctx, basePath, opts)
+	if err != nil {
+		return "", err
+	}
+	s.pusher.StartScan(agent.Scan{
+		ScanID:    scanID,
+		BasePath:  basePath,
+		StartedAt: opts.StartedAt,
+		Trigger:   opts.Trigger,
+		Note:      opts.Note,
+		Metadata:  opts.Metadata,
+	})
+	return scanID, nil
+}
+
+func (s *pushStorage) CompleteScan(ctx context.Context, scanID string, directoriesScanned int) error {
+	if err := s.Storage.CompleteScan(ctx, scanID, directoriesScanned); err != nil {
+		return err
+	}
+	s.pusher.Finish(scanID, agent.Finish{Status: "completed", Directories: directoriesScanned})
+	return nil
+}
+
+func (s *pushStorage) RecordScanCPU(ctx context.Context, scanID string, user, system time.Duration) error {
+	if err := s.Storage.RecordScanCPU(ctx, scanID, user, system); err != nil {
+		return err
+	}
+	s.pusher.ScanCPU(scanID, user, system)
+	return nil
+}
+
+func (s *pushStorage) FailScan(ctx context.Context, scanID string, reason string) error {
+	if err := s.Storage.FailScan(ctx, scanID, reason); err != nil {
+		return err
+	}
+	s.pusher.Finish(scanID, agent.Finish{Status: "failed", Reason: reason})
+	return nil
+}
+
+func (s *pushStorage) SkipScan(ctx context.Context, scanID string, reason string) error {
+	if err := s.Storage.SkipScan(ctx, scanID, reason); err != nil {
+		return err
+	}
+	s.pusher.Finish(scanID, agent.Finish{Status: "skipped", Reason: reason})
+	return nil
+}
+
+func (s *pushStorage) FailStaleScans(ctx context.Context, hostname, reason string, deleteRecords bool) ([]string, int64, error) {
+	ids, records, err := s.Storage.FailStaleScans(ctx, hostname, reason, deleteRecords)
+	if err != nil {
+		return nil, 0, err
+	}
+	// The central server's copies are failed too, but keep their records
+	for _, id := range ids {
+		s.pusher.Finish(id, agent.Finish{Status: "failed", Reason: reason})
+	}
+	return ids, records, nil
+}
+
+func (s *pushStorage) RecordUsage(ctx context.Context, record storage.UsageRecord) error {
+	return s.RecordUsageBatch(ctx, []storage.UsageRecord{record})
+}
+
+func (s *pushStorage) RecordUsageBatch(ctx context.Context, records []storage.UsageRecord) error {
+	if err := s.Storage.RecordUsageBatch(ctx, records); err != nil {
+		return err
+	}
+	if len(records) > 0 {
+		s.pusher.Usage(records[0].ScanID, records)
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package daemon
+
+import (
+	"time"
+
+	"github.com/jgalley/usgmon/pkg/scanner"
+	"github.com/jgalley/usgmon/pkg/storage"
+)
+
+// goneRecords returns deletion markers for directories in the previous
+// snapshot that a completed scan no longer found. Directories the scan was
+// told to leave out (exclusions, the skip list, directories claimed by an
+// overlapping path) were not looked for, so they are never marked gone.
+func (d *Daemon) goneRecords(previous map[string]storage.UsageRecord, seen map[string]bool, opts scanner.ScanOptions, basePath, scanID string) []storage.UsageRecord {
+	var gone []storage.UsageRecord
+	now := time.Now().UTC()
+	for dir := range previous {
+		if seen[dir] || opts.Excludes(dir) || opts.SkipDirs.Contains(dir) {
+			continue
+		}
+		gone = append(gone, storage.UsageRecord{
+			BasePath:   basePath,
+			Directory:  dir,
+			RecordedAt: now,
+			ScanID:     scanID,
+			Deleted:    true,
+			Hostname:   d.hostname,
+		})
+	}
+	return gone
+}
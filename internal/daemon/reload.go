@@ -0,0 +1,75 @@
+package daemon
+
+import (
+	"reflect"
+
+	"github.com/jgalley/usgmon/internal/config"
+)
+
+// Reload applies a changed configuration to the daemon. Scanners start for
+// added paths and stop for removed ones, cancelling any scan of a removed
+// path. Paths whose settings changed, or that inherit a changed
+// scan.interval, are rescheduled; a scan of such a path that is in progress
+// finishes with the old settings. Other settings only take effect after a
+// restart, which is logged when they differ.
+func (d *Daemon) Reload(cfg *config.Config) {
+	old, updated := *d.cfg, *cfg
+	old.Paths, updated.Paths = nil, nil
+	old.Scan.Interval, updated.Scan.Interval = 0, 0
+	if !reflect.DeepEqual(old, updated) {
+		d.logger.Warn("configuration changes outside paths and scan.interval take effect after a restart")
+	}
+
+	d.sd.Reloading()
+	defer d.sd.Ready()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	intervalChanged := cfg.Scan.Interval != d.interval
+	d.paths = cfg.Paths
+	d.interval = cfg.Scan.Interval
+	d.warnOverlappingPaths(d.paths)
+	if d.runCtx == nil {
+		return
+	}
+
+	want := make(map[string]config.PathConfig, len(cfg.Paths))
+	for _, p := range cfg.Paths {
+		want[scanKey(p)] = p
+	}
+
+	var added, removed, rescheduled int
+	for key, loop := range d.loops {
+		p, ok := want[key]
+		inherits := p.Interval == 0 && p.Schedule == ""
+		switch {
+		case !ok:
+			loop.cancel()
+			delete(d.loops, key)
+			if scan, running := d.scanners[key]; running {
+				scan.cancel()
+			}
+			d.logger.Info("stopped path scanner for removed path", "path", loop.cfg.Path, "depth", loop.cfg.Depth)
+			removed++
+		case !reflect.DeepEqual(p, loop.cfg) || (intervalChanged && inherits):
+			loop.cancel()
+			d.startLoop(p, false)
+			rescheduled++
+		}
+	}
+	for _, p := range cfg.Paths {
+		if _, ok := d.loops[scanKey(p)]; !ok {
+			d.startLoop(p, true)
+			added++
+		}
+	}
+
+	d.nudgeStatus()
+	d.logger.Info("configuration reloaded",
+		"paths", len(cfg.Paths),
+		"added", added,
+		"removed", removed,
+		"rescheduled", rescheduled,
+	)
+}
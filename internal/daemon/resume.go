@@ -0,0 +1,87 @@
+package daemon
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/jgalley/usgmon/internal/config"
+	"github.com/jgalley/usgmon/pkg/storage"
+)
+
+// resumeLookback is how many of a path's latest scans are searched for an
+// interrupted one.
+const resumeLookback = 50
+
+// abandonScan records that the scan scanID stopped before finishing. A scan
+// cut short by shutdown (ctx, the daemon context, is done) is marked
+// interrupted when scan.resume_scans is enabled, so the path's next scan
+// picks it up; any other is failed with reason.
+func (d *Daemon) abandonScan(ctx context.Context, scanID, reason string) {
+	if d.cfg.Scan.ResumeScans && ctx.Err() != nil {
+		if err := d.storage.InterruptScan(context.Background(), scanID); err != nil {
+			d.logger.Error("failed to mark scan as interrupted", "error", err)
+			return
+		}
+		d.logger.Info("scan interrupted, the path's next scan resumes it", "scan_id", scanID)
+		return
+	}
+	if err := d.storage.FailScan(context.Background(), scanID, reason); err != nil {
+		d.logger.Error("failed to mark scan as failed", "error", err)
+	}
+}
+
+// resumableScan returns the ID of the interrupted scan of pathCfg to resume,
+// or "" to start a new one. Only the latest full scan of the path made on
+// this host is resumed, and only if it started within scan.resume_max_age;
+// interrupted scans passed over are failed, as nothing will resume them.
+// Scans do not record their depth, so paths configured at several depths
+// are never resumed.
+func (d *Daemon) resumableScan(ctx context.Context, pathCfg config.PathConfig) string {
+	if !d.cfg.Scan.ResumeScans || d.discard || !d.uniqueBasePath(pathCfg) {
+		return ""
+	}
+	scans, err := d.storage.ListScans(ctx, storage.ScanListOptions{BasePath: pathCfg.Path, Limit: resumeLookback})
+	if err != nil {
+		d.logger.Warn("failed to look for an interrupted scan", "path", pathCfg.Path, "error", err)
+		return ""
+	}
+
+	var resume string
+	superseded := false
+	for _, sc := range scans {
+		if sc.Hostname != "" && sc.Hostname != d.hostname {
+			continue
+		}
+		if sc.Status != "interrupted" {
+			// Watch samples and skipped scans do not replace a full scan
+			if sc.Trigger != storage.TriggerWatch && !strings.HasPrefix(sc.Status, "skipped: ") {
+				superseded = true
+			}
+			continue
+		}
+		if resume == "" && !superseded && time.Since(sc.StartedAt) <= d.cfg.Scan.ResumeMaxAge {
+			resume = sc.ScanID
+			continue
+		}
+		d.logger.Info("not resuming interrupted scan", "path", pathCfg.Path, "scan_id", sc.ScanID,
+			"started_at", sc.StartedAt)
+		if err := d.storage.FailScan(ctx, sc.ScanID, "interrupted"); err != nil {
+			d.logger.Error("failed to mark scan as failed", "error", err)
+		}
+	}
+	return resume
+}
+
+// uniqueBasePath reports whether pathCfg is the only configured path with
+// its base path.
+func (d *Daemon) uniqueBasePath(pathCfg config.PathConfig) bool {
+	paths, _ := d.pathSettings()
+	n := 0
+	for _, p := range paths {
+		if p.Path == pathCfg.Path {
+			n++
+		}
+	}
+	return n == 1
+}
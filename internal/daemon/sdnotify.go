@@ -0,0 +1,101 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jgalley/usgmon/internal/systemd"
+	"github.com/jgalley/usgmon/pkg/storage"
+)
+
+// systemdStatusInterval is how often the status shown by systemctl status
+// is refreshed while nothing else updates it, so directory counts of
+// running scans keep moving.
+const systemdStatusInterval = 10 * time.Second
+
+// superviseSystemd keeps systemd informed until ctx is done: it updates the
+// service status whenever scans start or finish, and, if WatchdogSec= is
+// set, sends watchdog keepalives at half that interval. A keepalive is only
+// sent while the daemon's state can be locked and the database answers
+// within the interval, so a daemon that has hung is restarted.
+func (d *Daemon) superviseSystemd(ctx context.Context) {
+	tick := systemdStatusInterval
+	watchdog, watched := systemd.WatchdogInterval()
+	if watched {
+		tick = min(tick, watchdog/2)
+		d.logger.Info("systemd watchdog enabled", "interval", watchdog)
+	}
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+
+	for {
+		if err := d.sd.Status(d.statusLine()); err != nil {
+			d.logger.Debug("failed to send status to systemd", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-d.statusCh:
+			continue
+		case <-ticker.C:
+		}
+
+		if !watched {
+			continue
+		}
+		if err := d.checkHealth(ctx, watchdog); err != nil {
+			if ctx.Err() == nil {
+				d.logger.Warn("health check failed, withholding watchdog keepalive", "error", err)
+			}
+			continue
+		}
+		if err := d.sd.Watchdog(); err != nil {
+			d.logger.Warn("failed to send watchdog keepalive", "error", err)
+		}
+	}
+}
+
+// checkHealth checks that the database answers a query within timeout.
+func (d *Daemon) checkHealth(ctx context.Context, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	if _, err := d.storage.ListScans(ctx, storage.ScanListOptions{Limit: 1}); err != nil {
+		return fmt.Errorf("querying database: %w", err)
+	}
+	return nil
+}
+
+// statusLine describes what the daemon is doing: the paths being scanned
+// with their directories measured so far, or that it is idle.
+func (d *Daemon) statusLine() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.scanners) == 0 {
+		return fmt.Sprintf("idle; monitoring %d paths", len(d.paths))
+	}
+
+	keys := make([]string, 0, len(d.scanners))
+	for key := range d.scanners {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	scans := make([]string, len(keys))
+	for i, key := range keys {
+		scan := d.scanners[key]
+		scans[i] = fmt.Sprintf("%s (%d directories)", scan.cfg.Path, scan.directories.Load())
+	}
+	return "scanning " + strings.Join(scans, ", ")
+}
+
+// nudgeStatus has superviseSystemd send a fresh status now. It does not
+// block, so it may be called with d.mu held.
+func (d *Daemon) nudgeStatus() {
+	select {
+	case d.statusCh <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,257 @@
+package daemon
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jgalley/usgmon/internal/config"
+	"github.com/jgalley/usgmon/internal/fswatch"
+	"github.com/jgalley/usgmon/internal/humanize"
+	"github.com/jgalley/usgmon/pkg/scanner"
+	"github.com/jgalley/usgmon/pkg/storage"
+)
+
+// watchPath watches a path's tree until ctx is done (paths[].watch). Every
+// scan.watch_interval, the measured directories in which something changed
+// are measured again, and those whose size moved by scan.watch_threshold or
+// more since their last record are stored in a scan with the watch trigger,
+// so a disk filling up between full scans shows in the history. No samples
+// are taken while a full scan of the path runs; changes seen meanwhile are
+// measured once it has finished.
+func (d *Daemon) watchPath(ctx context.Context, pathCfg config.PathConfig) {
+	opts := d.scanOptions(pathCfg, d.skippedDirs(ctx, pathCfg))
+	w, err := fswatch.New(pathCfg.Path, fswatch.Options{
+		OneFileSystem: pathCfg.OneFileSystem,
+		Skip:          opts.Excludes,
+	})
+	if err != nil {
+		d.logger.Warn("cannot watch path, changes are only seen by full scans", "path", pathCfg.Path, "error", err)
+		return
+	}
+	defer w.Close()
+
+	interval, threshold := d.cfg.Scan.WatchInterval, int64(d.cfg.Scan.WatchThreshold)
+	d.logger.Info("watching path for changes",
+		"path", pathCfg.Path,
+		"interval", interval,
+		"threshold", humanize.FormatSize(threshold),
+	)
+
+	// With exclude_files there is no comparable history, so sizes are
+	// compared with the watcher's own earlier measurements
+	var measured map[string]int64
+	if len(pathCfg.ExcludeFiles) > 0 {
+		measured = make(map[string]int64)
+	}
+
+	unwatched := 0
+	pending := make(map[string]bool)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if n := w.Unwatched(); n > unwatched {
+			d.logger.Warn("inotify watch limit reached, raise fs.inotify.max_user_watches",
+				"path", pathCfg.Path, "unwatched_directories", n)
+			unwatched = n
+		}
+		dirs, overflow := w.Changes()
+		if overflow {
+			d.logger.Warn("watch events were lost, some changes are only seen by the next full scan", "path", pathCfg.Path)
+		}
+		for _, dir := range dirs {
+			if m, ok := measuredDir(pathCfg.Path, pathCfg.Depth, dir); ok {
+				pending[m] = true
+			}
+		}
+		if len(pending) == 0 || d.scanRunning(pathCfg) {
+			continue
+		}
+
+		d.sampleChanges(ctx, pathCfg, opts, pending, threshold, measured)
+		clear(pending)
+	}
+}
+
+// skippedDirs returns the skip-listed directories of a path, which a watch
+// leaves out along with the path's exclusions.
+func (d *Daemon) skippedDirs(ctx context.Context, pathCfg config.PathConfig) []string {
+	entries, err := d.storage.ListSkipEntries(ctx, pathCfg.Path)
+	if err != nil {
+		d.logger.Warn("failed to load skip list", "path", pathCfg.Path, "error", err)
+	}
+	var skipped []string
+	for _, e := range entries {
+		if e.Skipped {
+			skipped = append(skipped, e.Directory)
+		}
+	}
+	return skipped
+}
+
+// scanRunning reports whether a full scan of the path is in progress.
+func (d *Daemon) scanRunning(pathCfg config.PathConfig) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, ok := d.scanners[scanKey(pathCfg)]
+	return ok
+}
+
+// measuredDir returns the directory measured at depth below base that
+// contains dir. Changes above that depth, which add or remove measured
+// directories, are left to full scans. With leaf directories (-1), dir is
+// its own measured directory.
+func measuredDir(base string, depth int, dir string) (string, bool) {
+	rel, err := filepath.Rel(base, dir)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", false
+	}
+	if depth < 0 {
+		return dir, true
+	}
+	var parts []string
+	if rel != "." {
+		parts = strings.Split(rel, string(filepath.Separator))
+	}
+	if len(parts) < depth {
+		return "", false
+	}
+	return filepath.Join(append([]string{base}, parts[:depth]...)...), true
+}
+
+// sampleChanges measures the changed directories of a watched path and
+// records those that changed by at least threshold bytes. measured, if not
+// nil, holds the sizes the watcher measured before and is compared with
+// instead of the stored history. opts are the path's scan options.
+func (d *Daemon) sampleChanges(ctx context.Context, pathCfg config.PathConfig, opts scanner.ScanOptions, changed map[string]bool, threshold int64, measured map[string]int64) {
+	var previous map[string]storage.UsageRecord
+	if measured == nil {
+		records, err := d.storage.GetSnapshotAt(ctx, pathCfg.Path, d.hostname, time.Now())
+		if err != nil {
+			d.logger.Warn("failed to load previous sizes", "path", pathCfg.Path, "error", err)
+			return
+		}
+		previous = make(map[string]storage.UsageRecord, len(records))
+		for _, r := range records {
+			previous[r.Directory] = r
+		}
+	}
+
+	dirs := make([]string, 0, len(changed))
+	for dir := range changed {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+
+	if d.ioGate != nil {
+		opts.Throttle = d.ioGate
+	}
+	var results []scanner.Result
+	for _, dir := range dirs {
+		var prev int64
+		var known bool
+		if measured != nil {
+			prev, known = measured[dir]
+		} else if r, ok := previous[dir]; ok {
+			prev, known = r.SizeBytes, true
+		}
+		// With leaf directories, a directory without history may not be a
+		// leaf; new ones are picked up by the next full scan
+		if (!known && pathCfg.Depth < 0) || opts.Excludes(dir) {
+			continue
+		}
+
+		r, err := d.scanner.ScanSingleWithOptions(ctx, dir, opts)
+		if err == nil {
+			err = r.Error
+		}
+		if err != nil {
+			// Removed directories are marked by the next full scan
+			d.logger.Debug("failed to measure changed directory", "directory", dir, "error", err)
+			continue
+		}
+		if measured != nil {
+			measured[dir] = r.SizeBytes
+			if !known {
+				continue
+			}
+		}
+
+		delta := r.SizeBytes - prev
+		if delta < 0 {
+			delta = -delta
+		}
+		d.logger.Debug("measured changed directory",
+			"directory", dir,
+			"size_bytes", r.SizeBytes,
+			"previous_bytes", prev,
+		)
+		if delta >= threshold {
+			results = append(results, r)
+		}
+	}
+	if len(results) == 0 || ctx.Err() != nil {
+		return
+	}
+	d.recordSamples(ctx, pathCfg, opts.SkipPaths, results)
+}
+
+// recordSamples stores watch samples in a scan of their own and checks them
+// against the path's size alerts and growth baselines.
+func (d *Daemon) recordSamples(ctx context.Context, pathCfg config.PathConfig, skipped []string, results []scanner.Result) {
+	startOpts := storage.StartScanOptions{Trigger: storage.TriggerWatch, Hostname: d.hostname}
+	if d.cfg.Scan.RecordMetadata {
+		startOpts.Metadata = d.scanMetadata(pathCfg, skipped)
+	}
+	scanID, err := d.storage.StartScan(ctx, pathCfg.Path, startOpts)
+	if err != nil {
+		d.logger.Error("failed to create scan record", "error", err)
+		return
+	}
+
+	records := make([]storage.UsageRecord, 0, len(results))
+	for _, r := range results {
+		records = append(records, d.usageRecord(pathCfg, r, scanID))
+	}
+	if err := d.storage.RecordUsageBatch(ctx, records); err != nil {
+		d.logger.Error("failed to store watch samples", "path", pathCfg.Path, "error", err)
+		if err := d.storage.FailScan(context.Background(), scanID, err.Error()); err != nil {
+			d.logger.Error("failed to mark scan as failed", "error", err)
+		}
+		return
+	}
+	if err := d.storage.CompleteScan(ctx, scanID, len(records)); err != nil {
+		d.logger.Error("failed to complete scan", "error", err)
+		return
+	}
+
+	if pathCfg.AlertAbove > 0 || d.cfg.Alerts.QuotaPercent > 0 {
+		alerting, err := d.alerts.Load(ctx, pathCfg.Path)
+		if err != nil {
+			d.logger.Warn("failed to load alert state", "path", pathCfg.Path, "error", err)
+		}
+		for _, r := range results {
+			d.evaluateAlert(ctx, pathCfg, r, alerting)
+		}
+	}
+	for _, r := range results {
+		d.evaluateAnomaly(ctx, pathCfg, r, scanID, nil)
+	}
+
+	for _, r := range results {
+		d.logger.Info("recorded watch sample",
+			"path", pathCfg.Path,
+			"directory", r.Path,
+			"size_bytes", r.SizeBytes,
+			"size_human", humanize.FormatSize(r.SizeBytes),
+		)
+	}
+}
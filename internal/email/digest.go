@@ -0,0 +1,149 @@
+package email
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jgalley/usgmon/internal/humanize"
+	"github.com/jgalley/usgmon/pkg/storage"
+)
+
+// maxDigestScans bounds the scans read per path when counting a digest's
+// scans.
+const maxDigestScans = 1000
+
+// Digest summarizes usage changes over a time window, per base path.
+type Digest struct {
+	Since time.Time
+	Until time.Time
+	Paths []DigestPath
+}
+
+// DigestPath is the part of a digest covering one base path.
+type DigestPath struct {
+	BasePath    string
+	Completed   int // scans completed in the window
+	Failed      int // scans failed or cancelled in the window
+	Skipped     int // scans skipped in the window, e.g. while a previous one was still running
+	TopChangers []storage.DirectoryChange
+	Alerts      []storage.AlertState // directories over their threshold at the end of the window
+}
+
+// BuildDigest collects the scans, top changers and alerting directories of
+// each base path between since and until, listing up to top changers per
+// path.
+func BuildDigest(ctx context.Context, store storage.Storage, basePaths []string, since, until time.Time, top int) (*Digest, error) {
+	d := &Digest{Since: since, Until: until}
+	for _, basePath := range basePaths {
+		p := DigestPath{BasePath: basePath}
+
+		scans, err := store.ListScans(ctx, storage.ScanListOptions{BasePath: basePath, Limit: maxDigestScans})
+		if err != nil {
+			return nil, fmt.Errorf("listing scans of %s: %w", basePath, err)
+		}
+		for _, sc := range scans {
+			if sc.StartedAt.Before(since) || sc.StartedAt.After(until) {
+				continue
+			}
+			switch sc.Status {
+			case "completed":
+				p.Completed++
+			case "running", "interrupted":
+			default:
+				if strings.HasPrefix(sc.Status, "skipped: ") {
+					p.Skipped++
+					continue
+				}
+				p.Failed++
+			}
+		}
+
+		p.TopChangers, err = store.GetTopChangers(ctx, storage.TopChangerOptions{
+			BasePath:       basePath,
+			Since:          since,
+			Until:          until,
+			Direction:      "both",
+			MinChangeBytes: 1,
+			Limit:          top,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("finding top changers of %s: %w", basePath, err)
+		}
+
+		p.Alerts, err = store.ListAlertStates(ctx, basePath)
+		if err != nil {
+			return nil, fmt.Errorf("listing alerts of %s: %w", basePath, err)
+		}
+
+		d.Paths = append(d.Paths, p)
+	}
+	return d, nil
+}
+
+// Subject returns the digest email's subject line.
+func (d *Digest) Subject(hostname string) string {
+	subject := "[usgmon] Usage digest"
+	if hostname != "" {
+		subject += " for " + hostname
+	}
+	return subject + ", " + d.Until.Local().Format("2006-01-02")
+}
+
+// Text renders the digest as plain text.
+func (d *Digest) Text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Disk usage changes from %s to %s.\n",
+		d.Since.Local().Format("2006-01-02 15:04"), d.Until.Local().Format("2006-01-02 15:04"))
+	if len(d.Paths) == 0 {
+		b.WriteString("\nNo paths are configured.\n")
+	}
+
+	for _, p := range d.Paths {
+		fmt.Fprintf(&b, "\n%s\n%s\n", p.BasePath, strings.Repeat("=", len(p.BasePath)))
+		fmt.Fprintf(&b, "Scans: %d completed", p.Completed)
+		if p.Failed > 0 {
+			fmt.Fprintf(&b, ", %d failed", p.Failed)
+		}
+		if p.Skipped > 0 {
+			fmt.Fprintf(&b, ", %d skipped", p.Skipped)
+		}
+		b.WriteString("\n\n")
+
+		if len(p.TopChangers) == 0 {
+			b.WriteString("No size changes recorded.\n")
+		} else {
+			b.WriteString("Top changers:\n")
+			w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "  DIRECTORY\tBEFORE\tAFTER\tCHANGE")
+			for _, c := range p.TopChangers {
+				fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", c.Directory,
+					humanize.FormatSize(c.StartSize), humanize.FormatSize(c.EndSize), formatChange(c.ChangeBytes))
+			}
+			w.Flush()
+		}
+
+		if len(p.Alerts) > 0 {
+			b.WriteString("\nOver their alert threshold:\n")
+			w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "  DIRECTORY\tSIZE\tTHRESHOLD\tSINCE")
+			for _, a := range p.Alerts {
+				fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", a.Directory,
+					humanize.FormatSize(a.SizeBytes), humanize.FormatSize(a.ThresholdBytes), a.Since.Local().Format("2006-01-02 15:04"))
+			}
+			w.Flush()
+		}
+	}
+	return b.String()
+}
+
+// formatChange formats a size change with an explicit sign.
+func formatChange(bytes int64) string {
+	sign := "+"
+	if bytes < 0 {
+		sign = ""
+	}
+	return sign + humanize.FormatSize(bytes)
+}
@@ -0,0 +1,217 @@
+// Package email sends alert notifications and usage digests over SMTP.
+package email
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jgalley/usgmon/internal/alert"
+	"github.com/jgalley/usgmon/internal/humanize"
+)
+
+// Options configures a Mailer.
+type Options struct {
+	Host     string
+	Port     int
+	Username string // no authentication if empty
+	Password string
+	From     string   // may include a display name
+	To       []string // may include display names
+	TLS      string   // "starttls" (required), "tls" (implicit, usually port 465) or "none"
+	Timeout  time.Duration
+}
+
+// Mailer sends plain-text messages through an SMTP server.
+type Mailer struct {
+	opts     Options
+	hostname string
+}
+
+// NewMailer creates a Mailer.
+func NewMailer(opts Options) *Mailer {
+	hostname, _ := os.Hostname()
+	return &Mailer{opts: opts, hostname: hostname}
+}
+
+// Hostname returns the local host name used in subjects.
+func (m *Mailer) Hostname() string {
+	return m.hostname
+}
+
+// Send delivers a message with the given subject and body to every
+// recipient.
+func (m *Mailer) Send(ctx context.Context, subject, body string) error {
+	addr := net.JoinHostPort(m.opts.Host, strconv.Itoa(m.opts.Port))
+	dialer := &net.Dialer{Timeout: m.opts.Timeout}
+	tlsConfig := &tls.Config{ServerName: m.opts.Host}
+
+	var conn net.Conn
+	var err error
+	if m.opts.TLS == "tls" {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("connecting to %s: %w", addr, err)
+	}
+	// Bound the whole conversation, not just the dial
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	} else if m.opts.Timeout > 0 {
+		conn.SetDeadline(time.Now().Add(m.opts.Timeout))
+	}
+
+	c, err := smtp.NewClient(conn, m.opts.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("starting smtp session: %w", err)
+	}
+	defer c.Close()
+
+	if m.opts.TLS == "starttls" {
+		if err := c.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("starttls: %w", err)
+		}
+	}
+	if m.opts.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", m.opts.Username, m.opts.Password, m.opts.Host)); err != nil {
+			return fmt.Errorf("authenticating: %w", err)
+		}
+	}
+
+	if err := c.Mail(envelopeAddress(m.opts.From)); err != nil {
+		return fmt.Errorf("sender %s: %w", m.opts.From, err)
+	}
+	for _, to := range m.opts.To {
+		if err := c.Rcpt(envelopeAddress(to)); err != nil {
+			return fmt.Errorf("recipient %s: %w", to, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("starting message: %w", err)
+	}
+	if _, err := w.Write(m.message(subject, body)); err != nil {
+		return fmt.Errorf("writing message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("sending message: %w", err)
+	}
+	return c.Quit()
+}
+
+// envelopeAddress strips any display name from addr for the SMTP envelope.
+func envelopeAddress(addr string) string {
+	if a, err := mail.ParseAddress(addr); err == nil {
+		return a.Address
+	}
+	return addr
+}
+
+// message renders the headers and body with CRLF line endings.
+func (m *Mailer) message(subject, body string) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", m.opts.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(m.opts.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(strings.TrimRight(body, "\n"), "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return []byte(b.String())
+}
+
+// AlertNotifier emails each alert event as it happens. Messages are sent in
+// the background so a slow server never holds up a scan.
+type AlertNotifier struct {
+	mailer *Mailer
+	logger *slog.Logger
+	wg     sync.WaitGroup
+}
+
+// NewAlertNotifier creates an AlertNotifier sending through mailer.
+func NewAlertNotifier(mailer *Mailer, logger *slog.Logger) *AlertNotifier {
+	return &AlertNotifier{mailer: mailer, logger: logger}
+}
+
+// Notify emails the event in the background, implementing alert.Notifier.
+// Delivery failures are logged, not returned.
+func (n *AlertNotifier) Notify(ctx context.Context, e alert.Event) error {
+	subject := fmt.Sprintf("[usgmon] Size alert %s: %s", e.Kind, e.Directory)
+
+	var b strings.Builder
+	if e.Kind == alert.Anomaly {
+		subject = fmt.Sprintf("[usgmon] Growth anomaly: %s", e.Directory)
+		fmt.Fprintf(&b, "%s grew far more than it usually does.\n\n", e.Directory)
+		fmt.Fprintf(&b, "Host:       %s\n", n.mailer.hostname)
+		fmt.Fprintf(&b, "Path:       %s\n", e.BasePath)
+		fmt.Fprintf(&b, "Size:       %s (%d bytes)\n", humanize.FormatSize(e.SizeBytes), e.SizeBytes)
+		fmt.Fprintf(&b, "Growth:     %s (%d bytes)\n", humanize.FormatSize(e.GrowthBytes), e.GrowthBytes)
+		fmt.Fprintf(&b, "Usual:      %s ± %s per scan, over %d changes\n",
+			humanize.FormatSize(int64(e.Baseline.Mean)), humanize.FormatSize(int64(e.Baseline.Stddev)), e.Baseline.Samples)
+		fmt.Fprintf(&b, "Measured:   %s\n", e.Since.Local().Format("2006-01-02 15:04 MST"))
+		n.send(subject, b.String(), e)
+		return nil
+	}
+	switch e.Kind {
+	case alert.Recovered:
+		fmt.Fprintf(&b, "%s is back under its size threshold.\n\n", e.Directory)
+	case alert.Reminder:
+		fmt.Fprintf(&b, "%s is still over its size threshold.\n\n", e.Directory)
+	default:
+		fmt.Fprintf(&b, "%s has grown past its size threshold.\n\n", e.Directory)
+	}
+	fmt.Fprintf(&b, "Host:       %s\n", n.mailer.hostname)
+	fmt.Fprintf(&b, "Path:       %s\n", e.BasePath)
+	fmt.Fprintf(&b, "Size:       %s (%d bytes)\n", humanize.FormatSize(e.SizeBytes), e.SizeBytes)
+	fmt.Fprintf(&b, "Threshold:  %s (%d bytes)\n", humanize.FormatSize(e.ThresholdBytes), e.ThresholdBytes)
+	fmt.Fprintf(&b, "Over since: %s\n", e.Since.Local().Format("2006-01-02 15:04 MST"))
+
+	n.send(subject, b.String(), e)
+	return nil
+}
+
+// send emails an alert in the background.
+func (n *AlertNotifier) send(subject, body string, e alert.Event) {
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), n.mailer.opts.Timeout)
+		defer cancel()
+		if err := n.mailer.Send(ctx, subject, body); err != nil {
+			n.logger.Warn("failed to email alert", "directory", e.Directory, "kind", e.Kind, "error", err)
+			return
+		}
+		n.logger.Debug("emailed alert", "directory", e.Directory, "kind", e.Kind)
+	}()
+}
+
+// Close waits for alert emails in flight to be sent or fail, giving up when
+// ctx is done.
+func (n *AlertNotifier) Close(ctx context.Context) {
+	done := make(chan struct{})
+	go func() {
+		n.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		n.logger.Warn("abandoning pending alert emails")
+	}
+}
@@ -0,0 +1,86 @@
+// Package fswatch reports which directories of a tree have changed, so the
+// daemon can re-measure them between full scans. It uses inotify on Linux
+// and is unavailable elsewhere.
+package fswatch
+
+import (
+	"errors"
+	"sort"
+	"sync"
+)
+
+// ErrUnsupported is returned by New on platforms without inotify.
+var ErrUnsupported = errors.New("watching directories is not supported on this platform")
+
+// Options control which directories of a tree are watched.
+type Options struct {
+	// OneFileSystem leaves out directories on other filesystems than the
+	// root.
+	OneFileSystem bool
+	// Skip, if set, leaves out the directories it returns true for, along
+	// with everything below them.
+	Skip func(dir string) bool
+}
+
+// changeSet collects the directories changed since it was last drained.
+type changeSet struct {
+	mu        sync.Mutex
+	dirs      map[string]bool
+	overflow  bool
+	unwatched int
+}
+
+// add notes that an entry of dir changed.
+func (c *changeSet) add(dir string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.dirs == nil {
+		c.dirs = make(map[string]bool)
+	}
+	c.dirs[dir] = true
+}
+
+// drain returns the changed directories in order and whether events were
+// lost, and starts over.
+func (c *changeSet) drain() ([]string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	dirs := make([]string, 0, len(c.dirs))
+	for dir := range c.dirs {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	overflow := c.overflow
+	c.dirs, c.overflow = nil, false
+	return dirs, overflow
+}
+
+// setOverflow notes that the kernel dropped events.
+func (c *changeSet) setOverflow() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.overflow = true
+}
+
+// addUnwatched counts a directory that could not be watched.
+func (c *changeSet) addUnwatched() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.unwatched++
+}
+
+// Changes returns the directories in which entries were created, removed,
+// renamed or written since the last call, and whether the kernel dropped
+// events in that time, in which case changes anywhere may be missing.
+func (w *Watcher) Changes() (dirs []string, overflow bool) {
+	return w.changes.drain()
+}
+
+// Unwatched returns the number of directories left unwatched because the
+// inotify watch limit (fs.inotify.max_user_watches) was reached. Changes in
+// them go unnoticed.
+func (w *Watcher) Unwatched() int {
+	w.changes.mu.Lock()
+	defer w.changes.mu.Unlock()
+	return w.changes.unwatched
+}
@@ -0,0 +1,209 @@
+//go:build linux
+
+package fswatch
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// watchMask selects the events that can change the size of a tree: entries
+// created, removed or renamed, and files written to.
+const watchMask = unix.IN_CREATE | unix.IN_DELETE | unix.IN_MODIFY |
+	unix.IN_MOVED_FROM | unix.IN_MOVED_TO |
+	unix.IN_ONLYDIR | unix.IN_DONT_FOLLOW | unix.IN_EXCL_UNLINK
+
+// Watcher watches every directory of a tree with inotify. Directories
+// created or moved into the tree are watched as they appear.
+type Watcher struct {
+	root    string
+	opts    Options
+	dev     uint64 // device of root, with OneFileSystem
+	fd      int
+	file    *os.File
+	changes changeSet
+
+	mu    sync.Mutex
+	paths map[int]string // watch descriptor -> directory
+	wds   map[string]int // directory -> watch descriptor
+}
+
+// New starts watching the tree at root. Directories that cannot be read or
+// watched are left out, but failing to watch root itself is an error.
+// Events are read in the background until Close is called.
+func New(root string, opts Options) (*Watcher, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("initializing inotify: %w", err)
+	}
+	w := &Watcher{
+		root: root,
+		opts: opts,
+		fd:   fd,
+		// A non-blocking descriptor is read through the runtime poller, so
+		// Close interrupts a pending read
+		file:  os.NewFile(uintptr(fd), "inotify"),
+		paths: make(map[int]string),
+		wds:   make(map[string]int),
+	}
+
+	if opts.OneFileSystem {
+		info, err := os.Stat(root)
+		if err != nil {
+			w.file.Close()
+			return nil, err
+		}
+		w.dev, _ = deviceOf(info)
+	}
+	if err := w.addTree(root); err != nil {
+		w.file.Close()
+		return nil, err
+	}
+
+	go w.readEvents()
+	return w, nil
+}
+
+// Close stops watching.
+func (w *Watcher) Close() error {
+	return w.file.Close()
+}
+
+// addTree watches dir and every directory below it.
+func (w *Watcher) addTree(dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == w.root {
+				return err
+			}
+			// Leave out what cannot be read; it may have been removed already
+			return nil
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if path != w.root && w.opts.Skip != nil && w.opts.Skip(path) {
+			return filepath.SkipDir
+		}
+		if w.opts.OneFileSystem {
+			info, err := d.Info()
+			if err != nil {
+				return filepath.SkipDir
+			}
+			if dev, ok := deviceOf(info); ok && dev != w.dev {
+				return filepath.SkipDir
+			}
+		}
+
+		wd, err := unix.InotifyAddWatch(w.fd, path, watchMask)
+		if err != nil {
+			if path == w.root {
+				return fmt.Errorf("watching %s: %w", path, err)
+			}
+			if errors.Is(err, unix.ENOSPC) {
+				w.changes.addUnwatched()
+			}
+			return filepath.SkipDir
+		}
+		w.mu.Lock()
+		w.paths[wd] = path
+		w.wds[path] = wd
+		w.mu.Unlock()
+		return nil
+	})
+}
+
+// removeTree stops watching dir and every directory below it, e.g. when dir
+// is renamed: its watches would otherwise report changes under the old name.
+func (w *Watcher) removeTree(dir string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for path, wd := range w.wds {
+		if path == dir || strings.HasPrefix(path, dir+"/") {
+			unix.InotifyRmWatch(w.fd, uint32(wd))
+			delete(w.wds, path)
+			delete(w.paths, wd)
+		}
+	}
+}
+
+// forget drops a watch the kernel has removed, e.g. because its directory
+// was deleted.
+func (w *Watcher) forget(wd int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if path, ok := w.paths[wd]; ok {
+		delete(w.paths, wd)
+		if w.wds[path] == wd {
+			delete(w.wds, path)
+		}
+	}
+}
+
+// readEvents reads events until the watcher is closed.
+func (w *Watcher) readEvents() {
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := w.file.Read(buf)
+		if err != nil {
+			return
+		}
+		for off := 0; off+unix.SizeofInotifyEvent <= n; {
+			ev := (*unix.InotifyEvent)(unsafe.Pointer(&buf[off]))
+			nameStart := off + unix.SizeofInotifyEvent
+			name := string(bytes.TrimRight(buf[nameStart:nameStart+int(ev.Len)], "\x00"))
+			off = nameStart + int(ev.Len)
+			w.handle(int(ev.Wd), ev.Mask, name)
+		}
+	}
+}
+
+// handle records one event and keeps the watches in step with the tree.
+func (w *Watcher) handle(wd int, mask uint32, name string) {
+	if mask&unix.IN_Q_OVERFLOW != 0 {
+		w.changes.setOverflow()
+		return
+	}
+	if mask&unix.IN_IGNORED != 0 {
+		w.forget(wd)
+		return
+	}
+
+	w.mu.Lock()
+	dir, ok := w.paths[wd]
+	w.mu.Unlock()
+	if !ok {
+		return
+	}
+	w.changes.add(dir)
+
+	if mask&unix.IN_ISDIR == 0 || name == "" {
+		return
+	}
+	child := filepath.Join(dir, name)
+	switch {
+	case mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0:
+		w.addTree(child)
+	case mask&unix.IN_MOVED_FROM != 0:
+		w.removeTree(child)
+	}
+}
+
+// deviceOf returns the device a stat'ed file is on.
+func deviceOf(info fs.FileInfo) (uint64, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return uint64(st.Dev), true
+}
@@ -0,0 +1,18 @@
+//go:build !linux
+
+package fswatch
+
+// Watcher is unavailable on this platform.
+type Watcher struct {
+	changes changeSet
+}
+
+// New returns ErrUnsupported on platforms without inotify.
+func New(root string, opts Options) (*Watcher, error) {
+	return nil, ErrUnsupported
+}
+
+// Close does nothing.
+func (w *Watcher) Close() error {
+	return nil
+}
@@ -0,0 +1,143 @@
+// Package humanize formats and parses human-friendly sizes such as "1.50 GiB"
+// and "500G", shared by command output, logs, flags and configuration, and
+// draws sparklines of them.
+package humanize
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Binary size units.
+const (
+	KiB = 1024
+	MiB = KiB * 1024
+	GiB = MiB * 1024
+	TiB = GiB * 1024
+)
+
+// FormatSize formats bytes as a human-readable binary size, e.g. "1.50 GiB".
+// Negative values (size changes) are formatted by magnitude with a minus sign.
+func FormatSize(bytes int64) string {
+	if bytes < 0 {
+		if bytes == math.MinInt64 {
+			return fmt.Sprintf("-%.2f TiB", -float64(bytes)/TiB)
+		}
+		return "-" + FormatSize(-bytes)
+	}
+
+	switch {
+	case bytes >= TiB:
+		return fmt.Sprintf("%.2f TiB", float64(bytes)/float64(TiB))
+	case bytes >= GiB:
+		return fmt.Sprintf("%.2f GiB", float64(bytes)/float64(GiB))
+	case bytes >= MiB:
+		return fmt.Sprintf("%.2f MiB", float64(bytes)/float64(MiB))
+	case bytes >= KiB:
+		return fmt.Sprintf("%.2f KiB", float64(bytes)/float64(KiB))
+	default:
+		return fmt.Sprintf("%d B", bytes)
+	}
+}
+
+// ParseSize parses a human-readable size string like "100M", "1.5G", or "500K".
+// Suffixes are case-insensitive and always binary, whether written K, KB or
+// KiB: "1KB" is 1024 bytes, as FormatSize would print it, not 1000. A bare
+// number or a B suffix is bytes, and fractions of a byte are dropped.
+func ParseSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "0" {
+		return 0, nil
+	}
+
+	// Find where the number ends and the suffix begins
+	var numStr string
+	var suffix string
+	for i, c := range s {
+		if c < '0' || c > '9' {
+			if c != '.' {
+				numStr = s[:i]
+				suffix = strings.ToUpper(strings.TrimSpace(s[i:]))
+				break
+			}
+		}
+	}
+	if numStr == "" {
+		numStr = s
+	}
+
+	num, err := strconv.ParseFloat(numStr, 64)
+	if err != nil || math.IsNaN(num) || num < 0 {
+		return 0, fmt.Errorf("invalid number: %s", numStr)
+	}
+
+	var multiplier float64 = 1
+	switch suffix {
+	case "K", "KB", "KIB":
+		multiplier = KiB
+	case "M", "MB", "MIB":
+		multiplier = MiB
+	case "G", "GB", "GIB":
+		multiplier = GiB
+	case "T", "TB", "TIB":
+		multiplier = TiB
+	case "", "B":
+		multiplier = 1
+	default:
+		return 0, fmt.Errorf("unknown size suffix: %s", suffix)
+	}
+
+	size := num * multiplier
+	if size >= math.MaxInt64 {
+		return 0, fmt.Errorf("size too large: %s", s)
+	}
+
+	return int64(size), nil
+}
+
+// Size is a byte count that can be written as a human-readable string
+// ("500G") in configuration files.
+type Size int64
+
+// String formats the size with FormatSize.
+func (s Size) String() string {
+	return FormatSize(int64(s))
+}
+
+// UnmarshalText parses a size string with ParseSize.
+func (s *Size) UnmarshalText(text []byte) error {
+	n, err := ParseSize(string(text))
+	if err != nil {
+		return err
+	}
+	*s = Size(n)
+	return nil
+}
+
+// sparkTicks are the bar characters of a sparkline, lowest first.
+var sparkTicks = []rune("▁▂▃▄▅▆▇█")
+
+// Sparkline draws values as a row of bars scaled between their minimum and
+// maximum. It returns "" for no values.
+func Sparkline(values []int64) string {
+	if len(values) == 0 {
+		return ""
+	}
+	lo, hi := values[0], values[0]
+	for _, v := range values {
+		lo = min(lo, v)
+		hi = max(hi, v)
+	}
+
+	var b strings.Builder
+	for _, v := range values {
+		i := 0
+		if hi > lo {
+			i = int(float64(v-lo) / float64(hi-lo) * float64(len(sparkTicks)-1))
+		}
+		b.WriteRune(sparkTicks[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,129 @@
+package humanize
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+	}{
+		{"", 0},
+		{"0", 0},
+		{"1", 1},
+		{"512", 512},
+		{"512B", 512},
+		{"512 B", 512},
+		{"  100M  ", 100 * MiB},
+		{"1K", KiB},
+		{"1k", KiB},
+		{"1.5G", GiB + GiB/2},
+		{"1.50 GiB", GiB + GiB/2},
+		{"2T", 2 * TiB},
+		{"0.5K", 512},
+		{"1.999", 1},
+		{"0.1K", 102},
+		{".5M", MiB / 2},
+		{"8388607T", 8388607 * TiB},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseSize(tt.in)
+			if err != nil {
+				t.Fatalf("ParseSize(%q) error: %v", tt.in, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseSize(%q) = %d, want %d", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSizeSuffixesAreBinary(t *testing.T) {
+	units := []struct {
+		suffixes []string
+		want     int64
+	}{
+		{[]string{"K", "KB", "KiB", "kib", "kb"}, KiB},
+		{[]string{"M", "MB", "MiB"}, MiB},
+		{[]string{"G", "GB", "GiB"}, GiB},
+		{[]string{"T", "TB", "TiB"}, TiB},
+	}
+
+	for _, u := range units {
+		for _, suffix := range u.suffixes {
+			got, err := ParseSize("1" + suffix)
+			if err != nil {
+				t.Fatalf("ParseSize(%q) error: %v", "1"+suffix, err)
+			}
+			if got != u.want {
+				t.Errorf("ParseSize(%q) = %d, want %d", "1"+suffix, got, u.want)
+			}
+		}
+	}
+}
+
+func TestParseSizeErrors(t *testing.T) {
+	tests := []string{
+		"abc",
+		"K",
+		"1X",
+		"1PB",
+		"1e3",
+		"1.2.3M",
+		"-1",
+		"-5M",
+		"NaN",
+		"Inf",
+		"-Inf",
+		"8388608T",
+		"9223372036854775808",
+		"99999999999999999999G",
+	}
+
+	for _, in := range tests {
+		t.Run(in, func(t *testing.T) {
+			if got, err := ParseSize(in); err == nil {
+				t.Errorf("ParseSize(%q) = %d, want error", in, got)
+			}
+		})
+	}
+}
+
+func TestParseSizeRoundTrip(t *testing.T) {
+	sizes := []int64{
+		0, 1, 1023, KiB, 1536, MiB - 1, 100 * MiB, 5*GiB + 123456789, 3 * TiB, 1 << 62,
+	}
+
+	for _, size := range sizes {
+		formatted := FormatSize(size)
+		got, err := ParseSize(formatted)
+		if err != nil {
+			t.Fatalf("ParseSize(FormatSize(%d) = %q) error: %v", size, formatted, err)
+		}
+		// FormatSize keeps two decimals of the unit, so the round trip is
+		// exact up to half a hundredth of it
+		if diff := math.Abs(float64(got - size)); diff > 0.005*float64(size) {
+			t.Errorf("ParseSize(FormatSize(%d) = %q) = %d", size, formatted, got)
+		}
+	}
+}
+
+func TestSizeUnmarshalText(t *testing.T) {
+	var s Size
+	if err := s.UnmarshalText([]byte("500G")); err != nil {
+		t.Fatalf("UnmarshalText: %v", err)
+	}
+	if s != 500*GiB {
+		t.Errorf("Size = %d, want %d", s, int64(500*GiB))
+	}
+	if s.String() != "500.00 GiB" {
+		t.Errorf("String() = %q, want %q", s.String(), "500.00 GiB")
+	}
+	if err := s.UnmarshalText([]byte("-1G")); err == nil {
+		t.Error("UnmarshalText(-1G) succeeded, want error")
+	}
+}
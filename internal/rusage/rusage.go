@@ -0,0 +1,43 @@
+// Package rusage reads the CPU time consumed by the process and its
+// children, so the cost of a scan can be recorded alongside its duration.
+package rusage
+
+import (
+	"syscall"
+	"time"
+)
+
+// CPUTime is the CPU time consumed by the process and its waited-for
+// children (such as du).
+type CPUTime struct {
+	User   time.Duration
+	System time.Duration
+}
+
+// Read returns the CPU time used so far by the process and its children.
+func Read() (CPUTime, error) {
+	var self, children syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &self); err != nil {
+		return CPUTime{}, err
+	}
+	if err := syscall.Getrusage(syscall.RUSAGE_CHILDREN, &children); err != nil {
+		return CPUTime{}, err
+	}
+	return CPUTime{
+		User:   time.Duration(self.Utime.Nano() + children.Utime.Nano()),
+		System: time.Duration(self.Stime.Nano() + children.Stime.Nano()),
+	}, nil
+}
+
+// Sub returns the CPU time consumed between prev and t.
+func (t CPUTime) Sub(prev CPUTime) CPUTime {
+	return CPUTime{
+		User:   t.User - prev.User,
+		System: t.System - prev.System,
+	}
+}
+
+// Total returns user plus system time.
+func (t CPUTime) Total() time.Duration {
+	return t.User + t.System
+}
@@ -0,0 +1,98 @@
+// Package systemd implements the sd_notify protocol, through which a
+// service started with Type=notify reports readiness, status and watchdog
+// keepalives to systemd. Outside systemd, where $NOTIFY_SOCKET is not set,
+// every call is a no-op.
+package systemd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Notifier sends state changes to systemd's notification socket.
+type Notifier struct {
+	addr *net.UnixAddr // nil when not running under systemd
+}
+
+// NewNotifier returns a notifier for the socket named by $NOTIFY_SOCKET,
+// which does nothing if it is not set.
+func NewNotifier() *Notifier {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return &Notifier{}
+	}
+	// A leading @ names a socket in the abstract namespace
+	if strings.HasPrefix(socket, "@") {
+		socket = "\x00" + socket[1:]
+	}
+	return &Notifier{addr: &net.UnixAddr{Name: socket, Net: "unixgram"}}
+}
+
+// Enabled reports whether the process runs under systemd with a
+// notification socket.
+func (n *Notifier) Enabled() bool {
+	return n.addr != nil
+}
+
+// Notify sends state, one or more newline-separated assignments such as
+// "READY=1" or "STATUS=idle".
+func (n *Notifier) Notify(state string) error {
+	if n.addr == nil {
+		return nil
+	}
+	conn, err := net.DialUnix(n.addr.Net, nil, n.addr)
+	if err != nil {
+		return fmt.Errorf("connecting to notify socket: %w", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return fmt.Errorf("writing to notify socket: %w", err)
+	}
+	return nil
+}
+
+// Ready tells systemd the service has started.
+func (n *Notifier) Ready() error {
+	return n.Notify("READY=1")
+}
+
+// Reloading tells systemd the service is reloading its configuration;
+// Ready follows once it is done.
+func (n *Notifier) Reloading() error {
+	return n.Notify("RELOADING=1")
+}
+
+// Stopping tells systemd the service is shutting down.
+func (n *Notifier) Stopping() error {
+	return n.Notify("STOPPING=1")
+}
+
+// Status sets the one-line status systemctl status shows.
+func (n *Notifier) Status(status string) error {
+	// A newline would end the assignment
+	return n.Notify("STATUS=" + strings.ReplaceAll(status, "\n", " "))
+}
+
+// Watchdog sends a watchdog keepalive.
+func (n *Notifier) Watchdog() error {
+	return n.Notify("WATCHDOG=1")
+}
+
+// WatchdogInterval returns how often systemd expects watchdog keepalives
+// (WatchdogSec=), or false if the watchdog is not enabled for this
+// process.
+func WatchdogInterval() (time.Duration, bool) {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0, false
+	}
+	// WATCHDOG_PID, when set, names the process the watchdog is meant for
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0, false
+	}
+	return time.Duration(usec) * time.Microsecond, true
+}
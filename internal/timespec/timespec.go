@@ -0,0 +1,85 @@
+// Package timespec parses the points in time and durations accepted by
+// command flags such as --since and by the REST API: absolute dates and
+// times, or spans before now like "48h", "3d" and "2w".
+package timespec
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// absoluteTimeLayouts are the absolute formats accepted by Parse.
+var absoluteTimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+}
+
+// Parse parses a --since/--until value. It accepts an absolute date
+// (YYYY-MM-DD), a date and time ("2006-01-02 15:04", RFC 3339), a Go duration
+// ("48h", "90m"), or a simple day/week form ("3d", "2w"). Relative values are
+// resolved as that long before now. A bare date resolves to the start of the
+// day, or to its last nanosecond when endOfDay is set, for inclusive upper
+// bounds.
+func Parse(s string, now time.Time, endOfDay bool) (time.Time, error) {
+	s = strings.TrimSpace(s)
+
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		if endOfDay {
+			// Days are not always 24 hours long where clocks change
+			t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		}
+		return t, nil
+	}
+
+	for _, layout := range absoluteTimeLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	d, err := ParseDuration(s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q (use YYYY-MM-DD, \"YYYY-MM-DD HH:MM\", or a duration like 12h, 3d, 2w)", s)
+	}
+	return now.Add(-d), nil
+}
+
+// ParseDuration parses a non-negative Go duration or a number of days ("3d")
+// or weeks ("2w").
+func ParseDuration(s string) (time.Duration, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		if d < 0 {
+			return 0, fmt.Errorf("negative duration %q", s)
+		}
+		return d, nil
+	}
+
+	if len(s) < 2 {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+
+	var unit time.Duration
+	switch s[len(s)-1] {
+	case 'd', 'D':
+		unit = 24 * time.Hour
+	case 'w', 'W':
+		unit = 7 * 24 * time.Hour
+	default:
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+
+	n, err := strconv.ParseFloat(s[:len(s)-1], 64)
+	if err != nil || math.IsNaN(n) || n < 0 {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	// Also rejects infinity
+	if n > float64(math.MaxInt64/unit) {
+		return 0, fmt.Errorf("duration %q is too long", s)
+	}
+
+	return time.Duration(n * float64(unit)), nil
+}
@@ -0,0 +1,133 @@
+package timespec
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+	}{
+		{"0s", 0},
+		{"90m", 90 * time.Minute},
+		{"48h", 48 * time.Hour},
+		{"3d", 72 * time.Hour},
+		{"3D", 72 * time.Hour},
+		{"2w", 14 * 24 * time.Hour},
+		{"1.5d", 36 * time.Hour},
+		{"0d", 0},
+		{"106751d", 106751 * 24 * time.Hour}, // the most whole days a Duration holds
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseDuration(tt.in)
+			if err != nil {
+				t.Fatalf("ParseDuration(%q) error: %v", tt.in, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseDuration(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDurationErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"d",
+		"3",
+		"3y",
+		"-1h",
+		"-3d",
+		"NaNd",
+		"nanw",
+		"Infd",
+		"+Infw",
+		"-Infd",
+		"106752d",
+		"15251w",
+		"1e300d",
+		"3000000h",
+	}
+
+	for _, in := range tests {
+		t.Run(in, func(t *testing.T) {
+			if got, err := ParseDuration(in); err == nil {
+				t.Errorf("ParseDuration(%q) = %v, want error", in, got)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.Local)
+	tests := []struct {
+		in       string
+		endOfDay bool
+		want     time.Time
+	}{
+		{"2026-01-02", false, time.Date(2026, 1, 2, 0, 0, 0, 0, time.Local)},
+		{"2026-01-02", true, time.Date(2026, 1, 2, 23, 59, 59, 999999999, time.Local)},
+		{"2026-01-02 15:04", true, time.Date(2026, 1, 2, 15, 4, 0, 0, time.Local)},
+		{"2026-01-02 15:04:05", false, time.Date(2026, 1, 2, 15, 4, 5, 0, time.Local)},
+		{"2026-01-02T15:04:05Z", false, time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)},
+		{" 24h ", false, now.Add(-24 * time.Hour)},
+		{"3d", false, now.Add(-72 * time.Hour)},
+		{"2w", true, now.Add(-14 * 24 * time.Hour)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := Parse(tt.in, now, tt.endOfDay)
+			if err != nil {
+				t.Fatalf("Parse(%q) error: %v", tt.in, err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("Parse(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseEndOfDay(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+	local := time.Local
+	time.Local = loc
+	t.Cleanup(func() { time.Local = local })
+
+	tests := []struct {
+		in   string
+		want time.Time
+	}{
+		{"2026-03-08", time.Date(2026, 3, 8, 23, 59, 59, 999999999, loc)},  // 23 hours long
+		{"2026-11-01", time.Date(2026, 11, 1, 23, 59, 59, 999999999, loc)}, // 25 hours long
+		{"2026-06-15", time.Date(2026, 6, 15, 23, 59, 59, 999999999, loc)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := Parse(tt.in, time.Now(), true)
+			if err != nil {
+				t.Fatalf("Parse(%q) error: %v", tt.in, err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("Parse(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	now := time.Now()
+	for _, in := range []string{"", "yesterday", "2026-13-01", "-24h", "NaNd", "Infw"} {
+		if got, err := Parse(in, now, false); err == nil {
+			t.Errorf("Parse(%q) = %v, want error", in, got)
+		}
+	}
+}
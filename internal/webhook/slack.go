@@ -0,0 +1,132 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/jgalley/usgmon/internal/alert"
+	"github.com/jgalley/usgmon/internal/humanize"
+)
+
+// HistoryFunc returns up to limit previously recorded sizes of a directory,
+// newest first.
+type HistoryFunc func(ctx context.Context, directory string, limit int) ([]int64, error)
+
+// SlackOptions configures a Slack notifier.
+type SlackOptions struct {
+	Options
+	Channel  string // overrides the webhook's default channel, if set
+	Username string // overrides the webhook's default user name, if set
+	History  int    // recorded sizes shown in the sparkline; 0 omits it
+}
+
+// Slack posts alert events as formatted messages to Slack-compatible
+// incoming webhooks (Slack, Mattermost, Rocket.Chat).
+type Slack struct {
+	sender  *Sender
+	opts    SlackOptions
+	history HistoryFunc
+}
+
+// slackMessage is the incoming-webhook request body.
+type slackMessage struct {
+	Text     string `json:"text"`
+	Channel  string `json:"channel,omitempty"`
+	Username string `json:"username,omitempty"`
+}
+
+// NewSlack creates a Slack notifier. history supplies the sizes for the
+// sparkline and the change since the last scan; it may be nil.
+func NewSlack(opts SlackOptions, history HistoryFunc, logger *slog.Logger) *Slack {
+	return &Slack{sender: New(opts.Options, logger), opts: opts, history: history}
+}
+
+// Notify posts a message about the alert event in the background,
+// implementing alert.Notifier. Delivery failures are logged, not returned.
+func (s *Slack) Notify(ctx context.Context, e alert.Event) error {
+	var sizes []int64
+	if s.history != nil && s.opts.History > 0 {
+		// The current measurement is not stored yet when alerts are evaluated
+		prev, err := s.history(ctx, e.Directory, s.opts.History-1)
+		if err != nil {
+			s.sender.logger.Warn("failed to load size history for slack message", "directory", e.Directory, "error", err)
+		}
+		for i := len(prev) - 1; i >= 0; i-- {
+			sizes = append(sizes, prev[i])
+		}
+	}
+	sizes = append(sizes, e.SizeBytes)
+
+	s.sender.post("slack_alert_"+string(e.Kind), slackMessage{
+		Text:     s.format(e, sizes),
+		Channel:  s.opts.Channel,
+		Username: s.opts.Username,
+	})
+	return nil
+}
+
+// Close waits for pending deliveries, as Sender.Close does.
+func (s *Slack) Close(ctx context.Context) {
+	s.sender.Close(ctx)
+}
+
+// format renders the message text for e; sizes holds the directory's
+// recorded sizes, oldest first, ending with the current one.
+func (s *Slack) format(e alert.Event, sizes []int64) string {
+	var b strings.Builder
+	switch e.Kind {
+	case alert.Firing:
+		b.WriteString(":rotating_light: *Size alert firing*")
+	case alert.Reminder:
+		b.WriteString(":warning: *Size alert still firing*")
+	case alert.Recovered:
+		b.WriteString(":white_check_mark: *Size alert recovered*")
+	case alert.Anomaly:
+		b.WriteString(":chart_with_upwards_trend: *Growth anomaly*")
+	}
+	if s.sender.hostname != "" {
+		fmt.Fprintf(&b, " on %s", s.sender.hostname)
+	}
+
+	if e.Kind == alert.Anomaly {
+		fmt.Fprintf(&b, "\n`%s` grew *%s* to %s; it usually changes by %s ± %s between scans",
+			e.Directory, humanize.FormatSize(e.GrowthBytes), humanize.FormatSize(e.SizeBytes),
+			signedText(int64(e.Baseline.Mean)), humanize.FormatSize(int64(e.Baseline.Stddev)))
+		if len(sizes) > 2 {
+			fmt.Fprintf(&b, "\nLast %d scans: `%s` %s → %s",
+				len(sizes), humanize.Sparkline(sizes), humanize.FormatSize(sizes[0]), humanize.FormatSize(e.SizeBytes))
+		}
+		return b.String()
+	}
+
+	verb := "over"
+	if e.Kind == alert.Recovered {
+		verb = "back under"
+	}
+	fmt.Fprintf(&b, "\n`%s` is *%s*, %s its %s threshold",
+		e.Directory, humanize.FormatSize(e.SizeBytes), verb, humanize.FormatSize(e.ThresholdBytes))
+	if len(sizes) > 1 {
+		fmt.Fprintf(&b, " (%s since the last scan)", signedText(e.SizeBytes-sizes[len(sizes)-2]))
+	}
+	switch e.Kind {
+	case alert.Reminder:
+		fmt.Fprintf(&b, "\nOver the threshold since %s", e.Since.UTC().Format("2006-01-02 15:04 UTC"))
+	case alert.Recovered:
+		fmt.Fprintf(&b, "\nHad been over the threshold since %s", e.Since.UTC().Format("2006-01-02 15:04 UTC"))
+	}
+	if len(sizes) > 2 {
+		fmt.Fprintf(&b, "\nLast %d scans: `%s` %s → %s",
+			len(sizes), humanize.Sparkline(sizes), humanize.FormatSize(sizes[0]), humanize.FormatSize(e.SizeBytes))
+	}
+	return b.String()
+}
+
+// signedText formats a change in size with its sign.
+func signedText(change int64) string {
+	if change < 0 {
+		return "-" + humanize.FormatSize(-change)
+	}
+	return "+" + humanize.FormatSize(change)
+}
@@ -0,0 +1,246 @@
+// Package webhook posts scan and alert notifications as JSON to HTTP
+// endpoints. Deliveries run in the background and are retried with a
+// doubling delay, so a slow or unavailable receiver never holds up a scan.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/jgalley/usgmon/internal/alert"
+	"github.com/jgalley/usgmon/internal/humanize"
+)
+
+// maxConcurrent bounds the number of deliveries in flight at once.
+const maxConcurrent = 4
+
+// Payload is the JSON body posted for every event. Exactly one of Scan and
+// Alert is set, according to Event.
+type Payload struct {
+	Event     string    `json:"event"` // scan_completed, alert_firing, alert_reminder or alert_recovered
+	Timestamp time.Time `json:"timestamp"`
+	Hostname  string    `json:"hostname,omitempty"`
+	Scan      *Scan     `json:"scan,omitempty"`
+	Alert     *Alert    `json:"alert,omitempty"`
+}
+
+// Scan describes a completed scan.
+type Scan struct {
+	ScanID          string    `json:"scan_id"`
+	BasePath        string    `json:"base_path"`
+	Depth           int       `json:"depth"`
+	Trigger         string    `json:"trigger"`
+	Directories     int       `json:"directories"`
+	Errors          int       `json:"errors"`
+	TotalBytes      int64     `json:"total_bytes"`
+	TotalHuman      string    `json:"total_human"`
+	DurationSeconds float64   `json:"duration_seconds"`
+	TopChangers     []Changer `json:"top_changers"`
+}
+
+// Changer is a directory whose size changed since the previous scan.
+type Changer struct {
+	Directory   string `json:"directory"`
+	OldBytes    int64  `json:"old_bytes"`
+	NewBytes    int64  `json:"new_bytes"`
+	ChangeBytes int64  `json:"change_bytes"`
+}
+
+// Alert describes a size alert event.
+type Alert struct {
+	BasePath       string    `json:"base_path"`
+	Directory      string    `json:"directory"`
+	SizeBytes      int64     `json:"size_bytes"`
+	SizeHuman      string    `json:"size_human"`
+	ThresholdBytes int64     `json:"threshold_bytes"`
+	ThresholdHuman string    `json:"threshold_human"`
+	Since          time.Time `json:"since"`
+
+	// Set for alert_anomaly events only
+	GrowthBytes     int64   `json:"growth_bytes,omitempty"`
+	GrowthHuman     string  `json:"growth_human,omitempty"`
+	MeanGrowthBytes float64 `json:"mean_growth_bytes,omitempty"`
+	StddevBytes     float64 `json:"stddev_bytes,omitempty"`
+	Samples         int     `json:"samples,omitempty"`
+}
+
+// Options configures a Sender.
+type Options struct {
+	URLs       []string
+	Timeout    time.Duration // per attempt
+	Retries    int           // attempts after the first
+	RetryDelay time.Duration // before the first retry, doubling after each
+}
+
+// Sender delivers payloads to every configured URL.
+type Sender struct {
+	opts     Options
+	client   *http.Client
+	logger   *slog.Logger
+	hostname string
+
+	ctx    context.Context // cancelled to abandon retries on shutdown
+	cancel context.CancelFunc
+	sem    chan struct{}
+	wg     sync.WaitGroup
+}
+
+// New creates a Sender.
+func New(opts Options, logger *slog.Logger) *Sender {
+	ctx, cancel := context.WithCancel(context.Background())
+	hostname, _ := os.Hostname()
+	return &Sender{
+		opts:     opts,
+		client:   &http.Client{Timeout: opts.Timeout},
+		logger:   logger,
+		hostname: hostname,
+		ctx:      ctx,
+		cancel:   cancel,
+		sem:      make(chan struct{}, maxConcurrent),
+	}
+}
+
+// ScanCompleted sends a scan_completed event in the background.
+func (s *Sender) ScanCompleted(scan Scan) {
+	if scan.TopChangers == nil {
+		scan.TopChangers = []Changer{}
+	}
+	scan.TotalHuman = humanize.FormatSize(scan.TotalBytes)
+	s.send(Payload{Event: "scan_completed", Scan: &scan})
+}
+
+// Notify sends an alert event in the background, implementing
+// alert.Notifier. Delivery failures are logged, not returned, so they do not
+// change the alert's state.
+func (s *Sender) Notify(ctx context.Context, e alert.Event) error {
+	a := &Alert{
+		BasePath:       e.BasePath,
+		Directory:      e.Directory,
+		SizeBytes:      e.SizeBytes,
+		SizeHuman:      humanize.FormatSize(e.SizeBytes),
+		ThresholdBytes: e.ThresholdBytes,
+		ThresholdHuman: humanize.FormatSize(e.ThresholdBytes),
+		Since:          e.Since,
+	}
+	if e.Kind == alert.Anomaly {
+		a.GrowthBytes = e.GrowthBytes
+		a.GrowthHuman = humanize.FormatSize(e.GrowthBytes)
+		a.MeanGrowthBytes = e.Baseline.Mean
+		a.StddevBytes = e.Baseline.Stddev
+		a.Samples = e.Baseline.Samples
+	}
+	s.send(Payload{Event: "alert_" + string(e.Kind), Alert: a})
+	return nil
+}
+
+// Close waits for pending deliveries to finish, abandoning any still
+// retrying when ctx is done.
+func (s *Sender) Close(ctx context.Context) {
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		s.logger.Warn("abandoning pending webhook deliveries")
+		s.cancel()
+		<-done
+	}
+	s.cancel()
+}
+
+// send delivers p to every URL in the background.
+func (s *Sender) send(p Payload) {
+	p.Timestamp = time.Now().UTC()
+	p.Hostname = s.hostname
+	s.post(p.Event, p)
+}
+
+// post encodes v as JSON and delivers it to every URL in the background;
+// event names it in log lines.
+func (s *Sender) post(event string, v any) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		s.logger.Error("failed to encode webhook payload", "event", event, "error", err)
+		return
+	}
+
+	for _, url := range s.opts.URLs {
+		s.wg.Add(1)
+		go func(url string) {
+			defer s.wg.Done()
+			select {
+			case s.sem <- struct{}{}:
+			case <-s.ctx.Done():
+				return
+			}
+			defer func() { <-s.sem }()
+
+			if err := s.deliver(url, body); err != nil {
+				s.logger.Warn("webhook delivery failed", "url", url, "event", event, "error", err)
+				return
+			}
+			s.logger.Debug("webhook delivered", "url", url, "event", event)
+		}(url)
+	}
+}
+
+// deliver posts body to url, retrying transport errors, 429 and 5xx
+// responses. Other responses are final.
+func (s *Sender) deliver(url string, body []byte) error {
+	delay := s.opts.RetryDelay
+	var err error
+	for attempt := 0; ; attempt++ {
+		var retry bool
+		retry, err = s.attempt(url, body)
+		if err == nil || !retry || attempt >= s.opts.Retries {
+			return err
+		}
+
+		s.logger.Debug("retrying webhook", "url", url, "attempt", attempt+1, "delay", delay, "error", err)
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-s.ctx.Done():
+			timer.Stop()
+			return errors.Join(err, s.ctx.Err())
+		}
+		delay *= 2
+	}
+}
+
+// attempt makes a single delivery and reports whether a failure is worth
+// retrying.
+func (s *Sender) attempt(url string, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(s.ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "usgmon")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("receiver returned %s", resp.Status)
+}
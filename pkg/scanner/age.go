@@ -0,0 +1,112 @@
+package scanner
+
+import (
+	"fmt"
+	"io/fs"
+	"time"
+)
+
+// File times an age breakdown can be taken from.
+const (
+	AgeMtime = "mtime" // last modification, which reading a file leaves alone
+	AgeAtime = "atime" // last access; only as fresh as the mount's atime options allow
+)
+
+// AgeTimes lists the file times that can be selected by name.
+var AgeTimes = []string{AgeMtime, AgeAtime}
+
+// ValidAgeTime reports whether name is one of AgeTimes.
+func ValidAgeTime(name string) bool {
+	for _, t := range AgeTimes {
+		if t == name {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultAgeDays are the bucket bounds, in days, of an age breakdown when
+// none are given: younger than 30 days, 30 to 180 days, and older.
+var DefaultAgeDays = []int{30, 180}
+
+// ValidateAgeDays checks that age bucket bounds are positive and ascending.
+func ValidateAgeDays(days []int) error {
+	if len(days) == 0 {
+		return fmt.Errorf("at least one bound is required")
+	}
+	for i, d := range days {
+		if d <= 0 {
+			return fmt.Errorf("bound %d must be positive", d)
+		}
+		if i > 0 && d <= days[i-1] {
+			return fmt.Errorf("bounds must be ascending, got %d after %d", d, days[i-1])
+		}
+	}
+	return nil
+}
+
+// ageBytes sums sizes into buckets by the age of each entry. Bucket i holds
+// entries younger than bounds[i] but not younger than bounds[i-1], and the
+// last bucket entries at least as old as every bound. Entries dated in the
+// future count as new. A nil ageBytes ignores all entries.
+type ageBytes struct {
+	bounds []time.Duration
+	atime  bool
+	now    time.Time
+	sizes  []int64
+}
+
+// newAgeBytes returns an ageBytes with bucket bounds of the given days,
+// ascending, taking ages from the named file time (AgeMtime when empty). It
+// returns nil if there are no bounds.
+func newAgeBytes(days []int, basis string) *ageBytes {
+	if len(days) == 0 {
+		return nil
+	}
+	bounds := make([]time.Duration, len(days))
+	for i, d := range days {
+		bounds[i] = time.Duration(d) * 24 * time.Hour
+	}
+	return &ageBytes{
+		bounds: bounds,
+		atime:  basis == AgeAtime,
+		now:    time.Now(),
+		sizes:  make([]int64, len(days)+1),
+	}
+}
+
+// add counts an entry's size toward its age bucket, sizing entries as
+// ownerBytes.add does.
+func (a *ageBytes) add(info fs.FileInfo, allocated bool) {
+	if a == nil {
+		return
+	}
+	var size int64
+	switch {
+	case allocated:
+		size = allocatedSize(info)
+	case !info.IsDir():
+		size = info.Size()
+	default:
+		return
+	}
+
+	t := info.ModTime()
+	if a.atime {
+		t = accessTime(info)
+	}
+	age := a.now.Sub(t)
+	i := 0
+	for i < len(a.bounds) && age >= a.bounds[i] {
+		i++
+	}
+	a.sizes[i] += size
+}
+
+// result returns the bucket sizes, or nil if a is nil.
+func (a *ageBytes) result() []int64 {
+	if a == nil {
+		return nil
+	}
+	return a.sizes
+}
@@ -0,0 +1,19 @@
+//go:build darwin
+
+package scanner
+
+import (
+	"io/fs"
+	"syscall"
+	"time"
+)
+
+// accessTime returns the file's last access time, or its modification time
+// if the platform's stat data is unavailable.
+func accessTime(info fs.FileInfo) time.Time {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return info.ModTime()
+	}
+	return time.Unix(st.Atimespec.Sec, st.Atimespec.Nsec)
+}
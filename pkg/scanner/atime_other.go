@@ -0,0 +1,14 @@
+//go:build !linux && !darwin
+
+package scanner
+
+import (
+	"io/fs"
+	"time"
+)
+
+// accessTime falls back to the modification time on platforms whose access
+// time is not read.
+func accessTime(info fs.FileInfo) time.Time {
+	return info.ModTime()
+}
@@ -0,0 +1,128 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// AutoStrategy detects the best strategy per-directory.
+// This handles cases where symlinks cross filesystem boundaries
+// (e.g., base path on ext4 but symlink target on CephFS).
+type AutoStrategy struct {
+	duPath string
+	hasDu  bool
+	walk   *WalkStrategy // walk settings used when falling back; nil for defaults
+	logger *slog.Logger  // receives fallback warnings; nil for slog.Default
+
+	// exclude holds exclude globs for du; when set, CephFS directories are
+	// measured with du too, as recursive stats cannot leave anything out
+	exclude []string
+
+	// counts requests file and subdirectory counts, which du cannot provide,
+	// so directories not on CephFS are walked
+	counts bool
+
+	// allocated measures allocated sizes, which CephFS cannot report, so
+	// CephFS directories are measured with du too
+	allocated bool
+
+	// oneFileSystem keeps du from descending into other filesystems; the
+	// walk settings carry the same option
+	oneFileSystem bool
+
+	statfsTimeout time.Duration // bound on filesystem detection; 0 for DefaultStatfsTimeout
+
+	// duWrapper is a command du is run under; nil runs it directly
+	duWrapper []string
+
+	duGone   atomic.Bool
+	warnOnce sync.Once
+}
+
+// NewAutoStrategy creates an AutoStrategy that will detect per-directory.
+func NewAutoStrategy() *AutoStrategy {
+	duPath, err := exec.LookPath("du")
+	return &AutoStrategy{
+		duPath: duPath,
+		hasDu:  err == nil,
+	}
+}
+
+// Name returns the strategy name.
+func (s *AutoStrategy) Name() string {
+	return "auto"
+}
+
+// StrategyFor returns the appropriate strategy for a specific path.
+// This resolves symlinks and checks the actual filesystem type.
+func (s *AutoStrategy) StrategyFor(path string) Strategy {
+	// Resolve symlinks first to check the actual filesystem
+	resolvedPath, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		resolvedPath = path
+	}
+
+	// Check if this specific directory is on CephFS
+	cephfs, err := IsCephFS(resolvedPath, s.statfsTimeout)
+	if errors.Is(err, errStatfsTimeout) {
+		// du would block on the same mount; walk at least honours cancellation
+		s.log().Warn("filesystem detection timed out, falling back to walk",
+			"path", path, "timeout", s.effectiveStatfsTimeout())
+		return s.walkStrategy()
+	}
+	if cephfs && len(s.exclude) == 0 && !s.allocated {
+		return &CephStrategy{Counts: s.counts}
+	}
+
+	// Fall back to du or walk
+	if s.hasDu && !s.duGone.Load() && !s.counts {
+		return &DuStrategy{duPath: s.duPath, wrapper: s.duWrapper, exclude: s.exclude, allocated: s.allocated, oneFileSystem: s.oneFileSystem, fallback: s.walkStrategy(), onMissing: s.markDuGone}
+	}
+
+	return s.walkStrategy()
+}
+
+// walkStrategy returns the configured walk strategy.
+func (s *AutoStrategy) walkStrategy() *WalkStrategy {
+	if s.walk != nil {
+		return s.walk
+	}
+	return &WalkStrategy{}
+}
+
+// markDuGone switches the rest of the scan to the walk strategy after the du
+// binary found at detection time has disappeared (e.g. during a package upgrade).
+func (s *AutoStrategy) markDuGone() {
+	s.duGone.Store(true)
+	s.warnOnce.Do(func() {
+		s.log().Warn("du disappeared, falling back to walk for the rest of the scan", "du_path", s.duPath)
+	})
+}
+
+// log returns the logger for strategy warnings.
+func (s *AutoStrategy) log() *slog.Logger {
+	if s.logger != nil {
+		return s.logger
+	}
+	return slog.Default()
+}
+
+// effectiveStatfsTimeout returns the detection timeout in use.
+func (s *AutoStrategy) effectiveStatfsTimeout() time.Duration {
+	if s.statfsTimeout > 0 {
+		return s.statfsTimeout
+	}
+	return DefaultStatfsTimeout
+}
+
+// GetSize detects the filesystem type for this specific path and uses
+// the appropriate strategy.
+func (s *AutoStrategy) GetSize(ctx context.Context, path string) (int64, error) {
+	return s.StrategyFor(path).GetSize(ctx, path)
+}
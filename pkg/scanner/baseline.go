@@ -0,0 +1,93 @@
+package scanner
+
+import (
+	"os"
+	"time"
+)
+
+// Baseline is a directory's previous measurement, reused by the mtime
+// shortcut when the directory has not been modified since it was taken.
+type Baseline struct {
+	Measurement
+	MeasuredAt time.Time
+}
+
+// Strategy names of results reused from a Baseline.
+const (
+	MtimeShortcut  = "mtime-shortcut"
+	RctimeShortcut = "rctime-shortcut"
+)
+
+// reuseBaseline returns the directory's baseline as a result if the directory
+// has not changed since the baseline was measured. With opts.Rctime, CephFS
+// directories are judged by their recursive ctime, which covers the whole
+// tree. Otherwise, unless opts.RctimeOnly is set, the directory's own mtime
+// is checked, so changes deeper in the tree that do not touch it go
+// unnoticed.
+func reuseBaseline(opts ScanOptions, dir string) (Result, bool) {
+	// Fingerprints exist to catch changes a size or mtime check would miss,
+	// and files age into older buckets without touching any mtime
+	if opts.Baseline == nil || opts.Fingerprint || len(opts.AgeDays) > 0 {
+		return Result{}, false
+	}
+
+	b, ok := opts.Baseline[dir]
+	if !ok || (opts.measuresBoth() && b.AllocatedBytes == nil) || (opts.Counts && b.FileCount == nil) ||
+		(opts.ByOwner && b.OwnerBytes == nil) || (opts.LargestFiles > 0 && b.LargestFiles == nil) {
+		return Result{}, false
+	}
+	if !sameSizeMode(opts, b) {
+		return Result{}, false
+	}
+
+	strategy, ok := unchangedSince(opts, dir, b.MeasuredAt)
+	if !ok {
+		return Result{}, false
+	}
+
+	return Result{
+		Path:           dir,
+		SizeBytes:      b.SizeBytes,
+		SymlinkCount:   b.SymlinkCount,
+		AllocatedBytes: b.AllocatedBytes,
+		QuotaLimit:     b.QuotaLimit,
+		FileCount:      b.FileCount,
+		DirCount:       b.DirCount,
+		OwnerBytes:     b.OwnerBytes,
+		LargestFiles:   b.LargestFiles,
+		Strategy:       strategy,
+		Reused:         true,
+	}, true
+}
+
+// unchangedSince reports whether dir has not changed since t, and the
+// shortcut that showed it.
+func unchangedSince(opts ScanOptions, dir string, t time.Time) (string, bool) {
+	if opts.Rctime {
+		// rctime is read in whole seconds, so a change within the second
+		// before t could hide behind it
+		if rctime, err := readRctime(dir); err == nil {
+			return RctimeShortcut, !rctime.Add(time.Second).After(t)
+		}
+	}
+	if opts.RctimeOnly {
+		return "", false
+	}
+	info, err := os.Stat(dir)
+	if err != nil || !info.ModTime().Before(t) {
+		return "", false
+	}
+	return MtimeShortcut, true
+}
+
+// sameSizeMode reports whether a baseline's size was measured in the scan's
+// size mode. In the allocated mode both sizes hold the allocated size, so
+// only baselines whose sizes agree qualify, and other modes pass over such
+// baselines: they may come from a scan in the allocated mode.
+func sameSizeMode(opts ScanOptions, b Baseline) bool {
+	agree := b.AllocatedBytes != nil && *b.AllocatedBytes == b.SizeBytes
+	if opts.SizeMode == SizeAllocated {
+		return agree
+	}
+	return !agree
+}
@@ -0,0 +1,146 @@
+package scanner
+
+import (
+	"context"
+	"time"
+)
+
+// DefaultDuBatchSize is the number of directories measured per du process
+// when none is configured. BenchmarkMeasureDirs put 2,000 small directories
+// at about 1.2s one at a time, 95ms in batches of 16, 63ms at 32 and 48ms at
+// 64; 32 keeps most of the gain while leaving batches small enough to spread
+// a few hundred directories across workers.
+const DefaultDuBatchSize = 32
+
+// batchSize returns how many directories a worker takes at once: opts.BatchSize
+// when the strategy can batch (du, or auto and the filesystem accounting
+// strategies, which may resolve to du), else 1.
+func batchSize(strategy Strategy, opts ScanOptions) int {
+	if opts.BatchSize <= 1 {
+		return 1
+	}
+	switch strategy.(type) {
+	case resolver, BatchStrategy:
+		return opts.BatchSize
+	}
+	return 1
+}
+
+// chunkSlice splits dirs into chunks of at most size directories.
+func chunkSlice(dirs []string, size int) [][]string {
+	chunks := make([][]string, 0, (len(dirs)+size-1)/size)
+	for len(dirs) > size {
+		chunks = append(chunks, dirs[:size:size])
+		dirs = dirs[size:]
+	}
+	if len(dirs) > 0 {
+		chunks = append(chunks, dirs)
+	}
+	return chunks
+}
+
+// chunkDirs groups directories from dirs into chunks of at most size and
+// sends them to chunks, flushing the last partial chunk when dirs is closed.
+// discovered, if set, is called with each directory as it arrives. chunks
+// is closed when dirs is exhausted or ctx is cancelled.
+func chunkDirs(ctx context.Context, dirs <-chan string, size int, discovered func(string), chunks chan<- []string) {
+	defer close(chunks)
+
+	send := func(chunk []string) bool {
+		select {
+		case chunks <- chunk:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	var chunk []string
+	for dir := range dirs {
+		if discovered != nil {
+			discovered(dir)
+		}
+		chunk = append(chunk, dir)
+		if len(chunk) < size {
+			continue
+		}
+		if !send(chunk) {
+			return
+		}
+		chunk = nil
+	}
+	if len(chunk) > 0 {
+		send(chunk)
+	}
+}
+
+// measureDirs measures a chunk of directories. Those whose strategy is a
+// BatchStrategy are measured together in one call; the rest, and any the
+// batch could not report, are measured one at a time as measureDir would.
+// The throttle is waited on once for the whole chunk, and every directory
+// measured in the batch reports the batch's duration.
+func measureDirs(ctx context.Context, strategy Strategy, opts ScanOptions, dirs []string) []Result {
+	if len(dirs) == 1 {
+		return []Result{measureDir(ctx, strategy, opts, dirs[0])}
+	}
+
+	results := make([]Result, 0, len(dirs))
+
+	if opts.Throttle != nil {
+		if err := opts.Throttle.Wait(ctx); err != nil {
+			for _, dir := range dirs {
+				results = append(results, Result{Path: dir, Error: err, Strategy: strategy.Name()})
+			}
+			return results
+		}
+	}
+
+	var batch []string
+	var batcher Strategy
+	for _, dir := range dirs {
+		if r, ok := reuseBaseline(opts, dir); ok {
+			results = append(results, withOwner(opts, r))
+			continue
+		}
+		if opts.LooseFiles && isLooseFilesPath(dir) {
+			results = append(results, withOwner(opts, measureLooseFiles(ctx, opts, dir)))
+			continue
+		}
+		effective := effectiveStrategy(strategy, dir)
+		if _, ok := effective.(BatchStrategy); ok {
+			batcher = effective
+			batch = append(batch, dir)
+			continue
+		}
+		results = append(results, withOwner(opts, measureTimed(ctx, opts, effective, dir)))
+	}
+	if len(batch) == 0 {
+		return results
+	}
+
+	// A whole batch gets one directory's time; if it runs out, each
+	// directory is measured alone and only the slow ones fail
+	batchCtx, cancel := ctx, context.CancelFunc(func() {})
+	if opts.DirTimeout > 0 {
+		batchCtx, cancel = context.WithTimeout(ctx, opts.DirTimeout)
+	}
+	start := time.Now()
+	sizes, err := batcher.(BatchStrategy).GetSizes(batchCtx, batch)
+	elapsed := time.Since(start)
+	cancel()
+	for _, dir := range batch {
+		size, ok := sizes[dir]
+		if err != nil || !ok {
+			// Measure alone so the error, if any, is attributed to this directory
+			results = append(results, withOwner(opts, measureTimed(ctx, opts, batcher, dir)))
+			continue
+		}
+		results = append(results, withOwner(opts, Result{
+			Path:      dir,
+			SizeBytes: size,
+			Duration:  elapsed,
+			Strategy:  batcher.Name(),
+		}))
+	}
+	return results
+}
@@ -0,0 +1,56 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// benchTree creates n small directories of a few files each under a
+// temporary directory and returns it.
+func benchTree(b *testing.B, n int) string {
+	b.Helper()
+	root := b.TempDir()
+	data := make([]byte, 1500)
+	for i := 0; i < n; i++ {
+		dir := filepath.Join(root, fmt.Sprintf("d%04d", i))
+		if err := os.Mkdir(dir, 0o755); err != nil {
+			b.Fatal(err)
+		}
+		for j := 0; j < 3; j++ {
+			if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("f%d", j)), data, 0o644); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+	return root
+}
+
+// BenchmarkMeasureDirs scans 2,000 small directories with du over 4 workers
+// at several scan.du_batch_size values; DefaultDuBatchSize is chosen from
+// its results.
+func BenchmarkMeasureDirs(b *testing.B) {
+	if _, err := exec.LookPath("du"); err != nil {
+		b.Skip("du not found")
+	}
+	root := benchTree(b, 2000)
+
+	for _, size := range []int{1, 4, 8, 16, 32, 64, 128} {
+		b.Run(fmt.Sprintf("batch=%d", size), func(b *testing.B) {
+			s := New(4, nil)
+			opts := ScanOptions{Strategy: "du", BatchSize: size}
+			for i := 0; i < b.N; i++ {
+				results, err := s.ScanPathWithOptions(context.Background(), root, 1, opts)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if len(results) != 2000 {
+					b.Fatalf("measured %d directories, want 2000", len(results))
+				}
+			}
+		})
+	}
+}
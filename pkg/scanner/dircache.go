@@ -0,0 +1,132 @@
+package scanner
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// CachedDir is what a DirCache remembers about one directory: its identity
+// and mtime when it was read, and the size of the files directly in it.
+type CachedDir struct {
+	Dev            uint64
+	Ino            uint64
+	ModTime        time.Time
+	SizeBytes      int64 // apparent size of the files directly in the directory
+	AllocatedBytes int64 // disk blocks allocated to those files
+}
+
+// DirCache lets the walk strategy skip stat'ing the files of directories
+// whose mtime has not changed since an earlier walk. A directory's mtime
+// changes when entries are added, removed or renamed in it, but not when a
+// file in it is written in place, so this is a heuristic like the mtime
+// shortcut. Subdirectories are still read and checked, so a change anywhere
+// in a tree is found as long as it touched a directory.
+//
+// A DirCache is safe for concurrent use. Entries are keyed by path as the
+// caller named the measured directory.
+type DirCache struct {
+	mu      sync.Mutex
+	entries map[string]CachedDir
+	seen    map[string]bool
+	updated map[string]CachedDir
+}
+
+// NewDirCache returns a DirCache holding the entries of earlier walks.
+func NewDirCache(entries map[string]CachedDir) *DirCache {
+	if entries == nil {
+		entries = make(map[string]CachedDir)
+	}
+	return &DirCache{
+		entries: entries,
+		seen:    make(map[string]bool),
+		updated: make(map[string]CachedDir),
+	}
+}
+
+// lookup returns the entry for dir if info, the directory's current stat,
+// shows it unchanged since the entry was made. It notes dir as seen either
+// way. Looking up in a nil DirCache finds nothing.
+func (c *DirCache) lookup(dir string, info fs.FileInfo) (CachedDir, bool) {
+	if c == nil {
+		return CachedDir{}, false
+	}
+	dev, ino, ok := inodeOf(info)
+	if !ok {
+		return CachedDir{}, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.seen[dir] = true
+	e, ok := c.entries[dir]
+	if !ok || e.Dev != dev || e.Ino != ino || !e.ModTime.Equal(info.ModTime()) {
+		return CachedDir{}, false
+	}
+	return e, true
+}
+
+// store records the sizes of the files directly in dir, read by a walk that
+// started at start. Directories modified within a second before the walk are
+// left out: with coarse timestamps, a change made while the walk read them
+// could leave their mtime as it was.
+func (c *DirCache) store(dir string, info fs.FileInfo, size, allocated int64, start time.Time) {
+	if c == nil || info.ModTime().Add(time.Second).After(start) {
+		return
+	}
+	dev, ino, ok := inodeOf(info)
+	if !ok {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := CachedDir{Dev: dev, Ino: ino, ModTime: info.ModTime(), SizeBytes: size, AllocatedBytes: allocated}
+	c.entries[dir] = e
+	c.updated[dir] = e
+}
+
+// Updated returns the entries added or replaced since the cache was created.
+func (c *DirCache) Updated() map[string]CachedDir {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	updated := make(map[string]CachedDir, len(c.updated))
+	for dir, e := range c.updated {
+		updated[dir] = e
+	}
+	return updated
+}
+
+// Gone returns the directories of entries that no walk has looked up and
+// that no longer exist. Entries merely not walked, e.g. because the mtime
+// shortcut reused their tree's size, are kept.
+func (c *DirCache) Gone() []string {
+	c.mu.Lock()
+	var unseen []string
+	for dir := range c.entries {
+		if !c.seen[dir] {
+			unseen = append(unseen, dir)
+		}
+	}
+	c.mu.Unlock()
+
+	var gone []string
+	for _, dir := range unseen {
+		if _, err := os.Lstat(dir); errors.Is(err, fs.ErrNotExist) {
+			gone = append(gone, dir)
+		}
+	}
+	return gone
+}
+
+// inodeOf returns the device and inode number of a stat'ed file.
+func inodeOf(info fs.FileInfo) (dev, ino uint64, ok bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return uint64(st.Dev), st.Ino, true
+}
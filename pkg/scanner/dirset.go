@@ -0,0 +1,82 @@
+package scanner
+
+import (
+	"context"
+	"sync"
+)
+
+// DirSet is a set of directories identified by device and inode, so a
+// directory is recognised however it is reached (symlinks, bind mounts,
+// overlapping base paths). Loose-files entries, which cannot be stat'ed, are
+// identified by path instead. It is safe for concurrent use.
+type DirSet struct {
+	mu    sync.Mutex
+	ids   visitedSet
+	loose map[string]bool
+	n     int
+}
+
+// NewDirSet returns an empty DirSet.
+func NewDirSet() *DirSet {
+	return &DirSet{ids: make(visitedSet), loose: make(map[string]bool)}
+}
+
+// Add records the directory at path, following symlinks, or the loose-files
+// entry path names.
+func (s *DirSet) Add(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if isLooseFilesPath(path) {
+		if !s.loose[path] {
+			s.loose[path] = true
+			s.n++
+		}
+		return nil
+	}
+	seen, err := s.ids.seen(path)
+	if err == nil && !seen {
+		s.n++
+	}
+	return err
+}
+
+// Contains reports whether the directory or loose-files entry at path is in
+// the set. Directories that cannot be stat'ed are reported as absent.
+func (s *DirSet) Contains(path string) bool {
+	if s == nil {
+		return false
+	}
+	if isLooseFilesPath(path) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.loose[path]
+	}
+	dev, ino, err := fileID(path)
+	if err != nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ids[dev][ino]
+}
+
+// Len returns the number of directories and loose-files entries in the set.
+func (s *DirSet) Len() int {
+	if s == nil {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.n
+}
+
+// Directories returns the directories a scan of basePath at depth would
+// measure with opts, without measuring them. A negative depth means the leaf
+// directories of every branch. Loose-files entries are not included.
+func Directories(basePath string, depth int, opts ScanOptions) ([]string, error) {
+	opts.LooseFiles = false
+	if depth < 0 {
+		return leafDirectories(context.Background(), basePath, opts)
+	}
+	return (&Scanner{}).getDirectoriesAtDepth(basePath, depth, opts)
+}
@@ -0,0 +1,102 @@
+package scanner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// looseTree creates a base directory holding a file and two subdirectories,
+// a with a file and a subdirectory of its own and b empty, and returns it.
+func looseTree(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	for _, dir := range []string{"a/c", "b"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range []string{"top", "a/f"} {
+		if err := os.WriteFile(filepath.Join(root, file), []byte("data"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestSkipDirsLooseFiles(t *testing.T) {
+	root := looseTree(t)
+	tests := []struct {
+		name  string
+		depth int
+		done  []string // relative to root
+		want  []string
+	}{
+		{
+			name:  "depth 1",
+			depth: 1,
+			done:  []string{"a", LooseFilesName},
+			want:  []string{"b"},
+		},
+		{
+			name:  "depth 2",
+			depth: 2,
+			done:  []string{"a/c", filepath.Join("a", LooseFilesName)},
+			want:  []string{LooseFilesName, filepath.Join("b", LooseFilesName)},
+		},
+		{
+			name:  "leaves",
+			depth: -1,
+			done:  []string{"b", LooseFilesName, filepath.Join("a", LooseFilesName)},
+			want:  []string{"a/c"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			done := NewDirSet()
+			for _, dir := range tt.done {
+				if err := done.Add(filepath.Join(root, dir)); err != nil {
+					t.Fatalf("Add(%q): %v", dir, err)
+				}
+			}
+			if done.Len() != len(tt.done) {
+				t.Errorf("Len() = %d, want %d", done.Len(), len(tt.done))
+			}
+
+			opts := ScanOptions{Strategy: "walk", LooseFiles: true, SkipDirs: done}
+			results, err := New(2, nil).ScanPathWithOptions(context.Background(), root, tt.depth, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := relPaths(root, results); !slices.Equal(got, tt.want) {
+				t.Errorf("ScanPathWithOptions measured %q, want %q", got, tt.want)
+			}
+
+			resultCh, err := New(2, nil).ScanPathStreaming(context.Background(), root, tt.depth, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			results = nil
+			for r := range resultCh {
+				results = append(results, r)
+			}
+			if got := relPaths(root, results); !slices.Equal(got, tt.want) {
+				t.Errorf("ScanPathStreaming measured %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// relPaths returns the sorted paths of results relative to root.
+func relPaths(root string, results []Result) []string {
+	var paths []string
+	for _, r := range results {
+		rel, _ := filepath.Rel(root, r.Path)
+		paths = append(paths, rel)
+	}
+	slices.Sort(paths)
+	return paths
+}
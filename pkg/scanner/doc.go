@@ -0,0 +1,26 @@
+// Package scanner measures the size of directories at a fixed depth below a
+// base path, the way usgmon's scans do.
+//
+// A Scanner enumerates the directories and measures each with a Strategy on a
+// pool of workers:
+//
+//	s := scanner.New(4, nil) // nil detects the strategy per directory
+//	results, err := s.ScanPathWithOptions(ctx, "/home", 1, scanner.ScanOptions{})
+//	for _, r := range results {
+//		fmt.Println(r.Path, r.SizeBytes, r.Error)
+//	}
+//
+// The strategies can also be used on their own. CephStrategy reads the
+// recursive statistics CephFS keeps in extended attributes, so measuring a
+// directory costs a single lookup however large it is:
+//
+//	m, err := (&scanner.CephStrategy{Counts: true}).Measure(ctx, "/mnt/cephfs/projects/a")
+//	fmt.Println(m.SizeBytes, m.QuotaLimit, *m.FileCount)
+//
+// BtrfsStrategy, LustreStrategy and GPFSStrategy do the same from the quota
+// accounting of Btrfs subvolumes, Lustre projects and GPFS filesets.
+// WalkStrategy sums file sizes itself, as does WalkUringStrategy with its
+// stats overlapped through io_uring, and DuStrategy runs du(1). DetectStrategy
+// and AutoStrategy pick between the walk, du and ceph strategies by
+// filesystem.
+package scanner
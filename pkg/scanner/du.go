@@ -0,0 +1,175 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// DuStrategy uses the du command to calculate directory size.
+type DuStrategy struct {
+	duPath string
+
+	// wrapper is a command and arguments du is run under, such as ionice
+	// and nice to lower its priority; nil runs du directly.
+	wrapper []string
+
+	// exclude holds glob patterns passed to du as --exclude options.
+	exclude []string
+
+	// allocated measures disk blocks allocated instead of apparent size.
+	allocated bool
+
+	// oneFileSystem passes -x so du skips directories on other filesystems.
+	oneFileSystem bool
+
+	// fallback measures the directory instead if the du binary is missing at
+	// runtime; onMissing is notified when that happens. Both are optional.
+	fallback  Strategy
+	onMissing func()
+}
+
+// Name returns the strategy name.
+func (s *DuStrategy) Name() string {
+	return "du"
+}
+
+// GetSize executes du -sb (du -sB1 for allocated sizes) to get directory size.
+// Note: du without -L follows the argument symlink (if path is a symlink) but does
+// not follow symlinks inside the directory. This is the desired behavior - we want
+// to calculate size of symlinked directories at target depth, but not traverse
+// broken or circular symlinks inside them.
+func (s *DuStrategy) GetSize(ctx context.Context, path string) (int64, error) {
+	args := append(s.excludeArgs(s.sizeFlags("-s")), "--", path)
+	cmd := s.command(ctx, args)
+	// Force the C locale so output formatting does not depend on the daemon's environment
+	cmd.Env = append(os.Environ(), "LC_ALL=C")
+	output, err := cmd.Output()
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			if s.onMissing != nil {
+				s.onMissing()
+			}
+			if s.fallback != nil {
+				return s.fallback.GetSize(ctx, path)
+			}
+			return 0, fmt.Errorf("du binary %s is missing: %w", s.duPath, err)
+		}
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return 0, fmt.Errorf("du failed: %s", string(exitErr.Stderr))
+		}
+		return 0, fmt.Errorf("executing du: %w", err)
+	}
+
+	return parseDuOutput(string(output))
+}
+
+// GetSizes runs a single du -sb over all paths and returns the size du
+// reported for each. Any failure (du missing, a directory that vanished or
+// could not be fully read) fails the whole batch, so the caller can measure
+// the paths one at a time and attribute errors precisely.
+//
+// du counts a hard-linked file only once per invocation, so a file linked
+// into several of the paths is attributed to the first of them only.
+func (s *DuStrategy) GetSizes(ctx context.Context, paths []string) (map[string]int64, error) {
+	// NUL-terminated records keep paths containing newlines unambiguous
+	args := append(s.excludeArgs(s.sizeFlags("-s0")), "--")
+	args = append(args, paths...)
+	cmd := s.command(ctx, args)
+	cmd.Env = append(os.Environ(), "LC_ALL=C")
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("du failed: %s", string(exitErr.Stderr))
+		}
+		return nil, fmt.Errorf("executing du: %w", err)
+	}
+
+	return parseDuBatchOutput(string(output))
+}
+
+// command returns the du command with args, run under the wrapper if one is
+// set.
+func (s *DuStrategy) command(ctx context.Context, args []string) *exec.Cmd {
+	if len(s.wrapper) == 0 {
+		return exec.CommandContext(ctx, s.duPath, args...)
+	}
+	wrapped := append(append(append([]string(nil), s.wrapper[1:]...), s.duPath), args...)
+	return exec.CommandContext(ctx, s.wrapper[0], wrapped...)
+}
+
+// sizeFlags appends the size unit to flags: -b for apparent bytes, or -B1
+// for allocated bytes.
+func (s *DuStrategy) sizeFlags(flags string) string {
+	if s.allocated {
+		return flags + "B1"
+	}
+	return flags + "b"
+}
+
+// excludeArgs returns flags followed by -x when staying on one filesystem and
+// an --exclude option per exclude pattern.
+func (s *DuStrategy) excludeArgs(flags string) []string {
+	args := []string{flags}
+	if s.oneFileSystem {
+		args = append(args, "-x")
+	}
+	for _, pattern := range s.exclude {
+		args = append(args, "--exclude="+pattern)
+	}
+	return args
+}
+
+// parseDuBatchOutput parses du -sb0 output, one "size\tpath\x00" record per
+// argument, into sizes keyed by path.
+func parseDuBatchOutput(output string) (map[string]int64, error) {
+	sizes := make(map[string]int64)
+	for _, rec := range strings.Split(output, "\x00") {
+		if rec == "" {
+			continue
+		}
+		field, path, ok := strings.Cut(rec, "\t")
+		if !ok {
+			return nil, fmt.Errorf("unexpected du output: %q", rec)
+		}
+		size, err := parseDuOutput(field)
+		if err != nil {
+			return nil, err
+		}
+		sizes[path] = size
+	}
+	return sizes, nil
+}
+
+// parseDuOutput extracts the size from du -sb output ("12345\t/path/to/dir\n").
+// Only the leading field is considered, so paths containing whitespace or tabs
+// cannot confuse parsing.
+func parseDuOutput(output string) (int64, error) {
+	trimmed := strings.TrimLeft(output, " \t")
+	end := strings.IndexAny(trimmed, " \t\n")
+	if end < 0 {
+		end = len(trimmed)
+	}
+	field := trimmed[:end]
+
+	if field == "" {
+		return 0, fmt.Errorf("unexpected du output: %q", output)
+	}
+	for _, c := range field {
+		if c < '0' || c > '9' {
+			return 0, fmt.Errorf("unexpected du output: non-numeric size field %q", field)
+		}
+	}
+
+	size, err := strconv.ParseInt(field, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parsing du output %q: %w", field, err)
+	}
+
+	return size, nil
+}
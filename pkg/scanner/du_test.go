@@ -0,0 +1,142 @@
+package scanner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestParseDuOutput(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		want    int64
+		wantErr bool
+	}{
+		{name: "plain", output: "12345\t/srv/data\n", want: 12345},
+		{name: "no trailing newline", output: "12345\t/srv/data", want: 12345},
+		{name: "size only", output: "42", want: 42},
+		{name: "zero", output: "0\t/srv/empty\n", want: 0},
+		{name: "leading padding", output: "  \t 77\t/srv/data\n", want: 77},
+		{name: "space in path", output: "4096\t/srv/my data\n", want: 4096},
+		{name: "tab in path", output: "4096\t/srv/a\tb\n", want: 4096},
+		{name: "newline in path", output: "4096\t/srv/a\nb\n", want: 4096},
+		{name: "digits in path", output: "4096\t/srv/123 456\n", want: 4096},
+		{name: "max int64", output: "9223372036854775807\t/srv\n", want: 9223372036854775807},
+		{name: "empty", output: "", wantErr: true},
+		{name: "blank", output: " \t\n", wantErr: true},
+		{name: "path only", output: "\t/srv/data\n", wantErr: true},
+		{name: "negative", output: "-1\t/srv/data\n", wantErr: true},
+		{name: "decimal", output: "1.5\t/srv/data\n", wantErr: true},
+		{name: "grouped digits", output: "1,234\t/srv/data\n", wantErr: true},
+		{name: "human units", output: "4.0K\t/srv/data\n", wantErr: true},
+		{name: "error text", output: "du: cannot access '/srv/data'\n", wantErr: true},
+		{name: "overflow", output: "9223372036854775808\t/srv\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseDuOutput(tt.output)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseDuOutput(%q) = %d, want error", tt.output, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseDuOutput(%q) error: %v", tt.output, err)
+			}
+			if got != tt.want {
+				t.Errorf("parseDuOutput(%q) = %d, want %d", tt.output, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDuBatchOutput(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		want    map[string]int64
+		wantErr bool
+	}{
+		{name: "empty", output: "", want: map[string]int64{}},
+		{
+			name:   "several records",
+			output: "100\t/srv/a\x00200\t/srv/b\x00",
+			want:   map[string]int64{"/srv/a": 100, "/srv/b": 200},
+		},
+		{
+			name:   "no trailing NUL",
+			output: "100\t/srv/a\x00200\t/srv/b",
+			want:   map[string]int64{"/srv/a": 100, "/srv/b": 200},
+		},
+		{
+			name:   "whitespace in paths",
+			output: "1\t/srv/my data\x002\t/srv/a\tb\x003\t/srv/a\nb\x00",
+			want:   map[string]int64{"/srv/my data": 1, "/srv/a\tb": 2, "/srv/a\nb": 3},
+		},
+		{name: "missing tab", output: "100 /srv/a\x00", wantErr: true},
+		{name: "non-numeric size", output: "100\t/srv/a\x00abc\t/srv/b\x00", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseDuBatchOutput(tt.output)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseDuBatchOutput(%q) = %v, want error", tt.output, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseDuBatchOutput(%q) error: %v", tt.output, err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("parseDuBatchOutput(%q) = %v, want %v", tt.output, got, tt.want)
+			}
+			for path, size := range tt.want {
+				if got[path] != size {
+					t.Errorf("parseDuBatchOutput(%q)[%q] = %d, want %d", tt.output, path, got[path], size)
+				}
+			}
+		})
+	}
+}
+
+// fakeDu writes a du stand-in that reports 1234 bytes for its last argument
+// under the C locale, and a locale-formatted size otherwise.
+func fakeDu(t *testing.T) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a POSIX shell")
+	}
+	script := `#!/bin/sh
+for last; do :; done
+if [ "$LC_ALL" = C ]; then
+	printf '1234\t%s\n' "$last"
+else
+	printf '1.234\t%s\n' "$last"
+fi
+`
+	path := filepath.Join(t.TempDir(), "du")
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDuStrategyForcesCLocale(t *testing.T) {
+	t.Setenv("LC_ALL", "de_DE.UTF-8")
+	s := &DuStrategy{duPath: fakeDu(t)}
+
+	got, err := s.GetSize(context.Background(), "/srv/data")
+	if err != nil {
+		t.Fatalf("GetSize: %v", err)
+	}
+	if got != 1234 {
+		t.Errorf("GetSize = %d, want 1234", got)
+	}
+}
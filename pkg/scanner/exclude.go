@@ -0,0 +1,170 @@
+package scanner
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Exclude entries are either paths, which leave out that directory and
+// everything below it when enumerating directories to measure, or glob
+// patterns (containing *, ? or [), which also leave matching files and
+// directories out of sizes. Patterns follow du --exclude: * and ? match any
+// character including /, and a pattern matches a path if it matches the
+// whole path or any trailing run of its components, so "*.tmp" matches
+// every .tmp file and "cache" every entry named cache.
+
+// IsExcludeGlob reports whether an exclude entry is a glob pattern rather
+// than a path.
+func IsExcludeGlob(entry string) bool {
+	return strings.ContainsAny(entry, "*?[")
+}
+
+// ValidateExclude checks that an exclude entry is usable.
+func ValidateExclude(entry string) error {
+	if !IsExcludeGlob(entry) {
+		return nil
+	}
+	_, err := excludeRegexp(entry)
+	return err
+}
+
+// Excluded reports whether path is left out by any of the exclude entries.
+func Excluded(path string, excludes []string) bool {
+	for _, exc := range excludes {
+		if IsExcludeGlob(exc) {
+			if globExcludes(exc, path) {
+				return true
+			}
+		} else if path == exc || strings.HasPrefix(path, exc+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Excludes reports whether enumeration leaves path out, by the Exclude
+// entries or the SkipPaths.
+func (o ScanOptions) Excludes(path string) bool {
+	if Excluded(path, o.Exclude) {
+		return true
+	}
+	for _, skip := range o.SkipPaths {
+		if path == skip || strings.HasPrefix(path, skip+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// excludeGlobs returns the glob patterns among exclude entries; these are
+// the ones that apply to sizes as well as enumeration.
+func excludeGlobs(excludes []string) []string {
+	var globs []string
+	for _, exc := range excludes {
+		if IsExcludeGlob(exc) {
+			globs = append(globs, exc)
+		}
+	}
+	return globs
+}
+
+// matchesGlobs reports whether path matches any of the glob patterns.
+func matchesGlobs(path string, globs []string) bool {
+	for _, g := range globs {
+		if globExcludes(g, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// globExcludes matches pattern against path and each of its trailing runs
+// of components, as du --exclude does.
+func globExcludes(pattern, path string) bool {
+	re, err := excludeRegexp(pattern)
+	if err != nil {
+		return false
+	}
+	if re.MatchString(path) {
+		return true
+	}
+	for i := 0; i < len(path); i++ {
+		if path[i] == '/' && i+1 < len(path) && path[i+1] != '/' && re.MatchString(path[i+1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// excludeRegexps caches compiled patterns; the same few patterns are matched
+// against every entry of every scan.
+var excludeRegexps sync.Map // pattern -> *regexp.Regexp
+
+// excludeRegexp compiles a glob pattern to an anchored regular expression.
+func excludeRegexp(pattern string) (*regexp.Regexp, error) {
+	if re, ok := excludeRegexps.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+
+	var b strings.Builder
+	b.WriteString(`(?s)^`)
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			b.WriteString(`.*`)
+		case '?':
+			b.WriteString(`.`)
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+			}
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		case '[':
+			end := classEnd(pattern, i)
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := pattern[i+1 : end]
+			b.WriteByte('[')
+			if class[0] == '!' || class[0] == '^' {
+				b.WriteByte('^')
+				class = class[1:]
+			}
+			b.WriteString(strings.ReplaceAll(class, `\`, `\\`))
+			b.WriteByte(']')
+			i = end
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString(`$`)
+
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid exclude pattern %q: %w", pattern, err)
+	}
+	excludeRegexps.Store(pattern, re)
+	return re, nil
+}
+
+// classEnd returns the index of the ] closing the bracket expression that
+// starts at pattern[start], or -1 if it is not closed. A ] right after the
+// opening bracket (or its negation) is part of the class.
+func classEnd(pattern string, start int) int {
+	i := start + 1
+	if i < len(pattern) && (pattern[i] == '!' || pattern[i] == '^') {
+		i++
+	}
+	if i < len(pattern) && pattern[i] == ']' {
+		i++
+	}
+	for ; i < len(pattern); i++ {
+		if pattern[i] == ']' {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,61 @@
+//go:build linux
+
+package scanner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestDropCacheAdvisesDirectories(t *testing.T) {
+	root := looseTree(t)
+
+	tests := []struct {
+		name     string
+		strategy *WalkStrategy
+	}{
+		{name: "getdents", strategy: &WalkStrategy{DropCache: true}},
+		{name: "WalkDir", strategy: &WalkStrategy{DropCache: true, Fingerprint: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var advised []string
+			fadvise = func(fd int, offset, length int64, advice int) error {
+				var st unix.Stat_t
+				if err := unix.Fstat(fd, &st); err != nil {
+					t.Errorf("fstat of advised fd: %v", err)
+					return nil
+				}
+				if st.Mode&unix.S_IFMT != unix.S_IFDIR {
+					t.Errorf("advised fd %d is not a directory (mode %o)", fd, st.Mode)
+				}
+				if advice != unix.FADV_DONTNEED {
+					t.Errorf("advice = %d, want FADV_DONTNEED", advice)
+				}
+				path, _ := os.Readlink(filepath.Join("/proc/self/fd", strconv.Itoa(fd)))
+				rel, _ := filepath.Rel(root, path)
+				advised = append(advised, rel)
+				return nil
+			}
+			t.Cleanup(func() { fadvise = unix.Fadvise })
+
+			if _, err := tt.strategy.Measure(context.Background(), root); err != nil {
+				t.Fatal(err)
+			}
+			// Directories with entries are advised once read; the files in
+			// them never are
+			for _, dir := range []string{".", "a"} {
+				if !slices.Contains(advised, dir) {
+					t.Errorf("advised %q, want it to include %q", advised, dir)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,6 @@
+//go:build !linux
+
+package scanner
+
+// dropCache is a no-op on platforms without posix_fadvise.
+func dropCache(path string) {}
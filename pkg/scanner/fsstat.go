@@ -0,0 +1,27 @@
+package scanner
+
+import "time"
+
+// FilesystemUsage is the capacity of the filesystem holding a path, as
+// reported by statfs.
+type FilesystemUsage struct {
+	TotalBytes     int64
+	UsedBytes      int64
+	AvailableBytes int64 // free space available to unprivileged users
+}
+
+// StatFilesystem returns the capacity of the filesystem holding path. It
+// gives up after timeout so a dead mount cannot stall the caller; a
+// non-positive timeout uses DefaultStatfsTimeout.
+func StatFilesystem(path string, timeout time.Duration) (FilesystemUsage, error) {
+	stat, err := statfsTimeout(path, timeout)
+	if err != nil {
+		return FilesystemUsage{}, err
+	}
+	bsize := statfsBlockSize(stat)
+	return FilesystemUsage{
+		TotalBytes:     int64(stat.Blocks) * bsize,
+		UsedBytes:      int64(stat.Blocks-stat.Bfree) * bsize,
+		AvailableBytes: int64(stat.Bavail) * bsize,
+	}, nil
+}
@@ -0,0 +1,12 @@
+package scanner
+
+import "syscall"
+
+// statfsBlockSize returns the unit of statfs block counts: the fragment
+// size, which Linux reports separately from the preferred I/O size.
+func statfsBlockSize(stat syscall.Statfs_t) int64 {
+	if stat.Frsize > 0 {
+		return stat.Frsize
+	}
+	return stat.Bsize
+}
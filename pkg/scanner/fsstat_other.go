@@ -0,0 +1,10 @@
+//go:build !linux
+
+package scanner
+
+import "syscall"
+
+// statfsBlockSize returns the unit of statfs block counts.
+func statfsBlockSize(stat syscall.Statfs_t) int64 {
+	return int64(stat.Bsize)
+}
@@ -0,0 +1,375 @@
+//go:build linux
+
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+// haveGetdents reports whether walks can read directories with getdents64.
+const haveGetdents = true
+
+// direntBufSize is the buffer each getdents64 call fills; a large one cuts
+// the number of calls on directories with millions of entries.
+const direntBufSize = 256 << 10
+
+// Offsets into a struct linux_dirent64: d_ino, d_off, d_reclen, d_type and
+// the NUL-terminated d_name.
+const (
+	direntReclen = 16
+	direntType   = 18
+	direntName   = 19
+)
+
+// getdentsWalk holds the state of one walkGetdents measurement.
+type getdentsWalk struct {
+	s       *WalkStrategy
+	ctx     context.Context
+	buf     []byte
+	links   hardlinkSet
+	linksMu *sync.Mutex // guards links when workers share it
+	rootDev uint64
+	ring    *statRing // submits stats through io_uring; nil to stat directly
+	subdirs []string  // subdirectories of the directory being read
+
+	size, allocated, symlinks, files, dirs int64
+}
+
+// walkGetdents measures the tree at path as walkNoFollow does, for the options
+// getdentsSupported allows. Each directory is read with raw getdents64 calls
+// into one reused buffer, and files are stat'ed relative to their
+// directory's descriptor, sparing the allocations and path lookups of
+// filepath.WalkDir. The entry types getdents64 returns tell directories
+// apart, so they are only stat'ed when their blocks or device are needed.
+func (s *WalkStrategy) walkGetdents(ctx context.Context, path string) (Measurement, error) {
+	return newGetdentsWalk(ctx, s, nil).measure(path)
+}
+
+// newGetdentsWalk returns a walk with the options of s, stat'ing entries
+// through ring if it is not nil.
+func newGetdentsWalk(ctx context.Context, s *WalkStrategy, ring *statRing) *getdentsWalk {
+	w := &getdentsWalk{s: s, ctx: ctx, buf: make([]byte, direntBufSize), ring: ring}
+	if s.DedupeHardlinks {
+		w.links = make(hardlinkSet)
+	}
+	if ring != nil {
+		ring.walk = w
+	}
+	return w
+}
+
+// measure walks the tree at path.
+func (w *getdentsWalk) measure(path string) (Measurement, error) {
+	var st unix.Stat_t
+	if err := unix.Lstat(path, &st); err != nil {
+		if w.s.OneFileSystem {
+			return Measurement{}, err
+		}
+		// Like WalkDir, a directory that cannot be stat'ed measures empty
+		return w.measurement(), nil
+	}
+	w.rootDev = uint64(st.Dev)
+	if st.Mode&unix.S_IFMT != unix.S_IFDIR {
+		w.add(unix.DT_UNKNOWN, path, &entryStat{mode: st.Mode, size: st.Size, blocks: int64(st.Blocks)})
+		return w.measurement(), nil
+	}
+	if w.statDirs() {
+		w.allocated += int64(st.Blocks) * 512
+	}
+
+	walk := w.walkDir
+	if w.s.Workers > 1 && w.ring == nil {
+		walk = w.walkParallel
+	}
+	if err := walk(path); err != nil {
+		return Measurement{}, err
+	}
+	return w.measurement(), nil
+}
+
+// statDirs reports whether directories need a stat of their own.
+func (w *getdentsWalk) statDirs() bool {
+	return w.s.Allocated || w.s.SizeAllocated || w.s.OneFileSystem
+}
+
+// walkDir adds the entries of dir and the trees of its subdirectories.
+func (w *getdentsWalk) walkDir(dir string) error {
+	subdirs, err := w.readPath(dir)
+	if err != nil {
+		return err
+	}
+	for _, sub := range subdirs {
+		if err := w.walkDir(sub); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readPath adds the entries of dir and returns the paths of its
+// subdirectories. The descriptor is closed before they are read, so deep
+// trees do not hold one open per level. Directories that cannot be read
+// are skipped, as by WalkDir.
+func (w *getdentsWalk) readPath(dir string) ([]string, error) {
+	if err := w.ctx.Err(); err != nil {
+		return nil, err
+	}
+	fd, err := unix.Open(dir, unix.O_RDONLY|unix.O_DIRECTORY|unix.O_NOFOLLOW|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, nil
+	}
+	names, err := w.readDir(fd)
+	unix.Close(fd)
+	if err != nil {
+		return nil, err
+	}
+	if w.s.DropCache {
+		dropCache(dir)
+	}
+
+	subdirs := make([]string, len(names))
+	for i, name := range names {
+		subdirs[i] = dir + "/" + name
+	}
+	return subdirs, nil
+}
+
+// readDir adds the entries of the directory open as fd and returns the
+// names of the subdirectories to descend into. A read error ends the
+// directory with the entries read so far.
+func (w *getdentsWalk) readDir(fd int) ([]string, error) {
+	w.subdirs = nil
+	for {
+		n, err := unix.Getdents(fd, w.buf)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil || n <= 0 {
+			break
+		}
+		if err := w.ctx.Err(); err != nil {
+			w.ring.wait()
+			return nil, err
+		}
+
+		for off := 0; off+direntName <= n; {
+			reclen := int(binary.NativeEndian.Uint16(w.buf[off+direntReclen:]))
+			if reclen == 0 || off+reclen > n {
+				break
+			}
+			typ := w.buf[off+direntType]
+			name := w.buf[off+direntName : off+reclen]
+			if i := bytes.IndexByte(name, 0); i >= 0 {
+				name = name[:i]
+			}
+			off += reclen
+
+			if string(name) == "." || string(name) == ".." {
+				continue
+			}
+			if err := w.s.Limiter.Wait(w.ctx); err != nil {
+				w.ring.wait()
+				return nil, err
+			}
+			w.visit(fd, typ, string(name))
+		}
+	}
+	w.ring.wait()
+	return w.subdirs, nil
+}
+
+// visit adds one entry of the directory open as fd, stat'ing it first if
+// its type calls for it, through the ring if the walk has one.
+func (w *getdentsWalk) visit(fd int, typ uint8, name string) {
+	// Some filesystems do not fill in d_type, which leaves it DT_UNKNOWN
+	if typ == unix.DT_DIR && !w.statDirs() {
+		w.add(typ, name, nil)
+		return
+	}
+	if w.ring != nil {
+		w.ring.statx(fd, typ, name)
+		return
+	}
+	var st unix.Stat_t
+	if err := unix.Fstatat(fd, name, &st, unix.AT_SYMLINK_NOFOLLOW); err != nil {
+		w.add(typ, name, nil)
+		return
+	}
+	w.add(typ, name, &entryStat{
+		mode:   st.Mode,
+		dev:    uint64(st.Dev),
+		ino:    uint64(st.Ino),
+		nlink:  uint64(st.Nlink),
+		size:   st.Size,
+		blocks: int64(st.Blocks),
+	})
+}
+
+// entryStat is what a walk needs from the stat of an entry.
+type entryStat struct {
+	mode            uint32
+	dev, ino, nlink uint64
+	size, blocks    int64 // blocks in 512-byte units
+}
+
+// add adds an entry given its type from getdents64 and its stat, nil if it
+// was not or could not be stat'ed, and notes the subdirectories to descend
+// into.
+func (w *getdentsWalk) add(typ uint8, name string, st *entryStat) {
+	if typ == unix.DT_UNKNOWN {
+		if st == nil {
+			return
+		}
+		switch st.mode & unix.S_IFMT {
+		case unix.S_IFDIR:
+			typ = unix.DT_DIR
+		case unix.S_IFLNK:
+			typ = unix.DT_LNK
+		default:
+			typ = unix.DT_REG
+		}
+	}
+
+	if typ == unix.DT_DIR {
+		// A directory that cannot be stat'ed is assumed to be on the same
+		// filesystem, as by onDevice
+		if w.s.OneFileSystem && st != nil && st.dev != w.rootDev {
+			return
+		}
+		w.dirs++
+		if st != nil && (w.s.Allocated || w.s.SizeAllocated) {
+			w.allocated += st.blocks * 512
+		}
+		w.subdirs = append(w.subdirs, name)
+		return
+	}
+
+	// Entries that cannot be stat'ed are still counted, as by WalkDir
+	w.files++
+	if typ == unix.DT_LNK {
+		w.symlinks++
+	}
+	if st == nil || w.repeatLink(st) {
+		return
+	}
+	w.size += st.size
+	if w.s.Allocated || w.s.SizeAllocated {
+		w.allocated += st.blocks * 512
+	}
+}
+
+// repeatLink reports whether st is a further link to a file already seen,
+// as hardlinkSet.repeat does.
+func (w *getdentsWalk) repeatLink(st *entryStat) bool {
+	if w.links == nil || st.nlink < 2 {
+		return false
+	}
+	if w.linksMu != nil {
+		w.linksMu.Lock()
+		defer w.linksMu.Unlock()
+	}
+	return w.links.repeatInode(st.dev, st.ino, st.nlink)
+}
+
+// walkParallel walks the tree at root like walkDir with s.Workers workers.
+// Each adds up its own share of the tree, which is folded into w at the
+// end. Directories are handed out from a shared stack, so a worker that
+// runs out picks up the subdirectories the others found.
+func (w *getdentsWalk) walkParallel(root string) error {
+	stack := &dirStack{dirs: []string{root}}
+	stack.cond = sync.NewCond(&stack.mu)
+	w.linksMu = &sync.Mutex{}
+
+	workers := make([]*getdentsWalk, w.s.Workers)
+	var wg sync.WaitGroup
+	for i := range workers {
+		worker := &getdentsWalk{
+			s:       w.s,
+			ctx:     w.ctx,
+			buf:     make([]byte, direntBufSize),
+			links:   w.links,
+			linksMu: w.linksMu,
+			rootDev: w.rootDev,
+		}
+		workers[i] = worker
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				dir, ok := stack.pop()
+				if !ok {
+					return
+				}
+				stack.push(worker.readPath(dir))
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, worker := range workers {
+		w.size += worker.size
+		w.allocated += worker.allocated
+		w.symlinks += worker.symlinks
+		w.files += worker.files
+		w.dirs += worker.dirs
+	}
+	return stack.err
+}
+
+// dirStack hands out the directories of a parallel walk.
+type dirStack struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+	dirs []string
+	busy int   // directories handed out and not yet finished
+	err  error // first error, which ends the walk
+}
+
+// pop returns a directory to read, waiting while others are being read
+// that may add more. It returns false once the walk is over.
+func (q *dirStack) pop() (string, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.dirs) == 0 && q.busy > 0 && q.err == nil {
+		q.cond.Wait()
+	}
+	if len(q.dirs) == 0 || q.err != nil {
+		return "", false
+	}
+	dir := q.dirs[len(q.dirs)-1]
+	q.dirs = q.dirs[:len(q.dirs)-1]
+	q.busy++
+	return dir, true
+}
+
+// push finishes a directory handed out by pop, adding its subdirectories.
+func (q *dirStack) push(subdirs []string, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.busy--
+	if err != nil && q.err == nil {
+		q.err = err
+	}
+	q.dirs = append(q.dirs, subdirs...)
+	q.cond.Broadcast()
+}
+
+// measurement returns what the walk has added up.
+func (w *getdentsWalk) measurement() Measurement {
+	m := Measurement{SizeBytes: w.size, SymlinkCount: &w.symlinks}
+	if w.s.SizeAllocated {
+		m.SizeBytes = w.allocated
+	}
+	if w.s.Allocated {
+		m.AllocatedBytes = &w.allocated
+	}
+	if w.s.Counts {
+		m.FileCount, m.DirCount = &w.files, &w.dirs
+	}
+	return m
+}
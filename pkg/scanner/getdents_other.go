@@ -0,0 +1,13 @@
+//go:build !linux
+
+package scanner
+
+import "context"
+
+// haveGetdents reports whether walks can read directories with getdents64.
+const haveGetdents = false
+
+// walkGetdents is never used on platforms without getdents64.
+func (s *WalkStrategy) walkGetdents(ctx context.Context, path string) (Measurement, error) {
+	return s.walkNoFollow(ctx, path, path)
+}
@@ -0,0 +1,296 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GPFSMagic is the filesystem magic number for IBM Storage Scale (GPFS).
+const GPFSMagic = 0x47504653
+
+// GPFSStrategy reads the size of GPFS filesets from fileset quota accounting:
+// a directory that is the junction of an independent or dependent fileset is
+// measured with mmlsquota -j, which the filesystem answers from its own
+// counters instead of the client stat'ing every file. Other directories, and
+// filesets whose quota cannot be read (quotas not enabled), are measured by
+// Fallback instead.
+//
+// GPFS reports usage in allocated kilobytes, so sizes are rounded to whole
+// KiB and count space on disk rather than apparent size. Fileset junctions
+// are listed once per filesystem with mmlsfileset and remembered for the
+// lifetime of the strategy, so filesets linked later are only picked up by
+// the next scan.
+type GPFSStrategy struct {
+	// Fallback measures directories that are not fileset junctions; nil
+	// uses du.
+	Fallback Strategy
+
+	statfsTimeout time.Duration // bound on filesystem detection; 0 for DefaultStatfsTimeout
+
+	mu       sync.Mutex
+	filesets map[string]map[string]string // device -> junction path -> fileset name
+}
+
+// errNotJunction is returned for GPFS directories that are not a fileset's
+// junction.
+var errNotJunction = errors.New("directory is not a fileset junction")
+
+// Name returns the strategy name.
+func (s *GPFSStrategy) Name() string {
+	return "gpfs"
+}
+
+// StrategyFor returns s for fileset junctions on GPFS and the fallback for any
+// other directory.
+func (s *GPFSStrategy) StrategyFor(path string) Strategy {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		resolved = path
+	}
+	if ok, _ := IsGPFS(resolved, s.statfsTimeout); !ok {
+		return duFallback(s.Fallback)
+	}
+	if _, _, err := s.fileset(context.Background(), resolved); err != nil {
+		return duFallback(s.Fallback)
+	}
+	return s
+}
+
+// GetSize returns the space used by the fileset.
+func (s *GPFSStrategy) GetSize(ctx context.Context, path string) (int64, error) {
+	m, err := s.Measure(ctx, path)
+	return m.SizeBytes, err
+}
+
+// Measure reads the space used by the fileset whose junction is path and the
+// fileset's hard block limit, if one is set. Directories that are not a
+// junction, or whose quota cannot be read, are measured by the fallback
+// instead.
+func (s *GPFSStrategy) Measure(ctx context.Context, path string) (Measurement, error) {
+	select {
+	case <-ctx.Done():
+		return Measurement{}, ctx.Err()
+	default:
+	}
+
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		resolved = path
+	}
+
+	device, fileset, err := s.fileset(ctx, resolved)
+	if err == nil {
+		var m Measurement
+		if m, err = readFilesetQuota(ctx, device, fileset); err == nil {
+			return m, nil
+		}
+	}
+	if ctx.Err() != nil {
+		return Measurement{}, ctx.Err()
+	}
+	return measureFallback(ctx, duFallback(s.Fallback), path)
+}
+
+// fileset returns the GPFS device and the name of the fileset whose junction
+// is path.
+func (s *GPFSStrategy) fileset(ctx context.Context, path string) (string, string, error) {
+	device, err := gpfsDevice(path)
+	if err != nil {
+		return "", "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	junctions, ok := s.filesets[device]
+	if !ok {
+		out, err := runMm(ctx, "mmlsfileset", device, "-Y")
+		if err != nil {
+			return "", "", err
+		}
+		if junctions, err = parseMmlsfileset(out); err != nil {
+			return "", "", err
+		}
+		if s.filesets == nil {
+			s.filesets = make(map[string]map[string]string)
+		}
+		s.filesets[device] = junctions
+	}
+
+	name, ok := junctions[path]
+	if !ok {
+		return "", "", errNotJunction
+	}
+	return device, name, nil
+}
+
+// IsGPFS checks if the path is on a GPFS filesystem. Like IsCephFS, it gives
+// up after timeout.
+func IsGPFS(path string, timeout time.Duration) (bool, error) {
+	return onFilesystem(path, timeout, GPFSMagic)
+}
+
+// gpfsDevice returns the device name of the GPFS filesystem mounted deepest
+// above path, as the mm commands expect it.
+func gpfsDevice(path string) (string, error) {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var device, mountPoint string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		// ID parent major:minor root mountpoint options [optional...] - fstype source superoptions
+		pre, post, ok := strings.Cut(sc.Text(), " - ")
+		fields, postFields := strings.Fields(pre), strings.Fields(post)
+		if !ok || len(fields) < 5 || len(postFields) < 2 || postFields[0] != "gpfs" {
+			continue
+		}
+		mp := unescapeMountinfo(fields[4])
+		if !pathWithin(path, mp) || len(mp) < len(mountPoint) {
+			continue
+		}
+		device, mountPoint = strings.TrimPrefix(postFields[1], "/dev/"), mp
+	}
+	if err := sc.Err(); err != nil {
+		return "", err
+	}
+	if device == "" {
+		return "", fmt.Errorf("no GPFS mount found for %s", path)
+	}
+	return device, nil
+}
+
+// unescapeMountinfo decodes the octal escapes (\040 for a space) the kernel
+// uses in mountinfo paths.
+func unescapeMountinfo(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// pathWithin reports whether path is dir or below it.
+func pathWithin(path, dir string) bool {
+	return path == dir || dir == "/" || strings.HasPrefix(path, dir+"/")
+}
+
+// readFilesetQuota returns the usage and hard block limit of a fileset.
+func readFilesetQuota(ctx context.Context, device, fileset string) (Measurement, error) {
+	out, err := runMm(ctx, "mmlsquota", "-j", fileset, "--block-size", "1K", "-Y", device)
+	if err != nil {
+		return Measurement{}, err
+	}
+	return parseMmlsquota(out)
+}
+
+// runMm runs a GPFS administration command, which lives outside PATH by
+// default, and returns its output.
+func runMm(ctx context.Context, name string, args ...string) (string, error) {
+	bin, err := exec.LookPath(name)
+	if err != nil {
+		bin = filepath.Join("/usr/lpp/mmfs/bin", name)
+	}
+	cmd := exec.CommandContext(ctx, bin, args...)
+	cmd.Env = append(os.Environ(), "LC_ALL=C")
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return "", fmt.Errorf("%s failed: %s", name, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("executing %s: %w", name, err)
+	}
+	return string(output), nil
+}
+
+// parseMmY parses the colon-separated -Y output of an mm command into one map
+// per data row, keyed by the names on the HEADER row. Values are
+// percent-encoded.
+func parseMmY(output string) []map[string]string {
+	var header []string
+	var rows []map[string]string
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(line, ":")
+		if len(fields) < 3 {
+			continue
+		}
+		if fields[2] == "HEADER" {
+			header = fields
+			continue
+		}
+		if header == nil {
+			continue
+		}
+		row := make(map[string]string, len(header))
+		for i, name := range header {
+			if i < len(fields) && name != "" {
+				v, err := url.PathUnescape(fields[i])
+				if err != nil {
+					v = fields[i]
+				}
+				row[name] = v
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// parseMmlsfileset maps the junction paths of linked filesets to their names.
+func parseMmlsfileset(output string) (map[string]string, error) {
+	rows := parseMmY(output)
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("unexpected mmlsfileset output: %q", output)
+	}
+	junctions := make(map[string]string)
+	for _, row := range rows {
+		// Unlinked filesets have no junction
+		if row["status"] != "Linked" || row["path"] == "" {
+			continue
+		}
+		junctions[filepath.Clean(row["path"])] = row["filesetName"]
+	}
+	return junctions, nil
+}
+
+// parseMmlsquota parses the fileset row of mmlsquota -j -Y output in 1K
+// blocks.
+func parseMmlsquota(output string) (Measurement, error) {
+	for _, row := range parseMmY(output) {
+		if row["quotaType"] != "FILESET" {
+			continue
+		}
+		used, err := strconv.ParseInt(row["blockUsage"], 10, 64)
+		if err != nil {
+			return Measurement{}, fmt.Errorf("parsing mmlsquota blockUsage %q: %w", row["blockUsage"], err)
+		}
+		m := Measurement{SizeBytes: used * 1024}
+		if limit, err := strconv.ParseInt(row["blockLimit"], 10, 64); err == nil {
+			m.QuotaLimit = limit * 1024
+		}
+		return m, nil
+	}
+	return Measurement{}, fmt.Errorf("no fileset quota in mmlsquota output: %q", output)
+}
@@ -0,0 +1,81 @@
+package scanner
+
+import (
+	"container/heap"
+	"io/fs"
+	"sort"
+	"time"
+)
+
+// MaxLargestFiles bounds ScanOptions.LargestFiles, since every directory
+// measured keeps that many files in memory until its walk ends.
+const MaxLargestFiles = 1000
+
+// FileSize is a file found while measuring a directory.
+type FileSize struct {
+	Path      string
+	SizeBytes int64 // apparent size
+	ModTime   time.Time
+}
+
+// largestFiles keeps the n largest regular files offered to it in a min-heap,
+// so each file costs at most a comparison with the smallest kept. A nil
+// largestFiles ignores all files.
+type largestFiles struct {
+	n     int
+	files fileHeap
+}
+
+// newLargestFiles returns a largestFiles keeping n files, or nil if n is not
+// positive.
+func newLargestFiles(n int) *largestFiles {
+	if n <= 0 {
+		return nil
+	}
+	return &largestFiles{n: n}
+}
+
+// offer considers the file at path for the kept set.
+func (l *largestFiles) offer(path string, info fs.FileInfo) {
+	if l == nil || !info.Mode().IsRegular() {
+		return
+	}
+	if len(l.files) == l.n {
+		if info.Size() <= l.files[0].SizeBytes {
+			return
+		}
+		heap.Pop(&l.files)
+	}
+	heap.Push(&l.files, FileSize{Path: path, SizeBytes: info.Size(), ModTime: info.ModTime()})
+}
+
+// sorted returns the kept files, largest first.
+func (l *largestFiles) sorted() []FileSize {
+	if l == nil {
+		return nil
+	}
+	files := make([]FileSize, len(l.files))
+	copy(files, l.files)
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].SizeBytes != files[j].SizeBytes {
+			return files[i].SizeBytes > files[j].SizeBytes
+		}
+		return files[i].Path < files[j].Path
+	})
+	return files
+}
+
+// fileHeap is a min-heap of files by size.
+type fileHeap []FileSize
+
+func (h fileHeap) Len() int            { return len(h) }
+func (h fileHeap) Less(i, j int) bool  { return h[i].SizeBytes < h[j].SizeBytes }
+func (h fileHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *fileHeap) Push(x interface{}) { *h = append(*h, x.(FileSize)) }
+
+func (h *fileHeap) Pop() interface{} {
+	old := *h
+	f := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return f
+}
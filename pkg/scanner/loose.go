@@ -0,0 +1,130 @@
+package scanner
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// LooseFilesName is the final element of the synthetic entry that holds the
+// files sitting directly in an intermediate directory of a depth scan. A real
+// directory with this name is shadowed by the synthetic entry.
+const LooseFilesName = "(files)"
+
+// LooseFilesPath returns the synthetic entry for files directly in dir.
+func LooseFilesPath(dir string) string {
+	return filepath.Join(dir, LooseFilesName)
+}
+
+// isLooseFilesPath reports whether path names a synthetic loose-files entry.
+func isLooseFilesPath(path string) bool {
+	return filepath.Base(path) == LooseFilesName
+}
+
+// measureLooseFiles measures the non-directory entries directly in the parent
+// of entry, honouring the same file exclusions, symlink counting, allocated
+// sizes and fingerprints as the walk strategy. The allocated size includes the
+// directory's own blocks. Symlinks to directories count as entries unless
+// symlinks are followed, in which case they are scanned as directories.
+func measureLooseFiles(ctx context.Context, opts ScanOptions, entry string) Result {
+	start := time.Now()
+	dir := filepath.Dir(entry)
+
+	result := Result{Path: entry, Strategy: "loose-files"}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		result.Error = err
+		result.Duration = time.Since(start)
+		return result
+	}
+
+	// The directory's own blocks go here too, so allocated sizes add up as
+	// they do for du
+	var size, symlinks, allocated, files int64
+	measureAllocated := opts.measuresBoth() || opts.SizeMode == SizeAllocated
+	largest := newLargestFiles(opts.LargestFiles)
+	ages := newAgeBytes(opts.AgeDays, opts.AgeTime)
+	var owners ownerBytes
+	if opts.ByOwner {
+		owners = make(ownerBytes)
+	}
+	if measureAllocated {
+		if info, err := os.Lstat(dir); err == nil {
+			allocated = allocatedSize(info)
+			if opts.SizeMode == SizeAllocated {
+				owners.add(info, true)
+				ages.add(info, true)
+			}
+		}
+	}
+	hasher := sha256.New()
+	globs := excludeGlobs(opts.Exclude)
+	var links hardlinkSet
+	if opts.DedupeHardlinks {
+		links = make(hardlinkSet)
+	}
+	for _, e := range entries {
+		if ctx.Err() != nil {
+			result.Error = ctx.Err()
+			break
+		}
+		p := filepath.Join(dir, e.Name())
+		if e.IsDir() || matchesAny(e.Name(), opts.ExcludeFiles) || matchesGlobs(p, globs) {
+			continue
+		}
+		if isSymlink(e) {
+			if opts.FollowSymlinks {
+				if target, err := os.Stat(p); err == nil && target.IsDir() {
+					continue
+				}
+			}
+			symlinks++
+		}
+
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files++
+		if !links.repeat(info) {
+			size += info.Size()
+			if measureAllocated {
+				allocated += allocatedSize(info)
+			}
+			owners.add(info, opts.SizeMode == SizeAllocated)
+			ages.add(info, opts.SizeMode == SizeAllocated)
+		}
+		largest.offer(p, info)
+		if opts.Fingerprint {
+			fmt.Fprintf(hasher, "%s\x00%d\x00%d\x00%d\n", e.Name(), e.Type(), info.Size(), info.ModTime().UnixNano())
+		}
+	}
+
+	result.SizeBytes = size
+	result.SymlinkCount = &symlinks
+	if opts.SizeMode == SizeAllocated {
+		result.SizeBytes = allocated
+	} else if opts.measuresBoth() {
+		result.AllocatedBytes = &allocated
+	}
+	if opts.Fingerprint {
+		result.Fingerprint = hex.EncodeToString(hasher.Sum(nil))
+	}
+	if opts.Counts {
+		var dirs int64
+		result.FileCount, result.DirCount = &files, &dirs
+	}
+	if owners != nil {
+		result.OwnerBytes = owners
+	}
+	if largest != nil {
+		result.LargestFiles = largest.sorted()
+	}
+	result.AgeBytes = ages.result()
+	result.Duration = time.Since(start)
+	return result
+}
@@ -0,0 +1,164 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LustreMagic is the filesystem magic number for Lustre.
+const LustreMagic = 0x0bd00bd0
+
+// LustreStrategy reads the size of directories on Lustre from project quota
+// accounting: a directory tagged with a project ID (lfs project -p ID -s) is
+// measured with lfs quota -p, which the servers answer from their own
+// counters instead of the client stat'ing every file. Directories without a
+// project ID, and those whose filesystem does not track project quotas, are
+// measured by Fallback instead.
+//
+// Lustre reports usage in allocated kilobytes, so sizes are rounded to whole
+// KiB and count space on disk rather than apparent size. Everything tagged
+// with the project ID counts, including files outside the directory.
+type LustreStrategy struct {
+	// Fallback measures directories without a project; nil uses du.
+	Fallback Strategy
+
+	statfsTimeout time.Duration // bound on filesystem detection; 0 for DefaultStatfsTimeout
+}
+
+// errNoProject is returned when a Lustre directory has no project ID.
+var errNoProject = errors.New("directory has no project ID")
+
+// Name returns the strategy name.
+func (s *LustreStrategy) Name() string {
+	return "lustre"
+}
+
+// StrategyFor returns s for directories on Lustre and the fallback for any
+// other directory.
+func (s *LustreStrategy) StrategyFor(path string) Strategy {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		resolved = path
+	}
+	if ok, _ := IsLustre(resolved, s.statfsTimeout); ok {
+		return s
+	}
+	return duFallback(s.Fallback)
+}
+
+// GetSize returns the space used by the directory's project.
+func (s *LustreStrategy) GetSize(ctx context.Context, path string) (int64, error) {
+	m, err := s.Measure(ctx, path)
+	return m.SizeBytes, err
+}
+
+// Measure reads the space used by the directory's project and the project's
+// hard block limit, if one is set. Directories without a project ID, or
+// whose quota cannot be read, are measured by the fallback instead.
+func (s *LustreStrategy) Measure(ctx context.Context, path string) (Measurement, error) {
+	select {
+	case <-ctx.Done():
+		return Measurement{}, ctx.Err()
+	default:
+	}
+
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		resolved = path
+	}
+
+	m, err := readLustreProject(ctx, resolved)
+	if err == nil {
+		return m, nil
+	}
+	if ctx.Err() != nil {
+		return Measurement{}, ctx.Err()
+	}
+	return measureFallback(ctx, duFallback(s.Fallback), path)
+}
+
+// IsLustre checks if the path is on a Lustre filesystem. Like IsCephFS, it
+// gives up after timeout.
+func IsLustre(path string, timeout time.Duration) (bool, error) {
+	return onFilesystem(path, timeout, LustreMagic)
+}
+
+// readLustreProject looks up the project ID of the directory at path and
+// returns the project's usage.
+func readLustreProject(ctx context.Context, path string) (Measurement, error) {
+	out, err := runLfs(ctx, "project", "-d", path)
+	if err != nil {
+		return Measurement{}, err
+	}
+	projID, err := parseLfsProject(out)
+	if err != nil {
+		return Measurement{}, err
+	}
+
+	// Any path on the filesystem identifies it to lfs quota
+	out, err = runLfs(ctx, "quota", "-q", "-p", strconv.FormatUint(projID, 10), path)
+	if err != nil {
+		return Measurement{}, err
+	}
+	return parseLfsQuota(out)
+}
+
+// runLfs runs lfs with the given arguments and returns its output.
+func runLfs(ctx context.Context, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "lfs", args...)
+	cmd.Env = append(os.Environ(), "LC_ALL=C")
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return "", fmt.Errorf("lfs %s failed: %s", args[0], strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("executing lfs: %w", err)
+	}
+	return string(output), nil
+}
+
+// parseLfsProject extracts the project ID from lfs project -d output
+// ("  1000 P /mnt/lustre/dir"). Project 0 means the directory has none.
+func parseLfsProject(output string) (uint64, error) {
+	fields := strings.Fields(output)
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("unexpected lfs project output: %q", output)
+	}
+	projID, err := strconv.ParseUint(fields[0], 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("parsing lfs project ID %q: %w", fields[0], err)
+	}
+	if projID == 0 {
+		return 0, errNoProject
+	}
+	return projID, nil
+}
+
+// parseLfsQuota parses lfs quota -q output, whose columns are the filesystem,
+// kbytes used, soft limit, hard limit and grace for blocks, followed by the
+// same for files. A long filesystem name moves the numbers onto the next
+// line, and kbytes carries a trailing "*" when over quota.
+//
+//	/mnt/lustre  1048576  0  2097152  -  120  0  0  -
+func parseLfsQuota(output string) (Measurement, error) {
+	fields := strings.Fields(output)
+	if len(fields) < 4 {
+		return Measurement{}, fmt.Errorf("unexpected lfs quota output: %q", output)
+	}
+	kbytes, err := strconv.ParseInt(strings.TrimSuffix(fields[1], "*"), 10, 64)
+	if err != nil {
+		return Measurement{}, fmt.Errorf("parsing lfs quota kbytes %q: %w", fields[1], err)
+	}
+	m := Measurement{SizeBytes: kbytes * 1024}
+	if limit, err := strconv.ParseInt(fields[3], 10, 64); err == nil {
+		m.QuotaLimit = limit * 1024
+	}
+	return m, nil
+}
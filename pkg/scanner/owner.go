@@ -0,0 +1,95 @@
+package scanner
+
+import (
+	"io/fs"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"syscall"
+)
+
+// ownerNames caches UID and GID lookups, since every directory under a base
+// path usually resolves to the same handful of accounts.
+var ownerNames = struct {
+	sync.Mutex
+	users  map[uint32]string
+	groups map[uint32]string
+}{
+	users:  make(map[uint32]string),
+	groups: make(map[uint32]string),
+}
+
+// directoryOwner returns the user and group owning dir, resolved to names
+// where possible and numeric IDs otherwise. Loose-files entries report the
+// owner of their parent directory.
+func directoryOwner(dir string) (owner, group string, err error) {
+	if isLooseFilesPath(dir) {
+		dir = filepath.Dir(dir)
+	}
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		return "", "", err
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return "", "", nil
+	}
+
+	owner = UserName(st.Uid)
+
+	ownerNames.Lock()
+	defer ownerNames.Unlock()
+
+	group, ok = ownerNames.groups[st.Gid]
+	if !ok {
+		group = strconv.FormatUint(uint64(st.Gid), 10)
+		if g, err := user.LookupGroupId(group); err == nil {
+			group = g.Name
+		}
+		ownerNames.groups[st.Gid] = group
+	}
+
+	return owner, group, nil
+}
+
+// UserName returns the name of the user with the given UID, or the UID
+// itself if it has none.
+func UserName(uid uint32) string {
+	ownerNames.Lock()
+	defer ownerNames.Unlock()
+
+	name, ok := ownerNames.users[uid]
+	if !ok {
+		name = strconv.FormatUint(uint64(uid), 10)
+		if u, err := user.LookupId(name); err == nil {
+			name = u.Username
+		}
+		ownerNames.users[uid] = name
+	}
+	return name
+}
+
+// ownerBytes sums sizes by the UID owning each entry.
+type ownerBytes map[uint32]int64
+
+// add counts an entry's size toward its owner: its allocated size when
+// allocated is set, and otherwise its length for anything but a directory.
+// Adding to a nil ownerBytes does nothing.
+func (o ownerBytes) add(info fs.FileInfo, allocated bool) {
+	if o == nil {
+		return
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return
+	}
+	switch {
+	case allocated:
+		o[st.Uid] += allocatedSize(info)
+	case !info.IsDir():
+		o[st.Uid] += info.Size()
+	}
+}
@@ -0,0 +1,206 @@
+package scanner
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// errPoolClosed is reported for measurements submitted after Pool.Close.
+var errPoolClosed = errors.New("worker pool closed")
+
+// Pool is a persistent set of workers shared by multiple scans. Using a pool
+// avoids spawning and tearing down goroutines for every scan, and bounds the
+// total number of concurrent measurements across all scans using it.
+//
+// Each scan keeps one job waiting in the pool's queue at a time. Free
+// workers take the waiting job with the highest ScanOptions.Priority,
+// and among equal priorities the one submitted first, so concurrent scans of
+// equal priority interleave while higher-priority scans go ahead of the rest.
+type Pool struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	queue  jobQueue
+	seq    uint64
+	closed bool
+	quit   chan struct{}
+	wg     sync.WaitGroup
+	once   sync.Once
+}
+
+// Job states; a job is claimed by exactly one of a worker or its submitter.
+const (
+	jobPending int32 = iota
+	jobTaken
+	jobAbandoned
+)
+
+// poolJob is a chunk of directories submitted to a Pool and measured
+// together by one worker; chunks hold a single directory unless the scan
+// batches (ScanOptions.BatchSize).
+type poolJob struct {
+	ctx      context.Context
+	strategy Strategy
+	opts     ScanOptions
+	dirs     []string
+	results  chan<- Result
+	done     func()
+
+	seq   uint64
+	state atomic.Int32
+	taken chan struct{} // closed when a worker claims the job
+}
+
+// NewPool starts a pool with the given number of workers.
+func NewPool(workers int) *Pool {
+	if workers < 1 {
+		workers = 1
+	}
+	p := &Pool{quit: make(chan struct{})}
+	p.cond = sync.NewCond(&p.mu)
+	for i := 0; i < workers; i++ {
+		p.wg.Add(1)
+		go p.worker()
+	}
+	return p
+}
+
+// Close stops the workers and waits for in-flight measurements to finish.
+// Submissions after Close are rejected.
+func (p *Pool) Close() {
+	p.once.Do(func() {
+		p.mu.Lock()
+		p.closed = true
+		p.cond.Broadcast()
+		p.mu.Unlock()
+		close(p.quit)
+	})
+	p.wg.Wait()
+}
+
+// submit queues a job and waits for a worker to accept it. It returns false
+// if the job's context was cancelled or the pool was closed first.
+func (p *Pool) submit(job *poolJob) bool {
+	job.taken = make(chan struct{})
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return false
+	}
+	p.seq++
+	job.seq = p.seq
+	heap.Push(&p.queue, job)
+	p.cond.Signal()
+	p.mu.Unlock()
+
+	select {
+	case <-job.taken:
+		return true
+	case <-job.ctx.Done():
+	case <-p.quit:
+	}
+
+	// Withdraw the job, unless a worker claimed it in the meantime; workers
+	// discard withdrawn jobs when they reach them
+	return !job.state.CompareAndSwap(jobPending, jobAbandoned)
+}
+
+// run submits every chunk of directories from chunks and returns once all
+// accepted jobs have delivered their results to results. It stops submitting
+// when ctx is cancelled or the pool is closed. results must be drained by the
+// caller or buffered for every directory.
+func (p *Pool) run(ctx context.Context, strategy Strategy, opts ScanOptions, chunks <-chan []string, results chan<- Result) {
+	var wg sync.WaitGroup
+	for dirs := range chunks {
+		wg.Add(1)
+		job := &poolJob{ctx: ctx, strategy: strategy, opts: opts, dirs: dirs, results: results, done: wg.Done}
+		if !p.submit(job) {
+			wg.Done()
+			break
+		}
+	}
+	wg.Wait()
+}
+
+// measure runs a single measurement on the pool and waits for its result.
+func (p *Pool) measure(ctx context.Context, strategy Strategy, opts ScanOptions, dir string) Result {
+	results := make(chan Result, 1)
+	job := &poolJob{ctx: ctx, strategy: strategy, opts: opts, dirs: []string{dir}, results: results, done: func() {}}
+	if !p.submit(job) {
+		err := ctx.Err()
+		if err == nil {
+			err = errPoolClosed
+		}
+		return Result{Path: dir, Error: err, Strategy: strategy.Name()}
+	}
+	select {
+	case r := <-results:
+		return r
+	case <-ctx.Done():
+		return Result{Path: dir, Error: ctx.Err(), Strategy: strategy.Name()}
+	}
+}
+
+// next blocks until a job is queued and returns the most urgent one, or
+// returns nil once the pool is closed.
+func (p *Pool) next() *poolJob {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for p.queue.Len() == 0 && !p.closed {
+		p.cond.Wait()
+	}
+	if p.closed {
+		return nil
+	}
+	return heap.Pop(&p.queue).(*poolJob)
+}
+
+func (p *Pool) worker() {
+	defer p.wg.Done()
+	for {
+		job := p.next()
+		if job == nil {
+			return
+		}
+		if !job.state.CompareAndSwap(jobPending, jobTaken) {
+			continue
+		}
+		close(job.taken)
+
+		for _, r := range measureDirs(job.ctx, job.strategy, job.opts, job.dirs) {
+			select {
+			case job.results <- r:
+			case <-job.ctx.Done():
+			}
+		}
+		job.done()
+	}
+}
+
+// jobQueue is a heap of pending jobs ordered by descending priority, then
+// by submission order.
+type jobQueue []*poolJob
+
+func (q jobQueue) Len() int { return len(q) }
+
+func (q jobQueue) Less(i, j int) bool {
+	if q[i].opts.Priority != q[j].opts.Priority {
+		return q[i].opts.Priority > q[j].opts.Priority
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q jobQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *jobQueue) Push(x any) { *q = append(*q, x.(*poolJob)) }
+
+func (q *jobQueue) Pop() any {
+	old := *q
+	job := old[len(old)-1]
+	old[len(old)-1] = nil
+	*q = old[:len(old)-1]
+	return job
+}
@@ -0,0 +1,103 @@
+package scanner
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// orderStrategy records the order directories are measured in. Measuring
+// "block" reports on started and waits for release.
+type orderStrategy struct {
+	started chan struct{}
+	release chan struct{}
+
+	mu    sync.Mutex
+	order []string
+}
+
+func (s *orderStrategy) Name() string { return "order" }
+
+func (s *orderStrategy) GetSize(ctx context.Context, path string) (int64, error) {
+	if path == "block" {
+		close(s.started)
+		<-s.release
+		return 0, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.order = append(s.order, path)
+	return 0, nil
+}
+
+// queued returns the number of jobs waiting in the pool's queue.
+func (p *Pool) queued() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.queue.Len()
+}
+
+func TestPoolOrder(t *testing.T) {
+	p := NewPool(1)
+	defer p.Close()
+	s := &orderStrategy{started: make(chan struct{}), release: make(chan struct{})}
+	ctx := context.Background()
+
+	// Occupy the only worker so the jobs below queue up
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		p.measure(ctx, s, ScanOptions{}, "block")
+	}()
+	<-s.started
+
+	jobs := []struct {
+		dir      string
+		priority int
+	}{
+		{"low1", 0},
+		{"high1", 5},
+		{"low2", 0},
+		{"mid", 2},
+		{"high2", 5},
+		{"low3", 0},
+	}
+	for i, job := range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if r := p.measure(ctx, s, ScanOptions{Priority: job.priority}, job.dir); r.Error != nil {
+				t.Errorf("measure(%q): %v", job.dir, r.Error)
+			}
+		}()
+		// Submit one at a time so submission order is known
+		deadline := time.Now().Add(5 * time.Second)
+		for p.queued() < i+1 {
+			if time.Now().After(deadline) {
+				t.Fatalf("job %q was not queued", job.dir)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	close(s.release)
+	wg.Wait()
+
+	// Highest priority first, then in submission order
+	want := []string{"high1", "high2", "mid", "low1", "low2", "low3"}
+	if !slices.Equal(s.order, want) {
+		t.Errorf("measured %q, want %q", s.order, want)
+	}
+}
+
+func TestPoolClosed(t *testing.T) {
+	p := NewPool(1)
+	p.Close()
+	r := p.measure(context.Background(), &orderStrategy{}, ScanOptions{}, "dir")
+	if r.Error != errPoolClosed {
+		t.Errorf("measure after Close = %v, want %v", r.Error, errPoolClosed)
+	}
+}
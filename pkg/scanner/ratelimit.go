@@ -0,0 +1,60 @@
+package scanner
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimiter caps the filesystem operations walks make per second, shared
+// by every walk it is handed to. Each entry a walk reads or stats counts as
+// one operation. A nil RateLimiter does not limit.
+type RateLimiter struct {
+	mu     sync.Mutex
+	rate   float64 // operations per second
+	tokens float64 // operations available now; negative when reserved ahead
+	last   time.Time
+}
+
+// NewRateLimiter returns a limiter allowing perSecond operations a second,
+// in bursts of up to a second's worth.
+func NewRateLimiter(perSecond int) *RateLimiter {
+	return &RateLimiter{rate: float64(perSecond), tokens: float64(perSecond), last: time.Now()}
+}
+
+// Wait blocks until one more operation may proceed or ctx is cancelled.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	delay := l.reserve()
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// reserve takes one operation and returns how long to wait before making
+// it. Waiters reserve in turn, so concurrent walks share the rate fairly.
+func (l *RateLimiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.rate {
+		l.tokens = l.rate
+	}
+	l.last = now
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
@@ -0,0 +1,1040 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// visitedSet tracks visited directories by device+inode pairs to prevent loops.
+type visitedSet map[uint64]map[uint64]bool
+
+// seen checks if a path has been visited, and marks it as visited if not.
+// Returns true if the path was already visited.
+func (v visitedSet) seen(path string) (bool, error) {
+	dev, ino, err := fileID(path)
+	if err != nil {
+		return false, err
+	}
+	return v.add(dev, ino), nil
+}
+
+// add marks the directory with the given device and inode as visited.
+// Returns true if it was already visited.
+func (v visitedSet) add(dev, ino uint64) bool {
+	if v[dev] == nil {
+		v[dev] = make(map[uint64]bool)
+	}
+	if v[dev][ino] {
+		return true
+	}
+	v[dev][ino] = true
+	return false
+}
+
+// dirVisitor decides which directories an enumeration descends into: each
+// directory once and, with ScanOptions.OneFileSystem, only those on the
+// base path's filesystem.
+type dirVisitor struct {
+	visited visitedSet
+	dev     uint64 // device of the base path
+	oneFS   bool
+}
+
+// newDirVisitor returns a dirVisitor for an enumeration of basePath, which is
+// marked as visited.
+func newDirVisitor(basePath string, opts ScanOptions) (*dirVisitor, error) {
+	dev, ino, err := fileID(basePath)
+	if err != nil {
+		return nil, err
+	}
+	v := &dirVisitor{visited: make(visitedSet), dev: dev, oneFS: opts.OneFileSystem}
+	v.visited.add(dev, ino)
+	return v, nil
+}
+
+// skip reports whether the directory at path is left out because it cannot
+// be stat'ed, was already visited, or is on another filesystem. Directories
+// that are not skipped are marked as visited.
+func (v *dirVisitor) skip(path string) bool {
+	dev, ino, err := fileID(path)
+	if err != nil || (v.oneFS && dev != v.dev) {
+		return true
+	}
+	return v.visited.add(dev, ino)
+}
+
+// fileID returns the device and inode of path, following symlinks.
+func fileID(path string) (dev, ino uint64, err error) {
+	var stat syscall.Stat_t
+	if err := syscall.Stat(path, &stat); err != nil {
+		return 0, 0, err
+	}
+	return uint64(stat.Dev), stat.Ino, nil
+}
+
+// ScanOptions holds options for scanning operations.
+type ScanOptions struct {
+	FollowSymlinks  bool
+	Exclude         []string      // paths to skip during enumeration, or globs to skip everywhere (see IsExcludeGlob)
+	SkipPaths       []string      // paths to skip during enumeration, taken literally even if they contain glob characters
+	ExcludeFiles    []string      // file name globs to skip during size calculation (forces walk)
+	DropCache       bool          // advise dropping directory pages from the page cache during walks (best effort)
+	Fingerprint     bool          // compute per-directory change fingerprints (forces walk)
+	Allocated       bool          // also measure allocated (on-disk) size (forces walk); same as SizeMode both
+	SizeMode        string        // what SizeBytes measures, one of SizeModes; "" for apparent
+	Counts          bool          // also count files and subdirectories (walk or ceph; never du)
+	DedupeHardlinks bool          // count hard-linked files once per directory in walks, as du does
+	OneFileSystem   bool          // stay on the base path's filesystem, like du -x
+	ByOwner         bool          // also sum sizes per file owner UID (forces walk)
+	LargestFiles    int           // also keep the N largest files of each directory (forces walk); at most MaxLargestFiles
+	AgeDays         []int         // also split sizes by file age at these ascending bounds in days (forces walk)
+	AgeTime         string        // file time AgeDays applies to, one of AgeTimes; "" for mtime
+	Throttle        Throttle      // optional gate consulted before each measurement
+	Logger          *slog.Logger  // receives scanner warnings; nil for slog.Default
+	StatfsTimeout   time.Duration // bound on filesystem type detection; 0 for DefaultStatfsTimeout
+	DirTimeout      time.Duration // bound on measuring one directory, which then fails with ErrDirTimeout; 0 for none
+	LooseFiles      bool          // also measure files directly in intermediate directories
+	Strategy        string        // named strategy overriding the scanner's own; "" or "auto" to keep it
+	Owner           bool          // record each directory's owning user and group
+	Priority        int           // higher goes first when scans compete for a shared Pool
+	BatchSize       int           // directories measured per du invocation; 0 or 1 measures one at a time
+	SkipDirs        *DirSet       // directories and loose-files entries measured elsewhere (another base path, an interrupted scan); left out
+	WalkWorkers     int           // goroutines walking one directory's tree; 0 or 1 walks it sequentially
+	Limiter         *RateLimiter  // caps the entries walks read per second; nil for no limit
+	DuWrapper       []string      // command and arguments du runs under, e.g. ionice and nice; nil to run it directly
+
+	// Baseline holds previous measurements by directory. When set, a
+	// directory whose mtime predates its baseline is not measured again
+	// (the mtime shortcut). Ignored when Fingerprint is set.
+	Baseline map[string]Baseline
+
+	// Rctime checks directories on CephFS against Baseline by their
+	// recursive ctime (ceph.dir.rctime), which changes with anything in
+	// their tree, in place of their mtime (the rctime shortcut). With
+	// RctimeOnly, directories whose rctime cannot be read are always
+	// measured rather than checked by mtime.
+	Rctime     bool
+	RctimeOnly bool
+
+	// DirCache, when set, lets walks skip the files of directories whose
+	// mtime is unchanged since an earlier walk (forces walk). It is ignored
+	// with options that need every file: ExcludeFiles, exclude globs,
+	// Fingerprint, DedupeHardlinks, ByOwner, LargestFiles and AgeDays.
+	DirCache *DirCache
+
+	// Discovered, when set, is called from ScanPathStreaming with each
+	// directory as it is enumerated, before it is measured. It is called
+	// from one goroutine at a time and must not block.
+	Discovered func(dir string)
+}
+
+// Throttle delays measurements, e.g. while the host is under I/O pressure.
+type Throttle interface {
+	// Wait blocks until work may proceed or ctx is cancelled.
+	Wait(ctx context.Context) error
+}
+
+// Result represents the result of scanning a single directory.
+type Result struct {
+	Path           string
+	SizeBytes      int64
+	SymlinkCount   *int64 // nil unless the strategy counts symlinks (walk)
+	Fingerprint    string // empty unless ScanOptions.Fingerprint was set
+	AllocatedBytes *int64 // nil unless ScanOptions.Allocated or SizeMode asked for it
+	Error          error
+	Duration       time.Duration
+	Strategy       string
+	Reused         bool   // measurement was taken from ScanOptions.Baseline
+	Owner          string // owning user name or UID; empty unless ScanOptions.Owner was set
+	Group          string // owning group name or GID; empty unless ScanOptions.Owner was set
+	QuotaLimit     int64  // filesystem-enforced byte quota; 0 if none or not reported
+	FileCount      *int64 // files (non-directory entries) in the tree; nil unless ScanOptions.Counts was set
+	DirCount       *int64 // subdirectories in the tree; nil unless ScanOptions.Counts was set
+
+	// OwnerBytes splits SizeBytes by the UID owning each file; nil unless
+	// ScanOptions.ByOwner was set
+	OwnerBytes map[uint32]int64
+
+	// LargestFiles holds the largest files in the tree, largest first; nil
+	// unless ScanOptions.LargestFiles was set
+	LargestFiles []FileSize
+
+	// AgeBytes splits SizeBytes by file age, one entry per bucket of
+	// ScanOptions.AgeDays from youngest to oldest; nil unless AgeDays was set
+	AgeBytes []int64
+}
+
+// QuotaUsed returns the share of the quota in use as a percentage, or -1 when
+// the directory has no quota.
+func (r Result) QuotaUsed() float64 {
+	if r.QuotaLimit <= 0 {
+		return -1
+	}
+	return 100 * float64(r.SizeBytes) / float64(r.QuotaLimit)
+}
+
+// Scanner orchestrates directory size scanning with a worker pool.
+type Scanner struct {
+	workers  int
+	strategy Strategy
+	pool     *Pool
+}
+
+// New creates a new Scanner with the specified number of workers.
+// If strategy is nil, it will be auto-detected per scan.
+func New(workers int, strategy Strategy) *Scanner {
+	if workers < 1 {
+		workers = 1
+	}
+	return &Scanner{
+		workers:  workers,
+		strategy: strategy,
+	}
+}
+
+// UsePool makes scans submit work to a shared pool instead of starting their
+// own workers. Concurrent scans of different base paths then form a single
+// work stream bounded by the pool's size, with each result delivered back to
+// the scan that submitted it. Passing nil restores per-scan workers.
+func (s *Scanner) UsePool(p *Pool) {
+	s.pool = p
+}
+
+// ScanPath scans all directories at the given depth under basePath.
+// If depth is 0, it scans basePath itself.
+func (s *Scanner) ScanPath(ctx context.Context, basePath string, depth int) ([]Result, error) {
+	return s.ScanPathWithOptions(ctx, basePath, depth, ScanOptions{})
+}
+
+// ScanPathWithOptions scans all directories at the given depth under basePath with options.
+// If depth is 0, it scans basePath itself. A negative depth scans the leaf
+// directories of every branch, however deep each one is.
+func (s *Scanner) ScanPathWithOptions(ctx context.Context, basePath string, depth int, opts ScanOptions) ([]Result, error) {
+	var dirs []string
+	var err error
+	if depth < 0 {
+		dirs, err = leafDirectories(ctx, basePath, opts)
+	} else {
+		dirs, err = s.getDirectoriesAtDepth(basePath, depth, opts)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(dirs) == 0 {
+		return nil, nil
+	}
+
+	strategy := s.resolveStrategy(opts)
+	chunks := chunkSlice(dirs, batchSize(strategy, opts))
+
+	workCh := make(chan []string, len(chunks))
+	resultCh := make(chan Result, len(dirs))
+
+	// With a shared pool, measure there so the global worker cap holds
+	if s.pool != nil {
+		for _, chunk := range chunks {
+			workCh <- chunk
+		}
+		close(workCh)
+		s.pool.run(ctx, strategy, opts, workCh, resultCh)
+		close(resultCh)
+
+		var results []Result
+		for r := range resultCh {
+			results = append(results, r)
+		}
+		return results, ctx.Err()
+	}
+
+	// Spawn worker pool
+	var wg sync.WaitGroup
+	for i := 0; i < s.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for chunk := range workCh {
+				for _, r := range measureDirs(ctx, strategy, opts, chunk) {
+					resultCh <- r
+				}
+			}
+		}()
+	}
+
+	// Send work
+	for _, chunk := range chunks {
+		select {
+		case workCh <- chunk:
+		case <-ctx.Done():
+			close(workCh)
+			// Drain remaining results
+			go func() { wg.Wait(); close(resultCh) }()
+			var results []Result
+			for r := range resultCh {
+				results = append(results, r)
+			}
+			return results, ctx.Err()
+		}
+	}
+	close(workCh)
+
+	// Collect results
+	go func() { wg.Wait(); close(resultCh) }()
+
+	var results []Result
+	for r := range resultCh {
+		results = append(results, r)
+	}
+
+	return results, nil
+}
+
+// ScanPathStreaming scans directories and sends results to a channel as they complete.
+// The channel is closed when scanning is done. Caller should check ctx.Err() after
+// the channel closes to determine if the scan completed successfully or was cancelled.
+//
+// This implementation uses streaming enumeration: intermediate directory levels (0 to depth-1)
+// are enumerated synchronously (typically small), then level N directories are streamed
+// directly to workers as they're discovered. This allows workers to start processing
+// immediately rather than waiting for all directories to be enumerated first.
+func (s *Scanner) ScanPathStreaming(ctx context.Context, basePath string, depth int, opts ScanOptions) (<-chan Result, error) {
+	// Validate basePath upfront
+	info, err := os.Stat(basePath)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		resultCh := make(chan Result)
+		close(resultCh)
+		return resultCh, nil
+	}
+
+	strategy := s.resolveStrategy(opts)
+
+	// Bounded channels - no pre-sizing to len(dirs)
+	dirCh := make(chan string, s.workers*4)
+	resultCh := make(chan Result, s.workers*2)
+
+	chunkCh := make(chan []string, s.workers)
+
+	// Start enumerator goroutine FIRST
+	go func() {
+		// Negative depth means the leaf directories of every branch
+		if depth < 0 {
+			streamLeafDirectories(ctx, basePath, opts, dirCh)
+			return
+		}
+		s.streamDirectoriesAtDepth(ctx, basePath, depth, opts, dirCh)
+	}()
+	go chunkDirs(ctx, dirCh, batchSize(strategy, opts), opts.Discovered, chunkCh)
+
+	// With a shared pool, feed directories to it instead of starting workers
+	if s.pool != nil {
+		go func() {
+			defer close(resultCh)
+			s.pool.run(ctx, strategy, opts, chunkCh, resultCh)
+		}()
+		return resultCh, nil
+	}
+
+	// Start workers immediately - they begin as soon as dirs arrive
+	go func() {
+		defer close(resultCh)
+		var wg sync.WaitGroup
+		for i := 0; i < s.workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for chunk := range chunkCh {
+					for _, r := range measureDirs(ctx, strategy, opts, chunk) {
+						select {
+						case resultCh <- r:
+						case <-ctx.Done():
+							return
+						}
+					}
+				}
+			}()
+		}
+		wg.Wait()
+	}()
+
+	return resultCh, nil
+}
+
+// ScanSingle scans a single directory and returns its size.
+func (s *Scanner) ScanSingle(ctx context.Context, path string) (Result, error) {
+	return s.ScanSingleWithOptions(ctx, path, ScanOptions{})
+}
+
+// ScanSingleWithOptions scans a single directory and returns its size with options.
+func (s *Scanner) ScanSingleWithOptions(ctx context.Context, path string, opts ScanOptions) (Result, error) {
+	strategy := s.resolveStrategy(opts)
+	if s.pool != nil {
+		return s.pool.measure(ctx, strategy, opts, path), nil
+	}
+	return measureDir(ctx, strategy, opts, path), nil
+}
+
+// measureDir measures a single directory and, with opts.Owner, records who
+// owns it. An owner lookup failure is not treated as a measurement error.
+func measureDir(ctx context.Context, strategy Strategy, opts ScanOptions, dir string) Result {
+	return withOwner(opts, measureDirSize(ctx, strategy, opts, dir))
+}
+
+// withOwner fills in r's owner and group when opts.Owner is set. In the
+// allocated size mode it also copies the size to AllocatedBytes, whichever
+// strategy measured it, so records say what their size is.
+func withOwner(opts ScanOptions, r Result) Result {
+	if r.Error != nil {
+		return r
+	}
+	if opts.Owner {
+		r.Owner, r.Group, _ = directoryOwner(r.Path)
+	}
+	if opts.SizeMode == SizeAllocated {
+		size := r.SizeBytes
+		r.AllocatedBytes = &size
+	}
+	return r
+}
+
+// Size modes select what a measurement's SizeBytes holds.
+const (
+	SizeApparent  = "apparent"  // sum of file lengths, as du --apparent-size
+	SizeAllocated = "allocated" // disk blocks allocated, as du without options
+	SizeBoth      = "both"      // apparent, plus the allocated size in AllocatedBytes (forces walk)
+)
+
+// SizeModes lists the size modes that can be selected by name.
+var SizeModes = []string{SizeApparent, SizeAllocated, SizeBoth}
+
+// ValidSizeMode reports whether name is one of SizeModes.
+func ValidSizeMode(name string) bool {
+	for _, m := range SizeModes {
+		if m == name {
+			return true
+		}
+	}
+	return false
+}
+
+// dirCache returns the DirCache walks may use, or nil if there is none or the
+// options need every file to be read.
+func (o ScanOptions) dirCache() *DirCache {
+	if len(o.ExcludeFiles) > 0 || len(excludeGlobs(o.Exclude)) > 0 || o.Fingerprint || o.DedupeHardlinks ||
+		o.ByOwner || o.LargestFiles > 0 || len(o.AgeDays) > 0 {
+		return nil
+	}
+	return o.DirCache
+}
+
+// measuresBoth reports whether the apparent and allocated sizes are both
+// measured, which only the walk strategy can do in one pass.
+func (o ScanOptions) measuresBoth() bool {
+	return o.Allocated || o.SizeMode == SizeBoth
+}
+
+// measureDirSize measures a single directory, resolving AutoStrategy to the
+// concrete strategy for that directory and using Measurer when available.
+// If opts.Throttle is set, it is waited on before measuring. With
+// opts.Baseline, a directory not modified since its previous measurement
+// reuses that measurement instead. With opts.LooseFiles, synthetic
+// loose-files entries are measured from their parent's direct entries.
+func measureDirSize(ctx context.Context, strategy Strategy, opts ScanOptions, dir string) Result {
+	if r, ok := reuseBaseline(opts, dir); ok {
+		return r
+	}
+
+	if opts.Throttle != nil {
+		if err := opts.Throttle.Wait(ctx); err != nil {
+			return Result{Path: dir, Error: err, Strategy: strategy.Name()}
+		}
+	}
+
+	if opts.LooseFiles && isLooseFilesPath(dir) {
+		return withDirTimeout(ctx, opts, dir, "loose-files", func(ctx context.Context) Result {
+			return measureLooseFiles(ctx, opts, dir)
+		})
+	}
+
+	return measureTimed(ctx, opts, effectiveStrategy(strategy, dir), dir)
+}
+
+// ErrDirTimeout is the error of a directory whose measurement took longer
+// than ScanOptions.DirTimeout.
+var ErrDirTimeout = errors.New("directory measurement timed out")
+
+// measureTimed measures dir as measureWith does, within opts.DirTimeout.
+func measureTimed(ctx context.Context, opts ScanOptions, effectiveStrategy Strategy, dir string) Result {
+	return withDirTimeout(ctx, opts, dir, effectiveStrategy.Name(), func(ctx context.Context) Result {
+		return measureWith(ctx, effectiveStrategy, dir)
+	})
+}
+
+// withDirTimeout runs measure with a context cancelled after opts.DirTimeout,
+// if one is set. A measurement still running at the deadline fails with
+// ErrDirTimeout without waiting for it: like statfsTimeout, it may be
+// blocked in the kernel on a hung mount, and is left to finish in the
+// background while the worker moves on.
+func withDirTimeout(ctx context.Context, opts ScanOptions, dir, strategy string, measure func(context.Context) Result) Result {
+	if opts.DirTimeout <= 0 {
+		return measure(ctx)
+	}
+	start := time.Now()
+	timeoutCtx, cancel := context.WithTimeout(ctx, opts.DirTimeout)
+	defer cancel()
+
+	done := make(chan Result, 1)
+	go func() { done <- measure(timeoutCtx) }()
+	var r Result
+	select {
+	case r = <-done:
+		if r.Error == nil || timeoutCtx.Err() == nil {
+			return r
+		}
+	case <-timeoutCtx.Done():
+		r = Result{Path: dir, Error: timeoutCtx.Err(), Duration: time.Since(start), Strategy: strategy}
+	}
+	if ctx.Err() == nil {
+		r.Error = fmt.Errorf("%w after %s", ErrDirTimeout, opts.DirTimeout)
+	}
+	return r
+}
+
+// resolver is implemented by strategies that hand some directories to another
+// strategy (AutoStrategy and the filesystem accounting strategies).
+type resolver interface {
+	StrategyFor(path string) Strategy
+}
+
+// effectiveStrategy returns the concrete strategy that measures dir,
+// resolving strategies that hand some directories to another.
+func effectiveStrategy(strategy Strategy, dir string) Strategy {
+	if r, ok := strategy.(resolver); ok {
+		return r.StrategyFor(dir)
+	}
+	return strategy
+}
+
+// measureWith measures dir with a concrete strategy, using Measurer when
+// available.
+func measureWith(ctx context.Context, effectiveStrategy Strategy, dir string) Result {
+	start := time.Now()
+
+	var m Measurement
+	var err error
+	if measurer, ok := effectiveStrategy.(Measurer); ok {
+		m, err = measurer.Measure(ctx, dir)
+	} else {
+		m.SizeBytes, err = effectiveStrategy.GetSize(ctx, dir)
+	}
+
+	return Result{
+		Path:           dir,
+		SizeBytes:      m.SizeBytes,
+		SymlinkCount:   m.SymlinkCount,
+		Fingerprint:    m.Fingerprint,
+		AllocatedBytes: m.AllocatedBytes,
+		QuotaLimit:     m.QuotaLimit,
+		FileCount:      m.FileCount,
+		DirCount:       m.DirCount,
+		OwnerBytes:     m.OwnerBytes,
+		LargestFiles:   m.LargestFiles,
+		AgeBytes:       m.AgeBytes,
+		Error:          err,
+		Duration:       time.Since(start),
+		Strategy:       effectiveStrategy.Name(),
+	}
+}
+
+// resolveStrategy determines the strategy for a scan with the given options.
+// File exclusions, fingerprints, allocated sizes, per-owner sizes, largest
+// files and age breakdowns can only be produced by the walk strategy, and only
+// it uses a DirCache, so they force it. Otherwise opts.Strategy, when set, takes precedence over the
+// scanner's strategy.
+func (s *Scanner) resolveStrategy(opts ScanOptions) Strategy {
+	globs := excludeGlobs(opts.Exclude)
+	allocated := opts.SizeMode == SizeAllocated
+	walk := &WalkStrategy{
+		ExcludeFiles:    opts.ExcludeFiles,
+		Exclude:         globs,
+		DropCache:       opts.DropCache,
+		Fingerprint:     opts.Fingerprint,
+		Allocated:       opts.measuresBoth(),
+		SizeAllocated:   allocated,
+		Counts:          opts.Counts,
+		DedupeHardlinks: opts.DedupeHardlinks,
+		OneFileSystem:   opts.OneFileSystem,
+		ByOwner:         opts.ByOwner,
+		LargestFiles:    opts.LargestFiles,
+		AgeDays:         opts.AgeDays,
+		AgeTime:         opts.AgeTime,
+		Cache:           opts.dirCache(),
+		Workers:         opts.WalkWorkers,
+		Limiter:         opts.Limiter,
+	}
+	if len(opts.ExcludeFiles) > 0 || opts.Fingerprint || opts.measuresBoth() || opts.ByOwner || opts.LargestFiles > 0 ||
+		len(opts.AgeDays) > 0 || walk.Cache != nil {
+		return walk
+	}
+	switch opts.Strategy {
+	case "walk":
+		return walk
+	case "walk-uring":
+		return &WalkUringStrategy{Walk: walk, logger: opts.Logger}
+	case "du":
+		// A missing binary surfaces as a measurement error rather than a
+		// silent switch to another strategy the operator did not choose
+		duPath, err := exec.LookPath("du")
+		if err != nil {
+			duPath = "du"
+		}
+		return &DuStrategy{duPath: duPath, wrapper: opts.DuWrapper, exclude: globs, allocated: allocated, oneFileSystem: opts.OneFileSystem}
+	case "ceph":
+		// Ceph's recursive stats cannot leave anything out; config
+		// validation rejects exclude globs with this strategy
+		return &CephStrategy{Counts: opts.Counts}
+	case "btrfs", "lustre", "gpfs":
+		// Like a forced du, directories the filesystem's accounting does not
+		// cover do not fall back any further
+		duPath, err := exec.LookPath("du")
+		if err != nil {
+			duPath = "du"
+		}
+		du := &DuStrategy{duPath: duPath, wrapper: opts.DuWrapper, allocated: allocated, oneFileSystem: opts.OneFileSystem}
+		switch opts.Strategy {
+		case "btrfs":
+			return &BtrfsStrategy{Fallback: du, statfsTimeout: opts.StatfsTimeout}
+		case "lustre":
+			return &LustreStrategy{Fallback: du, statfsTimeout: opts.StatfsTimeout}
+		default:
+			return &GPFSStrategy{Fallback: du, statfsTimeout: opts.StatfsTimeout}
+		}
+	}
+	if s.strategy == nil {
+		auto := NewAutoStrategy()
+		auto.walk = walk
+		auto.exclude = globs
+		auto.counts = opts.Counts
+		auto.allocated = allocated
+		auto.oneFileSystem = opts.OneFileSystem
+		auto.logger = opts.Logger
+		auto.statfsTimeout = opts.StatfsTimeout
+		auto.duWrapper = opts.DuWrapper
+		return auto
+	}
+	return s.strategy
+}
+
+// Strategy returns the scanner's strategy name.
+func (s *Scanner) Strategy() string {
+	if s.strategy != nil {
+		return s.strategy.Name()
+	}
+	return "auto"
+}
+
+// getDirectoriesAtDepth returns all directories at exactly the specified depth.
+// Depth 0 returns just the basePath itself (if it's a directory).
+// Depth 1 returns immediate subdirectories, etc. With opts.LooseFiles, a
+// loose-files entry is added for every intermediate directory.
+func (s *Scanner) getDirectoriesAtDepth(basePath string, depth int, opts ScanOptions) ([]string, error) {
+	info, err := os.Stat(basePath)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, nil
+	}
+
+	if depth == 0 {
+		if opts.SkipDirs.Contains(basePath) {
+			return nil, nil
+		}
+		return []string{basePath}, nil
+	}
+
+	visited, err := newDirVisitor(basePath, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	currentLevel := []string{basePath}
+	var loose []string
+
+	for d := 0; d < depth; d++ {
+		var nextLevel []string
+		for _, dir := range currentLevel {
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				// Skip directories we can't read
+				continue
+			}
+			if wantLooseFiles(dir, opts) {
+				loose = append(loose, LooseFilesPath(dir))
+			}
+			for _, entry := range entries {
+				entryPath := filepath.Join(dir, entry.Name())
+
+				if isSymlink(entry) {
+					if !opts.FollowSymlinks {
+						continue
+					}
+					// Follow symlink and check if it points to a directory
+					targetInfo, err := os.Stat(entryPath)
+					if err != nil {
+						// Broken symlink or permission error
+						continue
+					}
+					if !targetInfo.IsDir() {
+						continue
+					}
+					// Check for loops
+					if visited.skip(entryPath) {
+						continue
+					}
+					if opts.Excludes(entryPath) {
+						continue
+					}
+					nextLevel = append(nextLevel, entryPath)
+				} else if entry.IsDir() {
+					// Check for loops (even for non-symlinks, in case of bind mounts)
+					if visited.skip(entryPath) {
+						continue
+					}
+					if opts.Excludes(entryPath) {
+						continue
+					}
+					nextLevel = append(nextLevel, entryPath)
+				}
+			}
+		}
+		currentLevel = nextLevel
+	}
+
+	if opts.SkipDirs != nil {
+		kept := currentLevel[:0]
+		for _, dir := range currentLevel {
+			if !opts.SkipDirs.Contains(dir) {
+				kept = append(kept, dir)
+			}
+		}
+		currentLevel = kept
+	}
+
+	return append(currentLevel, loose...), nil
+}
+
+// streamDirectoriesAtDepth enumerates directories at the specified depth and streams them
+// to dirCh as they're discovered. Levels 0 to depth-1 are enumerated synchronously (small),
+// then level N directories are streamed directly to the channel. Loose-files
+// entries for intermediate directories are sent as those directories are read.
+// The channel is closed when enumeration completes or context is cancelled.
+func (s *Scanner) streamDirectoriesAtDepth(ctx context.Context, basePath string, depth int, opts ScanOptions, dirCh chan<- string) {
+	defer close(dirCh)
+
+	// Handle depth 0: just send basePath
+	if depth == 0 {
+		if opts.SkipDirs.Contains(basePath) {
+			return
+		}
+		select {
+		case dirCh <- basePath:
+		case <-ctx.Done():
+		}
+		return
+	}
+
+	visited, err := newDirVisitor(basePath, opts)
+	if err != nil {
+		return
+	}
+
+	sendLoose := func(dir string) bool {
+		if !wantLooseFiles(dir, opts) {
+			return true
+		}
+		select {
+		case dirCh <- LooseFilesPath(dir):
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	// Enumerate levels 0 to depth-1 synchronously (these are typically small)
+	currentLevel := []string{basePath}
+	for d := 0; d < depth-1; d++ {
+		var nextLevel []string
+		for _, dir := range currentLevel {
+			select {
+			case <-ctx.Done():
+				return
+			default:
+			}
+
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				// Skip directories we can't read
+				continue
+			}
+			if !sendLoose(dir) {
+				return
+			}
+			for _, entry := range entries {
+				entryPath := filepath.Join(dir, entry.Name())
+
+				if isSymlink(entry) {
+					if !opts.FollowSymlinks {
+						continue
+					}
+					// Follow symlink and check if it points to a directory
+					targetInfo, err := os.Stat(entryPath)
+					if err != nil {
+						continue
+					}
+					if !targetInfo.IsDir() {
+						continue
+					}
+					if visited.skip(entryPath) {
+						continue
+					}
+					if opts.Excludes(entryPath) {
+						continue
+					}
+					nextLevel = append(nextLevel, entryPath)
+				} else if entry.IsDir() {
+					if visited.skip(entryPath) {
+						continue
+					}
+					if opts.Excludes(entryPath) {
+						continue
+					}
+					nextLevel = append(nextLevel, entryPath)
+				}
+			}
+		}
+		currentLevel = nextLevel
+	}
+
+	// Stream the final level (level N) directly to the channel as directories are discovered
+	for _, dir := range currentLevel {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		if !sendLoose(dir) {
+			return
+		}
+		for _, entry := range entries {
+			entryPath := filepath.Join(dir, entry.Name())
+
+			var shouldSend bool
+
+			if isSymlink(entry) {
+				if !opts.FollowSymlinks {
+					continue
+				}
+				targetInfo, err := os.Stat(entryPath)
+				if err != nil {
+					continue
+				}
+				if !targetInfo.IsDir() {
+					continue
+				}
+				if visited.skip(entryPath) {
+					continue
+				}
+				if opts.Excludes(entryPath) {
+					continue
+				}
+				shouldSend = true
+			} else if entry.IsDir() {
+				if visited.skip(entryPath) {
+					continue
+				}
+				if opts.Excludes(entryPath) {
+					continue
+				}
+				shouldSend = true
+			}
+
+			if shouldSend && !opts.SkipDirs.Contains(entryPath) {
+				select {
+				case dirCh <- entryPath:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}
+}
+
+// walkLeaves calls visit with every leaf directory under basePath, one
+// without subdirectories a scan would descend into, and with opts.LooseFiles
+// the loose-files entry of every other directory, so that together they
+// account for the whole tree. basePath is its own leaf when it has no
+// subdirectories. The walk stops early when visit returns false.
+func walkLeaves(ctx context.Context, basePath string, opts ScanOptions, visit func(string) bool) error {
+	info, err := os.Stat(basePath)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return nil
+	}
+
+	visited, err := newDirVisitor(basePath, opts)
+	if err != nil {
+		return err
+	}
+
+	// Depth first, so only the unvisited siblings along one branch are held
+	stack := []string{basePath}
+	for len(stack) > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		dir := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		children := childDirs(dir, opts, visited)
+		entry := dir
+		if len(children) > 0 {
+			stack = append(stack, children...)
+			if !wantLooseFiles(dir, opts) {
+				continue
+			}
+			entry = LooseFilesPath(dir)
+		} else if opts.SkipDirs.Contains(dir) {
+			continue
+		}
+		if !visit(entry) {
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// leafDirectories returns the entries walkLeaves finds under basePath.
+func leafDirectories(ctx context.Context, basePath string, opts ScanOptions) ([]string, error) {
+	var dirs []string
+	err := walkLeaves(ctx, basePath, opts, func(dir string) bool {
+		dirs = append(dirs, dir)
+		return true
+	})
+	return dirs, err
+}
+
+// streamLeafDirectories sends the entries walkLeaves finds under basePath to
+// dirCh as they are found, closing it when the walk ends.
+func streamLeafDirectories(ctx context.Context, basePath string, opts ScanOptions, dirCh chan<- string) {
+	defer close(dirCh)
+	walkLeaves(ctx, basePath, opts, func(dir string) bool {
+		select {
+		case dirCh <- dir:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	})
+}
+
+// DeepestLevel returns the deepest directory level reachable under basePath,
+// following the same enumeration rules as a scan (symlinks, excludes, loop
+// detection). The search stops at limit; a negative limit means no limit.
+// Level 0 is basePath itself.
+func DeepestLevel(basePath string, limit int, opts ScanOptions) (int, error) {
+	info, err := os.Stat(basePath)
+	if err != nil {
+		return 0, err
+	}
+	if !info.IsDir() {
+		return 0, nil
+	}
+
+	visited, err := newDirVisitor(basePath, opts)
+	if err != nil {
+		return 0, err
+	}
+
+	level := 0
+	currentLevel := []string{basePath}
+	for limit < 0 || level < limit {
+		var nextLevel []string
+		for _, dir := range currentLevel {
+			nextLevel = append(nextLevel, childDirs(dir, opts, visited)...)
+		}
+		if len(nextLevel) == 0 {
+			break
+		}
+		currentLevel = nextLevel
+		level++
+	}
+
+	return level, nil
+}
+
+// childDirs lists the subdirectories of dir that a scan would descend into.
+func childDirs(dir string, opts ScanOptions, visited *dirVisitor) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	var dirs []string
+	for _, entry := range entries {
+		entryPath := filepath.Join(dir, entry.Name())
+
+		if isSymlink(entry) {
+			if !opts.FollowSymlinks {
+				continue
+			}
+			targetInfo, err := os.Stat(entryPath)
+			if err != nil || !targetInfo.IsDir() {
+				continue
+			}
+		} else if !entry.IsDir() {
+			continue
+		}
+
+		if visited.skip(entryPath) {
+			continue
+		}
+		if opts.Excludes(entryPath) {
+			continue
+		}
+		dirs = append(dirs, entryPath)
+	}
+
+	return dirs
+}
+
+// wantLooseFiles reports whether a loose-files entry should be measured for
+// dir: one is wanted, and it is neither excluded nor in opts.SkipDirs (as
+// when a resumed scan has already measured it).
+func wantLooseFiles(dir string, opts ScanOptions) bool {
+	entry := LooseFilesPath(dir)
+	return opts.LooseFiles && !opts.Excludes(entry) && !opts.SkipDirs.Contains(entry)
+}
+
+// isSymlink checks if a directory entry is a symbolic link.
+func isSymlink(entry fs.DirEntry) bool {
+	return entry.Type()&fs.ModeSymlink != 0
+}
@@ -0,0 +1,171 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// Strategy defines the interface for directory size calculation methods.
+type Strategy interface {
+	// Name returns the strategy name for logging.
+	Name() string
+
+	// GetSize returns the total size in bytes of the given directory.
+	GetSize(ctx context.Context, path string) (int64, error)
+}
+
+// Measurement holds the size of a directory plus any extra details a
+// strategy was able to collect while computing it.
+type Measurement struct {
+	SizeBytes      int64
+	SymlinkCount   *int64 // nil when the strategy does not count symlinks
+	Fingerprint    string // hash of the tree's entries; empty unless requested (walk only)
+	AllocatedBytes *int64 // disk blocks allocated; nil unless requested (walk only)
+	QuotaLimit     int64  // byte quota enforced by the filesystem; 0 if none (ceph, btrfs, lustre, gpfs)
+	FileCount      *int64 // non-directory entries in the tree; nil unless requested (walk, ceph)
+	DirCount       *int64 // subdirectories in the tree, excluding itself; nil unless requested (walk, ceph)
+
+	// OwnerBytes splits SizeBytes by the UID owning each file; nil unless
+	// requested (walk only)
+	OwnerBytes map[uint32]int64
+
+	// LargestFiles holds the largest regular files in the tree, largest
+	// first; nil unless requested (walk only)
+	LargestFiles []FileSize
+
+	// AgeBytes splits SizeBytes into file age buckets, youngest first; nil
+	// unless requested (walk only)
+	AgeBytes []int64
+}
+
+// Measurer is implemented by strategies that can report more than the size.
+type Measurer interface {
+	Measure(ctx context.Context, path string) (Measurement, error)
+}
+
+// BatchStrategy is implemented by strategies that can measure several
+// directories in one operation. GetSizes returns the sizes it could measure,
+// keyed by path; paths missing from the result, or every path when an error
+// is returned, need to be measured individually.
+type BatchStrategy interface {
+	GetSizes(ctx context.Context, paths []string) (map[string]int64, error)
+}
+
+// CephFSMagic is the filesystem magic number for CephFS.
+const CephFSMagic = 0x00c36400
+
+// DefaultStatfsTimeout bounds filesystem type detection when no timeout is configured.
+const DefaultStatfsTimeout = 5 * time.Second
+
+// errStatfsTimeout is returned when statfs does not complete in time, which
+// usually means the path sits on a hung network mount.
+var errStatfsTimeout = errors.New("statfs timed out")
+
+// StrategyNames lists the strategies that can be selected by name. "auto"
+// detects the strategy per directory.
+var StrategyNames = []string{"auto", "walk", "walk-uring", "du", "ceph", "btrfs", "lustre", "gpfs"}
+
+// ValidStrategy reports whether name is one of StrategyNames.
+func ValidStrategy(name string) bool {
+	for _, n := range StrategyNames {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// DetectStrategy returns the best available strategy for the given path.
+// Note: followSymlinks only affects directory enumeration (finding dirs at depth N),
+// not size calculation. Strategies always resolve the target path but never follow
+// symlinks inside directories during size calculation.
+func DetectStrategy(path string, followSymlinks bool) Strategy {
+	cephfs, err := IsCephFS(path, DefaultStatfsTimeout)
+	if errors.Is(err, errStatfsTimeout) {
+		// du would block on the same mount; walk at least honours cancellation
+		return &WalkStrategy{}
+	}
+	if cephfs {
+		return &CephStrategy{}
+	}
+
+	if duPath, err := exec.LookPath("du"); err == nil {
+		return &DuStrategy{duPath: duPath}
+	}
+
+	return &WalkStrategy{}
+}
+
+// IsCephFS checks if the path is on a CephFS filesystem. It gives up after
+// timeout so a dead mount cannot stall detection; a non-positive timeout
+// uses DefaultStatfsTimeout.
+func IsCephFS(path string, timeout time.Duration) (bool, error) {
+	return onFilesystem(path, timeout, CephFSMagic)
+}
+
+// onFilesystem reports whether path is on a filesystem with the given magic
+// number, giving up after timeout.
+func onFilesystem(path string, timeout time.Duration, magic uint32) (bool, error) {
+	stat, err := statfsTimeout(path, timeout)
+	if err != nil {
+		return false, err
+	}
+	return uint32(stat.Type) == magic, nil
+}
+
+// duFallback returns fallback, or du when it is nil. The strategies that read
+// a filesystem's own accounting (btrfs, lustre, gpfs) use it for directories
+// the accounting does not cover.
+func duFallback(fallback Strategy) Strategy {
+	if fallback != nil {
+		return fallback
+	}
+	duPath, err := exec.LookPath("du")
+	if err != nil {
+		duPath = "du"
+	}
+	return &DuStrategy{duPath: duPath}
+}
+
+// measureFallback measures path with a fallback strategy, using Measurer when
+// available.
+func measureFallback(ctx context.Context, fallback Strategy, path string) (Measurement, error) {
+	if measurer, ok := fallback.(Measurer); ok {
+		return measurer.Measure(ctx, path)
+	}
+	size, err := fallback.GetSize(ctx, path)
+	return Measurement{SizeBytes: size}, err
+}
+
+// statfsTimeout runs statfs in a goroutine and waits at most timeout for it.
+// On timeout the goroutine is left blocked in the kernel until the mount
+// responds; there is no way to interrupt it.
+func statfsTimeout(path string, timeout time.Duration) (syscall.Statfs_t, error) {
+	if timeout <= 0 {
+		timeout = DefaultStatfsTimeout
+	}
+
+	type statfsResult struct {
+		stat syscall.Statfs_t
+		err  error
+	}
+	done := make(chan statfsResult, 1)
+	go func() {
+		var r statfsResult
+		r.err = syscall.Statfs(path, &r.stat)
+		done <- r
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case r := <-done:
+		return r.stat, r.err
+	case <-timer.C:
+		return syscall.Statfs_t{}, errStatfsTimeout
+	}
+}
@@ -0,0 +1,240 @@
+//go:build linux
+
+package scanner
+
+import (
+	"fmt"
+	"sync/atomic"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// io_uring constants from linux/io_uring.h.
+const (
+	ioringOpStatx        = 21
+	ioringEnterGetevents = 1
+	ioringOffSQRing      = 0
+	ioringOffCQRing      = 0x8000000
+	ioringOffSQEs        = 0x10000000
+)
+
+// uringEntries is the number of statx calls a walk keeps in flight.
+const uringEntries = 256
+
+// statxMask selects the statx fields a walk uses.
+const statxMask = unix.STATX_TYPE | unix.STATX_MODE | unix.STATX_NLINK | unix.STATX_INO |
+	unix.STATX_SIZE | unix.STATX_BLOCKS
+
+// uringParams is struct io_uring_params.
+type uringParams struct {
+	sqEntries, cqEntries, flags, sqThreadCPU, sqThreadIdle, features, wqFd uint32
+
+	resv  [3]uint32
+	sqOff uringSQOffsets
+	cqOff uringCQOffsets
+}
+
+// uringSQOffsets is struct io_sqring_offsets.
+type uringSQOffsets struct {
+	head, tail, ringMask, ringEntries, flags, dropped, array, resv1 uint32
+
+	userAddr uint64
+}
+
+// uringCQOffsets is struct io_cqring_offsets.
+type uringCQOffsets struct {
+	head, tail, ringMask, ringEntries, overflow, cqes, flags, resv1 uint32
+
+	userAddr uint64
+}
+
+// uringSQE is struct io_uring_sqe as a statx request fills it in.
+type uringSQE struct {
+	opcode   uint8
+	flags    uint8
+	ioprio   uint16
+	fd       int32  // directory the path is relative to
+	off      uint64 // addr2: the statx buffer
+	addr     uint64 // the path
+	len      uint32 // statx mask
+	opFlags  uint32 // statx flags
+	userData uint64
+	_        [3]uint64
+}
+
+// uringCQE is struct io_uring_cqe.
+type uringCQE struct {
+	userData uint64
+	res      int32
+	flags    uint32
+}
+
+// statSlot holds one statx call in flight. The kernel reads the path and
+// writes the result asynchronously, so both stay put until it completes.
+type statSlot struct {
+	buf  unix.Statx_t
+	path []byte // NUL-terminated name
+	name string
+	typ  uint8
+}
+
+// statRing submits the stats of a walk through an io_uring, so many are in
+// flight at once and their latency overlaps. Each completed stat is added to
+// walk.
+type statRing struct {
+	fd   int
+	walk *getdentsWalk
+	err  error // first io_uring_enter failure
+
+	sqRing, cqRing, sqeMem []byte
+	sqTail, sqMask         *uint32
+	sqArray                []uint32
+	sqes                   []uringSQE
+	cqHead, cqTail, cqMask *uint32
+	cqes                   []uringCQE
+
+	slots       []statSlot
+	free        []uint32 // indexes of unused slots
+	unsubmitted uint32
+	inflight    int
+}
+
+// newStatRing sets up an io_uring with room for entries requests. It fails
+// on kernels without io_uring, or where it is disabled (the
+// kernel.io_uring_disabled sysctl, container seccomp profiles).
+func newStatRing(entries uint32) (*statRing, error) {
+	var p uringParams
+	fd, _, errno := unix.Syscall(unix.SYS_IO_URING_SETUP, uintptr(entries), uintptr(unsafe.Pointer(&p)), 0)
+	if errno != 0 {
+		return nil, fmt.Errorf("io_uring_setup: %w", errno)
+	}
+	r := &statRing{fd: int(fd)}
+
+	var err error
+	mmap := func(offset int64, size uint32) []byte {
+		if err != nil {
+			return nil
+		}
+		var mem []byte
+		mem, err = unix.Mmap(r.fd, offset, int(size), unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED|unix.MAP_POPULATE)
+		return mem
+	}
+	r.sqRing = mmap(ioringOffSQRing, p.sqOff.array+p.sqEntries*4)
+	r.cqRing = mmap(ioringOffCQRing, p.cqOff.cqes+p.cqEntries*uint32(unsafe.Sizeof(uringCQE{})))
+	r.sqeMem = mmap(ioringOffSQEs, p.sqEntries*uint32(unsafe.Sizeof(uringSQE{})))
+	if err != nil {
+		r.Close()
+		return nil, fmt.Errorf("mapping io_uring: %w", err)
+	}
+
+	r.sqTail = (*uint32)(unsafe.Pointer(&r.sqRing[p.sqOff.tail]))
+	r.sqMask = (*uint32)(unsafe.Pointer(&r.sqRing[p.sqOff.ringMask]))
+	r.sqArray = unsafe.Slice((*uint32)(unsafe.Pointer(&r.sqRing[p.sqOff.array])), p.sqEntries)
+	r.sqes = unsafe.Slice((*uringSQE)(unsafe.Pointer(&r.sqeMem[0])), p.sqEntries)
+	r.cqHead = (*uint32)(unsafe.Pointer(&r.cqRing[p.cqOff.head]))
+	r.cqTail = (*uint32)(unsafe.Pointer(&r.cqRing[p.cqOff.tail]))
+	r.cqMask = (*uint32)(unsafe.Pointer(&r.cqRing[p.cqOff.ringMask]))
+	r.cqes = unsafe.Slice((*uringCQE)(unsafe.Pointer(&r.cqRing[p.cqOff.cqes])), p.cqEntries)
+
+	// No more requests than submission entries are ever in flight, so
+	// neither ring can overflow
+	r.slots = make([]statSlot, p.sqEntries)
+	r.free = make([]uint32, p.sqEntries)
+	for i := range r.free {
+		r.free[i] = uint32(i)
+	}
+	return r, nil
+}
+
+// Close tears the ring down. Requests must not be in flight.
+func (r *statRing) Close() error {
+	for _, mem := range [][]byte{r.sqRing, r.cqRing, r.sqeMem} {
+		if mem != nil {
+			unix.Munmap(mem)
+		}
+	}
+	return unix.Close(r.fd)
+}
+
+// statx queues a stat of name, relative to the directory open as dirfd,
+// first waiting for a slot if all are in flight.
+func (r *statRing) statx(dirfd int, typ uint8, name string) {
+	for len(r.free) == 0 {
+		if r.err != nil {
+			r.walk.add(typ, name, nil)
+			return
+		}
+		r.reap(1)
+	}
+	i := r.free[len(r.free)-1]
+	r.free = r.free[:len(r.free)-1]
+	slot := &r.slots[i]
+	slot.name, slot.typ = name, typ
+	slot.path = append(append(slot.path[:0], name...), 0)
+
+	tail := *r.sqTail
+	idx := tail & *r.sqMask
+	r.sqes[idx] = uringSQE{
+		opcode:   ioringOpStatx,
+		fd:       int32(dirfd),
+		off:      uint64(uintptr(unsafe.Pointer(&slot.buf))),
+		addr:     uint64(uintptr(unsafe.Pointer(&slot.path[0]))),
+		len:      statxMask,
+		opFlags:  unix.AT_SYMLINK_NOFOLLOW,
+		userData: uint64(i),
+	}
+	r.sqArray[idx] = idx
+	atomic.StoreUint32(r.sqTail, tail+1)
+	r.unsubmitted++
+	r.inflight++
+}
+
+// wait submits the queued requests and adds every result to the walk. A nil
+// ring has nothing to wait for.
+func (r *statRing) wait() {
+	for r != nil && r.inflight > 0 && r.err == nil {
+		r.reap(r.inflight)
+	}
+}
+
+// reap submits the queued requests, waits until at least min have
+// completed and adds the results of all that have.
+func (r *statRing) reap(min int) {
+	for {
+		n, _, errno := unix.Syscall6(unix.SYS_IO_URING_ENTER, uintptr(r.fd), uintptr(r.unsubmitted), uintptr(min),
+			ioringEnterGetevents, 0, 0)
+		if errno == unix.EINTR {
+			continue
+		}
+		if errno != 0 {
+			r.err = fmt.Errorf("io_uring_enter: %w", errno)
+			return
+		}
+		r.unsubmitted -= uint32(n)
+		break
+	}
+
+	head := *r.cqHead
+	tail := atomic.LoadUint32(r.cqTail)
+	for ; head != tail; head++ {
+		cqe := r.cqes[head&*r.cqMask]
+		slot := &r.slots[cqe.userData]
+		if cqe.res < 0 {
+			r.walk.add(slot.typ, slot.name, nil)
+		} else {
+			st := &slot.buf
+			r.walk.add(slot.typ, slot.name, &entryStat{
+				mode:   uint32(st.Mode),
+				dev:    unix.Mkdev(st.Dev_major, st.Dev_minor),
+				ino:    st.Ino,
+				nlink:  uint64(st.Nlink),
+				size:   int64(st.Size),
+				blocks: int64(st.Blocks),
+			})
+		}
+		r.free = append(r.free, uint32(cqe.userData))
+		r.inflight--
+	}
+	atomic.StoreUint32(r.cqHead, head)
+}
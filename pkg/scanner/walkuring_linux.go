@@ -0,0 +1,25 @@
+//go:build linux
+
+package scanner
+
+import "context"
+
+// measureUring walks the tree at path, stat'ing entries through a ring of
+// its own.
+func (s *WalkUringStrategy) measureUring(ctx context.Context, path string) (Measurement, error) {
+	ring, err := newStatRing(uringEntries)
+	if err != nil {
+		s.warnUnavailable(err)
+		return s.Walk.walkGetdents(ctx, path)
+	}
+	defer ring.Close()
+
+	m, err := newGetdentsWalk(ctx, s.Walk, ring).measure(path)
+	if err == nil {
+		err = ring.err
+	}
+	if err != nil {
+		return Measurement{}, err
+	}
+	return m, nil
+}
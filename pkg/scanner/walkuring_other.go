@@ -0,0 +1,15 @@
+//go:build !linux
+
+package scanner
+
+import (
+	"context"
+	"errors"
+)
+
+// measureUring walks the tree at path with the plain walk, as io_uring is
+// Linux only.
+func (s *WalkUringStrategy) measureUring(ctx context.Context, path string) (Measurement, error) {
+	s.warnUnavailable(errors.New("io_uring is Linux only"))
+	return s.Walk.walkNoFollow(ctx, path, path)
+}
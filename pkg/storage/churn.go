@@ -0,0 +1,250 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// churnScan is a completed scan GetNewAndDeleted compares.
+type churnScan struct {
+	id          string
+	completedAt time.Time
+}
+
+// GetNewAndDeleted compares the first and last completed scans of a base path
+// whose completion falls in opts' time range, separately for each host. A
+// directory the last scan recorded that had no record as of the end of the
+// first scan is new, listed as growing from nothing; one that was there at
+// the end of the first scan but which the last scan did not record is
+// deleted, listed as shrinking to nothing. A host with a single scan in range
+// has nothing to compare. Scans that leave unchanged directories unrecorded
+// (scan.min_change_percent or min_change_bytes) make those look deleted.
+//
+// The Owner, Hostname, Match, MatchRegex, Direction and minimum change
+// options apply as in GetTopChangers; a Limit of 0 or less lists all.
+func (s *SQLiteStorage) GetNewAndDeleted(ctx context.Context, opts TopChangerOptions) ([]DirectoryChange, error) {
+	basePath := strings.TrimSuffix(opts.BasePath, "/")
+	if basePath == "" {
+		basePath = "/"
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT scan_id, completed_at, hostname
+		FROM scans
+		WHERE (base_path = ? OR base_path = ? || '/')
+		  AND status = 'completed'
+		  AND completed_at BETWEEN ? AND ?
+		  AND (? = '' OR hostname = ?)
+		ORDER BY completed_at ASC`,
+		basePath, basePath, opts.Since.UTC(), opts.Until.UTC(), opts.Hostname, opts.Hostname,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("querying scans: %w", err)
+	}
+	first := make(map[string]churnScan)
+	last := make(map[string]churnScan)
+	var hosts []string
+	for rows.Next() {
+		var sc churnScan
+		var host string
+		if err := rows.Scan(&sc.id, &sc.completedAt, &host); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scanning row: %w", err)
+		}
+		if _, ok := first[host]; !ok {
+			first[host] = sc
+			hosts = append(hosts, host)
+		}
+		last[host] = sc
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return nil, fmt.Errorf("iterating rows: %w", err)
+	}
+	rows.Close()
+
+	var results []DirectoryChange
+	for _, host := range hosts {
+		if first[host].id == last[host].id {
+			continue
+		}
+		changes, err := s.scanChurn(ctx, basePath, host, first[host], last[host], opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range changes {
+			if opts.Owner != "" && c.Owner != opts.Owner {
+				continue
+			}
+			if opts.listsChange(c) {
+				results = append(results, c)
+			}
+		}
+	}
+
+	sortChanges(results)
+	if opts.Limit > 0 && len(results) > opts.Limit {
+		results = results[:opts.Limit]
+	}
+	return results, nil
+}
+
+// scanChurn lists the directories under basePath that only one of host's
+// scans from and to has.
+func (s *SQLiteStorage) scanChurn(ctx context.Context, basePath, host string, from, to churnScan, opts TopChangerOptions) ([]DirectoryChange, error) {
+	query := `
+		WITH ranked AS (
+			SELECT directory, base_path, size_bytes, recorded_at, owner, owner_group, deleted,
+				ROW_NUMBER() OVER (PARTITION BY directory ORDER BY recorded_at DESC) AS rn
+			FROM ` + usageSource + `
+			WHERE (base_path = ? OR base_path = ? || '/')
+			  AND recorded_at <= ?
+			  AND file_filter = ''
+			  AND hostname = ?
+			  AND ` + directoryMatchSQL + `
+		),
+		before AS (
+			SELECT directory, base_path, size_bytes, recorded_at, owner, owner_group
+			FROM ranked
+			WHERE rn = 1 AND deleted = 0
+		),
+		after AS (
+			SELECT directory, base_path, size_bytes, recorded_at, owner, owner_group
+			FROM usage_records
+			WHERE scan_id = ? AND deleted = 0 AND file_filter = ''
+			  AND ` + directoryMatchSQL + `
+		)
+		SELECT 'new', a.directory, a.base_path, a.size_bytes, a.recorded_at, a.owner, a.owner_group
+		FROM after a
+		WHERE NOT EXISTS (SELECT 1 FROM before b WHERE b.directory = a.directory)
+		UNION ALL
+		SELECT 'deleted', b.directory, b.base_path, b.size_bytes, b.recorded_at, b.owner, b.owner_group
+		FROM before b
+		WHERE NOT EXISTS (SELECT 1 FROM after a WHERE a.directory = b.directory)`
+
+	args := []interface{}{basePath, basePath, from.completedAt.UTC(), host}
+	args = append(args, directoryMatchArgs(opts.Match, opts.MatchRegex)...)
+	args = append(args, to.id)
+	args = append(args, directoryMatchArgs(opts.Match, opts.MatchRegex)...)
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying new and deleted directories: %w", err)
+	}
+	defer rows.Close()
+
+	var results []DirectoryChange
+	for rows.Next() {
+		var dc DirectoryChange
+		var size int64
+		var recordedAt time.Time
+		if err := rows.Scan(&dc.Status, &dc.Directory, &dc.BasePath, &size, &recordedAt, &dc.Owner, &dc.Group); err != nil {
+			return nil, fmt.Errorf("scanning row: %w", err)
+		}
+		dc.Hostname = host
+		if dc.Status == ChangeNew {
+			dc.StartTime, dc.EndTime = from.completedAt, recordedAt
+			dc.EndSize = size
+		} else {
+			dc.StartTime, dc.EndTime = recordedAt, to.completedAt
+			dc.StartSize = size
+			if size > 0 {
+				dc.ChangePercent = -100
+			}
+		}
+		dc.ChangeBytes = dc.EndSize - dc.StartSize
+		results = append(results, dc)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating rows: %w", err)
+	}
+
+	return results, nil
+}
+
+// listsChange reports whether c passes opts' Direction and minimum change
+// thresholds, which GetTopChangers applies in SQL.
+func (opts TopChangerOptions) listsChange(c DirectoryChange) bool {
+	switch {
+	case opts.Direction == "increase" && c.ChangeBytes <= 0,
+		opts.Direction == "decrease" && c.ChangeBytes >= 0:
+		return false
+	}
+	if opts.MinChangeBytes == 0 && opts.MinChangePercent == 0 {
+		return true
+	}
+	change := c.ChangeBytes
+	if change < 0 {
+		change = -change
+	}
+	if opts.MinChangeBytes > 0 && change >= opts.MinChangeBytes {
+		return true
+	}
+	if opts.MinChangePercent > 0 && change != 0 {
+		return c.StartSize <= 0 || float64(change)*100/float64(c.StartSize) >= opts.MinChangePercent
+	}
+	return false
+}
+
+// topChangersWithChurn runs GetTopChangers with opts' new and deleted
+// directories in place of the same directories' changes between their first
+// and last records.
+func (s *SQLiteStorage) topChangersWithChurn(ctx context.Context, opts TopChangerOptions) ([]DirectoryChange, error) {
+	// A new directory the thresholds leave out must not show its partial
+	// change instead, so the filters are applied here
+	all := opts
+	all.Direction, all.MinChangeBytes, all.MinChangePercent, all.Limit = "both", 0, 0, 0
+	churn, err := s.GetNewAndDeleted(ctx, all)
+	if err != nil {
+		return nil, err
+	}
+	replaced := make(map[[2]string]bool, len(churn))
+	var results []DirectoryChange
+	for _, c := range churn {
+		if (c.Status == ChangeNew && !opts.IncludeNew) || (c.Status == ChangeDeleted && !opts.IncludeDeleted) {
+			continue
+		}
+		replaced[[2]string{c.Hostname, c.Directory}] = true
+		if opts.listsChange(c) {
+			results = append(results, c)
+		}
+	}
+
+	plain := opts
+	plain.IncludeNew, plain.IncludeDeleted = false, false
+	if plain.Limit >= 0 {
+		plain.Limit += len(replaced)
+	}
+	changes, err := s.GetTopChangers(ctx, plain)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range changes {
+		if !replaced[[2]string{c.Hostname, c.Directory}] {
+			results = append(results, c)
+		}
+	}
+
+	sortChanges(results)
+	if opts.Limit >= 0 && len(results) > opts.Limit {
+		results = results[:opts.Limit]
+	}
+	return results, nil
+}
+
+// sortChanges orders changes largest first, as GetTopChangers does.
+func sortChanges(changes []DirectoryChange) {
+	sort.SliceStable(changes, func(i, j int) bool {
+		a, b := changes[i].ChangeBytes, changes[j].ChangeBytes
+		if a < 0 {
+			a = -a
+		}
+		if b < 0 {
+			b = -b
+		}
+		return a > b
+	})
+}
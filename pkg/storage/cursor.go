@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// UsageCursor marks a record's place in the newest-first order QueryUsage
+// returns, so the next page of a query can start after it (see
+// QueryOptions.After). Unlike an offset, a cursor does not shift when new
+// records are stored between pages.
+type UsageCursor struct {
+	RecordedAt time.Time
+	ID         int64 // 0 for a daily rollup
+	BasePath   string
+	Directory  string
+}
+
+// Cursor returns the cursor of r, to continue a query after it.
+func (r UsageRecord) Cursor() UsageCursor {
+	return UsageCursor{RecordedAt: r.RecordedAt, ID: r.ID, BasePath: r.BasePath, Directory: r.Directory}
+}
+
+// cursorToken is the encoded form of a UsageCursor.
+type cursorToken struct {
+	RecordedAt time.Time `json:"t"`
+	ID         int64     `json:"i"`
+	BasePath   string    `json:"b"`
+	Directory  string    `json:"d"`
+}
+
+// String encodes the cursor as an opaque token safe for command lines and
+// URLs; ParseUsageCursor decodes it.
+func (c UsageCursor) String() string {
+	data, _ := json.Marshal(cursorToken{RecordedAt: c.RecordedAt, ID: c.ID, BasePath: c.BasePath, Directory: c.Directory})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// ParseUsageCursor decodes a token made by UsageCursor.String.
+func ParseUsageCursor(token string) (UsageCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return UsageCursor{}, fmt.Errorf("invalid cursor %q", token)
+	}
+	var t cursorToken
+	if err := json.Unmarshal(data, &t); err != nil || t.RecordedAt.IsZero() {
+		return UsageCursor{}, fmt.Errorf("invalid cursor %q", token)
+	}
+	return UsageCursor{RecordedAt: t.RecordedAt, ID: t.ID, BasePath: t.BasePath, Directory: t.Directory}, nil
+}
@@ -0,0 +1,23 @@
+// Package storage keeps usgmon's scans and usage records.
+//
+// Storage is the interface the rest of usgmon is written against;
+// SQLiteStorage implements it on a single SQLite file, which can be shared
+// with a running daemon:
+//
+//	store, err := storage.NewSQLiteStorage("/var/lib/usgmon/usgmon.db")
+//	if err != nil {
+//		return err
+//	}
+//	defer store.Close()
+//	if err := store.Initialize(ctx); err != nil {
+//		return err
+//	}
+//
+//	records, err := store.QueryUsage(ctx, storage.QueryOptions{
+//		Directory: "/home/alice",
+//		Limit:     10,
+//	})
+//
+// Initialize creates the schema and migrates a database written by an older
+// version, so it must be called before anything else.
+package storage
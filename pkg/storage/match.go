@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"regexp"
+	"sync"
+
+	"modernc.org/sqlite"
+)
+
+// directoryMatchSQL is the condition of the Match and MatchRegex query
+// filters on the directory column, each skipped when empty;
+// directoryMatchArgs supplies its arguments. A GLOB pattern without a slash
+// matches the directory's name, what follows its last slash, and one with a
+// slash its whole path.
+const directoryMatchSQL = `(? = '' OR (CASE WHEN instr(?, '/') > 0 THEN directory
+		ELSE substr(directory, length(rtrim(directory, replace(directory, '/', ''))) + 1) END) GLOB ?)
+	AND (? = '' OR directory REGEXP ?)`
+
+// directoryMatchArgs returns the arguments of directoryMatchSQL.
+func directoryMatchArgs(glob, regex string) []interface{} {
+	return []interface{}{glob, glob, glob, regex, regex}
+}
+
+func init() {
+	// SQLite parses X REGEXP Y but leaves regexp(Y, X) to the application
+	sqlite.MustRegisterDeterministicScalarFunction("regexp", 2, func(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		pattern, ok1 := args[0].(string)
+		value, ok2 := args[1].(string)
+		if !ok1 || !ok2 {
+			return false, nil
+		}
+		re, err := compileRegexp(pattern)
+		if err != nil {
+			return nil, err
+		}
+		return re.MatchString(value), nil
+	})
+}
+
+// regexps caches compiled patterns, as REGEXP runs once per row.
+var regexps sync.Map // pattern -> *regexp.Regexp
+
+func compileRegexp(pattern string) (*regexp.Regexp, error) {
+	if re, ok := regexps.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regular expression %q: %w", pattern, err)
+	}
+	regexps.Store(pattern, re)
+	return re, nil
+}
@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"os"
+	"strings"
+)
+
+// ScanMetadata records the environment and effective options a scan ran
+// with, so historical anomalies can be traced to configuration or tooling
+// changes. It is stored as JSON on the scan's row.
+type ScanMetadata struct {
+	Version  string `json:"version"`
+	Hostname string `json:"hostname"`
+	Kernel   string `json:"kernel"`
+
+	Depth           int      `json:"depth"`
+	FollowSymlinks  bool     `json:"follow_symlinks"`
+	OneFileSystem   bool     `json:"one_file_system,omitempty"`
+	MtimeCache      bool     `json:"mtime_cache,omitempty"`
+	Strategy        string   `json:"strategy"`
+	Workers         int      `json:"workers"`
+	Exclude         []string `json:"exclude,omitempty"`
+	ExcludeFiles    []string `json:"exclude_files,omitempty"`
+	LooseFiles      bool     `json:"loose_files,omitempty"`
+	Fingerprint     bool     `json:"fingerprint,omitempty"`
+	AllocatedSize   bool     `json:"allocated_size,omitempty"`
+	SizeMode        string   `json:"size_mode,omitempty"`
+	CountEntries    bool     `json:"count_entries,omitempty"`
+	MtimeShortcut   bool     `json:"mtime_shortcut,omitempty"`
+	RctimeShortcut  bool     `json:"rctime_shortcut,omitempty"`
+	DuBatchSize     int      `json:"du_batch_size,omitempty"`
+	WalkWorkers     int      `json:"walk_workers,omitempty"`
+	DedupeHardlinks bool     `json:"dedupe_hardlinks,omitempty"`
+	UsageByOwner    bool     `json:"usage_by_owner,omitempty"`
+	LargestFiles    int      `json:"largest_files,omitempty"`
+	AgeBreakdown    string   `json:"age_breakdown,omitempty"`
+	AgeBuckets      []int    `json:"age_buckets,omitempty"`
+}
+
+// NewScanMetadata returns metadata describing this host for a scan by the
+// given usgmon version; the caller fills in the scan options. Host details
+// that cannot be read are left empty.
+func NewScanMetadata(version string) *ScanMetadata {
+	m := &ScanMetadata{Version: version}
+	m.Hostname, _ = os.Hostname()
+	if release, err := os.ReadFile("/proc/sys/kernel/osrelease"); err == nil {
+		m.Kernel = strings.TrimSpace(string(release))
+	}
+	return m
+}
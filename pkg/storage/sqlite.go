@@ -0,0 +1,2219 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteStorage implements Storage using SQLite.
+type SQLiteStorage struct {
+	db *sql.DB
+}
+
+// NewSQLiteStorage creates a new SQLite storage instance.
+func NewSQLiteStorage(dbPath string) (*SQLiteStorage, error) {
+	// Ensure parent directory exists
+	if err := os.MkdirAll(filepath.Dir(dbPath), 0755); err != nil {
+		return nil, fmt.Errorf("creating database directory: %w", err)
+	}
+
+	// busy_timeout and foreign_keys are per-connection, so they must be set in
+	// the DSN to apply to every pooled connection; concurrent path scans
+	// otherwise hit SQLITE_BUSY, and deletes skip the foreign key checks
+	db, err := sql.Open("sqlite", dbPath+"?_pragma=busy_timeout(5000)&_pragma=foreign_keys(1)")
+	if err != nil {
+		return nil, fmt.Errorf("opening database: %w", err)
+	}
+
+	// Enable WAL mode for better concurrent access
+	if _, err := db.Exec("PRAGMA journal_mode=WAL"); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("enabling WAL mode: %w", err)
+	}
+
+	return &SQLiteStorage{db: db}, nil
+}
+
+// Initialize creates the database schema.
+func (s *SQLiteStorage) Initialize(ctx context.Context) error {
+	schema := `
+		CREATE TABLE IF NOT EXISTS scans (
+			scan_id TEXT PRIMARY KEY,
+			base_path TEXT NOT NULL,
+			started_at DATETIME NOT NULL,
+			completed_at DATETIME,
+			directories_scanned INTEGER DEFAULT 0,
+			status TEXT DEFAULT 'running'
+		);
+
+		CREATE TABLE IF NOT EXISTS usage_records (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			base_path TEXT NOT NULL,
+			directory TEXT NOT NULL,
+			size_bytes INTEGER NOT NULL,
+			recorded_at DATETIME NOT NULL,
+			scan_id TEXT NOT NULL,
+			FOREIGN KEY (scan_id) REFERENCES scans(scan_id)
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_usage_dir_time ON usage_records(directory, recorded_at);
+		CREATE INDEX IF NOT EXISTS idx_usage_base_path ON usage_records(base_path);
+		CREATE INDEX IF NOT EXISTS idx_usage_scan_id ON usage_records(scan_id);
+		CREATE INDEX IF NOT EXISTS idx_usage_base_path_time ON usage_records(base_path, recorded_at, directory, size_bytes);
+
+		CREATE TABLE IF NOT EXISTS usage_rollups (
+			base_path TEXT NOT NULL,
+			directory TEXT NOT NULL,
+			day DATETIME NOT NULL,
+			samples INTEGER NOT NULL,
+			min_bytes INTEGER NOT NULL,
+			max_bytes INTEGER NOT NULL,
+			avg_bytes INTEGER NOT NULL,
+			owner TEXT NOT NULL DEFAULT '',
+			owner_group TEXT NOT NULL DEFAULT '',
+			hostname TEXT NOT NULL DEFAULT '',
+			PRIMARY KEY (base_path, directory, day, hostname)
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_rollups_dir_day ON usage_rollups(directory, day);
+
+		CREATE TABLE IF NOT EXISTS usage_by_owner (
+			record_id INTEGER NOT NULL,
+			uid INTEGER NOT NULL,
+			owner TEXT NOT NULL DEFAULT '',
+			size_bytes INTEGER NOT NULL,
+			PRIMARY KEY (record_id, uid),
+			FOREIGN KEY (record_id) REFERENCES usage_records(id)
+		);
+
+		CREATE TABLE IF NOT EXISTS large_files (
+			record_id INTEGER NOT NULL,
+			path TEXT NOT NULL,
+			size_bytes INTEGER NOT NULL,
+			mtime DATETIME NOT NULL,
+			PRIMARY KEY (record_id, path),
+			FOREIGN KEY (record_id) REFERENCES usage_records(id)
+		);
+
+		CREATE TABLE IF NOT EXISTS usage_by_age (
+			record_id INTEGER NOT NULL,
+			basis TEXT NOT NULL,
+			min_days INTEGER NOT NULL,
+			max_days INTEGER NOT NULL,
+			size_bytes INTEGER NOT NULL,
+			PRIMARY KEY (record_id, min_days),
+			FOREIGN KEY (record_id) REFERENCES usage_records(id)
+		);
+
+		CREATE TABLE IF NOT EXISTS skip_list (
+			directory TEXT PRIMARY KEY,
+			consecutive_errors INTEGER NOT NULL DEFAULT 0,
+			last_error TEXT NOT NULL DEFAULT '',
+			skipped INTEGER NOT NULL DEFAULT 0,
+			manual INTEGER NOT NULL DEFAULT 0,
+			updated_at DATETIME NOT NULL
+		);
+
+		CREATE TABLE IF NOT EXISTS alert_state (
+			directory TEXT PRIMARY KEY,
+			base_path TEXT NOT NULL,
+			threshold_bytes INTEGER NOT NULL,
+			size_bytes INTEGER NOT NULL,
+			since DATETIME NOT NULL,
+			last_notified DATETIME NOT NULL
+		);
+
+		CREATE TABLE IF NOT EXISTS ingest_batches (
+			scan_id TEXT NOT NULL,
+			batch INTEGER NOT NULL,
+			PRIMARY KEY (scan_id, batch)
+		);
+
+		CREATE TABLE IF NOT EXISTS scan_progress (
+			scan_id TEXT NOT NULL,
+			directory TEXT NOT NULL,
+			PRIMARY KEY (scan_id, directory)
+		);
+
+		CREATE TABLE IF NOT EXISTS fs_stats (
+			base_path TEXT NOT NULL,
+			recorded_at DATETIME NOT NULL,
+			scan_id TEXT NOT NULL,
+			hostname TEXT NOT NULL DEFAULT '',
+			total_bytes INTEGER NOT NULL,
+			used_bytes INTEGER NOT NULL,
+			available_bytes INTEGER NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS idx_fs_stats_path_time ON fs_stats(base_path, recorded_at);
+		CREATE INDEX IF NOT EXISTS idx_fs_stats_scan_id ON fs_stats(scan_id);
+
+		CREATE TABLE IF NOT EXISTS dir_cache (
+			hostname TEXT NOT NULL DEFAULT '',
+			base_path TEXT NOT NULL,
+			directory TEXT NOT NULL,
+			dev INTEGER NOT NULL,
+			ino INTEGER NOT NULL,
+			mtime_ns INTEGER NOT NULL,
+			size_bytes INTEGER NOT NULL,
+			allocated_bytes INTEGER NOT NULL,
+			PRIMARY KEY (hostname, base_path, directory)
+		);
+	`
+
+	_, err := s.db.ExecContext(ctx, schema)
+	if err != nil {
+		return fmt.Errorf("creating schema: %w", err)
+	}
+
+	if err := s.migrate(ctx); err != nil {
+		return fmt.Errorf("migrating schema: %w", err)
+	}
+
+	return nil
+}
+
+// migrate adds columns introduced after the initial schema to existing databases.
+func (s *SQLiteStorage) migrate(ctx context.Context) error {
+	columns := []struct {
+		table, name, def string
+	}{
+		{"usage_records", "file_filter", "TEXT NOT NULL DEFAULT ''"},
+		{"usage_records", "symlink_count", "INTEGER"},
+		{"usage_records", "fingerprint", "TEXT NOT NULL DEFAULT ''"},
+		{"scans", "note", "TEXT NOT NULL DEFAULT ''"},
+		{"scans", "trigger", "TEXT NOT NULL DEFAULT ''"},
+		{"scans", "cpu_user_ms", "INTEGER"},
+		{"scans", "cpu_system_ms", "INTEGER"},
+		{"usage_records", "allocated_bytes", "INTEGER"},
+		{"usage_records", "owner", "TEXT NOT NULL DEFAULT ''"},
+		{"usage_records", "owner_group", "TEXT NOT NULL DEFAULT ''"},
+		{"usage_records", "quota_limit", "INTEGER"},
+		{"scans", "metadata", "TEXT NOT NULL DEFAULT ''"},
+		{"usage_records", "deleted", "INTEGER NOT NULL DEFAULT 0"},
+		{"usage_records", "file_count", "INTEGER"},
+		{"usage_records", "dir_count", "INTEGER"},
+		{"scans", "hostname", "TEXT NOT NULL DEFAULT ''"},
+		{"usage_records", "hostname", "TEXT NOT NULL DEFAULT ''"},
+		{"usage_rollups", "hostname", "TEXT NOT NULL DEFAULT ''"},
+	}
+
+	for _, c := range columns {
+		if err := s.addColumnIfMissing(ctx, c.table, c.name, c.def); err != nil {
+			return err
+		}
+	}
+
+	// Changes other than new columns, each a no-op once applied
+	steps := []func(context.Context) error{
+		s.keyRollupsByHost,
+		s.keyDirCacheByPath,
+	}
+	for _, step := range steps {
+		if err := step(ctx); err != nil {
+			return err
+		}
+	}
+
+	// Migrations are append-only, so their count identifies the schema version
+	if _, err := s.db.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version = %d", len(columns)+len(steps))); err != nil {
+		return fmt.Errorf("setting schema version: %w", err)
+	}
+
+	return nil
+}
+
+// keyRollupsByHost adds hostname to the primary key of usage_rollups, so
+// different hosts' rollups of a directory's day are kept apart. SQLite cannot
+// alter a primary key, so the table is rebuilt.
+func (s *SQLiteStorage) keyRollupsByHost(ctx context.Context) error {
+	var keyed bool
+	if err := s.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) > 0 FROM pragma_table_info('usage_rollups') WHERE name = 'hostname' AND pk > 0`,
+	).Scan(&keyed); err != nil {
+		return fmt.Errorf("reading usage_rollups key: %w", err)
+	}
+	if keyed {
+		return nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range []string{
+		`CREATE TABLE usage_rollups_new (
+			base_path TEXT NOT NULL,
+			directory TEXT NOT NULL,
+			day DATETIME NOT NULL,
+			samples INTEGER NOT NULL,
+			min_bytes INTEGER NOT NULL,
+			max_bytes INTEGER NOT NULL,
+			avg_bytes INTEGER NOT NULL,
+			owner TEXT NOT NULL DEFAULT '',
+			owner_group TEXT NOT NULL DEFAULT '',
+			hostname TEXT NOT NULL DEFAULT '',
+			PRIMARY KEY (base_path, directory, day, hostname)
+		)`,
+		`INSERT INTO usage_rollups_new (base_path, directory, day, samples, min_bytes, max_bytes, avg_bytes, owner, owner_group, hostname)
+		 SELECT base_path, directory, day, samples, min_bytes, max_bytes, avg_bytes, owner, owner_group, hostname FROM usage_rollups`,
+		`DROP TABLE usage_rollups`,
+		`ALTER TABLE usage_rollups_new RENAME TO usage_rollups`,
+		`CREATE INDEX IF NOT EXISTS idx_rollups_dir_day ON usage_rollups(directory, day)`,
+	} {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("rebuilding usage_rollups: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing transaction: %w", err)
+	}
+	return nil
+}
+
+// keyDirCacheByPath keys dir_cache by host and base path as well as
+// directory, so hosts and overlapping base paths no longer share or remove
+// each other's entries. Older entries cannot be attributed to either, and
+// the cache only saves work, so the table is recreated empty.
+func (s *SQLiteStorage) keyDirCacheByPath(ctx context.Context) error {
+	var keyed bool
+	if err := s.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) > 0 FROM pragma_table_info('dir_cache') WHERE name = 'base_path'`,
+	).Scan(&keyed); err != nil {
+		return fmt.Errorf("reading dir_cache columns: %w", err)
+	}
+	if keyed {
+		return nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range []string{
+		`DROP TABLE dir_cache`,
+		`CREATE TABLE dir_cache (
+			hostname TEXT NOT NULL DEFAULT '',
+			base_path TEXT NOT NULL,
+			directory TEXT NOT NULL,
+			dev INTEGER NOT NULL,
+			ino INTEGER NOT NULL,
+			mtime_ns INTEGER NOT NULL,
+			size_bytes INTEGER NOT NULL,
+			allocated_bytes INTEGER NOT NULL,
+			PRIMARY KEY (hostname, base_path, directory)
+		)`,
+	} {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("rebuilding dir_cache: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing transaction: %w", err)
+	}
+	return nil
+}
+
+// addColumnIfMissing adds a column to a table unless it already exists.
+func (s *SQLiteStorage) addColumnIfMissing(ctx context.Context, table, column, def string) error {
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return fmt.Errorf("reading %s columns: %w", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid, notNull, pk int
+			name, colType    string
+			dflt             sql.NullString
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dflt, &pk); err != nil {
+			return fmt.Errorf("scanning %s columns: %w", table, err)
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("iterating %s columns: %w", table, err)
+	}
+
+	if _, err := s.db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, def)); err != nil {
+		return fmt.Errorf("adding %s.%s: %w", table, column, err)
+	}
+
+	return nil
+}
+
+// Close closes the database connection.
+func (s *SQLiteStorage) Close() error {
+	return s.db.Close()
+}
+
+// StartScan creates a new scan record.
+func (s *SQLiteStorage) StartScan(ctx context.Context, basePath string, opts StartScanOptions) (string, error) {
+	scanID := opts.ScanID
+	if scanID == "" {
+		scanID = uuid.New().String()
+	}
+	now := time.Now().UTC()
+	if !opts.StartedAt.IsZero() {
+		now = opts.StartedAt.UTC()
+	}
+
+	var metadata string
+	if opts.Metadata != nil {
+		b, err := json.Marshal(opts.Metadata)
+		if err != nil {
+			return "", fmt.Errorf("encoding scan metadata: %w", err)
+		}
+		metadata = string(b)
+	}
+
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO scans (scan_id, base_path, started_at, status, note, trigger, metadata, hostname) VALUES (?, ?, ?, 'running', ?, ?, ?, ?)
+		 ON CONFLICT (scan_id) DO NOTHING`,
+		scanID, basePath, now, opts.Note, opts.Trigger, metadata, opts.Hostname,
+	)
+	if err != nil {
+		return "", fmt.Errorf("inserting scan record: %w", err)
+	}
+
+	return scanID, nil
+}
+
+// CompleteScan marks a scan as completed.
+func (s *SQLiteStorage) CompleteScan(ctx context.Context, scanID string, directoriesScanned int) error {
+	now := time.Now().UTC()
+
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE scans SET completed_at = ?, directories_scanned = ?, status = 'completed' WHERE scan_id = ?`,
+		now, directoriesScanned, scanID,
+	)
+	if err != nil {
+		return fmt.Errorf("completing scan: %w", err)
+	}
+
+	return s.clearScanProgress(ctx, scanID)
+}
+
+// RecordScanCPU stores the user and system CPU time a scan consumed.
+func (s *SQLiteStorage) RecordScanCPU(ctx context.Context, scanID string, user, system time.Duration) error {
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE scans SET cpu_user_ms = ?, cpu_system_ms = ? WHERE scan_id = ?`,
+		user.Milliseconds(), system.Milliseconds(), scanID,
+	)
+	if err != nil {
+		return fmt.Errorf("recording scan cpu time: %w", err)
+	}
+
+	return nil
+}
+
+// FailScan marks a scan as failed.
+func (s *SQLiteStorage) FailScan(ctx context.Context, scanID string, reason string) error {
+	now := time.Now().UTC()
+
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE scans SET completed_at = ?, status = ? WHERE scan_id = ?`,
+		now, "failed: "+reason, scanID,
+	)
+	if err != nil {
+		return fmt.Errorf("failing scan: %w", err)
+	}
+
+	return s.clearScanProgress(ctx, scanID)
+}
+
+// SkipScan marks a scan as skipped. Its completion time is its start time,
+// as nothing was measured.
+func (s *SQLiteStorage) SkipScan(ctx context.Context, scanID string, reason string) error {
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE scans SET completed_at = started_at, directories_scanned = 0, status = ? WHERE scan_id = ?`,
+		"skipped: "+reason, scanID,
+	)
+	if err != nil {
+		return fmt.Errorf("skipping scan: %w", err)
+	}
+
+	return nil
+}
+
+// FailStaleScans marks the running and interrupted scans of hostname as
+// failed, dating their completion to their last usage record, and optionally
+// deletes those records. Scans without a host name, from before it was
+// recorded, count as this host's.
+func (s *SQLiteStorage) FailStaleScans(ctx context.Context, hostname, reason string, deleteRecords bool) ([]string, int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx,
+		`SELECT scan_id FROM scans WHERE status IN ('running', 'interrupted') AND (hostname = ? OR hostname = '') ORDER BY started_at`,
+		hostname,
+	)
+	if err != nil {
+		return nil, 0, fmt.Errorf("querying running scans: %w", err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, 0, fmt.Errorf("scanning scan id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("iterating running scans: %w", err)
+	}
+
+	var records int64
+	for _, id := range ids {
+		_, err := tx.ExecContext(ctx,
+			`UPDATE scans SET status = ?,
+				completed_at = COALESCE((SELECT MAX(recorded_at) FROM usage_records WHERE scan_id = ?), started_at)
+			 WHERE scan_id = ?`,
+			"failed: "+reason, id, id,
+		)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failing scan %s: %w", id, err)
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM scan_progress WHERE scan_id = ?`, id); err != nil {
+			return nil, 0, fmt.Errorf("clearing progress of scan %s: %w", id, err)
+		}
+		if !deleteRecords {
+			continue
+		}
+
+		for _, table := range []string{"usage_by_owner", "large_files", "usage_by_age"} {
+			if _, err := tx.ExecContext(ctx,
+				`DELETE FROM `+table+` WHERE record_id IN (SELECT id FROM usage_records WHERE scan_id = ?)`, id); err != nil {
+				return nil, 0, fmt.Errorf("deleting %s of scan %s: %w", table, id, err)
+			}
+		}
+		res, err := tx.ExecContext(ctx, `DELETE FROM usage_records WHERE scan_id = ?`, id)
+		if err != nil {
+			return nil, 0, fmt.Errorf("deleting usage records of scan %s: %w", id, err)
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return nil, 0, fmt.Errorf("checking affected rows: %w", err)
+		}
+		records += n
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, 0, fmt.Errorf("committing transaction: %w", err)
+	}
+	return ids, records, nil
+}
+
+// InterruptScan marks a running scan as interrupted.
+func (s *SQLiteStorage) InterruptScan(ctx context.Context, scanID string) error {
+	now := time.Now().UTC()
+
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE scans SET completed_at = ?, status = 'interrupted' WHERE scan_id = ? AND status = 'running'`,
+		now, scanID,
+	)
+	if err != nil {
+		return fmt.Errorf("interrupting scan: %w", err)
+	}
+
+	return nil
+}
+
+// InterruptStaleScans marks the running scans of hostname as interrupted,
+// dating their completion to their last usage record as FailStaleScans does.
+func (s *SQLiteStorage) InterruptStaleScans(ctx context.Context, hostname string) ([]string, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx,
+		`SELECT scan_id FROM scans WHERE status = 'running' AND (hostname = ? OR hostname = '') ORDER BY started_at`,
+		hostname,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("querying running scans: %w", err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scanning scan id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating running scans: %w", err)
+	}
+
+	for _, id := range ids {
+		_, err := tx.ExecContext(ctx,
+			`UPDATE scans SET status = 'interrupted',
+				completed_at = COALESCE((SELECT MAX(recorded_at) FROM usage_records WHERE scan_id = ?), started_at)
+			 WHERE scan_id = ?`,
+			id, id,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("interrupting scan %s: %w", id, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("committing transaction: %w", err)
+	}
+	return ids, nil
+}
+
+// RecordScanProgress records directories a scan finished without storing them.
+func (s *SQLiteStorage) RecordScanProgress(ctx context.Context, scanID string, directories []string) error {
+	if len(directories) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx,
+		`INSERT INTO scan_progress (scan_id, directory) VALUES (?, ?) ON CONFLICT DO NOTHING`)
+	if err != nil {
+		return fmt.Errorf("preparing statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, dir := range directories {
+		if _, err := stmt.ExecContext(ctx, scanID, dir); err != nil {
+			return fmt.Errorf("recording progress of %s: %w", dir, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing transaction: %w", err)
+	}
+	return nil
+}
+
+// ResumeScan marks an interrupted scan as running again and returns the
+// directories it had finished.
+func (s *SQLiteStorage) ResumeScan(ctx context.Context, scanID string) ([]string, error) {
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE scans SET completed_at = NULL, status = 'running' WHERE scan_id = ? AND status = 'interrupted'`,
+		scanID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("resuming scan: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("checking affected rows: %w", err)
+	}
+	if n == 0 {
+		return nil, fmt.Errorf("scan %s is not interrupted", scanID)
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT directory FROM usage_records WHERE scan_id = ?
+		 UNION SELECT directory FROM scan_progress WHERE scan_id = ?`,
+		scanID, scanID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("querying finished directories: %w", err)
+	}
+	defer rows.Close()
+
+	var dirs []string
+	for rows.Next() {
+		var dir string
+		if err := rows.Scan(&dir); err != nil {
+			return nil, fmt.Errorf("scanning directory: %w", err)
+		}
+		dirs = append(dirs, dir)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating finished directories: %w", err)
+	}
+	return dirs, nil
+}
+
+// clearScanProgress deletes the progress recorded for a scan that has
+// ended and cannot be resumed.
+func (s *SQLiteStorage) clearScanProgress(ctx context.Context, scanID string) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM scan_progress WHERE scan_id = ?`, scanID); err != nil {
+		return fmt.Errorf("clearing scan progress: %w", err)
+	}
+	return nil
+}
+
+// ListScans retrieves scan records, most recent first.
+func (s *SQLiteStorage) ListScans(ctx context.Context, opts ScanListOptions) ([]Scan, error) {
+	query := `SELECT scan_id, base_path, started_at, completed_at, directories_scanned, status, note, trigger,
+		             cpu_user_ms, cpu_system_ms, metadata, hostname
+		      FROM scans WHERE 1=1`
+	args := []interface{}{}
+
+	if opts.BasePath != "" {
+		query += " AND base_path = ?"
+		args = append(args, opts.BasePath)
+	}
+
+	if opts.Trigger != "" {
+		query += " AND trigger = ?"
+		args = append(args, opts.Trigger)
+	}
+
+	if opts.ScanID != "" {
+		query += " AND scan_id = ?"
+		args = append(args, opts.ScanID)
+	}
+
+	if opts.Hostname != "" {
+		query += " AND hostname = ?"
+		args = append(args, opts.Hostname)
+	}
+
+	query += " ORDER BY started_at DESC"
+
+	if opts.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, opts.Limit)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying scans: %w", err)
+	}
+	defer rows.Close()
+
+	var scans []Scan
+	for rows.Next() {
+		var sc Scan
+		var completedAt sql.NullTime
+		var cpuUser, cpuSystem sql.NullInt64
+		var metadata string
+		if err := rows.Scan(&sc.ScanID, &sc.BasePath, &sc.StartedAt, &completedAt, &sc.DirectoriesScanned, &sc.Status, &sc.Note, &sc.Trigger,
+			&cpuUser, &cpuSystem, &metadata, &sc.Hostname); err != nil {
+			return nil, fmt.Errorf("scanning row: %w", err)
+		}
+		if metadata != "" {
+			sc.Metadata = &ScanMetadata{}
+			if err := json.Unmarshal([]byte(metadata), sc.Metadata); err != nil {
+				return nil, fmt.Errorf("decoding metadata of scan %s: %w", sc.ScanID, err)
+			}
+		}
+		if completedAt.Valid {
+			sc.CompletedAt = &completedAt.Time
+		}
+		if cpuUser.Valid && cpuSystem.Valid {
+			user := time.Duration(cpuUser.Int64) * time.Millisecond
+			system := time.Duration(cpuSystem.Int64) * time.Millisecond
+			sc.CPUUser, sc.CPUSystem = &user, &system
+		}
+		scans = append(scans, sc)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating rows: %w", err)
+	}
+
+	return scans, nil
+}
+
+// RecordUsage stores a single usage measurement. Its per-owner breakdown,
+// if any, is written in the same transaction.
+func (s *SQLiteStorage) RecordUsage(ctx context.Context, record UsageRecord) error {
+	return s.RecordUsageBatch(ctx, []UsageRecord{record})
+}
+
+// RecordUsageBatch stores multiple usage measurements in a single transaction.
+func (s *SQLiteStorage) RecordUsageBatch(ctx context.Context, records []UsageRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := insertUsage(ctx, tx, records); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing transaction: %w", err)
+	}
+
+	return nil
+}
+
+// RecordIngestedUsage stores batch number batch of a pushed scan's records,
+// in one transaction with a note of the batch, unless the batch was stored
+// before. It reports whether the records were stored.
+func (s *SQLiteStorage) RecordIngestedUsage(ctx context.Context, scanID string, batch int, records []UsageRecord) (bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx,
+		`INSERT INTO ingest_batches (scan_id, batch) VALUES (?, ?) ON CONFLICT (scan_id, batch) DO NOTHING`,
+		scanID, batch,
+	)
+	if err != nil {
+		return false, fmt.Errorf("recording batch: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return false, fmt.Errorf("checking affected rows: %w", err)
+	} else if n == 0 {
+		return false, nil
+	}
+
+	if err := insertUsage(ctx, tx, records); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("committing transaction: %w", err)
+	}
+
+	return true, nil
+}
+
+// insertUsage inserts usage records and their breakdowns in tx.
+func insertUsage(ctx context.Context, tx *sql.Tx, records []UsageRecord) error {
+	stmt, err := tx.PrepareContext(ctx,
+		`INSERT INTO usage_records (base_path, directory, size_bytes, recorded_at, scan_id, file_filter, symlink_count, fingerprint, allocated_bytes, owner, owner_group, quota_limit, deleted, file_count, dir_count, hostname)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+	)
+	if err != nil {
+		return fmt.Errorf("preparing statement: %w", err)
+	}
+	defer stmt.Close()
+
+	ownerStmt, err := tx.PrepareContext(ctx,
+		`INSERT INTO usage_by_owner (record_id, uid, owner, size_bytes) VALUES (?, ?, ?, ?)`,
+	)
+	if err != nil {
+		return fmt.Errorf("preparing statement: %w", err)
+	}
+	defer ownerStmt.Close()
+
+	fileStmt, err := tx.PrepareContext(ctx,
+		`INSERT INTO large_files (record_id, path, size_bytes, mtime) VALUES (?, ?, ?, ?)`,
+	)
+	if err != nil {
+		return fmt.Errorf("preparing statement: %w", err)
+	}
+	defer fileStmt.Close()
+
+	ageStmt, err := tx.PrepareContext(ctx,
+		`INSERT INTO usage_by_age (record_id, basis, min_days, max_days, size_bytes) VALUES (?, ?, ?, ?, ?)`,
+	)
+	if err != nil {
+		return fmt.Errorf("preparing statement: %w", err)
+	}
+	defer ageStmt.Close()
+
+	for _, record := range records {
+		res, err := stmt.ExecContext(ctx,
+			record.BasePath, record.Directory, record.SizeBytes, record.RecordedAt.UTC(), record.ScanID, record.FileFilter, record.SymlinkCount, record.Fingerprint, record.AllocatedBytes, record.Owner, record.Group, record.QuotaLimit, record.Deleted, record.FileCount, record.DirCount, record.Hostname,
+		)
+		if err != nil {
+			return fmt.Errorf("inserting record for %s: %w", record.Directory, err)
+		}
+		if len(record.OwnerUsage) == 0 && len(record.LargeFiles) == 0 && len(record.AgeUsage) == 0 {
+			continue
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("reading record ID for %s: %w", record.Directory, err)
+		}
+		for _, o := range record.OwnerUsage {
+			if _, err := ownerStmt.ExecContext(ctx, id, o.UID, o.Owner, o.SizeBytes); err != nil {
+				return fmt.Errorf("inserting owner usage for %s: %w", record.Directory, err)
+			}
+		}
+		for _, f := range record.LargeFiles {
+			if _, err := fileStmt.ExecContext(ctx, id, f.Path, f.SizeBytes, f.ModTime.UTC()); err != nil {
+				return fmt.Errorf("inserting large file for %s: %w", record.Directory, err)
+			}
+		}
+		for _, a := range record.AgeUsage {
+			if _, err := ageStmt.ExecContext(ctx, id, a.Basis, a.MinDays, a.MaxDays, a.SizeBytes); err != nil {
+				return fmt.Errorf("inserting age usage for %s: %w", record.Directory, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// usageSource stands in for usage_records in queries that should also see
+// daily rollups of older records. A rollup row has ID 0 and no scan, is
+// timestamped at the start of its day, and reports the day's average size;
+// the trailing rollup_* columns are NULL for raw records.
+const usageSource = `(
+	SELECT id, base_path, directory, size_bytes, recorded_at, scan_id,
+		file_filter, symlink_count, fingerprint, allocated_bytes, owner, owner_group, quota_limit, deleted, file_count, dir_count, hostname,
+		NULL AS rollup_samples, NULL AS rollup_min, NULL AS rollup_max
+	FROM usage_records
+	UNION ALL
+	SELECT 0, base_path, directory, avg_bytes, day, '',
+		'', NULL, '', NULL, owner, owner_group, NULL, 0, NULL, NULL, hostname,
+		samples, min_bytes, max_bytes
+	FROM usage_rollups
+)`
+
+// rollupColumns receives the rollup_* columns of a usageSource row.
+type rollupColumns struct {
+	samples, min, max sql.NullInt64
+}
+
+// rollup returns the row's rollup summary, or nil for a raw record.
+func (c rollupColumns) rollup() *Rollup {
+	if !c.samples.Valid {
+		return nil
+	}
+	return &Rollup{Samples: int(c.samples.Int64), MinBytes: c.min.Int64, MaxBytes: c.max.Int64}
+}
+
+// QueryUsage retrieves usage records matching the given options.
+func (s *SQLiteStorage) QueryUsage(ctx context.Context, opts QueryOptions) ([]UsageRecord, error) {
+	query := `SELECT id, base_path, directory, size_bytes, recorded_at, scan_id, file_filter, symlink_count, fingerprint, allocated_bytes, owner, owner_group, quota_limit, deleted, file_count, dir_count, hostname,
+		             rollup_samples, rollup_min, rollup_max
+		      FROM ` + usageSource + ` WHERE 1=1`
+	args := []interface{}{}
+
+	if opts.Directory != "" {
+		query += " AND directory = ?"
+		args = append(args, opts.Directory)
+	}
+
+	if opts.BasePath != "" {
+		query += " AND base_path = ?"
+		args = append(args, opts.BasePath)
+	}
+
+	if opts.Since != nil {
+		query += " AND recorded_at >= ?"
+		args = append(args, opts.Since.UTC())
+	}
+
+	if opts.Until != nil {
+		query += " AND recorded_at <= ?"
+		args = append(args, opts.Until.UTC())
+	}
+
+	if opts.Owner != "" {
+		query += " AND owner = ?"
+		args = append(args, opts.Owner)
+	}
+
+	if opts.Hostname != "" {
+		query += " AND hostname = ?"
+		args = append(args, opts.Hostname)
+	}
+
+	if opts.ByOwner {
+		query += " AND id IN (SELECT record_id FROM usage_by_owner)"
+	}
+
+	if opts.LargeFiles {
+		query += " AND id IN (SELECT record_id FROM large_files)"
+	}
+
+	if opts.ByAge {
+		query += " AND id IN (SELECT record_id FROM usage_by_age)"
+	}
+
+	if opts.Match != "" || opts.MatchRegex != "" {
+		query += " AND " + directoryMatchSQL
+		args = append(args, directoryMatchArgs(opts.Match, opts.MatchRegex)...)
+	}
+
+	if c := opts.After; c != nil {
+		query += " AND (recorded_at, id, base_path, directory) < (?, ?, ?, ?)"
+		args = append(args, c.RecordedAt.UTC(), c.ID, c.BasePath, c.Directory)
+	}
+
+	// The tiebreakers give records a total order for cursors
+	query += " ORDER BY recorded_at DESC, id DESC, base_path DESC, directory DESC"
+
+	if opts.Limit > 0 || opts.Offset > 0 {
+		// SQLite needs a LIMIT for OFFSET; -1 is none
+		limit := -1
+		if opts.Limit > 0 {
+			limit = opts.Limit
+		}
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, limit, max(opts.Offset, 0))
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying usage: %w", err)
+	}
+	defer rows.Close()
+
+	var records []UsageRecord
+	for rows.Next() {
+		var r UsageRecord
+		var rc rollupColumns
+		if err := rows.Scan(&r.ID, &r.BasePath, &r.Directory, &r.SizeBytes, &r.RecordedAt, &r.ScanID, &r.FileFilter, &r.SymlinkCount, &r.Fingerprint, &r.AllocatedBytes, &r.Owner, &r.Group, &r.QuotaLimit, &r.Deleted, &r.FileCount, &r.DirCount, &r.Hostname,
+			&rc.samples, &rc.min, &rc.max); err != nil {
+			return nil, fmt.Errorf("scanning row: %w", err)
+		}
+		r.Rollup = rc.rollup()
+		records = append(records, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating rows: %w", err)
+	}
+
+	if opts.ByOwner {
+		if err := s.loadOwnerUsage(ctx, records); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.LargeFiles {
+		if err := s.loadLargeFiles(ctx, records); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.ByAge {
+		if err := s.loadAgeUsage(ctx, records); err != nil {
+			return nil, err
+		}
+	}
+
+	return records, nil
+}
+
+// loadOwnerUsage fills in the per-owner breakdown of each record.
+func (s *SQLiteStorage) loadOwnerUsage(ctx context.Context, records []UsageRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	byID, in, args := recordIDs(records)
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT record_id, uid, owner, size_bytes FROM usage_by_owner
+		 WHERE record_id IN (`+in+`)
+		 ORDER BY record_id, size_bytes DESC, uid`,
+		args...,
+	)
+	if err != nil {
+		return fmt.Errorf("querying owner usage: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var o OwnerUsage
+		if err := rows.Scan(&id, &o.UID, &o.Owner, &o.SizeBytes); err != nil {
+			return fmt.Errorf("scanning row: %w", err)
+		}
+		if r, ok := byID[id]; ok {
+			r.OwnerUsage = append(r.OwnerUsage, o)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("iterating rows: %w", err)
+	}
+
+	return nil
+}
+
+// loadLargeFiles fills in the large files captured with each record.
+func (s *SQLiteStorage) loadLargeFiles(ctx context.Context, records []UsageRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	byID, in, args := recordIDs(records)
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT record_id, path, size_bytes, mtime FROM large_files
+		 WHERE record_id IN (`+in+`)
+		 ORDER BY record_id, size_bytes DESC, path`,
+		args...,
+	)
+	if err != nil {
+		return fmt.Errorf("querying large files: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var f LargeFile
+		if err := rows.Scan(&id, &f.Path, &f.SizeBytes, &f.ModTime); err != nil {
+			return fmt.Errorf("scanning row: %w", err)
+		}
+		if r, ok := byID[id]; ok {
+			r.LargeFiles = append(r.LargeFiles, f)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("iterating rows: %w", err)
+	}
+
+	return nil
+}
+
+// loadAgeUsage fills in the age breakdown of each record.
+func (s *SQLiteStorage) loadAgeUsage(ctx context.Context, records []UsageRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	byID, in, args := recordIDs(records)
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT record_id, basis, min_days, max_days, size_bytes FROM usage_by_age
+		 WHERE record_id IN (`+in+`)
+		 ORDER BY record_id, min_days`,
+		args...,
+	)
+	if err != nil {
+		return fmt.Errorf("querying age usage: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var a AgeUsage
+		if err := rows.Scan(&id, &a.Basis, &a.MinDays, &a.MaxDays, &a.SizeBytes); err != nil {
+			return fmt.Errorf("scanning row: %w", err)
+		}
+		if r, ok := byID[id]; ok {
+			r.AgeUsage = append(r.AgeUsage, a)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("iterating rows: %w", err)
+	}
+
+	return nil
+}
+
+// recordIDs indexes records by ID and returns the placeholders and arguments
+// of an IN list of their IDs.
+func recordIDs(records []UsageRecord) (map[int64]*UsageRecord, string, []interface{}) {
+	byID := make(map[int64]*UsageRecord, len(records))
+	placeholders := make([]string, len(records))
+	args := make([]interface{}, len(records))
+	for i := range records {
+		byID[records[i].ID] = &records[i]
+		placeholders[i] = "?"
+		args[i] = records[i].ID
+	}
+	return byID, strings.Join(placeholders, ", "), args
+}
+
+// bucketAggregates are the SQL expressions of each aggregation over
+// usageSource rows. Rollups count with the range and samples they summarize;
+// for last, SQLite takes the bare size_bytes from the row that supplied
+// MAX(recorded_at), which every bucket query selects.
+var bucketAggregates = map[string]string{
+	AggMin:  "MIN(COALESCE(rollup_min, size_bytes))",
+	AggMax:  "MAX(COALESCE(rollup_max, size_bytes))",
+	AggAvg:  "CAST(ROUND(SUM(size_bytes * COALESCE(rollup_samples, 1)) * 1.0 / SUM(COALESCE(rollup_samples, 1))) AS INTEGER)",
+	AggLast: "size_bytes",
+}
+
+// bucketOrigin is the Unix time buckets are aligned to, Monday 1970-01-05
+// 00:00 UTC, so week buckets start on Mondays.
+const bucketOrigin = 4 * 24 * 60 * 60
+
+// QueryBuckets aggregates usage records into one value per time bucket per
+// directory, newest bucket first.
+func (s *SQLiteStorage) QueryBuckets(ctx context.Context, opts BucketOptions) ([]UsageBucket, error) {
+	agg, ok := bucketAggregates[opts.Agg]
+	if !ok {
+		return nil, fmt.Errorf("unknown aggregation %q", opts.Agg)
+	}
+	width := int64(opts.Bucket / time.Second)
+	if width < 1 || opts.Bucket%time.Second != 0 {
+		return nil, fmt.Errorf("bucket width must be a whole number of seconds, not %s", opts.Bucket)
+	}
+
+	// recorded_at is stored as UTC text, so its first 19 characters are
+	// the time to the second. Buckets count from a Monday midnight.
+	query := `SELECT directory,
+		(CAST(strftime('%s', substr(recorded_at, 1, 19)) AS INTEGER) - ?) / ? * ? + ? AS bucket,
+		` + agg + `, SUM(COALESCE(rollup_samples, 1)), MAX(recorded_at)
+		FROM ` + usageSource + ` WHERE deleted = 0 AND file_filter = ''`
+	args := []interface{}{bucketOrigin, width, width, bucketOrigin}
+
+	if opts.Directory != "" {
+		query += " AND directory = ?"
+		args = append(args, opts.Directory)
+	}
+	if opts.BasePath != "" {
+		query += " AND base_path = ?"
+		args = append(args, opts.BasePath)
+	}
+	if opts.Since != nil {
+		query += " AND recorded_at >= ?"
+		args = append(args, opts.Since.UTC())
+	}
+	if opts.Until != nil {
+		query += " AND recorded_at <= ?"
+		args = append(args, opts.Until.UTC())
+	}
+	if opts.Hostname != "" {
+		query += " AND hostname = ?"
+		args = append(args, opts.Hostname)
+	}
+	if opts.Match != "" || opts.MatchRegex != "" {
+		query += " AND " + directoryMatchSQL
+		args = append(args, directoryMatchArgs(opts.Match, opts.MatchRegex)...)
+	}
+
+	query += " GROUP BY directory, bucket ORDER BY bucket DESC, directory"
+	if opts.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, opts.Limit)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying buckets: %w", err)
+	}
+	defer rows.Close()
+
+	var buckets []UsageBucket
+	for rows.Next() {
+		var b UsageBucket
+		var start int64
+		var newest string // only there for AggLast
+		if err := rows.Scan(&b.Directory, &start, &b.SizeBytes, &b.Samples, &newest); err != nil {
+			return nil, fmt.Errorf("scanning row: %w", err)
+		}
+		b.Start = time.Unix(start, 0).UTC()
+		buckets = append(buckets, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating rows: %w", err)
+	}
+	return buckets, nil
+}
+
+// GetLatestUsage retrieves the most recent usage record for a directory.
+func (s *SQLiteStorage) GetLatestUsage(ctx context.Context, directory string) (*UsageRecord, error) {
+	var r UsageRecord
+	err := s.db.QueryRowContext(ctx,
+		`SELECT id, base_path, directory, size_bytes, recorded_at, scan_id, file_filter, symlink_count, fingerprint, allocated_bytes, owner, owner_group, quota_limit, deleted, file_count, dir_count, hostname
+		 FROM usage_records
+		 WHERE directory = ? AND file_filter = ''
+		 ORDER BY recorded_at DESC
+		 LIMIT 1`,
+		directory,
+	).Scan(&r.ID, &r.BasePath, &r.Directory, &r.SizeBytes, &r.RecordedAt, &r.ScanID, &r.FileFilter, &r.SymlinkCount, &r.Fingerprint, &r.AllocatedBytes, &r.Owner, &r.Group, &r.QuotaLimit, &r.Deleted, &r.FileCount, &r.DirCount, &r.Hostname)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("querying latest usage: %w", err)
+	}
+
+	return &r, nil
+}
+
+// GetTopChangers finds directories with the largest usage changes over a time interval.
+func (s *SQLiteStorage) GetTopChangers(ctx context.Context, opts TopChangerOptions) ([]DirectoryChange, error) {
+	if opts.IncludeNew || opts.IncludeDeleted {
+		return s.topChangersWithChurn(ctx, opts)
+	}
+
+	// Normalize base path: remove trailing slash for consistent comparison
+	basePath := opts.BasePath
+	if len(basePath) > 1 && basePath[len(basePath)-1] == '/' {
+		basePath = basePath[:len(basePath)-1]
+	}
+
+	query := `
+		WITH ranked AS (
+			SELECT
+				directory,
+				base_path,
+				size_bytes,
+				recorded_at,
+				owner,
+				owner_group,
+				hostname,
+				ROW_NUMBER() OVER (PARTITION BY hostname, directory ORDER BY recorded_at ASC) AS rn_first,
+				ROW_NUMBER() OVER (PARTITION BY hostname, directory ORDER BY recorded_at DESC) AS rn_last
+			FROM ` + usageSource + `
+			WHERE (base_path = ? OR base_path = ? || '/')
+			  AND recorded_at BETWEEN ? AND ?
+			  AND file_filter = ''
+			  AND (? = '' OR hostname = ?)
+			  AND ` + directoryMatchSQL + `
+		),
+		changes AS (
+			SELECT
+				r1.directory,
+				r1.base_path,
+				r1.size_bytes AS start_size,
+				r1.recorded_at AS start_time,
+				r2.size_bytes AS end_size,
+				r2.recorded_at AS end_time,
+				r2.owner,
+				r2.owner_group,
+				r1.hostname
+			FROM ranked r1
+			JOIN ranked r2 ON r1.hostname = r2.hostname AND r1.directory = r2.directory
+			WHERE r1.rn_first = 1 AND r2.rn_last = 1
+		)
+		SELECT
+			directory, base_path, start_size, end_size, start_time, end_time,
+			(end_size - start_size) AS change_bytes,
+			CASE WHEN start_size > 0 THEN ROUND(100.0 * (end_size - start_size) / start_size, 2) ELSE 0 END AS change_percent,
+			owner, owner_group, hostname
+		FROM changes
+		WHERE ((? = 0 AND ? = 0)
+		    OR (? > 0 AND ABS(end_size - start_size) >= ?)
+		    OR (? > 0 AND end_size <> start_size AND (start_size <= 0 OR 100.0 * ABS(end_size - start_size) / start_size >= ?)))
+		  AND (? = '' OR owner = ?)
+		  AND (? = 'both' OR (? = 'increase' AND end_size > start_size) OR (? = 'decrease' AND end_size < start_size))
+		ORDER BY ABS(end_size - start_size) DESC
+		LIMIT ?;
+	`
+
+	args := []interface{}{
+		basePath,
+		basePath,
+		opts.Since.UTC(),
+		opts.Until.UTC(),
+		opts.Hostname,
+		opts.Hostname,
+	}
+	args = append(args, directoryMatchArgs(opts.Match, opts.MatchRegex)...)
+	args = append(args,
+		opts.MinChangeBytes,
+		opts.MinChangePercent,
+		opts.MinChangeBytes,
+		opts.MinChangeBytes,
+		opts.MinChangePercent,
+		opts.MinChangePercent,
+		opts.Owner,
+		opts.Owner,
+		opts.Direction,
+		opts.Direction,
+		opts.Direction,
+		opts.Limit,
+	)
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying top changers: %w", err)
+	}
+	defer rows.Close()
+
+	var results []DirectoryChange
+	for rows.Next() {
+		var dc DirectoryChange
+		if err := rows.Scan(
+			&dc.Directory,
+			&dc.BasePath,
+			&dc.StartSize,
+			&dc.EndSize,
+			&dc.StartTime,
+			&dc.EndTime,
+			&dc.ChangeBytes,
+			&dc.ChangePercent,
+			&dc.Owner,
+			&dc.Group,
+			&dc.Hostname,
+		); err != nil {
+			return nil, fmt.Errorf("scanning row: %w", err)
+		}
+		results = append(results, dc)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating rows: %w", err)
+	}
+
+	return results, nil
+}
+
+// GetTrends classifies each directory under a base path by its usage history.
+func (s *SQLiteStorage) GetTrends(ctx context.Context, opts TrendOptions) ([]DirectoryTrend, error) {
+	basePath := strings.TrimSuffix(opts.BasePath, "/")
+	if basePath == "" {
+		basePath = "/"
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT base_path, directory, size_bytes, recorded_at
+		FROM `+usageSource+`
+		WHERE (base_path = ? OR base_path = ? || '/')
+		  AND recorded_at >= ? AND recorded_at <= ?
+		  AND file_filter = ''
+		ORDER BY directory, recorded_at`,
+		basePath, basePath, opts.Since.UTC(), opts.Until.UTC(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("querying trends: %w", err)
+	}
+	defer rows.Close()
+
+	var trends []DirectoryTrend
+	var points []trendPoint
+	var cur DirectoryTrend
+	flush := func() {
+		if len(points) == 0 {
+			return
+		}
+		cur.Samples = len(points)
+		cur.StartSize, cur.StartTime = points[0].size, points[0].at
+		cur.EndSize, cur.EndTime = points[len(points)-1].size, points[len(points)-1].at
+		cur.Trend, cur.BytesPerDay = classifyTrend(points)
+		trends = append(trends, cur)
+		points = points[:0]
+	}
+
+	for rows.Next() {
+		var bp, dir string
+		var p trendPoint
+		if err := rows.Scan(&bp, &dir, &p.size, &p.at); err != nil {
+			return nil, fmt.Errorf("scanning row: %w", err)
+		}
+		if dir != cur.Directory {
+			flush()
+			cur = DirectoryTrend{Directory: dir, BasePath: bp}
+		}
+		points = append(points, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating rows: %w", err)
+	}
+	flush()
+
+	return trends, nil
+}
+
+// GetSnapshotAt reconstructs each directory's size under basePath as of t,
+// separately for each host.
+func (s *SQLiteStorage) GetSnapshotAt(ctx context.Context, basePath, hostname string, t time.Time) ([]UsageRecord, error) {
+	basePath = strings.TrimSuffix(basePath, "/")
+	if basePath == "" {
+		basePath = "/"
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		WITH ranked AS (
+			SELECT
+				id, base_path, directory, size_bytes, recorded_at, scan_id,
+				file_filter, symlink_count, fingerprint, allocated_bytes, owner, owner_group, quota_limit, deleted, file_count, dir_count, hostname,
+				rollup_samples, rollup_min, rollup_max,
+				ROW_NUMBER() OVER (PARTITION BY hostname, directory ORDER BY recorded_at DESC) AS rn
+			FROM `+usageSource+`
+			WHERE (base_path = ? OR base_path = ? || '/')
+			  AND recorded_at <= ?
+			  AND file_filter = ''
+			  AND (? = '' OR hostname = ?)
+		)
+		SELECT id, base_path, directory, size_bytes, recorded_at, scan_id,
+			file_filter, symlink_count, fingerprint, allocated_bytes, owner, owner_group, quota_limit, deleted, file_count, dir_count, hostname,
+			rollup_samples, rollup_min, rollup_max
+		FROM ranked
+		WHERE rn = 1 AND deleted = 0
+		ORDER BY directory, hostname`,
+		basePath, basePath, t.UTC(), hostname, hostname,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("querying snapshot: %w", err)
+	}
+	defer rows.Close()
+
+	var records []UsageRecord
+	for rows.Next() {
+		var r UsageRecord
+		var rc rollupColumns
+		if err := rows.Scan(&r.ID, &r.BasePath, &r.Directory, &r.SizeBytes, &r.RecordedAt, &r.ScanID,
+			&r.FileFilter, &r.SymlinkCount, &r.Fingerprint, &r.AllocatedBytes, &r.Owner, &r.Group, &r.QuotaLimit, &r.Deleted, &r.FileCount, &r.DirCount, &r.Hostname,
+			&rc.samples, &rc.min, &rc.max); err != nil {
+			return nil, fmt.Errorf("scanning row: %w", err)
+		}
+		r.Rollup = rc.rollup()
+		records = append(records, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating rows: %w", err)
+	}
+
+	return records, nil
+}
+
+// Info returns database metadata and aggregate counts.
+func (s *SQLiteStorage) Info(ctx context.Context) (*DBInfo, error) {
+	var info DBInfo
+
+	if err := s.db.QueryRowContext(ctx, "SELECT sqlite_version()").Scan(&info.SQLiteVersion); err != nil {
+		return nil, fmt.Errorf("querying sqlite version: %w", err)
+	}
+	if err := s.db.QueryRowContext(ctx, "PRAGMA user_version").Scan(&info.SchemaVersion); err != nil {
+		return nil, fmt.Errorf("querying schema version: %w", err)
+	}
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM usage_records").Scan(&info.UsageRecords); err != nil {
+		return nil, fmt.Errorf("counting usage records: %w", err)
+	}
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM scans").Scan(&info.Scans); err != nil {
+		return nil, fmt.Errorf("counting scans: %w", err)
+	}
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM usage_rollups").Scan(&info.Rollups); err != nil {
+		return nil, fmt.Errorf("counting rollups: %w", err)
+	}
+
+	// MIN/MAX would lose the column's DATETIME type, so order instead
+	for _, q := range []struct {
+		order string
+		dest  **time.Time
+	}{
+		{"ASC", &info.EarliestRecord},
+		{"DESC", &info.LatestRecord},
+	} {
+		var t time.Time
+		err := s.db.QueryRowContext(ctx,
+			"SELECT recorded_at FROM usage_records ORDER BY recorded_at "+q.order+" LIMIT 1",
+		).Scan(&t)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("querying record time range: %w", err)
+		}
+		*q.dest = &t
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT base_path, COUNT(*) FROM usage_records GROUP BY base_path ORDER BY base_path`)
+	if err != nil {
+		return nil, fmt.Errorf("counting records per base path: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var bp BasePathCount
+		if err := rows.Scan(&bp.BasePath, &bp.Records); err != nil {
+			return nil, fmt.Errorf("scanning row: %w", err)
+		}
+		info.BasePaths = append(info.BasePaths, bp)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating rows: %w", err)
+	}
+
+	return &info, nil
+}
+
+// futureTolerance allows for clock skew before a timestamp counts as being
+// in the future.
+const futureTolerance = 5 * time.Minute
+
+// CheckIntegrity verifies the database file and the consistency of its contents.
+func (s *SQLiteStorage) CheckIntegrity(ctx context.Context) ([]IntegrityProblem, error) {
+	var problems []IntegrityProblem
+
+	// integrity_check reports a single "ok" row when the file is sound
+	rows, err := s.db.QueryContext(ctx, "PRAGMA integrity_check")
+	if err != nil {
+		return nil, fmt.Errorf("running integrity check: %w", err)
+	}
+	for rows.Next() {
+		var msg string
+		if err := rows.Scan(&msg); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scanning row: %w", err)
+		}
+		if msg != "ok" {
+			problems = append(problems, IntegrityProblem{Check: "integrity_check", Detail: msg})
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating rows: %w", err)
+	}
+
+	// foreign_key_check lists every violating row; summarize per table
+	rows, err = s.db.QueryContext(ctx, `
+		SELECT "table", parent, COUNT(*)
+		FROM pragma_foreign_key_check
+		GROUP BY "table", parent
+		ORDER BY "table", parent`)
+	if err != nil {
+		return nil, fmt.Errorf("running foreign key check: %w", err)
+	}
+	for rows.Next() {
+		var table, parent string
+		var n int64
+		if err := rows.Scan(&table, &parent, &n); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scanning row: %w", err)
+		}
+		problems = append(problems, IntegrityProblem{
+			Check:  "foreign_key_check",
+			Detail: fmt.Sprintf("%d %s rows reference missing %s rows", n, table, parent),
+		})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating rows: %w", err)
+	}
+
+	future := time.Now().UTC().Add(futureTolerance)
+	sanity := []struct {
+		check  string
+		query  string
+		args   []interface{}
+		detail string
+	}{
+		{
+			"negative_sizes",
+			"SELECT COUNT(*) FROM usage_records WHERE size_bytes < 0 OR allocated_bytes < 0",
+			nil,
+			"usage records have a negative size",
+		},
+		{
+			"future_records",
+			"SELECT COUNT(*) FROM usage_records WHERE recorded_at > ?",
+			[]interface{}{future},
+			"usage records are timestamped in the future",
+		},
+		{
+			"future_scans",
+			"SELECT COUNT(*) FROM scans WHERE started_at > ?",
+			[]interface{}{future},
+			"scans started in the future",
+		},
+		{
+			"scan_times",
+			"SELECT COUNT(*) FROM scans WHERE completed_at < started_at",
+			nil,
+			"scans completed before they started",
+		},
+	}
+	for _, c := range sanity {
+		var n int64
+		if err := s.db.QueryRowContext(ctx, c.query, c.args...).Scan(&n); err != nil {
+			return nil, fmt.Errorf("running %s check: %w", c.check, err)
+		}
+		if n > 0 {
+			problems = append(problems, IntegrityProblem{Check: c.check, Detail: fmt.Sprintf("%d %s", n, c.detail)})
+		}
+	}
+
+	return problems, nil
+}
+
+// ListSkipEntries returns tracked skip-list entries for directories under prefix.
+func (s *SQLiteStorage) ListSkipEntries(ctx context.Context, prefix string) ([]SkipEntry, error) {
+	query := `SELECT directory, consecutive_errors, last_error, skipped, manual, updated_at
+		      FROM skip_list`
+	args := []interface{}{}
+
+	if prefix != "" {
+		prefix = strings.TrimSuffix(prefix, "/")
+		query += " WHERE directory = ? OR substr(directory, 1, length(?) + 1) = ? || '/'"
+		args = append(args, prefix, prefix, prefix)
+	}
+
+	query += " ORDER BY directory"
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying skip list: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []SkipEntry
+	for rows.Next() {
+		var e SkipEntry
+		if err := rows.Scan(&e.Directory, &e.ConsecutiveErrors, &e.LastError, &e.Skipped, &e.Manual, &e.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scanning row: %w", err)
+		}
+		entries = append(entries, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating rows: %w", err)
+	}
+
+	return entries, nil
+}
+
+// RecordDirectoryError increments the consecutive error count for a directory.
+func (s *SQLiteStorage) RecordDirectoryError(ctx context.Context, directory, message string, threshold int) (*SkipEntry, error) {
+	now := time.Now().UTC()
+
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO skip_list (directory, consecutive_errors, last_error, skipped, manual, updated_at)
+		 VALUES (?, 1, ?, 0, 0, ?)
+		 ON CONFLICT(directory) DO UPDATE SET
+			consecutive_errors = consecutive_errors + 1,
+			last_error = excluded.last_error,
+			updated_at = excluded.updated_at`,
+		directory, message, now,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("recording directory error: %w", err)
+	}
+
+	if threshold > 0 {
+		_, err = s.db.ExecContext(ctx,
+			`UPDATE skip_list SET skipped = 1 WHERE directory = ? AND consecutive_errors >= ?`,
+			directory, threshold,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("marking directory skipped: %w", err)
+		}
+	}
+
+	var e SkipEntry
+	err = s.db.QueryRowContext(ctx,
+		`SELECT directory, consecutive_errors, last_error, skipped, manual, updated_at
+		 FROM skip_list WHERE directory = ?`,
+		directory,
+	).Scan(&e.Directory, &e.ConsecutiveErrors, &e.LastError, &e.Skipped, &e.Manual, &e.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("querying skip entry: %w", err)
+	}
+
+	return &e, nil
+}
+
+// ClearDirectoryErrors removes a learned skip-list entry.
+func (s *SQLiteStorage) ClearDirectoryErrors(ctx context.Context, directory string) error {
+	_, err := s.db.ExecContext(ctx,
+		`DELETE FROM skip_list WHERE directory = ? AND manual = 0`,
+		directory,
+	)
+	if err != nil {
+		return fmt.Errorf("clearing directory errors: %w", err)
+	}
+
+	return nil
+}
+
+// AddSkipEntry manually adds a directory to the skip list.
+func (s *SQLiteStorage) AddSkipEntry(ctx context.Context, directory, reason string) error {
+	now := time.Now().UTC()
+
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO skip_list (directory, consecutive_errors, last_error, skipped, manual, updated_at)
+		 VALUES (?, 0, ?, 1, 1, ?)
+		 ON CONFLICT(directory) DO UPDATE SET
+			last_error = excluded.last_error,
+			skipped = 1,
+			manual = 1,
+			updated_at = excluded.updated_at`,
+		directory, reason, now,
+	)
+	if err != nil {
+		return fmt.Errorf("adding skip entry: %w", err)
+	}
+
+	return nil
+}
+
+// RemoveSkipEntry removes a directory from the skip list.
+func (s *SQLiteStorage) RemoveSkipEntry(ctx context.Context, directory string) (bool, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM skip_list WHERE directory = ?`, directory)
+	if err != nil {
+		return false, fmt.Errorf("removing skip entry: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("checking affected rows: %w", err)
+	}
+
+	return n > 0, nil
+}
+
+// scansBeforeQuery selects finished scans started before a cutoff.
+const scansBeforeQuery = `SELECT scan_id FROM scans WHERE started_at < ? AND status NOT IN ('running', 'interrupted')`
+
+// scansBeyondLatestQuery selects finished scans started before the Nth
+// newest completed scan of their base path and host, optionally restricted
+// to one base path. Skipped and failed scans do not count towards the N.
+const scansBeyondLatestQuery = `
+	SELECT scan_id FROM scans s
+	WHERE status NOT IN ('running', 'interrupted') AND (? = '' OR base_path = ?)
+	  AND started_at < (
+		SELECT c.started_at FROM scans c
+		WHERE c.status = 'completed' AND c.hostname = s.hostname AND c.base_path = s.base_path
+		ORDER BY c.started_at DESC
+		LIMIT 1 OFFSET ? - 1
+	  )`
+
+// supersededRecord holds for a usage record r that a later record of the
+// same directory, host and file filter replaces. Scans that leave unchanged
+// directories unrecorded (min_change_*, watches, mtime_shortcut) make an old
+// record the current value of its directory for as long as it stays so.
+const supersededRecord = `EXISTS (
+	SELECT 1 FROM usage_records n
+	WHERE n.directory = r.directory AND n.base_path = r.base_path
+	  AND n.hostname = r.hostname AND n.file_filter = r.file_filter
+	  AND n.recorded_at > r.recorded_at)`
+
+// rollupCandidates selects the usage records RollupUsageBefore replaces:
+// unfiltered, non-deleted records from before a UTC midnight, optionally
+// for one base path. Filtered measurements and deletion markers stay raw,
+// as do records that are still their directory's latest value.
+const rollupCandidates = `
+	FROM usage_records AS r
+	WHERE recorded_at < ? AND file_filter = '' AND deleted = 0
+	  AND (? = '' OR base_path = ?)
+	  AND ` + supersededRecord
+
+// rollupCutoff rounds cutoff down to a UTC midnight, so only whole days are
+// rolled up.
+func rollupCutoff(cutoff time.Time) time.Time {
+	return cutoff.UTC().Truncate(24 * time.Hour)
+}
+
+// CountUsageToRollUp counts the records RollupUsageBefore would replace.
+func (s *SQLiteStorage) CountUsageToRollUp(ctx context.Context, basePath string, cutoff time.Time) (int64, error) {
+	var n int64
+	err := s.db.QueryRowContext(ctx, `SELECT COUNT(*)`+rollupCandidates,
+		rollupCutoff(cutoff), basePath, basePath,
+	).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("counting usage records: %w", err)
+	}
+	return n, nil
+}
+
+// RollupUsageBefore replaces old usage records with daily rollups. A day
+// rolled up again (records added after an earlier rollup) is merged into
+// the existing rollup.
+func (s *SQLiteStorage) RollupUsageBefore(ctx context.Context, basePath string, cutoff time.Time) (int64, int64, error) {
+	args := []interface{}{rollupCutoff(cutoff), basePath, basePath}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// recorded_at is stored as UTC text, so its first ten characters are the
+	// day; the owner columns come from each day's last record, as SQLite
+	// takes bare columns from the row that supplied MAX(). The outer WHERE
+	// keeps the parser from reading ON CONFLICT as a join constraint.
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO usage_rollups (base_path, directory, day, samples, min_bytes, max_bytes, avg_bytes, owner, owner_group, hostname)
+		SELECT base_path, directory, day, samples, min_bytes, max_bytes, avg_bytes, owner, owner_group, hostname
+		FROM (
+			SELECT base_path, directory, substr(recorded_at, 1, 10) || ' 00:00:00 +0000 UTC' AS day,
+				COUNT(*) AS samples, MIN(size_bytes) AS min_bytes, MAX(size_bytes) AS max_bytes,
+				CAST(ROUND(AVG(size_bytes)) AS INTEGER) AS avg_bytes,
+				owner, owner_group, hostname, MAX(recorded_at)
+			`+rollupCandidates+`
+			GROUP BY base_path, directory, day, hostname
+		)
+		WHERE true
+		ON CONFLICT (base_path, directory, day, hostname) DO UPDATE SET
+			min_bytes = MIN(min_bytes, excluded.min_bytes),
+			max_bytes = MAX(max_bytes, excluded.max_bytes),
+			avg_bytes = (avg_bytes * samples + excluded.avg_bytes * excluded.samples) / (samples + excluded.samples),
+			samples = samples + excluded.samples,
+			owner = excluded.owner,
+			owner_group = excluded.owner_group`,
+		args...,
+	)
+	if err != nil {
+		return 0, 0, fmt.Errorf("inserting rollups: %w", err)
+	}
+	days, err := res.RowsAffected()
+	if err != nil {
+		return 0, 0, fmt.Errorf("checking affected rows: %w", err)
+	}
+
+	// Per-owner and age breakdowns and large files are not rolled up
+	if _, err := tx.ExecContext(ctx, `DELETE FROM usage_by_owner WHERE record_id IN (SELECT id`+rollupCandidates+`)`, args...); err != nil {
+		return 0, 0, fmt.Errorf("deleting owner usage: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM large_files WHERE record_id IN (SELECT id`+rollupCandidates+`)`, args...); err != nil {
+		return 0, 0, fmt.Errorf("deleting large files: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM usage_by_age WHERE record_id IN (SELECT id`+rollupCandidates+`)`, args...); err != nil {
+		return 0, 0, fmt.Errorf("deleting age usage: %w", err)
+	}
+
+	res, err = tx.ExecContext(ctx, `DELETE`+rollupCandidates, args...)
+	if err != nil {
+		return 0, 0, fmt.Errorf("deleting usage records: %w", err)
+	}
+	records, err := res.RowsAffected()
+	if err != nil {
+		return 0, 0, fmt.Errorf("checking affected rows: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, 0, fmt.Errorf("committing transaction: %w", err)
+	}
+
+	return days, records, nil
+}
+
+// CountScansBefore counts finished scans started before cutoff and their usage records.
+func (s *SQLiteStorage) CountScansBefore(ctx context.Context, cutoff time.Time) (int64, int64, error) {
+	return s.countScans(ctx, scansBeforeQuery, false, cutoff.UTC())
+}
+
+// PruneScansBefore deletes finished scans started before cutoff along with their records.
+func (s *SQLiteStorage) PruneScansBefore(ctx context.Context, cutoff time.Time) (int64, int64, error) {
+	return s.deleteScans(ctx, scansBeforeQuery, false, cutoff.UTC())
+}
+
+// CountScansKeepingLatest counts the scans and records PruneScansKeepingLatest would delete.
+func (s *SQLiteStorage) CountScansKeepingLatest(ctx context.Context, basePath string, keepN int) (int64, int64, error) {
+	return s.countScans(ctx, scansBeyondLatestQuery, true, basePath, basePath, keepN)
+}
+
+// PruneScansKeepingLatest deletes finished scans from before the newest keepN
+// completed ones per base path and host. Records that are still their
+// directory's latest value are kept, and with them their scans.
+func (s *SQLiteStorage) PruneScansKeepingLatest(ctx context.Context, basePath string, keepN int) (int64, int64, error) {
+	return s.deleteScans(ctx, scansBeyondLatestQuery, true, basePath, basePath, keepN)
+}
+
+// countScans counts the scans selected by a scan_id subquery and their usage
+// records. With keepLatest, records that are still their directory's latest
+// value are left out, as are the scans that hold any.
+func (s *SQLiteStorage) countScans(ctx context.Context, selectQuery string, keepLatest bool, args ...interface{}) (int64, int64, error) {
+	scanFilter, recordFilter := "", ""
+	if keepLatest {
+		scanFilter = ` WHERE NOT EXISTS (SELECT 1 FROM usage_records r WHERE r.scan_id = p.scan_id AND NOT ` + supersededRecord + `)`
+		recordFilter = ` AND ` + supersededRecord
+	}
+	var scans, records int64
+	err := s.db.QueryRowContext(ctx,
+		`SELECT
+			(SELECT COUNT(*) FROM (`+selectQuery+`) p`+scanFilter+`),
+			(SELECT COUNT(*) FROM usage_records r WHERE scan_id IN (`+selectQuery+`)`+recordFilter+`)`,
+		append(append([]interface{}{}, args...), args...)...,
+	).Scan(&scans, &records)
+	if err != nil {
+		return 0, 0, fmt.Errorf("counting scans: %w", err)
+	}
+
+	return scans, records, nil
+}
+
+// deleteScans deletes the scans selected by a scan_id subquery along with
+// their records. With keepLatest, records that are still their directory's
+// latest value are kept, and so are the scans that hold any.
+func (s *SQLiteStorage) deleteScans(ctx context.Context, selectQuery string, keepLatest bool, args ...interface{}) (int64, int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Collect the IDs first so both deletes act on the same set
+	if _, err := tx.ExecContext(ctx, `CREATE TEMP TABLE IF NOT EXISTS prune_ids (scan_id TEXT PRIMARY KEY)`); err != nil {
+		return 0, 0, fmt.Errorf("creating temp table: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM prune_ids`); err != nil {
+		return 0, 0, fmt.Errorf("clearing temp table: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO prune_ids `+selectQuery, args...); err != nil {
+		return 0, 0, fmt.Errorf("selecting scans: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `CREATE TEMP TABLE IF NOT EXISTS prune_records (id INTEGER PRIMARY KEY)`); err != nil {
+		return 0, 0, fmt.Errorf("creating temp table: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM prune_records`); err != nil {
+		return 0, 0, fmt.Errorf("clearing temp table: %w", err)
+	}
+	recordQuery := `INSERT INTO prune_records SELECT id FROM usage_records r WHERE scan_id IN (SELECT scan_id FROM prune_ids)`
+	if keepLatest {
+		recordQuery += ` AND ` + supersededRecord
+	}
+	if _, err := tx.ExecContext(ctx, recordQuery); err != nil {
+		return 0, 0, fmt.Errorf("selecting usage records: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM usage_by_owner WHERE record_id IN (SELECT id FROM prune_records)`); err != nil {
+		return 0, 0, fmt.Errorf("deleting owner usage: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM large_files WHERE record_id IN (SELECT id FROM prune_records)`); err != nil {
+		return 0, 0, fmt.Errorf("deleting large files: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM usage_by_age WHERE record_id IN (SELECT id FROM prune_records)`); err != nil {
+		return 0, 0, fmt.Errorf("deleting age usage: %w", err)
+	}
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM usage_records WHERE id IN (SELECT id FROM prune_records)`)
+	if err != nil {
+		return 0, 0, fmt.Errorf("deleting usage records: %w", err)
+	}
+	records, err := res.RowsAffected()
+	if err != nil {
+		return 0, 0, fmt.Errorf("checking affected rows: %w", err)
+	}
+
+	// Scans left holding records stay
+	if _, err := tx.ExecContext(ctx, `DELETE FROM prune_ids WHERE EXISTS (SELECT 1 FROM usage_records WHERE usage_records.scan_id = prune_ids.scan_id)`); err != nil {
+		return 0, 0, fmt.Errorf("keeping scans: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM scan_progress WHERE scan_id IN (SELECT scan_id FROM prune_ids)`); err != nil {
+		return 0, 0, fmt.Errorf("deleting scan progress: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM fs_stats WHERE scan_id IN (SELECT scan_id FROM prune_ids)`); err != nil {
+		return 0, 0, fmt.Errorf("deleting filesystem stats: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM ingest_batches WHERE scan_id IN (SELECT scan_id FROM prune_ids)`); err != nil {
+		return 0, 0, fmt.Errorf("deleting ingested batches: %w", err)
+	}
+
+	res, err = tx.ExecContext(ctx, `DELETE FROM scans WHERE scan_id IN (SELECT scan_id FROM prune_ids)`)
+	if err != nil {
+		return 0, 0, fmt.Errorf("deleting scans: %w", err)
+	}
+	scans, err := res.RowsAffected()
+	if err != nil {
+		return 0, 0, fmt.Errorf("checking affected rows: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM prune_ids`); err != nil {
+		return 0, 0, fmt.Errorf("clearing temp table: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM prune_records`); err != nil {
+		return 0, 0, fmt.Errorf("clearing temp table: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, 0, fmt.Errorf("committing transaction: %w", err)
+	}
+
+	return scans, records, nil
+}
+
+// ListAlertStates returns directories under basePath that are currently alerting.
+func (s *SQLiteStorage) ListAlertStates(ctx context.Context, basePath string) ([]AlertState, error) {
+	query := `SELECT directory, base_path, threshold_bytes, size_bytes, since, last_notified
+		      FROM alert_state`
+	args := []interface{}{}
+
+	if basePath != "" {
+		query += " WHERE base_path = ?"
+		args = append(args, basePath)
+	}
+
+	query += " ORDER BY directory"
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying alert state: %w", err)
+	}
+	defer rows.Close()
+
+	var states []AlertState
+	for rows.Next() {
+		var a AlertState
+		if err := rows.Scan(&a.Directory, &a.BasePath, &a.ThresholdBytes, &a.SizeBytes, &a.Since, &a.LastNotified); err != nil {
+			return nil, fmt.Errorf("scanning row: %w", err)
+		}
+		states = append(states, a)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating rows: %w", err)
+	}
+
+	return states, nil
+}
+
+// SaveAlertState creates or updates a directory's alert state.
+func (s *SQLiteStorage) SaveAlertState(ctx context.Context, state AlertState) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO alert_state (directory, base_path, threshold_bytes, size_bytes, since, last_notified)
+		 VALUES (?, ?, ?, ?, ?, ?)
+		 ON CONFLICT(directory) DO UPDATE SET
+			base_path = excluded.base_path,
+			threshold_bytes = excluded.threshold_bytes,
+			size_bytes = excluded.size_bytes,
+			since = excluded.since,
+			last_notified = excluded.last_notified`,
+		state.Directory, state.BasePath, state.ThresholdBytes, state.SizeBytes, state.Since.UTC(), state.LastNotified.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("saving alert state: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteAlertState clears a directory's alert state.
+func (s *SQLiteStorage) DeleteAlertState(ctx context.Context, directory string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM alert_state WHERE directory = ?`, directory)
+	if err != nil {
+		return fmt.Errorf("deleting alert state: %w", err)
+	}
+
+	return nil
+}
+
+// LoadDirCache returns the directory cache entries a host saved for basePath.
+func (s *SQLiteStorage) LoadDirCache(ctx context.Context, basePath, hostname string) ([]DirCacheEntry, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT directory, dev, ino, mtime_ns, size_bytes, allocated_bytes FROM dir_cache
+		 WHERE hostname = ? AND base_path = ?`,
+		hostname, basePath,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("querying directory cache: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []DirCacheEntry
+	for rows.Next() {
+		var e DirCacheEntry
+		var dev, ino, mtime int64
+		if err := rows.Scan(&e.Directory, &dev, &ino, &mtime, &e.SizeBytes, &e.AllocatedBytes); err != nil {
+			return nil, fmt.Errorf("scanning row: %w", err)
+		}
+		e.Dev, e.Ino, e.ModTime = uint64(dev), uint64(ino), time.Unix(0, mtime)
+		entries = append(entries, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating rows: %w", err)
+	}
+
+	return entries, nil
+}
+
+// SaveDirCache creates or replaces the given directory cache entries of a
+// host's basePath and deletes those of the removed directories, in one
+// transaction.
+func (s *SQLiteStorage) SaveDirCache(ctx context.Context, basePath, hostname string, entries []DirCacheEntry, removed []string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	upsert, err := tx.PrepareContext(ctx,
+		`INSERT OR REPLACE INTO dir_cache (hostname, base_path, directory, dev, ino, mtime_ns, size_bytes, allocated_bytes)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+	)
+	if err != nil {
+		return fmt.Errorf("preparing statement: %w", err)
+	}
+	defer upsert.Close()
+
+	// Device and inode numbers are stored as their int64 bit patterns
+	for _, e := range entries {
+		if _, err := upsert.ExecContext(ctx, hostname, basePath, e.Directory, int64(e.Dev), int64(e.Ino), e.ModTime.UnixNano(), e.SizeBytes, e.AllocatedBytes); err != nil {
+			return fmt.Errorf("saving directory cache for %s: %w", e.Directory, err)
+		}
+	}
+
+	del, err := tx.PrepareContext(ctx, `DELETE FROM dir_cache WHERE hostname = ? AND base_path = ? AND directory = ?`)
+	if err != nil {
+		return fmt.Errorf("preparing statement: %w", err)
+	}
+	defer del.Close()
+
+	for _, dir := range removed {
+		if _, err := del.ExecContext(ctx, hostname, basePath, dir); err != nil {
+			return fmt.Errorf("deleting directory cache for %s: %w", dir, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing transaction: %w", err)
+	}
+
+	return nil
+}
+
+// RecordFilesystemStats stores the capacity of a base path's filesystem.
+func (s *SQLiteStorage) RecordFilesystemStats(ctx context.Context, stats FilesystemStats) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO fs_stats (base_path, recorded_at, scan_id, hostname, total_bytes, used_bytes, available_bytes)
+		 VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		stats.BasePath, stats.RecordedAt.UTC(), stats.ScanID, stats.Hostname,
+		stats.TotalBytes, stats.UsedBytes, stats.AvailableBytes,
+	)
+	if err != nil {
+		return fmt.Errorf("inserting filesystem stats: %w", err)
+	}
+	return nil
+}
+
+// QueryFilesystemStats returns the filesystem capacity recorded for basePath
+// since the given time, newest first.
+func (s *SQLiteStorage) QueryFilesystemStats(ctx context.Context, basePath string, since time.Time) ([]FilesystemStats, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT base_path, recorded_at, scan_id, hostname, total_bytes, used_bytes, available_bytes
+		 FROM fs_stats WHERE base_path = ? AND recorded_at >= ?
+		 ORDER BY recorded_at DESC`,
+		strings.TrimSuffix(basePath, "/"), since.UTC(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("querying filesystem stats: %w", err)
+	}
+	defer rows.Close()
+
+	var stats []FilesystemStats
+	for rows.Next() {
+		var st FilesystemStats
+		if err := rows.Scan(&st.BasePath, &st.RecordedAt, &st.ScanID, &st.Hostname,
+			&st.TotalBytes, &st.UsedBytes, &st.AvailableBytes); err != nil {
+			return nil, fmt.Errorf("scanning row: %w", err)
+		}
+		stats = append(stats, st)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating rows: %w", err)
+	}
+
+	return stats, nil
+}
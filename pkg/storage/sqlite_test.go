@@ -0,0 +1,562 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sort"
+	"testing"
+	"time"
+)
+
+// testDay is a UTC midnight the test records are placed around.
+var testDay = time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+
+// newTestStorage returns an initialized storage in a temporary directory.
+func newTestStorage(t *testing.T) *SQLiteStorage {
+	t.Helper()
+	s, err := NewSQLiteStorage(filepath.Join(t.TempDir(), "usgmon.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	if err := s.Initialize(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// testRecord is a usage record a test stores, in a scan of its own unless
+// scan names a shared one.
+type testRecord struct {
+	scan string
+	host string
+	dir  string
+	at   time.Duration // after testDay
+	size int64
+}
+
+// storeRecords stores records under base path /b, creating a completed scan
+// for each distinct scan name (or record) started at its first record's time,
+// and returns the scan IDs by name.
+func storeRecords(t *testing.T, s *SQLiteStorage, records []testRecord) map[string]string {
+	t.Helper()
+	ctx := context.Background()
+	ids := make(map[string]string)
+	for i, r := range records {
+		name := r.scan
+		if name == "" {
+			name = fmt.Sprint(i)
+		}
+		id, ok := ids[name]
+		if !ok {
+			var err error
+			id, err = s.StartScan(ctx, "/b", StartScanOptions{Hostname: r.host, StartedAt: testDay.Add(r.at)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := s.CompleteScan(ctx, id, 1); err != nil {
+				t.Fatal(err)
+			}
+			ids[name] = id
+		}
+		err := s.RecordUsageBatch(ctx, []UsageRecord{{
+			BasePath:   "/b",
+			Directory:  r.dir,
+			SizeBytes:  r.size,
+			RecordedAt: testDay.Add(r.at),
+			ScanID:     id,
+			Hostname:   r.host,
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return ids
+}
+
+// rawRecords lists the raw usage records left, as "host dir size".
+func rawRecords(t *testing.T, s *SQLiteStorage) []string {
+	t.Helper()
+	rows, err := s.db.Query(`SELECT hostname, directory, size_bytes FROM usage_records`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var host, dir string
+		var size int64
+		if err := rows.Scan(&host, &dir, &size); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, fmt.Sprintf("%s %s %d", host, dir, size))
+	}
+	sort.Strings(got)
+	return got
+}
+
+func TestRollupUsageBefore(t *testing.T) {
+	const day = 24 * time.Hour
+	tests := []struct {
+		name        string
+		records     []testRecord
+		wantRollups []string // "host dir samples min max avg"
+		wantRaw     []string
+	}{
+		{
+			name: "superseded records of a day are rolled up",
+			records: []testRecord{
+				{dir: "/b/x", at: 10 * time.Hour, size: 100},
+				{dir: "/b/x", at: 14 * time.Hour, size: 300},
+				{dir: "/b/x", at: 2*day + time.Hour, size: 500},
+			},
+			wantRollups: []string{" /b/x 2 100 300 200"},
+			wantRaw:     []string{" /b/x 500"},
+		},
+		{
+			name: "a directory's latest record stays raw",
+			records: []testRecord{
+				{dir: "/b/x", at: 10 * time.Hour, size: 100},
+				{dir: "/b/y", at: 11 * time.Hour, size: 700},
+				{dir: "/b/x", at: 2*day + time.Hour, size: 500},
+			},
+			wantRollups: []string{" /b/x 1 100 100 100"},
+			wantRaw:     []string{" /b/x 500", " /b/y 700"},
+		},
+		{
+			name: "the latest record of a rolled-up day stays raw",
+			records: []testRecord{
+				{dir: "/b/x", at: 10 * time.Hour, size: 100},
+				{dir: "/b/x", at: 14 * time.Hour, size: 300},
+			},
+			wantRollups: []string{" /b/x 1 100 100 100"},
+			wantRaw:     []string{" /b/x 300"},
+		},
+		{
+			name: "hosts are rolled up separately",
+			records: []testRecord{
+				{host: "h1", dir: "/b/x", at: 10 * time.Hour, size: 100},
+				{host: "h2", dir: "/b/x", at: 11 * time.Hour, size: 900},
+				{host: "h1", dir: "/b/x", at: 2 * day, size: 150},
+				{host: "h2", dir: "/b/x", at: 2 * day, size: 950},
+			},
+			wantRollups: []string{"h1 /b/x 1 100 100 100", "h2 /b/x 1 900 900 900"},
+			wantRaw:     []string{"h1 /b/x 150", "h2 /b/x 950"},
+		},
+		{
+			name: "records after the cutoff stay raw",
+			records: []testRecord{
+				{dir: "/b/x", at: 3*day + time.Hour, size: 100},
+				{dir: "/b/x", at: 3*day + 2*time.Hour, size: 200},
+			},
+			wantRaw: []string{" /b/x 100", " /b/x 200"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStorage(t)
+			ctx := context.Background()
+			storeRecords(t, s, tt.records)
+
+			cutoff := testDay.Add(3*day + time.Hour)
+			want, err := s.CountUsageToRollUp(ctx, "", cutoff)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, replaced, err := s.RollupUsageBefore(ctx, "", cutoff)
+			if err != nil {
+				t.Fatalf("RollupUsageBefore: %v", err)
+			}
+			if replaced != want {
+				t.Errorf("replaced %d records, CountUsageToRollUp said %d", replaced, want)
+			}
+
+			rows, err := s.db.Query(`SELECT hostname, directory, samples, min_bytes, max_bytes, avg_bytes FROM usage_rollups ORDER BY hostname, directory, day`)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var rollups []string
+			for rows.Next() {
+				var host, dir string
+				var samples, lo, hi, avg int64
+				if err := rows.Scan(&host, &dir, &samples, &lo, &hi, &avg); err != nil {
+					t.Fatal(err)
+				}
+				rollups = append(rollups, fmt.Sprintf("%s %s %d %d %d %d", host, dir, samples, lo, hi, avg))
+			}
+			rows.Close()
+			if !slices.Equal(rollups, tt.wantRollups) {
+				t.Errorf("rollups = %q, want %q", rollups, tt.wantRollups)
+			}
+			if got := rawRecords(t, s); !slices.Equal(got, tt.wantRaw) {
+				t.Errorf("raw records = %q, want %q", got, tt.wantRaw)
+			}
+
+			// Every directory still has its current value
+			snapshot, err := s.GetSnapshotAt(ctx, "/b", "", testDay.Add(10*day))
+			if err != nil {
+				t.Fatal(err)
+			}
+			dirs := make(map[string]bool)
+			for _, r := range tt.records {
+				dirs[r.host+" "+r.dir] = true
+			}
+			if len(snapshot) != len(dirs) {
+				t.Errorf("snapshot has %d directories, want %d", len(snapshot), len(dirs))
+			}
+		})
+	}
+}
+
+func TestRecordUsageStoresUTC(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+	ids := storeRecords(t, s, []testRecord{{scan: "s", dir: "/b/x", at: 12 * time.Hour, size: 100}})
+
+	// An hour later than the first record, but earlier as local clock text
+	later := testDay.Add(13 * time.Hour).In(time.FixedZone("EST", -5*60*60))
+	err := s.RecordUsageBatch(ctx, []UsageRecord{{
+		BasePath:   "/b",
+		Directory:  "/b/x",
+		SizeBytes:  200,
+		RecordedAt: later,
+		ScanID:     ids["s"],
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := s.GetLatestUsage(ctx, "/b/x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == nil || got.SizeBytes != 200 || !got.RecordedAt.Equal(later) {
+		t.Errorf("GetLatestUsage = %+v, want the record of size 200 at %v", got, later)
+	}
+}
+
+func TestDirCacheKeyedByHostAndBasePath(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+	entry := func(size int64) []DirCacheEntry {
+		return []DirCacheEntry{{Directory: "/b/x", Dev: 1, Ino: 2, ModTime: testDay, SizeBytes: size}}
+	}
+	for _, save := range []struct {
+		base, host string
+		size       int64
+	}{
+		{"/b", "h1", 1},
+		{"/b", "h2", 2},
+		{"/b/x", "h1", 3},
+	} {
+		if err := s.SaveDirCache(ctx, save.base, save.host, entry(save.size), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// One host's base path forgetting the directory leaves the others' entries
+	if err := s.SaveDirCache(ctx, "/b", "h1", nil, []string{"/b/x"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		base, host string
+		want       []int64
+	}{
+		{"/b", "h1", nil},
+		{"/b", "h2", []int64{2}},
+		{"/b/x", "h1", []int64{3}},
+		{"/b/x", "h2", nil},
+	}
+	for _, tt := range tests {
+		entries, err := s.LoadDirCache(ctx, tt.base, tt.host)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []int64
+		for _, e := range entries {
+			got = append(got, e.SizeBytes)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("LoadDirCache(%q, %q) sizes = %v, want %v", tt.base, tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestMigrateKeysDirCacheByPath(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	// The table as it was before entries were keyed by host and base path
+	for _, stmt := range []string{
+		`DROP TABLE dir_cache`,
+		`CREATE TABLE dir_cache (
+			directory TEXT PRIMARY KEY,
+			dev INTEGER NOT NULL,
+			ino INTEGER NOT NULL,
+			mtime_ns INTEGER NOT NULL,
+			size_bytes INTEGER NOT NULL,
+			allocated_bytes INTEGER NOT NULL
+		)`,
+		`INSERT INTO dir_cache VALUES ('/b/x', 1, 2, 3, 4, 5)`,
+	} {
+		if _, err := s.db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := s.Initialize(ctx); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	if err := s.SaveDirCache(ctx, "/b", "h", []DirCacheEntry{{Directory: "/b/x", SizeBytes: 7}}, nil); err != nil {
+		t.Fatalf("SaveDirCache after migrating: %v", err)
+	}
+	var rows int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM dir_cache`).Scan(&rows); err != nil {
+		t.Fatal(err)
+	}
+	if rows != 1 {
+		t.Errorf("dir_cache has %d rows after migrating, want only the new entry", rows)
+	}
+}
+
+// countRows returns the number of rows in table.
+func countRows(t *testing.T, s *SQLiteStorage, table string) int64 {
+	t.Helper()
+	var n int64
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM ` + table).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestPruneScansKeepingLatest(t *testing.T) {
+	const day = 24 * time.Hour
+	tests := []struct {
+		name        string
+		records     []testRecord
+		keep        int
+		wantScans   int64 // deleted
+		wantRecords int64 // deleted
+		wantRaw     []string
+	}{
+		{
+			name: "older scans go with their superseded records",
+			records: []testRecord{
+				{scan: "s1", dir: "/b/x", at: 0, size: 100},
+				{scan: "s2", dir: "/b/x", at: day, size: 200},
+				{scan: "s3", dir: "/b/x", at: 2 * day, size: 300},
+			},
+			keep:        1,
+			wantScans:   2,
+			wantRecords: 2,
+			wantRaw:     []string{" /b/x 300"},
+		},
+		{
+			name: "keeping two scans",
+			records: []testRecord{
+				{scan: "s1", dir: "/b/x", at: 0, size: 100},
+				{scan: "s2", dir: "/b/x", at: day, size: 200},
+				{scan: "s3", dir: "/b/x", at: 2 * day, size: 300},
+			},
+			keep:        2,
+			wantScans:   1,
+			wantRecords: 1,
+			wantRaw:     []string{" /b/x 200", " /b/x 300"},
+		},
+		{
+			name: "a scan holding a directory's latest record stays",
+			records: []testRecord{
+				{scan: "s1", dir: "/b/x", at: 0, size: 100},
+				{scan: "s1", dir: "/b/y", at: 0, size: 700},
+				{scan: "s2", dir: "/b/x", at: day, size: 500},
+			},
+			keep:        1,
+			wantScans:   0,
+			wantRecords: 1,
+			wantRaw:     []string{" /b/x 500", " /b/y 700"},
+		},
+		{
+			name: "hosts keep their own latest scans",
+			records: []testRecord{
+				{scan: "s1", host: "h1", dir: "/b/x", at: 0, size: 100},
+				{scan: "s2", host: "h1", dir: "/b/x", at: day, size: 150},
+				{scan: "s3", host: "h1", dir: "/b/x", at: 2 * day, size: 200},
+				{scan: "s4", host: "h2", dir: "/b/x", at: 0, size: 900},
+				{scan: "s5", host: "h2", dir: "/b/x", at: day, size: 950},
+			},
+			keep:        2,
+			wantScans:   1,
+			wantRecords: 1,
+			wantRaw:     []string{"h1 /b/x 150", "h1 /b/x 200", "h2 /b/x 900", "h2 /b/x 950"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStorage(t)
+			ctx := context.Background()
+			storeRecords(t, s, tt.records)
+			scansBefore := countRows(t, s, "scans")
+
+			countScans, countRecords, err := s.CountScansKeepingLatest(ctx, "", tt.keep)
+			if err != nil {
+				t.Fatal(err)
+			}
+			scans, records, err := s.PruneScansKeepingLatest(ctx, "", tt.keep)
+			if err != nil {
+				t.Fatalf("PruneScansKeepingLatest: %v", err)
+			}
+			if scans != tt.wantScans || records != tt.wantRecords {
+				t.Errorf("pruned %d scans and %d records, want %d and %d", scans, records, tt.wantScans, tt.wantRecords)
+			}
+			if countScans != scans || countRecords != records {
+				t.Errorf("CountScansKeepingLatest = %d, %d, but %d scans and %d records were pruned", countScans, countRecords, scans, records)
+			}
+			if got := countRows(t, s, "scans"); got != scansBefore-tt.wantScans {
+				t.Errorf("%d scans left, want %d", got, scansBefore-tt.wantScans)
+			}
+			if got := rawRecords(t, s); !slices.Equal(got, tt.wantRaw) {
+				t.Errorf("records left = %q, want %q", got, tt.wantRaw)
+			}
+		})
+	}
+}
+
+func TestPruneScansBefore(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+	storeRecords(t, s, []testRecord{
+		{scan: "s1", dir: "/b/x", at: 0, size: 100},
+		{scan: "s1", dir: "/b/y", at: 0, size: 700},
+		{scan: "s2", dir: "/b/x", at: 48 * time.Hour, size: 500},
+	})
+
+	// An age cutoff removes old scans whole, latest records included
+	scans, records, err := s.PruneScansBefore(ctx, testDay.Add(24*time.Hour))
+	if err != nil {
+		t.Fatalf("PruneScansBefore: %v", err)
+	}
+	if scans != 1 || records != 2 {
+		t.Errorf("pruned %d scans and %d records, want 1 and 2", scans, records)
+	}
+	if got, want := rawRecords(t, s), []string{" /b/x 500"}; !slices.Equal(got, want) {
+		t.Errorf("records left = %q, want %q", got, want)
+	}
+}
+
+func TestRecordIngestedUsageRetry(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+	scanID, err := s.StartScan(ctx, "/b", StartScanOptions{ScanID: "agent-scan", Hostname: "h", StartedAt: testDay})
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch := func(sizes ...int64) []UsageRecord {
+		var records []UsageRecord
+		for _, size := range sizes {
+			records = append(records, UsageRecord{
+				BasePath:   "/b",
+				Directory:  fmt.Sprintf("/b/d%d", size),
+				SizeBytes:  size,
+				RecordedAt: testDay,
+				ScanID:     scanID,
+				Hostname:   "h",
+			})
+		}
+		return records
+	}
+
+	steps := []struct {
+		batch      int
+		records    []UsageRecord
+		wantStored bool
+		wantRows   int64
+	}{
+		{1, batch(1, 2), true, 2},
+		{1, batch(1, 2), false, 2}, // retried after the server stored it
+		{2, batch(3), true, 3},
+		{1, batch(1, 2), false, 3},
+		{2, batch(3), false, 3},
+	}
+	for i, step := range steps {
+		stored, err := s.RecordIngestedUsage(ctx, scanID, step.batch, step.records)
+		if err != nil {
+			t.Fatalf("step %d: RecordIngestedUsage: %v", i, err)
+		}
+		if stored != step.wantStored {
+			t.Errorf("step %d: RecordIngestedUsage(batch %d) stored = %v, want %v", i, step.batch, stored, step.wantStored)
+		}
+		if got := countRows(t, s, "usage_records"); got != step.wantRows {
+			t.Errorf("step %d: %d usage records, want %d", i, got, step.wantRows)
+		}
+	}
+}
+
+// userVersion returns the schema version recorded in the database.
+func userVersion(t *testing.T, s *SQLiteStorage) int {
+	t.Helper()
+	var v int
+	if err := s.db.QueryRow(`PRAGMA user_version`).Scan(&v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestMigrateUserVersion(t *testing.T) {
+	ctx := context.Background()
+	current := userVersion(t, newTestStorage(t))
+	if current == 0 {
+		t.Fatal("a new database has user_version 0")
+	}
+
+	// A database from before hosts were recorded, with a rollup to carry over
+	s := newTestStorage(t)
+	for _, stmt := range []string{
+		`DROP TABLE usage_rollups`,
+		`CREATE TABLE usage_rollups (
+			base_path TEXT NOT NULL,
+			directory TEXT NOT NULL,
+			day DATETIME NOT NULL,
+			samples INTEGER NOT NULL,
+			min_bytes INTEGER NOT NULL,
+			max_bytes INTEGER NOT NULL,
+			avg_bytes INTEGER NOT NULL,
+			owner TEXT NOT NULL DEFAULT '',
+			owner_group TEXT NOT NULL DEFAULT '',
+			PRIMARY KEY (base_path, directory, day)
+		)`,
+		`INSERT INTO usage_rollups (base_path, directory, day, samples, min_bytes, max_bytes, avg_bytes)
+		 VALUES ('/b', '/b/x', '2026-03-10 00:00:00 +0000 UTC', 2, 1, 3, 2)`,
+		`PRAGMA user_version = 0`,
+	} {
+		if _, err := s.db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := s.Initialize(ctx); err != nil {
+			t.Fatalf("Initialize #%d: %v", i+1, err)
+		}
+		if got := userVersion(t, s); got != current {
+			t.Errorf("user_version after Initialize #%d = %d, want %d", i+1, got, current)
+		}
+	}
+
+	var keyed bool
+	if err := s.db.QueryRow(
+		`SELECT COUNT(*) > 0 FROM pragma_table_info('usage_rollups') WHERE name = 'hostname' AND pk > 0`,
+	).Scan(&keyed); err != nil {
+		t.Fatal(err)
+	}
+	if !keyed {
+		t.Error("usage_rollups is not keyed by hostname after migrating")
+	}
+	if got := countRows(t, s, "usage_rollups"); got != 1 {
+		t.Errorf("%d rollups after migrating, want 1", got)
+	}
+}
@@ -0,0 +1,541 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// UsageRecord represents a single disk usage measurement.
+type UsageRecord struct {
+	ID             int64
+	BasePath       string
+	Directory      string
+	SizeBytes      int64
+	RecordedAt     time.Time
+	ScanID         string
+	FileFilter     string  // comma-separated file globs excluded from the measurement, if any
+	SymlinkCount   *int64  // nil when the scan strategy did not count symlinks
+	Fingerprint    string  // change fingerprint of the directory tree, if computed
+	AllocatedBytes *int64  // disk space allocated (blocks), nil unless recorded; SizeBytes is apparent unless recorded in the allocated size mode
+	Owner          string  // directory's owning user name (or numeric UID), if recorded
+	Group          string  // directory's owning group name (or numeric GID), if recorded
+	QuotaLimit     *int64  // filesystem-enforced byte quota, nil if none was reported
+	Deleted        bool    // marker recorded when the directory disappeared; SizeBytes is 0
+	FileCount      *int64  // files (non-directory entries) in the tree, nil unless recorded
+	DirCount       *int64  // subdirectories in the tree, nil unless recorded
+	Hostname       string  // host that made the measurement; empty in records from before hosts were recorded
+	Rollup         *Rollup // set for a daily rollup of older records; nil for raw records
+
+	// OwnerUsage splits SizeBytes by the user owning each file, largest
+	// first; nil unless the scan measured it. QueryUsage only loads it with
+	// QueryOptions.ByOwner.
+	OwnerUsage []OwnerUsage
+
+	// LargeFiles holds the directory's largest files, largest first; nil
+	// unless the scan captured them. QueryUsage only loads them with
+	// QueryOptions.LargeFiles.
+	LargeFiles []LargeFile
+
+	// AgeUsage splits SizeBytes by file age, youngest first; nil unless the
+	// scan measured it. QueryUsage only loads it with QueryOptions.ByAge.
+	AgeUsage []AgeUsage
+}
+
+// AgeUsage is the part of a directory's size held by files of one age range.
+type AgeUsage struct {
+	Basis     string `json:"basis"`        // file time the ages were taken from: mtime or atime
+	MinDays   int    `json:"min_age_days"` // inclusive
+	MaxDays   int    `json:"max_age_days"` // exclusive; 0 for no upper bound
+	SizeBytes int64  `json:"size_bytes"`
+}
+
+// Label describes the age range, as "<30d", "30-180d" or ">=180d".
+func (a AgeUsage) Label() string {
+	switch {
+	case a.MaxDays == 0:
+		return fmt.Sprintf(">=%dd", a.MinDays)
+	case a.MinDays == 0:
+		return fmt.Sprintf("<%dd", a.MaxDays)
+	default:
+		return fmt.Sprintf("%d-%dd", a.MinDays, a.MaxDays)
+	}
+}
+
+// NewAgeUsage converts the sizes of age buckets bounded by days, youngest
+// first, to AgeUsage taken from the basis file time. It returns nil unless
+// there is one size more than bounds.
+func NewAgeUsage(basis string, days []int, sizes []int64) []AgeUsage {
+	if len(sizes) == 0 || len(sizes) != len(days)+1 {
+		return nil
+	}
+	usage := make([]AgeUsage, len(sizes))
+	for i, size := range sizes {
+		usage[i] = AgeUsage{Basis: basis, SizeBytes: size}
+		if i > 0 {
+			usage[i].MinDays = days[i-1]
+		}
+		if i < len(days) {
+			usage[i].MaxDays = days[i]
+		}
+	}
+	return usage
+}
+
+// LargeFile is one of the largest files found under a measured directory.
+type LargeFile struct {
+	Path      string    `json:"path"`
+	SizeBytes int64     `json:"size_bytes"`
+	ModTime   time.Time `json:"mtime"`
+}
+
+// OwnerUsage is the part of a directory's size held by files of one owner.
+type OwnerUsage struct {
+	UID       uint32 `json:"uid"`
+	Owner     string `json:"owner"` // user name at scan time, or the UID if it had none
+	SizeBytes int64  `json:"size_bytes"`
+}
+
+// NewOwnerUsage converts sizes keyed by UID to OwnerUsage, largest first,
+// naming each owner with name. It returns nil for no sizes.
+func NewOwnerUsage(sizes map[uint32]int64, name func(uid uint32) string) []OwnerUsage {
+	if len(sizes) == 0 {
+		return nil
+	}
+	usage := make([]OwnerUsage, 0, len(sizes))
+	for uid, size := range sizes {
+		usage = append(usage, OwnerUsage{UID: uid, Owner: name(uid), SizeBytes: size})
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		if usage[i].SizeBytes != usage[j].SizeBytes {
+			return usage[i].SizeBytes > usage[j].SizeBytes
+		}
+		return usage[i].UID < usage[j].UID
+	})
+	return usage
+}
+
+// Rollup summarizes the raw records of one directory over one UTC day that
+// RollupUsageBefore replaced. The UsageRecord carrying it is timestamped at
+// the start of the day, has no scan, and reports the day's average size.
+type Rollup struct {
+	Samples  int
+	MinBytes int64
+	MaxBytes int64
+}
+
+// Scan represents a scan operation.
+type Scan struct {
+	ScanID             string
+	BasePath           string
+	StartedAt          time.Time
+	CompletedAt        *time.Time
+	DirectoriesScanned int
+	Status             string
+	Note               string
+	Trigger            string
+	CPUUser            *time.Duration // nil unless recorded by the daemon
+	CPUSystem          *time.Duration // nil unless recorded by the daemon
+	Metadata           *ScanMetadata  // nil unless scan.record_metadata was set
+	Hostname           string         // host that ran the scan; empty in scans from before hosts were recorded
+}
+
+// Scan triggers record what initiated a scan.
+const (
+	TriggerScheduled = "scheduled" // daemon interval tick
+	TriggerStartup   = "startup"   // daemon's initial scan of each path
+	TriggerOnce      = "once"      // serve --once (cron/timer runs)
+	TriggerManual    = "manual"    // scan --store
+	TriggerAPI       = "api"       // POST /api/v1/scans
+	TriggerScanNow   = "scan-now"  // usgmon scan-now, over the control socket
+	TriggerGRPC      = "grpc"      // usgmon.v1 TriggerScan RPC
+	TriggerWatch     = "watch"     // daemon watch mode sample (paths[].watch)
+)
+
+// StartScanOptions holds optional metadata recorded when a scan starts.
+type StartScanOptions struct {
+	Note     string        // free-text annotation, e.g. "before archiving 2024 data"
+	Trigger  string        // what initiated the scan, e.g. TriggerScheduled
+	Metadata *ScanMetadata // environment and options to record; nil for none
+	Hostname string        // host running the scan
+
+	// Set for scans pushed by agents, which choose their own IDs and start
+	// times. Starting a scan whose ID already exists is then a no-op, so a
+	// push can be retried.
+	ScanID    string
+	StartedAt time.Time
+}
+
+// ScanListOptions specifies filters for listing scans.
+type ScanListOptions struct {
+	BasePath string
+	Trigger  string // only scans with this trigger, if set
+	ScanID   string // only the scan with this ID, if set
+	Hostname string // only scans made by this host, if set
+	Limit    int
+}
+
+// QueryOptions specifies filters for querying usage records.
+type QueryOptions struct {
+	Directory  string
+	BasePath   string
+	Since      *time.Time
+	Until      *time.Time
+	Owner      string // only records whose directory is owned by this user
+	Hostname   string // only records made by this host
+	ByOwner    bool   // only records with a per-owner breakdown, loaded into OwnerUsage
+	LargeFiles bool   // only records with captured large files, loaded into LargeFiles
+	ByAge      bool   // only records with an age breakdown, loaded into AgeUsage
+	Match      string // only directories whose name (or path, if it has a slash) matches this GLOB pattern
+	MatchRegex string // only directories whose path matches this Go regular expression, unanchored
+	Limit      int
+	Offset     int          // records to skip before Limit applies
+	After      *UsageCursor // only records after this one in newest-first order, for the next page
+}
+
+// Aggregations of the records in a time bucket, for BucketOptions.Agg.
+const (
+	AggMin  = "min"  // smallest size
+	AggMax  = "max"  // largest size
+	AggAvg  = "avg"  // mean size
+	AggLast = "last" // size of the newest record
+)
+
+// Aggregations lists the valid BucketOptions.Agg values.
+var Aggregations = []string{AggMin, AggMax, AggAvg, AggLast}
+
+// BucketOptions specifies an aggregation of usage records into time buckets.
+// Deletion markers and records taken with a file filter are left out.
+type BucketOptions struct {
+	Directory  string
+	BasePath   string
+	Since      *time.Time
+	Until      *time.Time
+	Hostname   string        // only records made by this host
+	Match      string        // only directories whose name (or path, if it has a slash) matches this GLOB pattern
+	MatchRegex string        // only directories whose path matches this Go regular expression, unanchored
+	Bucket     time.Duration // width of each bucket, whole seconds; aligned to UTC midnight, and weeks to Mondays
+	Agg        string        // one of Aggregations
+	Limit      int
+}
+
+// UsageBucket is a directory's aggregated size over one time bucket. Daily
+// rollups contribute the minimum, maximum and samples they summarize.
+type UsageBucket struct {
+	Directory string
+	Start     time.Time
+	SizeBytes int64
+	Samples   int // records aggregated, counting those a rollup replaced
+}
+
+// TopChangerOptions specifies parameters for finding top changers.
+type TopChangerOptions struct {
+	BasePath  string
+	Since     time.Time
+	Until     time.Time
+	Direction string // "increase", "decrease", "both"
+	// A directory is listed if its change reaches either threshold that is
+	// set; with neither set, every directory is. A directory that grew from
+	// nothing passes any percentage.
+	MinChangeBytes   int64
+	MinChangePercent float64
+	Owner            string // only directories currently owned by this user
+	Hostname         string // only records made by this host
+	Match            string // only directories whose name (or path, if it has a slash) matches this GLOB pattern
+	MatchRegex       string // only directories whose path matches this Go regular expression, unanchored
+	IncludeNew       bool   // also list directories new since the first scan in range (see GetNewAndDeleted)
+	IncludeDeleted   bool   // also list directories gone by the last scan in range
+	Limit            int
+}
+
+// DirectoryChange represents a directory's usage change over time.
+type DirectoryChange struct {
+	Directory     string
+	BasePath      string
+	StartSize     int64
+	EndSize       int64
+	StartTime     time.Time
+	EndTime       time.Time
+	ChangeBytes   int64
+	ChangePercent float64
+	Owner         string // owner as of the end of the interval, if recorded
+	Group         string
+	Hostname      string // host that measured the directory
+	Status        string // ChangeNew or ChangeDeleted, or empty for a directory in both scans
+}
+
+// Statuses of a DirectoryChange found by GetNewAndDeleted.
+const (
+	ChangeNew     = "new"
+	ChangeDeleted = "deleted"
+)
+
+// Trend categories assigned by GetTrends.
+const (
+	TrendGrowing   = "growing"
+	TrendShrinking = "shrinking"
+	TrendFlat      = "flat"
+	TrendVolatile  = "volatile"
+	TrendSpiked    = "spiked"
+	TrendUnknown   = "unknown" // too few records to classify
+)
+
+// TrendOptions specifies parameters for classifying directory trends.
+type TrendOptions struct {
+	BasePath string
+	Since    time.Time
+	Until    time.Time
+}
+
+// DirectoryTrend classifies a directory's usage history over a time range.
+type DirectoryTrend struct {
+	Directory   string
+	BasePath    string
+	Trend       string
+	Samples     int
+	StartSize   int64
+	EndSize     int64
+	StartTime   time.Time
+	EndTime     time.Time
+	BytesPerDay float64 // least-squares slope of size over time
+}
+
+// DBInfo summarizes the state of the database.
+type DBInfo struct {
+	SQLiteVersion  string
+	SchemaVersion  int
+	UsageRecords   int64
+	Scans          int64
+	Rollups        int64
+	EarliestRecord *time.Time // nil when there are no usage records
+	LatestRecord   *time.Time
+	BasePaths      []BasePathCount
+}
+
+// BasePathCount is the number of usage records stored for a base path.
+type BasePathCount struct {
+	BasePath string
+	Records  int64
+}
+
+// IntegrityProblem is a single finding from CheckIntegrity.
+type IntegrityProblem struct {
+	Check  string // name of the check that found the problem
+	Detail string
+}
+
+// SkipEntry represents a directory on the persistent scan-skip list.
+// Entries are either learned after repeated scan errors or added manually.
+type SkipEntry struct {
+	Directory         string
+	ConsecutiveErrors int
+	LastError         string
+	Skipped           bool
+	Manual            bool
+	UpdatedAt         time.Time
+}
+
+// AlertState records a directory that is currently over its alert threshold,
+// so alerts fire on transitions rather than on every scan.
+type AlertState struct {
+	Directory      string
+	BasePath       string
+	ThresholdBytes int64
+	SizeBytes      int64     // size at the most recent notification
+	Since          time.Time // when the directory went over the threshold
+	LastNotified   time.Time
+}
+
+// DirCacheEntry is the walk strategy's memory of one directory, used to skip
+// reading the files of directories whose mtime has not changed.
+type DirCacheEntry struct {
+	Directory      string
+	Dev            uint64
+	Ino            uint64
+	ModTime        time.Time
+	SizeBytes      int64 // apparent size of the files directly in the directory
+	AllocatedBytes int64 // disk blocks allocated to those files
+}
+
+// FilesystemStats is the capacity of the filesystem holding a base path,
+// recorded when a scan of the path completes.
+type FilesystemStats struct {
+	BasePath       string
+	RecordedAt     time.Time
+	ScanID         string
+	Hostname       string // host that made the measurement
+	TotalBytes     int64
+	UsedBytes      int64
+	AvailableBytes int64 // free space available to unprivileged users
+}
+
+// Storage defines the interface for persisting usage data.
+type Storage interface {
+	// Initialize prepares the storage (creates tables, etc.).
+	Initialize(ctx context.Context) error
+
+	// Close releases any resources held by the storage.
+	Close() error
+
+	// StartScan creates a new scan record and returns its ID.
+	StartScan(ctx context.Context, basePath string, opts StartScanOptions) (string, error)
+
+	// CompleteScan marks a scan as completed.
+	CompleteScan(ctx context.Context, scanID string, directoriesScanned int) error
+
+	// RecordScanCPU stores the user and system CPU time a scan consumed.
+	RecordScanCPU(ctx context.Context, scanID string, user, system time.Duration) error
+
+	// FailScan marks a scan as failed.
+	FailScan(ctx context.Context, scanID string, reason string) error
+
+	// SkipScan marks a scan as skipped: it never ran, e.g. because a
+	// previous scan of the path was still running.
+	SkipScan(ctx context.Context, scanID string, reason string) error
+
+	// FailStaleScans marks the scans made on hostname that are still
+	// recorded as running or interrupted as failed with reason, for use at
+	// startup when they can only be left over from a process that exited
+	// mid-scan. With deleteRecords, their usage records are deleted too. It
+	// returns the IDs of the scans and the number of records deleted.
+	FailStaleScans(ctx context.Context, hostname, reason string, deleteRecords bool) ([]string, int64, error)
+
+	// InterruptScan marks a running scan as interrupted: it stopped before
+	// finishing and may be resumed with ResumeScan.
+	InterruptScan(ctx context.Context, scanID string) error
+
+	// InterruptStaleScans marks the scans made on hostname that are still
+	// recorded as running as interrupted, as FailStaleScans fails them, so
+	// they can be resumed. It returns their IDs.
+	InterruptStaleScans(ctx context.Context, hostname string) ([]string, error)
+
+	// RecordScanProgress records directories a scan has finished measuring
+	// without storing a usage record for them, e.g. because they had not
+	// changed, so a resumed scan does not measure them again.
+	RecordScanProgress(ctx context.Context, scanID string, directories []string) error
+
+	// ResumeScan marks an interrupted scan as running again and returns the
+	// directories it had finished: those with a usage record in the scan
+	// and those recorded with RecordScanProgress.
+	ResumeScan(ctx context.Context, scanID string) ([]string, error)
+
+	// ListScans retrieves scan records, most recent first.
+	ListScans(ctx context.Context, opts ScanListOptions) ([]Scan, error)
+
+	// RecordUsage stores a usage measurement.
+	RecordUsage(ctx context.Context, record UsageRecord) error
+
+	// RecordUsageBatch stores multiple usage measurements efficiently.
+	RecordUsageBatch(ctx context.Context, records []UsageRecord) error
+
+	// RecordIngestedUsage stores a numbered batch of usage measurements
+	// pushed for a scan, unless a batch with the same number was already
+	// stored for it, so a retried push is stored once. It reports whether
+	// the batch was stored.
+	RecordIngestedUsage(ctx context.Context, scanID string, batch int, records []UsageRecord) (bool, error)
+
+	// QueryUsage retrieves usage records matching the given options.
+	QueryUsage(ctx context.Context, opts QueryOptions) ([]UsageRecord, error)
+
+	// QueryBuckets aggregates usage records into one value per time bucket
+	// per directory, newest bucket first.
+	QueryBuckets(ctx context.Context, opts BucketOptions) ([]UsageBucket, error)
+
+	// GetLatestUsage retrieves the most recent usage record for a directory.
+	GetLatestUsage(ctx context.Context, directory string) (*UsageRecord, error)
+
+	// GetTopChangers finds directories with the largest usage changes over a time interval.
+	GetTopChangers(ctx context.Context, opts TopChangerOptions) ([]DirectoryChange, error)
+
+	// GetNewAndDeleted compares the first and last completed scans of a base
+	// path in a time range, per host, and lists the directories only one of
+	// them has, as a change from or to nothing.
+	GetNewAndDeleted(ctx context.Context, opts TopChangerOptions) ([]DirectoryChange, error)
+
+	// GetTrends classifies each directory under a base path as growing,
+	// shrinking, flat, volatile, or spiked based on its records in a time range.
+	GetTrends(ctx context.Context, opts TrendOptions) ([]DirectoryTrend, error)
+
+	// GetSnapshotAt reconstructs each directory's size under basePath as of t,
+	// using the most recent record at or before t per host and directory,
+	// for one host or, if hostname is empty, all of them.
+	GetSnapshotAt(ctx context.Context, basePath, hostname string, t time.Time) ([]UsageRecord, error)
+
+	// CountUsageToRollUp counts the records RollupUsageBefore would replace.
+	CountUsageToRollUp(ctx context.Context, basePath string, cutoff time.Time) (int64, error)
+
+	// RollupUsageBefore replaces unfiltered usage records from whole UTC days
+	// before cutoff with one daily rollup per host and directory, for one base path or
+	// all of them if basePath is empty. It returns the number of directory-days
+	// rolled up and of records removed.
+	RollupUsageBefore(ctx context.Context, basePath string, cutoff time.Time) (days int64, records int64, err error)
+
+	// CountScansBefore counts finished scans started before cutoff and their usage records.
+	CountScansBefore(ctx context.Context, cutoff time.Time) (scans int64, records int64, err error)
+
+	// PruneScansBefore deletes finished scans started before cutoff along with their records.
+	PruneScansBefore(ctx context.Context, cutoff time.Time) (scans int64, records int64, err error)
+
+	// CountScansKeepingLatest counts the scans and usage records PruneScansKeepingLatest
+	// would delete.
+	CountScansKeepingLatest(ctx context.Context, basePath string, keepN int) (scans int64, records int64, err error)
+
+	// PruneScansKeepingLatest deletes finished scans from before the newest keepN completed
+	// ones per base path and host (or for one base path if basePath is non-empty) along with
+	// their records. Records that are still their directory's latest value are kept, and
+	// with them the scans that hold them; skipped and failed scans do not count towards keepN.
+	PruneScansKeepingLatest(ctx context.Context, basePath string, keepN int) (scans int64, records int64, err error)
+
+	// Info returns database metadata and aggregate counts.
+	Info(ctx context.Context) (*DBInfo, error)
+
+	// CheckIntegrity verifies the database file and the consistency of its
+	// contents, returning any problems found. An empty result means the
+	// database passed every check.
+	CheckIntegrity(ctx context.Context) ([]IntegrityProblem, error)
+
+	// ListSkipEntries returns tracked skip-list entries for directories under prefix.
+	// An empty prefix returns all entries.
+	ListSkipEntries(ctx context.Context, prefix string) ([]SkipEntry, error)
+
+	// RecordDirectoryError increments the consecutive error count for a directory,
+	// marking it skipped once the count reaches threshold (0 disables skipping).
+	RecordDirectoryError(ctx context.Context, directory, message string, threshold int) (*SkipEntry, error)
+
+	// ClearDirectoryErrors removes a learned skip-list entry after a successful scan.
+	// Manual entries are left untouched.
+	ClearDirectoryErrors(ctx context.Context, directory string) error
+
+	// AddSkipEntry manually adds a directory to the skip list.
+	AddSkipEntry(ctx context.Context, directory, reason string) error
+
+	// RemoveSkipEntry removes a directory from the skip list.
+	// Returns false if the directory was not on the list.
+	RemoveSkipEntry(ctx context.Context, directory string) (bool, error)
+
+	// ListAlertStates returns directories under basePath that are currently alerting.
+	// An empty basePath returns all of them.
+	ListAlertStates(ctx context.Context, basePath string) ([]AlertState, error)
+
+	// SaveAlertState creates or updates a directory's alert state.
+	SaveAlertState(ctx context.Context, state AlertState) error
+
+	// DeleteAlertState clears a directory's alert state once it has recovered.
+	DeleteAlertState(ctx context.Context, directory string) error
+
+	// LoadDirCache returns the directory cache entries a host saved for
+	// basePath.
+	LoadDirCache(ctx context.Context, basePath, hostname string) ([]DirCacheEntry, error)
+
+	// SaveDirCache creates or replaces the given directory cache entries of a
+	// host's basePath and deletes those of the removed directories.
+	SaveDirCache(ctx context.Context, basePath, hostname string, entries []DirCacheEntry, removed []string) error
+
+	// RecordFilesystemStats stores the capacity of a base path's filesystem.
+	RecordFilesystemStats(ctx context.Context, stats FilesystemStats) error
+
+	// QueryFilesystemStats returns the filesystem capacity recorded for
+	// basePath since the given time, newest first.
+	QueryFilesystemStats(ctx context.Context, basePath string, since time.Time) ([]FilesystemStats, error)
+}
@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"math"
+	"time"
+)
+
+// Heuristics used by classifyTrend. They are deliberately simple: the goal is
+// to separate sustained trajectories from one-off jumps, not to forecast.
+const (
+	// minTrendSamples is the fewest records needed to classify a directory.
+	minTrendSamples = 3
+
+	// trendNoiseFraction is the share of a directory's mean size below which
+	// a change between consecutive records is treated as noise.
+	trendNoiseFraction = 0.01
+
+	// trendSteadyFraction is the share of significant changes that must move
+	// in one direction for a directory to count as growing or shrinking.
+	trendSteadyFraction = 0.8
+
+	// trendSpikeFactor is how many times larger than the typical earlier
+	// change the most recent increase must be to count as a spike.
+	trendSpikeFactor = 3.0
+)
+
+// trendPoint is a single size measurement in a directory's history.
+type trendPoint struct {
+	at   time.Time
+	size int64
+}
+
+// classifyTrend assigns a trend category to a time-ordered series and
+// returns its least-squares growth rate in bytes per day.
+func classifyTrend(points []trendPoint) (string, float64) {
+	rate := trendSlope(points)
+	if len(points) < minTrendSamples {
+		return TrendUnknown, rate
+	}
+
+	var mean float64
+	for _, p := range points {
+		mean += float64(p.size)
+	}
+	mean /= float64(len(points))
+	noise := math.Max(mean*trendNoiseFraction, 1)
+
+	deltas := make([]float64, len(points)-1)
+	var up, down int
+	for i := 1; i < len(points); i++ {
+		d := float64(points[i].size - points[i-1].size)
+		deltas[i-1] = d
+		switch {
+		case d > noise:
+			up++
+		case d < -noise:
+			down++
+		}
+	}
+
+	if up+down == 0 {
+		return TrendFlat, rate
+	}
+
+	// A spike is a significant increase in the latest interval that dwarfs
+	// the changes seen before it.
+	last := deltas[len(deltas)-1]
+	var typical float64
+	for _, d := range deltas[:len(deltas)-1] {
+		typical += math.Abs(d)
+	}
+	typical /= float64(len(deltas) - 1)
+	if last > noise && last > trendSpikeFactor*typical {
+		return TrendSpiked, rate
+	}
+
+	net := float64(points[len(points)-1].size - points[0].size)
+	moves := float64(up + down)
+	switch {
+	case float64(up) >= trendSteadyFraction*moves && net > noise:
+		return TrendGrowing, rate
+	case float64(down) >= trendSteadyFraction*moves && net < -noise:
+		return TrendShrinking, rate
+	default:
+		return TrendVolatile, rate
+	}
+}
+
+// trendSlope fits a least-squares line to the series and returns its slope in
+// bytes per day. It returns 0 for fewer than two points or a zero time span.
+func trendSlope(points []trendPoint) float64 {
+	if len(points) < 2 {
+		return 0
+	}
+
+	origin := points[0].at
+	n := float64(len(points))
+	var sumX, sumY, sumXY, sumXX float64
+	for _, p := range points {
+		x := p.at.Sub(origin).Hours() / 24
+		y := float64(p.size)
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+
+	denom := n*sumXX - sumX*sumX
+	if denom == 0 {
+		return 0
+	}
+	return (n*sumXY - sumX*sumY) / denom
+}